@@ -0,0 +1,680 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"project/models"
+	"project/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Admin Handlers ---
+
+// GetAssessmentFunnelHandler returns the assessment drop-off funnel per questionnaire version and user type.
+// GET /api/admin/analytics/assessment-funnel?version=v1&user_type=guest&from=2024-01-01&to=2024-02-01
+// All query parameters are optional; "to" is exclusive.
+func (h *APIHandler) GetAssessmentFunnelHandler(c *gin.Context) {
+	filter := models.AssessmentEventFilter{
+		QuestionnaireVersion: c.Query("version"),
+		UserType:             c.Query("user_type"),
+	}
+	if filter.UserType != "" && filter.UserType != "guest" && filter.UserType != "registered" {
+		utils.SendJSONError(c, http.StatusBadRequest, "user_type must be 'guest' or 'registered'.", nil)
+		return
+	}
+	if from := c.Query("from"); from != "" {
+		since, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD.", err)
+			return
+		}
+		filter.Since = &since
+	}
+	if to := c.Query("to"); to != "" {
+		until, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD.", err)
+			return
+		}
+		filter.Until = &until
+	}
+
+	if h.analyticsService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("analyticsservice not initialized"))
+		return
+	}
+
+	reports, err := h.analyticsService.GetAssessmentFunnel(filter)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to build the assessment funnel report.", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Assessment funnel report generated successfully",
+		"data":    reports,
+	})
+}
+
+// AdminListPlanTemplatesHandler lists all plan templates, including unpublished ones.
+// GET /api/admin/plan-templates
+func (h *APIHandler) AdminListPlanTemplatesHandler(c *gin.Context) {
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+
+	templates, err := h.planTemplateService.ListTemplates(false)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to list plan templates.", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan templates retrieved successfully",
+		"data":    templates,
+	})
+}
+
+// CreatePlanTemplateHandler adds a template to the plan template library.
+// POST /api/admin/plan-templates
+// Request body: { "title": "string", "description": "string", "goals": ["string"], "duration_days": 28, "published": true,
+// "tasks": [{ "type": "exercise", "title": "string", "description": "string", "frequency": "每日2次", "duration": "string", "progression": "kegel", "level": 1,
+// "exercise_id": 3 }] }
+func (h *APIHandler) CreatePlanTemplateHandler(c *gin.Context) {
+	var input models.PlanTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+
+	template, err := h.planTemplateService.CreateTemplate(input)
+	if err != nil {
+		sendServiceError(c, err, "plan template", "Failed to create plan template.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan template created successfully",
+		"data":    template,
+	})
+}
+
+// UpdatePlanTemplateHandler replaces the content of a plan template. Plans already created from it are not changed.
+// PUT /api/admin/plan-templates/:templateID
+// Request body: same as CreatePlanTemplateHandler; "published" is left unchanged if omitted.
+func (h *APIHandler) UpdatePlanTemplateHandler(c *gin.Context) {
+	templateID, err := parseUint(c.Param("templateID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TemplateID parameter.", err)
+		return
+	}
+	var input models.PlanTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+
+	template, err := h.planTemplateService.UpdateTemplate(templateID, input)
+	if err != nil {
+		sendServiceError(c, err, "plan template", "Failed to update plan template.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan template updated successfully",
+		"data":    template,
+	})
+}
+
+// DeletePlanTemplateHandler removes a template from the library. Plans already created from it are kept.
+// DELETE /api/admin/plan-templates/:templateID
+func (h *APIHandler) DeletePlanTemplateHandler(c *gin.Context) {
+	templateID, err := parseUint(c.Param("templateID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TemplateID parameter.", err)
+		return
+	}
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+
+	if err := h.planTemplateService.DeleteTemplate(templateID); err != nil {
+		sendServiceError(c, err, "plan template", "Failed to delete plan template.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan template deleted successfully",
+		"data":    nil,
+	})
+}
+
+// AdminListArticlesHandler lists the knowledge base articles in any review status, optionally filtered like
+// ListArticlesHandler or by status.
+// GET /api/admin/articles?status=in_review&q=&category=&tag=&offset=0&limit=20
+func (h *APIHandler) AdminListArticlesHandler(c *gin.Context) {
+	query, ok := bindArticleQuery(c)
+	if !ok || !h.articleServiceReady(c) {
+		return
+	}
+	query.Status = models.ReviewStatus(c.Query("status"))
+
+	page, err := h.articleService.ListArticles(query)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to list articles.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Articles retrieved successfully",
+		"data":    page,
+	})
+}
+
+// AdminGetArticleHandler returns a knowledge base article in any review status.
+// GET /api/admin/articles/:articleID
+func (h *APIHandler) AdminGetArticleHandler(c *gin.Context) {
+	articleID, err := parseUint(c.Param("articleID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ArticleID parameter.", err)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+
+	article, err := h.articleService.GetArticle(articleID, false)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to retrieve article.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Article retrieved successfully",
+		"data":    article,
+	})
+}
+
+// CreateArticleHandler adds a draft article to the knowledge base. It is shown to users once approved, see
+// ReviewArticleHandler.
+// POST /api/admin/articles
+// Request body: { "title": "string", "body": "Markdown", "category": "male_health", "tags": ["string"],
+// "references": [{ "title": "string", "url": "string" }] }
+func (h *APIHandler) CreateArticleHandler(c *gin.Context) {
+	var input models.ArticleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+
+	article, err := h.articleService.CreateArticle(input)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to create article.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Article created successfully",
+		"data":    article,
+	})
+}
+
+// UpdateArticleHandler replaces the content of a knowledge base article. An approved article goes back to review.
+// PUT /api/admin/articles/:articleID
+// Request body: same as CreateArticleHandler.
+func (h *APIHandler) UpdateArticleHandler(c *gin.Context) {
+	articleID, err := parseUint(c.Param("articleID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ArticleID parameter.", err)
+		return
+	}
+	var input models.ArticleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+
+	article, err := h.articleService.UpdateArticle(articleID, input)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to update article.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Article updated successfully",
+		"data":    article,
+	})
+}
+
+// ReviewArticleHandler moves a knowledge base article through the editorial review: draft → in_review →
+// approved, back to draft if rejected, and retired when withdrawn. Approving, rejecting and retiring record the
+// reviewer and date.
+// POST /api/admin/articles/:articleID/review
+// Request body: { "status": "approved", "reviewer": "string", "note": "string" }
+func (h *APIHandler) ReviewArticleHandler(c *gin.Context) {
+	articleID, err := parseUint(c.Param("articleID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ArticleID parameter.", err)
+		return
+	}
+	var input models.ReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+
+	article, err := h.articleService.ReviewArticle(articleID, input)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to review article.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Article review updated successfully",
+		"data":    article,
+	})
+}
+
+// DeleteArticleHandler removes an article from the knowledge base.
+// DELETE /api/admin/articles/:articleID
+func (h *APIHandler) DeleteArticleHandler(c *gin.Context) {
+	articleID, err := parseUint(c.Param("articleID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ArticleID parameter.", err)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+
+	if err := h.articleService.DeleteArticle(articleID); err != nil {
+		sendServiceError(c, err, "article", "Failed to delete article.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Article deleted successfully",
+		"data":    nil,
+	})
+}
+
+// AdminListExercisesHandler lists the exercises of the library in any review status, optionally filtered like
+// ListExercisesHandler or by status.
+// GET /api/admin/exercises?status=in_review&category=&difficulty=
+func (h *APIHandler) AdminListExercisesHandler(c *gin.Context) {
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+	query := models.ExerciseQuery{
+		Status:     models.ReviewStatus(c.Query("status")),
+		Category:   models.ExerciseCategory(c.Query("category")),
+		Difficulty: models.ExerciseDifficulty(c.Query("difficulty")),
+	}
+
+	exercises, err := h.exerciseService.ListExercises(query)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to list exercises.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercises retrieved successfully",
+		"data":    exercises,
+	})
+}
+
+// AdminGetExerciseHandler returns an exercise of the library in any review status.
+// GET /api/admin/exercises/:exerciseID
+func (h *APIHandler) AdminGetExerciseHandler(c *gin.Context) {
+	exerciseID, err := parseUint(c.Param("exerciseID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ExerciseID parameter.", err)
+		return
+	}
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+
+	exercise, err := h.exerciseService.GetExercise(exerciseID, false)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to retrieve exercise.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercise retrieved successfully",
+		"data":    exercise,
+	})
+}
+
+// CreateExerciseHandler adds a draft exercise to the library. Users, plans and the planner see it once approved,
+// see ReviewExerciseHandler.
+// POST /api/admin/exercises
+// Request body: { "name": "string", "summary": "string", "category": "pelvic_floor", "difficulty": "beginner",
+// "target_muscles": ["string"], "steps": ["string"], "key_points": ["string"], "common_mistakes": ["string"],
+// "media": [{ "type": "image | video", "url": "string", "title": "string" }] }
+func (h *APIHandler) CreateExerciseHandler(c *gin.Context) {
+	var input models.ExerciseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+
+	exercise, err := h.exerciseService.CreateExercise(input)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to create exercise.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercise created successfully",
+		"data":    exercise,
+	})
+}
+
+// UpdateExerciseHandler replaces the content of an exercise. An approved exercise goes back to review.
+// PUT /api/admin/exercises/:exerciseID
+// Request body: same as CreateExerciseHandler.
+func (h *APIHandler) UpdateExerciseHandler(c *gin.Context) {
+	exerciseID, err := parseUint(c.Param("exerciseID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ExerciseID parameter.", err)
+		return
+	}
+	var input models.ExerciseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+
+	exercise, err := h.exerciseService.UpdateExercise(exerciseID, input)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to update exercise.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercise updated successfully",
+		"data":    exercise,
+	})
+}
+
+// ReviewExerciseHandler moves an exercise through the editorial review, like ReviewArticleHandler.
+// POST /api/admin/exercises/:exerciseID/review
+// Request body: { "status": "approved", "reviewer": "string", "note": "string" }
+func (h *APIHandler) ReviewExerciseHandler(c *gin.Context) {
+	exerciseID, err := parseUint(c.Param("exerciseID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ExerciseID parameter.", err)
+		return
+	}
+	var input models.ReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+
+	exercise, err := h.exerciseService.ReviewExercise(exerciseID, input)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to review exercise.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercise review updated successfully",
+		"data":    exercise,
+	})
+}
+
+// DeleteExerciseHandler removes an exercise from the library. Plan tasks following it show without instructions.
+// DELETE /api/admin/exercises/:exerciseID
+func (h *APIHandler) DeleteExerciseHandler(c *gin.Context) {
+	exerciseID, err := parseUint(c.Param("exerciseID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ExerciseID parameter.", err)
+		return
+	}
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+
+	if err := h.exerciseService.DeleteExercise(exerciseID); err != nil {
+		sendServiceError(c, err, "exercise", "Failed to delete exercise.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercise deleted successfully",
+		"data":    nil,
+	})
+}
+
+// ListContentFeedbackHandler counts the "useful / not useful" votes on each article or exercise, the content
+// with the most "not useful" votes first.
+// GET /api/admin/content-feedback?content_type=article
+func (h *APIHandler) ListContentFeedbackHandler(c *gin.Context) {
+	if !h.contentFeedbackServiceReady(c) {
+		return
+	}
+
+	summaries, err := h.contentFeedbackService.ListFeedbackSummaries(models.ContentType(c.Query("content_type")))
+	if err != nil {
+		sendServiceError(c, err, "content feedback", "Failed to list content feedback.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Content feedback retrieved successfully",
+		"data":    summaries,
+	})
+}
+
+// jobRunnerReady reports whether the job runner is available, sending an error if not.
+func (h *APIHandler) jobRunnerReady(c *gin.Context) bool {
+	if h.jobRunner == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("jobrunner not initialized"))
+		return false
+	}
+	return true
+}
+
+// ListJobsHandler lists the background jobs with their schedules and run state.
+// GET /api/admin/jobs
+func (h *APIHandler) ListJobsHandler(c *gin.Context) {
+	if !h.jobRunnerReady(c) {
+		return
+	}
+
+	jobs, err := h.jobRunner.ListJobs()
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to list jobs.", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Jobs retrieved successfully",
+		"data":    jobs,
+	})
+}
+
+// UpdateJobHandler changes the schedule of a background job or enables or disables it.
+// PATCH /api/admin/jobs/:jobID
+// Request body: { "cron_expr": "0 4 * * *", "enabled": true } (both optional)
+func (h *APIHandler) UpdateJobHandler(c *gin.Context) {
+	jobID, err := parseUint(c.Param("jobID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid JobID parameter.", err)
+		return
+	}
+	var input models.JobUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.jobRunnerReady(c) {
+		return
+	}
+
+	job, err := h.jobRunner.UpdateJob(jobID, input, time.Now())
+	if err != nil {
+		sendServiceError(c, err, "job", "Failed to update job.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Job updated successfully",
+		"data":    job,
+	})
+}
+
+// RunJobHandler starts a background job immediately and returns its execution, which keeps running after the
+// response. Fails with 409 if the job is already running.
+// POST /api/admin/jobs/:jobID/run
+func (h *APIHandler) RunJobHandler(c *gin.Context) {
+	jobID, err := parseUint(c.Param("jobID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid JobID parameter.", err)
+		return
+	}
+	if !h.jobRunnerReady(c) {
+		return
+	}
+
+	execution, err := h.jobRunner.RunNow(jobID)
+	if err != nil {
+		sendServiceError(c, err, "job", "Failed to run job.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Job started successfully",
+		"data":    execution,
+	})
+}
+
+// ListJobExecutionsHandler returns the most recent executions of a background job with their logs.
+// GET /api/admin/jobs/:jobID/executions?limit=20
+func (h *APIHandler) ListJobExecutionsHandler(c *gin.Context) {
+	jobID, err := parseUint(c.Param("jobID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid JobID parameter.", err)
+		return
+	}
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid limit parameter.", err)
+			return
+		}
+	}
+	if !h.jobRunnerReady(c) {
+		return
+	}
+
+	executions, err := h.jobRunner.GetExecutions(jobID, limit)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to retrieve job executions.", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Job executions retrieved successfully",
+		"data":    executions,
+	})
+}
+
+// webhookServiceReady reports whether the webhook service is available, sending an error if not.
+func (h *APIHandler) webhookServiceReady(c *gin.Context) bool {
+	if h.webhookService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("webhookservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// ListWebhookDeadLettersHandler returns the most recent webhook deliveries that failed all their attempts.
+// GET /api/admin/webhooks/dead-letters?limit=50
+func (h *APIHandler) ListWebhookDeadLettersHandler(c *gin.Context) {
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid limit parameter.", err)
+			return
+		}
+	}
+	if !h.webhookServiceReady(c) {
+		return
+	}
+
+	deadLetters, err := h.webhookService.ListDeadLetters(limit)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to retrieve webhook dead letters.", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Webhook dead letters retrieved successfully",
+		"data":    deadLetters,
+	})
+}
+
+// ReplayWebhookDeadLetterHandler queues a dead letter for delivery again; the retry job sends it on its next run.
+// POST /api/admin/webhooks/dead-letters/:deadLetterID/replay
+func (h *APIHandler) ReplayWebhookDeadLetterHandler(c *gin.Context) {
+	deadLetterID, err := parseUint(c.Param("deadLetterID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid DeadLetterID parameter.", err)
+		return
+	}
+	if !h.webhookServiceReady(c) {
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDeadLetter(deadLetterID, time.Now())
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			utils.SendJSONError(c, http.StatusNotFound, "Dead letter not found.", err)
+		case strings.HasPrefix(err.Error(), "cannot"):
+			utils.SendJSONError(c, http.StatusConflict, "The webhook endpoint of this dead letter no longer exists.", err)
+		default:
+			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to replay dead letter.", err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Dead letter queued for delivery",
+		"data":    delivery,
+	})
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"project/models"
+	"project/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Knowledge Base Handlers ---
+
+// articleServiceReady reports whether the article service is available, sending an error if not.
+func (h *APIHandler) articleServiceReady(c *gin.Context) bool {
+	if h.articleService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("articleservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// bindArticleQuery reads the search, filter and paging parameters of the article lists, sending an error if they
+// are not valid.
+func bindArticleQuery(c *gin.Context) (models.ArticleQuery, bool) {
+	query := models.ArticleQuery{
+		Query:    c.Query("q"),
+		Category: models.ArticleCategory(c.Query("category")),
+		Tag:      c.Query("tag"),
+		Limit:    20,
+	}
+	var err error
+	if value := c.Query("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid offset parameter.", err)
+			return query, false
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid limit parameter.", err)
+			return query, false
+		}
+	}
+	return query, true
+}
+
+// ListArticlesHandler lists the approved knowledge base articles, newest first, optionally of one category or tag.
+// With q, it searches them like SearchArticlesHandler.
+// GET /api/articles?category=male_health&tag=凯格尔&offset=0&limit=20
+func (h *APIHandler) ListArticlesHandler(c *gin.Context) {
+	query, ok := bindArticleQuery(c)
+	if !ok || !h.articleServiceReady(c) {
+		return
+	}
+	query.Status = models.ReviewStatusApproved
+
+	page, err := h.articleService.ListArticles(query)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to list articles.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Articles retrieved successfully",
+		"data":    page,
+	})
+}
+
+// SearchArticlesHandler searches the approved knowledge base articles by keywords, best matches first. Chinese
+// text matches without word breaks, e.g. 盆底 finds articles about 盆底肌.
+// GET /api/articles/search?q=盆底肌训练&category=exercise_technique&offset=0&limit=20
+func (h *APIHandler) SearchArticlesHandler(c *gin.Context) {
+	query, ok := bindArticleQuery(c)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(query.Query) == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: q is required.", nil)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+	query.Status = models.ReviewStatusApproved
+
+	page, err := h.articleService.ListArticles(query)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to search articles.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Articles retrieved successfully",
+		"data":    page,
+	})
+}
+
+// GetArticleHandler returns an approved knowledge base article.
+// GET /api/articles/:articleID
+func (h *APIHandler) GetArticleHandler(c *gin.Context) {
+	articleID, err := parseUint(c.Param("articleID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ArticleID parameter.", err)
+		return
+	}
+	if !h.articleServiceReady(c) {
+		return
+	}
+
+	article, err := h.articleService.GetArticle(articleID, true)
+	if err != nil {
+		sendServiceError(c, err, "article", "Failed to retrieve article.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Article retrieved successfully",
+		"data":    article,
+	})
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"project/services"
+	"project/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Assessment Handlers ---
+
+// GetAssessmentProfileHandler returns the latest structured health profile of a user.
+// GET /api/assessment/profile/:userID
+func (h *APIHandler) GetAssessmentProfileHandler(c *gin.Context) {
+	userID := c.Param("userID")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "UserID parameter is required.", nil)
+		return
+	}
+
+	if h.assessmentProfileService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("assessmentprofileservice not initialized"))
+		return
+	}
+
+	profile, err := h.assessmentProfileService.GetLatestProfile(userID)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to fetch assessment profile.", err)
+		return
+	}
+	if profile == nil {
+		utils.SendJSONError(c, http.StatusNotFound, "No assessment profile found. Please complete the assessment first.", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Assessment profile retrieved successfully",
+		"data":    profile,
+	})
+}
+
+// GetAssessmentSummaryHandler returns the summary generated for the latest completed assessment of a user.
+// GET /api/assessment/summary/:userID
+func (h *APIHandler) GetAssessmentSummaryHandler(c *gin.Context) {
+	userID := c.Param("userID")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "UserID parameter is required.", nil)
+		return
+	}
+
+	if h.assessmentService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("assessmentservice not initialized"))
+		return
+	}
+
+	assessment, err := h.assessmentService.GetAssessmentResult(userID)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to fetch assessment summary.", err)
+		return
+	}
+	if assessment == nil {
+		utils.SendJSONError(c, http.StatusNotFound, "No completed assessment found. Please complete the assessment first.", nil)
+		return
+	}
+	if assessment.Summary == "" {
+		utils.SendJSONError(c, http.StatusNotFound, "The summary of this assessment is not available yet.", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Assessment summary retrieved successfully",
+		"data": gin.H{
+			"assessment_id":        assessment.ID,
+			"user_id":              assessment.UserID,
+			"summary":              assessment.Summary,
+			"summary_generated_at": assessment.SummaryGeneratedAt,
+			"completed_at":         assessment.CompletedAt,
+		},
+	})
+}
+
+// RetakeAssessmentHandler starts a new assessment for a user who wants to measure progress.
+// POST /api/assessment/retake
+// Request body: { "user_id": "string" }
+func (h *APIHandler) RetakeAssessmentHandler(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request format.", err)
+		return
+	}
+
+	if h.assessmentService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("assessmentservice not initialized"))
+		return
+	}
+
+	question, assessment, userVisibleErr := h.assessmentService.StartRetake(req.UserID)
+	if userVisibleErr != nil {
+		var tooSoon *services.RetakeTooSoonError
+		if errors.As(userVisibleErr, &tooSoon) {
+			// Too early for a retake; the message tells the user when it will be possible.
+			utils.SendJSONError(c, http.StatusConflict, tooSoon.Error(), tooSoon)
+			return
+		}
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to start assessment.", userVisibleErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Assessment started",
+		"data": gin.H{
+			"assessment": assessment,
+			"question":   question,
+		},
+	})
+}
+
+// GetAssessmentHistoryHandler lists the completed assessments of a user, oldest first.
+// GET /api/assessment/history/:userID
+func (h *APIHandler) GetAssessmentHistoryHandler(c *gin.Context) {
+	userID := c.Param("userID")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "UserID parameter is required.", nil)
+		return
+	}
+
+	if h.assessmentService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("assessmentservice not initialized"))
+		return
+	}
+
+	assessments, err := h.assessmentService.ListAssessments(userID)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to fetch assessment history.", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Assessment history retrieved successfully",
+		"data":    assessments, // Empty array if the user has not completed an assessment
+	})
+}
+
+// CompareAssessmentsHandler shows how answers and scores changed between two completed assessments.
+// GET /api/assessment/compare/:userID?from=<assessmentID>&to=<assessmentID>
+// Without from/to, the last two completed assessments are compared.
+func (h *APIHandler) CompareAssessmentsHandler(c *gin.Context) {
+	userID := c.Param("userID")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "UserID parameter is required.", nil)
+		return
+	}
+
+	var fromID, toID uint
+	fromStr, toStr := c.Query("from"), c.Query("to")
+	if (fromStr == "") != (toStr == "") {
+		utils.SendJSONError(c, http.StatusBadRequest, "Both 'from' and 'to' must be given, or neither.", nil)
+		return
+	}
+	if fromStr != "" {
+		var err error
+		if fromID, err = parseUint(fromStr); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid 'from' assessment ID.", err)
+			return
+		}
+		if toID, err = parseUint(toStr); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid 'to' assessment ID.", err)
+			return
+		}
+	}
+
+	if h.assessmentProfileService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("assessmentprofileservice not initialized"))
+		return
+	}
+
+	comparison, err := h.assessmentProfileService.CompareAssessments(userID, fromID, toID)
+	if err != nil {
+		lowerErr := strings.ToLower(err.Error())
+		if strings.Contains(lowerErr, "not found") || strings.Contains(lowerErr, "at least two") || strings.Contains(lowerErr, "only completed") {
+			utils.SendJSONError(c, http.StatusNotFound, "Two completed assessments are needed for a comparison.", err)
+		} else {
+			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to compare assessments.", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Assessment comparison retrieved successfully",
+		"data":    comparison,
+	})
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"project/config"
+	"project/models"
+	"project/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Calendar Handlers ---
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+// calendarServiceReady reports whether the calendar service is available, sending an error if not.
+func (h *APIHandler) calendarServiceReady(c *gin.Context) bool {
+	if h.calendarService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("calendarservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// ExportPlanCalendarHandler downloads a plan's tasks as an iCalendar file.
+// GET /api/plan/:planID/calendar.ics?timezone=Asia/Shanghai&discreet=true
+// discreet replaces task titles with neutral ones; the configured calendar.discreet if omitted.
+func (h *APIHandler) ExportPlanCalendarHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	opts := models.CalendarOptions{Timezone: c.Query("timezone")}
+	if value := c.Query("discreet"); value != "" {
+		discreet, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid discreet parameter, expected true or false.", err)
+			return
+		}
+		opts.Discreet = &discreet
+	}
+	if !h.calendarServiceReady(c) {
+		return
+	}
+
+	ics, err := h.calendarService.ExportPlan(planID, opts, time.Now())
+	if err != nil {
+		sendPlanError(c, err, "Failed to export plan calendar.")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="plan-%d.ics"`, planID))
+	c.Data(http.StatusOK, calendarContentType, ics)
+}
+
+// GetCalendarSubscriptionHandler returns a user's calendar subscription and its URLs.
+// GET /api/plan/calendar/subscription?user_id=xxx
+func (h *APIHandler) GetCalendarSubscriptionHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	if !h.calendarServiceReady(c) {
+		return
+	}
+
+	feed, err := h.calendarService.GetFeed(userID)
+	if err != nil {
+		sendServiceError(c, err, "calendar subscription", "Failed to retrieve calendar subscription.")
+		return
+	}
+	if feed == nil {
+		utils.SendJSONError(c, http.StatusNotFound, "No calendar subscription yet.", nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Calendar subscription retrieved successfully",
+		"data":    calendarSubscription(c, feed),
+	})
+}
+
+// SaveCalendarSubscriptionHandler creates a user's calendar subscription or changes its settings. The feed URL
+// always shows the user's active plans; rotate issues a new URL and invalidates the old one.
+// POST /api/plan/calendar/subscription
+// Request body: { "user_id": "string", "discreet": true, "timezone": "Asia/Shanghai", "rotate": false }
+func (h *APIHandler) SaveCalendarSubscriptionHandler(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		models.CalendarFeedInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.calendarServiceReady(c) {
+		return
+	}
+
+	feed, err := h.calendarService.SaveFeed(req.UserID, req.CalendarFeedInput)
+	if err != nil {
+		sendServiceError(c, err, "calendar subscription", "Failed to save calendar subscription.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Calendar subscription saved successfully",
+		"data":    calendarSubscription(c, feed),
+	})
+}
+
+// DeleteCalendarSubscriptionHandler revokes a user's calendar subscription; its URL stops working.
+// DELETE /api/plan/calendar/subscription?user_id=xxx
+func (h *APIHandler) DeleteCalendarSubscriptionHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	if !h.calendarServiceReady(c) {
+		return
+	}
+
+	if err := h.calendarService.DeleteFeed(userID); err != nil {
+		sendServiceError(c, err, "calendar subscription", "Failed to delete calendar subscription.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Calendar subscription deleted successfully",
+		"data":    nil,
+	})
+}
+
+// CalendarFeedHandler serves a subscription feed to calendar apps. The token in the URL is the only credential.
+// GET /api/plan/calendar/feed/:token (the token may end in ".ics")
+func (h *APIHandler) CalendarFeedHandler(c *gin.Context) {
+	if !h.calendarServiceReady(c) {
+		return
+	}
+
+	ics, err := h.calendarService.RenderFeed(c.Param("token"), time.Now())
+	if err != nil {
+		sendServiceError(c, err, "calendar feed", "Failed to render calendar feed.")
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=900")
+	c.Data(http.StatusOK, calendarContentType, ics)
+}
+
+// calendarSubscription adds the feed URLs to a subscription: an https URL and a webcal URL that opens
+// calendar apps directly.
+func calendarSubscription(c *gin.Context, feed *models.CalendarFeed) gin.H {
+	base := strings.TrimRight(config.AppConfig.Calendar.FeedBaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	url := base + "/api/plan/calendar/feed/" + feed.Token + ".ics"
+	webcal := "webcal://" + strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	return gin.H{
+		"subscription": feed,
+		"url":          url,
+		"webcal_url":   webcal,
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"project/models"
+	"project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Content Feedback Handlers ---
+
+// contentFeedbackServiceReady reports whether the content feedback service is available, sending an error if not.
+func (h *APIHandler) contentFeedbackServiceReady(c *gin.Context) bool {
+	if h.contentFeedbackService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("contentfeedbackservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// SubmitArticleFeedbackHandler records whether a user found an approved article useful. A user's later vote
+// replaces their earlier one.
+// POST /api/articles/:articleID/feedback
+// Request body: { "user_id": "string", "useful": true, "comment": "string" }
+func (h *APIHandler) SubmitArticleFeedbackHandler(c *gin.Context) {
+	h.submitContentFeedback(c, models.ContentTypeArticle, "articleID")
+}
+
+// SubmitExerciseFeedbackHandler records whether a user found an approved exercise useful, like
+// SubmitArticleFeedbackHandler.
+// POST /api/exercises/:exerciseID/feedback
+// Request body: { "user_id": "string", "useful": false, "comment": "string" }
+func (h *APIHandler) SubmitExerciseFeedbackHandler(c *gin.Context) {
+	h.submitContentFeedback(c, models.ContentTypeExercise, "exerciseID")
+}
+
+// submitContentFeedback records a vote on the content identified by the path parameter idParam and responds with
+// the votes on it.
+func (h *APIHandler) submitContentFeedback(c *gin.Context, contentType models.ContentType, idParam string) {
+	contentID, err := parseUint(c.Param(idParam))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid content ID parameter.", err)
+		return
+	}
+	var input models.ContentFeedbackInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request body.", err)
+		return
+	}
+	if !h.contentFeedbackServiceReady(c) {
+		return
+	}
+
+	summary, err := h.contentFeedbackService.SubmitFeedback(contentType, contentID, input)
+	if err != nil {
+		sendServiceError(c, err, "content", "Failed to submit feedback.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Feedback submitted successfully",
+		"data":    summary,
+	})
+}
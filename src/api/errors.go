@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"project/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendServiceError maps a service error about a resource, such as "article" or "job", to an HTTP status following
+// the conventions of the service errors: "not found", "unauthorized", and the "invalid" and "cannot" prefixes.
+// Only the message of the service itself counts, so a wrapped repository error ends up as a 500.
+// Plan endpoints use sendPlanError.
+func sendServiceError(c *gin.Context, err error, resource, fallback string) {
+	msg := serviceErrorText(err)
+	switch {
+	case strings.Contains(strings.ToLower(msg), "not found"):
+		utils.SendJSONError(c, http.StatusNotFound, strings.ToUpper(resource[:1])+resource[1:]+" not found.", err)
+	case strings.Contains(msg, "unauthorized"):
+		utils.SendJSONError(c, http.StatusForbidden, fmt.Sprintf("You are not authorized to access this %s.", resource), err)
+	case strings.HasPrefix(msg, "invalid"):
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request.", err)
+	case strings.HasPrefix(msg, "cannot"):
+		utils.SendJSONError(c, http.StatusConflict, fmt.Sprintf("This action is not allowed for the %s's current status.", resource), err)
+	default:
+		utils.SendJSONError(c, http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// serviceErrorText returns the message a service gave err, without the text of the error it wraps.
+func serviceErrorText(err error) string {
+	msg := err.Error()
+	if wrapped := errors.Unwrap(err); wrapped != nil {
+		msg = strings.TrimSuffix(msg, ": "+wrapped.Error())
+	}
+	return msg
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendServiceError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repoErr := errors.New("record not found")
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantMsg    string
+	}{
+		{"Not found", errors.New("article 3 not found"), http.StatusNotFound, "Article not found."},
+		{"Unauthorized", errors.New("unauthorized to read notification 4"), http.StatusForbidden, "You are not authorized to access this article."},
+		{"Invalid", fmt.Errorf("invalid template: task 1: %w", errors.New("title is required")), http.StatusBadRequest, "Invalid request."},
+		{"Cannot", errors.New("cannot run job 'cleanup': it is already running"), http.StatusConflict, "This action is not allowed for the article's current status."},
+		{"Wrapped repository error", fmt.Errorf("%s: %w", "failed to update article ID 3", repoErr), http.StatusInternalServerError, "Failed to update article."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/articles/3", nil)
+
+			sendServiceError(c, tt.err, "article", "Failed to update article.")
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var body map[string]string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantMsg, body["error"])
+		})
+	}
+}
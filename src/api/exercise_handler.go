@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"project/models"
+	"project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Exercise Library Handlers ---
+
+// exerciseServiceReady reports whether the exercise service is available, sending an error if not.
+func (h *APIHandler) exerciseServiceReady(c *gin.Context) bool {
+	if h.exerciseService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("exerciseservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// ListExercisesHandler lists the approved exercises of the library, optionally of one category or difficulty.
+// GET /api/exercises?category=pelvic_floor&difficulty=beginner
+func (h *APIHandler) ListExercisesHandler(c *gin.Context) {
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+	query := models.ExerciseQuery{
+		Status:     models.ReviewStatusApproved,
+		Category:   models.ExerciseCategory(c.Query("category")),
+		Difficulty: models.ExerciseDifficulty(c.Query("difficulty")),
+	}
+
+	exercises, err := h.exerciseService.ListExercises(query)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to list exercises.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercises retrieved successfully",
+		"data":    exercises,
+	})
+}
+
+// GetExerciseHandler returns an approved exercise with its steps, key points, common mistakes and media.
+// GET /api/exercises/:exerciseID
+func (h *APIHandler) GetExerciseHandler(c *gin.Context) {
+	exerciseID, err := parseUint(c.Param("exerciseID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid ExerciseID parameter.", err)
+		return
+	}
+	if !h.exerciseServiceReady(c) {
+		return
+	}
+
+	exercise, err := h.exerciseService.GetExercise(exerciseID, true)
+	if err != nil {
+		sendServiceError(c, err, "exercise", "Failed to retrieve exercise.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Exercise retrieved successfully",
+		"data":    exercise,
+	})
+}
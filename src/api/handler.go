@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -27,6 +28,20 @@ type APIHandler struct {
 	chatService      services.ChatService
 	planRepo         repository.PlanRepository // Added PlanRepository
 	planService      services.PlanService      // Added PlanService
+	assessmentProfileService services.AssessmentProfileService
+	analyticsService         services.AnalyticsService
+	plannerService           services.PlannerService
+	planSafetyService        services.PlanSafetyService
+	planTemplateService      services.PlanTemplateService
+	calendarService          services.CalendarService
+	jobRunner                services.JobRunner
+	notificationService      services.NotificationService
+	webhookService           services.WebhookService
+	events                   services.EventBus
+	pushHub                  services.PushHub
+	articleService           services.ArticleService
+	exerciseService          services.ExerciseService
+	contentFeedbackService   services.ContentFeedbackService
 	db               *gorm.DB                  // If direct DB access is needed by handlers
 }
 
@@ -40,6 +55,20 @@ func NewAPIHandler(
 	schedulerService services.SchedulerService,
 	chatService services.ChatService,
 	planService services.PlanService, // Added PlanService
+	assessmentProfileService services.AssessmentProfileService,
+	analyticsService services.AnalyticsService,
+	plannerService services.PlannerService,
+	planSafetyService services.PlanSafetyService,
+	planTemplateService services.PlanTemplateService,
+	calendarService services.CalendarService,
+	jobRunner services.JobRunner,
+	notificationService services.NotificationService,
+	webhookService services.WebhookService,
+	events services.EventBus,
+	pushHub services.PushHub,
+	articleService services.ArticleService,
+	exerciseService services.ExerciseService,
+	contentFeedbackService services.ContentFeedbackService,
 	db *gorm.DB,
 ) *APIHandler {
 	return &APIHandler{
@@ -51,6 +80,20 @@ func NewAPIHandler(
 		schedulerService:  schedulerService,
 		chatService:      chatService,
 		planService:      planService, // Store PlanService
+		assessmentProfileService: assessmentProfileService,
+		analyticsService:         analyticsService,
+		plannerService:           plannerService,
+		planSafetyService:        planSafetyService,
+		planTemplateService:      planTemplateService,
+		calendarService:          calendarService,
+		jobRunner:                jobRunner,
+		notificationService:      notificationService,
+		webhookService:           webhookService,
+		events:                   events,
+		pushHub:                  pushHub,
+		articleService:           articleService,
+		exerciseService:          exerciseService,
+		contentFeedbackService:   contentFeedbackService,
 		db:               db,
 	}
 }
@@ -98,12 +141,23 @@ func (h *APIHandler) InitHandler(c *gin.Context) {
 		remainingQuota = -1 // Indicates no limit for registered users or not applicable
 	}
 
+	var unreadNotifications int64
+	if h.notificationService != nil && userID != "" { // A new guest has no notifications yet
+		count, err := h.notificationService.UnreadCount(actualUserID)
+		if err != nil {
+			log.Printf("Error counting unread notifications for %s in InitHandler: %v. Assuming 0.", actualUserID, err)
+		} else {
+			unreadNotifications = count
+		}
+	}
+
 	response := models.InitResponse{
 		UserType:       userType,
 		UserID:         actualUserID,
 		GuestChatQuota: guestChatQuota,
 		MessagesSent:   messagesSent,
 		RemainingQuota: remainingQuota,
+		UnreadNotifications: unreadNotifications,
 		Models:         config.AppConfig.LLMModels,
 		Groups:         config.AppConfig.LLMGroups,
 		Characters:     config.AppConfig.LLMCharacters,
@@ -203,6 +257,7 @@ func (h *APIHandler) ChatHandler(c *gin.Context) {
 	log.Printf("INFO: User '%s' message调度给AI: %s", clientReq.UserID, nextAgentIDForChat)
 
 	var additionalContextHeader string
+	var completedAssessment *models.UserAssessment // Set when this message completed the assessment
 	if nextAgentIDForChat == ProfileAssessmentAgentID {
 		if h.assessmentService == nil || h.assessmentRepo == nil {
 			utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("assessment service/repo not initialized"))
@@ -227,15 +282,19 @@ func (h *APIHandler) ChatHandler(c *gin.Context) {
 		}
 		if tempAssessment != nil { currentAssessmentStatus = tempAssessment.Status }
 
-		if assessmentUserVisibleError != nil {
+		var tooSoon *services.RetakeTooSoonError
+		if errors.As(assessmentUserVisibleError, &tooSoon) {
+			// A finished assessment is not an error in chat: the agent still replies and tells the user when a retake is possible.
+			log.Printf("INFO: User '%s' asked for an assessment before the retake interval passed (next allowed %s).", clientReq.UserID, tooSoon.NextAllowed.Format("2006-01-02"))
+			additionalContextHeader = fmt.Sprintf("\n\n[系统指令：评估已完成]\n用户已于 %s 完成健康评估，%s 起可以重新评估。请按照你的角色设定，告知用户下次可以重新评估的日期，并继续回答用户的问题。",
+				tooSoon.CompletedAt.Format("2006-01-02"), tooSoon.NextAllowed.Format("2006-01-02"))
+		} else if assessmentUserVisibleError != nil {
 			log.Printf("INFO: AssessmentService for user '%s' returned message/error: %v", clientReq.UserID, assessmentUserVisibleError)
 			// This error is often a user-facing message (e.g., "Please agree to terms"), send as SSE.
 			sendErrorEvent(c, assessmentUserVisibleError.Error(), ProfileAssessmentAgentID)
 			if tempAssessment != nil { saveAIMessage(h.chatRepo, clientReq.UserID, ProfileAssessmentAgentID, assessmentUserVisibleError.Error())}
 			return
-		}
-		// Context header generation logic... (as before)
-		if assessmentQuestionForAgent != nil {
+		} else if assessmentQuestionForAgent != nil { // Context header generation logic... (as before)
 			additionalContextHeader = fmt.Sprintf("\n\n[系统指令：当前评估问题]\n问题文本：%s", assessmentQuestionForAgent.Text)
 			if len(assessmentQuestionForAgent.Options) > 0 {
 				additionalContextHeader += "\n选项（请选择一项或多项）：\n"
@@ -244,13 +303,52 @@ func (h *APIHandler) ChatHandler(c *gin.Context) {
 				}
 			}
 		} else if currentAssessmentStatus == models.AssessmentStatusCompleted {
-			additionalContextHeader = "\n\n[系统指令：评估已完成]\n请按照你的角色设定，告知用户评估已完成，并提示后续流程。"
+			// The agent's reply to this header is the assessment summary; it is stored once the stream finishes.
+			additionalContextHeader = h.assessmentService.BuildSummaryContext(tempAssessment)
+			completedAssessment = tempAssessment
+			if h.assessmentProfileService != nil {
+				// A scoring failure must not block the completion reply; the profile can be rebuilt later.
+				if profile, profileErr := h.assessmentProfileService.BuildProfile(tempAssessment); profileErr != nil {
+					log.Printf("ERROR: Failed to build assessment profile for user '%s' (assessment ID %d): %v", clientReq.UserID, tempAssessment.ID, profileErr)
+				} else if len(profile.RiskFlags) > 0 {
+					h.publishEvent(models.EventRiskRaised, clientReq.UserID, gin.H{"assessment_id": tempAssessment.ID, "risk_flags": profile.RiskFlags})
+				}
+			}
 		} else if currentAssessmentStatus == models.AssessmentStatusCancelled {
             additionalContextHeader = "\n\n[系统指令：评估已中止]\n请按照你的角色设定，礼貌告知用户评估已中止。"
         }
 		log.Printf("INFO: Additional context header for AI %s: %.50s...", nextAgentIDForChat, additionalContextHeader)
 	}
 
+	// Agents discussing progress get the changes between the last two completed assessments.
+	if h.assessmentProfileService != nil {
+		for _, agentID := range config.AppConfig.AssessmentRetake.ProgressAgentIDs {
+			if agentID != nextAgentIDForChat {
+				continue
+			}
+			progressHeader, progressErr := h.assessmentProfileService.BuildProgressContext(clientReq.UserID)
+			if progressErr != nil {
+				log.Printf("WARN: Failed to build assessment progress context for user '%s': %v", clientReq.UserID, progressErr)
+			}
+			additionalContextHeader += progressHeader
+			break
+		}
+	}
+
+	// Asking the planner agent for a plan creates a structured plan first; the agent's reply then presents it.
+	var createdPlan *models.Plan
+	if h.plannerService != nil && nextAgentIDForChat == config.AppConfig.Planner.AgentID && services.IsPlanRequest(clientReq.Message) {
+		plan, planErr := h.plannerService.GeneratePlan(clientReq.UserID, clientReq.Message)
+		if planErr != nil {
+			log.Printf("ERROR: Planner failed to create a plan for user '%s': %v", clientReq.UserID, planErr)
+			additionalContextHeader += "\n\n[系统指令：计划创建失败]\n系统暂时未能为用户生成并保存计划。请向用户致歉，说明稍后可以再试，并可先在对话中给出简要建议。\n"
+		} else {
+			createdPlan = plan
+			additionalContextHeader += planCreatedContext(plan)
+			h.publishEvent(models.EventPlanCreated, clientReq.UserID, planEventData(plan))
+		}
+	}
+
 	var selectedAIConfig *config.LLMCharacter
 	for _, char := range config.AppConfig.LLMCharacters {
 		if char.ID == nextAgentIDForChat {
@@ -275,13 +373,13 @@ func (h *APIHandler) ChatHandler(c *gin.Context) {
 		AIName:       selectedAIConfig.Name,
 		History:      completeHistory,
 		Index:        0,
+		Retrieval:    selectedAIConfig.Retrieval,
 	}
 
-	fullAIReply, err := h.chatService.ProcessMessageStream(userChatMessage, serviceReq, c.Writer, additionalContextHeader)
 	// ProcessMessageStream is expected to handle its own errors by writing to the SSE stream.
 	// If ProcessMessageStream itself fails catastrophically before starting the stream (e.g., context creation fails),
 	// it should return an error.
-	err := h.chatService.ProcessMessageStream(userChatMessage, serviceReq, c.Writer, additionalContextHeader)
+	fullAIReply, err := h.chatService.ProcessMessageStream(userChatMessage, serviceReq, c.Writer, additionalContextHeader)
 	if err != nil {
 		// This error is tricky. If stream has started, headers are sent.
 		// If stream hasn't started, we can send a normal HTTP error.
@@ -295,14 +393,45 @@ func (h *APIHandler) ChatHandler(c *gin.Context) {
 		// If it *can* fail before streaming, then SendJSONError might be appropriate *if caught before headers are flushed*.
 	}
 
+	// Other open sessions of the user show the reply as well
+	if err == nil && strings.TrimSpace(fullAIReply) != "" {
+		h.publishEvent(models.EventMessageCreated, clientReq.UserID, gin.H{"role": "assistant", "name": selectedAIConfig.Name, "agent_id": selectedAIConfig.ID, "content": fullAIReply})
+	}
+
+	// Store the streamed reply as the assessment summary. A partial reply from a broken stream is not stored;
+	// the summary can be regenerated later.
+	if completedAssessment != nil {
+		if err != nil || strings.TrimSpace(fullAIReply) == "" {
+			log.Printf("WARN: Assessment summary for user '%s' (assessment ID %d) was not generated completely; not storing it.", clientReq.UserID, completedAssessment.ID)
+		} else if _, summaryErr := h.assessmentService.SaveSummary(completedAssessment.ID, fullAIReply); summaryErr != nil {
+			log.Printf("ERROR: Failed to store assessment summary for user '%s' (assessment ID %d): %v", clientReq.UserID, completedAssessment.ID, summaryErr)
+		}
+	}
+
+	// The reply must link to the created plan; append the link if the agent left it out. Rejected plans are not linked.
+	if createdPlan != nil && createdPlan.Status != models.PlanStatusRejected {
+		link := services.PlanLink(createdPlan.ID)
+		if !strings.Contains(fullAIReply, link) {
+			sendContentEvent(c, fmt.Sprintf("\n\n查看计划：%s", link))
+		}
+		sendPlanCreatedEvent(c, createdPlan.ID, link)
+	}
+
 	// Increment quota if the message stream processing was initiated successfully.
 	// Note: This doesn't mean the AI reply was successful, only that the user's message was processed to the point of starting a stream.
 	if isGuest && h.quotaRepo != nil {
-		if _, quotaErr := h.quotaRepo.IncrementQuota(clientReq.UserID); quotaErr != nil {
+		if quota, quotaErr := h.quotaRepo.IncrementQuota(clientReq.UserID); quotaErr != nil {
 			log.Printf("ERROR: Failed to increment quota for guest %s after message processing: %v", clientReq.UserID, quotaErr)
 			// This is an internal error, not directly reported to client here as main interaction might be ongoing/done.
 		} else {
 			log.Printf("INFO: Incremented chat quota for guest user %s.", clientReq.UserID)
+			if quota != nil {
+				h.publishEvent(models.EventQuotaUpdated, clientReq.UserID, gin.H{"messages_sent": quota.MessagesSent, "quota": config.AppConfig.GuestChatQuota})
+			}
+			// Published once: further messages are refused before they get here
+			if quota != nil && quota.MessagesSent == config.AppConfig.GuestChatQuota {
+				h.publishEvent(models.EventQuotaExhausted, clientReq.UserID, gin.H{"messages_sent": quota.MessagesSent, "quota": config.AppConfig.GuestChatQuota})
+			}
 		}
 	}
 	// saveAIMessage is called within ProcessMessageStream by the OpenAI client part.
@@ -368,6 +497,70 @@ func sendInfoEvent(c *gin.Context, eventType string, message string) {
 		log.Printf("写入信息SSE事件到客户端失败: %v", err)
 	}
 }
+
+// sendContentEvent writes a reply fragment in the same format as the streamed AI reply.
+func sendContentEvent(c *gin.Context, content string) {
+	data := fmt.Sprintf("data: {\"content\": \"%s\"}\n\n", escapeJSONString(content))
+	if _, err := c.Writer.Write([]byte(data)); err == nil {
+		c.Writer.Flush()
+	} else {
+		log.Printf("写入内容SSE事件到客户端失败: %v", err)
+	}
+}
+
+// sendPlanCreatedEvent tells the client which plan the planner agent just created.
+func sendPlanCreatedEvent(c *gin.Context, planID uint, link string) {
+	data := fmt.Sprintf("data: {\"event\": \"plan_created\", \"plan_id\": %d, \"link\": \"%s\"}\n\n", planID, escapeJSONString(link))
+	if _, err := c.Writer.Write([]byte(data)); err == nil {
+		c.Writer.Flush()
+	} else {
+		log.Printf("写入计划SSE事件到客户端失败: %v", err)
+	}
+}
+
+// publishEvent publishes an application event, e.g. for the webhooks, if the event bus is configured.
+func (h *APIHandler) publishEvent(eventType, userID string, data interface{}) {
+	if h.events == nil {
+		return
+	}
+	h.events.Publish(models.Event{Type: eventType, UserID: userID, Data: data})
+}
+
+// planEventData describes a new plan in a plan.created event. Rejected plans are included with their status.
+func planEventData(plan *models.Plan) gin.H {
+	return gin.H{"plan_id": plan.ID, "title": plan.Title, "status": plan.Status, "link": services.PlanLink(plan.ID)}
+}
+
+// publishPlanUpdated publishes a plan.updated event, so that the user's other sessions reload the plan.
+func (h *APIHandler) publishPlanUpdated(userID string, planID uint, action string) {
+	h.publishEvent(models.EventPlanUpdated, userID, gin.H{"plan_id": planID, "action": action, "link": services.PlanLink(planID)})
+}
+
+// planCreatedContext tells the planner agent about the plan that was just saved and its safety review outcome,
+// so its reply can present it.
+func planCreatedContext(plan *models.Plan) string {
+	var sb strings.Builder
+	if plan.Status == models.PlanStatusRejected {
+		sb.WriteString("\n\n[系统指令：计划未通过安全审核]\n")
+		sb.WriteString(fmt.Sprintf("为用户生成的计划《%s》未通过健康安全审核，不会生效。审核说明：%s\n", plan.Title, plan.ReviewNotes))
+		sb.WriteString("请温和地向用户解释原因，不要提供被驳回的内容，并建议用户咨询医生或调整需求后再试。\n")
+		return sb.String()
+	}
+	sb.WriteString("\n\n[系统指令：计划已创建]\n")
+	sb.WriteString(fmt.Sprintf("已根据用户的需求创建并保存计划《%s》（计划ID：%d）。任务如下：\n", plan.Title, plan.ID))
+	for _, task := range plan.Tasks {
+		sb.WriteString(fmt.Sprintf("%d. %s（%s，%s）\n", task.Order, task.Title, task.Frequency, task.Duration))
+	}
+	if plan.Status == models.PlanStatusPending {
+		sb.WriteString("该计划仍在等待健康安全审核，审核通过后才会生效，请告知用户。\n")
+	}
+	if plan.ReviewNotes != "" {
+		sb.WriteString(fmt.Sprintf("安全审核说明（请向用户说明）：%s\n", plan.ReviewNotes))
+	}
+	sb.WriteString(fmt.Sprintf("请简要介绍这份计划的重点和执行建议，不要重新制定计划，并在回复末尾附上计划链接：%s\n", services.PlanLink(plan.ID)))
+	return sb.String()
+}
+
 // getAvailableAIs, saveAIMessage, isStreamClosedError would need to be defined here or made accessible.
 // For brevity, they are not fully re-implemented in this snippet.
 // These functions were originally in `src/api/chat.go`.
@@ -377,27 +570,44 @@ func sendInfoEvent(c *gin.Context, eventType string, message string) {
 
 // GeneratePlanHandler handles requests to generate a new plan for a user.
 // POST /api/plan/generate
-// Request body: { "user_id": "string" }
+// Request body: { "user_id": "string", "mode": "rules|planner", "request": "string" }
+// The default "rules" mode applies the configured plan rules; "planner" asks the planner agent for a personalized plan.
 func (h *APIHandler) GeneratePlanHandler(c *gin.Context) {
 	var req struct {
-		UserID string `json:"user_id" binding:"required"`
+		UserID  string `json:"user_id" binding:"required"`
+		Mode    string `json:"mode"`
+		Request string `json:"request"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request format.", err)
 		return
 	}
 
-	if h.planService == nil {
-		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+	var plan *models.Plan
+	var err error
+	switch req.Mode {
+	case "", "rules":
+		if h.planService == nil {
+			utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+			return
+		}
+		plan, err = h.planService.GeneratePlan(req.UserID)
+	case "planner":
+		if h.plannerService == nil {
+			utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("plannerservice not initialized"))
+			return
+		}
+		plan, err = h.plannerService.GeneratePlan(req.UserID, req.Request)
+	default:
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid mode. Use 'rules' or 'planner'.", nil)
 		return
 	}
-
-	plan, err := h.planService.GeneratePlan(req.UserID)
 	if err != nil {
 		// Specific error handling can be added here if service returns custom error types
 		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to generate plan.", err)
 		return
 	}
+	h.publishEvent(models.EventPlanCreated, req.UserID, planEventData(plan))
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
@@ -465,6 +675,15 @@ func (h *APIHandler) GetPlanDetailsHandler(c *gin.Context) {
 	    utils.SendJSONError(c, http.StatusNotFound, "Plan not found.", nil)
 	    return
 	}
+	progress, err := h.planService.GetPlanProgress(planID)
+	if err != nil {
+		log.Printf("WARN: Failed to build progress for plan ID %d; returning the plan without it: %v", planID, err)
+	} else {
+		plan.Progress = progress
+	}
+	if h.exerciseService != nil { // Exercise tasks show how to do their exercise
+		h.exerciseService.AttachExercises(plan.Tasks)
+	}
 
 
 	c.JSON(http.StatusOK, gin.H{
@@ -474,9 +693,128 @@ func (h *APIHandler) GetPlanDetailsHandler(c *gin.Context) {
 	})
 }
 
-// CompleteTaskHandler handles requests to mark a task as completed.
+// GetPlanReviewsHandler returns the safety reviews of a plan, oldest first.
+// GET /api/plan/:planID/reviews
+func (h *APIHandler) GetPlanReviewsHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	if h.planSafetyService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("plansafetyservice not initialized"))
+		return
+	}
+
+	reviews, err := h.planSafetyService.GetReviews(planID)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to fetch plan reviews.", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan reviews retrieved successfully",
+		"data":    reviews,
+	})
+}
+
+// GetTodayTasksHandler lists the occurrences of a plan's tasks due today, with their check-in state.
+// GET /api/plan/:planID/today?timezone=Asia/Shanghai
+func (h *APIHandler) GetTodayTasksHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	if h.planService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+		return
+	}
+
+	due, err := h.planService.GetDueTasks(planID, c.Query("timezone"), time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.SendJSONError(c, http.StatusNotFound, "Plan not found.", err)
+		} else {
+			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to fetch today's tasks.", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Today's tasks retrieved successfully",
+		"data":    due,
+	})
+}
+
+// GetPlanReportHandler returns the check-in report of a plan: completion and feedback, overall and per task.
+// GET /api/plan/:planID/report
+func (h *APIHandler) GetPlanReportHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	if h.planService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+		return
+	}
+
+	progress, err := h.planService.GetPlanProgress(planID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			utils.SendJSONError(c, http.StatusNotFound, "Plan not found.", err)
+		} else {
+			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to build the plan report.", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan report generated successfully",
+		"data":    progress,
+	})
+}
+
+// GetPlanCheckInsHandler returns the check-in history of a plan's tasks.
+// GET /api/plan/:planID/checkins
+func (h *APIHandler) GetPlanCheckInsHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	if h.planService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+		return
+	}
+
+	checkIns, err := h.planService.GetCheckIns(planID)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to fetch check-ins.", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Check-ins retrieved successfully",
+		"data":    checkIns,
+	})
+}
+
+// checkInRequest is the request body of the complete and skip endpoints.
+type checkInRequest struct {
+	UserID string `json:"user_id" binding:"required"` // For authorization
+	models.CheckInInput
+}
+
+// CompleteTaskHandler handles requests to mark today's occurrence of a task as completed.
 // POST /api/plan/task/:taskID/complete
-// Request body: { "user_id": "string" } (for authorization)
+// Request body: { "user_id": "string", "timezone": "string", "note": "string", "completed_amount": number, "target_amount": number,
+// "feedback": { "fatigue": 1-5, "mood": 1-5, "perceived_effect": 1-5, "tags": ["string"] } }, all but user_id optional
 func (h *APIHandler) CompleteTaskHandler(c *gin.Context) {
 	taskIDStr := c.Param("taskID")
 	taskID, err := parseUint(taskIDStr)
@@ -485,9 +823,7 @@ func (h *APIHandler) CompleteTaskHandler(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		UserID string `json:"user_id" binding:"required"`
-	}
+	var req checkInRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
 		return
@@ -498,29 +834,34 @@ func (h *APIHandler) CompleteTaskHandler(c *gin.Context) {
 		return
 	}
 
-	updatedTask, err := h.planService.MarkTaskCompleted(taskID, req.UserID)
+	checkIn, err := h.planService.MarkTaskCompleted(taskID, req.UserID, req.CheckInInput, time.Now())
 	if err != nil {
 		// Example of more granular error handling based on error content
 		if strings.Contains(strings.ToLower(err.Error()), "not found") {
 			utils.SendJSONError(c, http.StatusNotFound, "Task or related plan not found.", err)
 		} else if strings.Contains(strings.ToLower(err.Error()), "unauthorized") {
 			utils.SendJSONError(c, http.StatusForbidden, "You are not authorized to complete this task.", err)
+		} else if strings.Contains(err.Error(), "not due") || strings.Contains(err.Error(), "not active") {
+			utils.SendJSONError(c, http.StatusBadRequest, "This task cannot be checked in today.", err)
+		} else if strings.HasPrefix(err.Error(), "invalid check-in") {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid check-in details.", err)
 		} else {
 			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to complete task.", err)
 		}
 		return
 	}
+	h.publishEvent(models.EventTaskCompleted, req.UserID, checkIn)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Task marked as completed",
-		"data":    updatedTask,
+		"data":    checkIn,
 	})
 }
 
-// SkipTaskHandler handles requests to mark a task as skipped.
+// SkipTaskHandler handles requests to mark today's occurrence of a task as skipped.
 // POST /api/plan/task/:taskID/skip
-// Request body: { "user_id": "string" } (for authorization)
+// Request body: as for CompleteTaskHandler
 func (h *APIHandler) SkipTaskHandler(c *gin.Context) {
 	taskIDStr := c.Param("taskID")
 	taskID, err := parseUint(taskIDStr)
@@ -529,9 +870,7 @@ func (h *APIHandler) SkipTaskHandler(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		UserID string `json:"user_id" binding:"required"`
-	}
+	var req checkInRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
 		return
@@ -542,7 +881,7 @@ func (h *APIHandler) SkipTaskHandler(c *gin.Context) {
 		return
 	}
 
-	updatedTask, err := h.planService.MarkTaskSkipped(taskID, req.UserID)
+	checkIn, err := h.planService.MarkTaskSkipped(taskID, req.UserID, req.CheckInInput, time.Now())
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "not found") {
 			utils.SendJSONError(c, http.StatusNotFound, "Task or related plan not found.", err)
@@ -550,16 +889,69 @@ func (h *APIHandler) SkipTaskHandler(c *gin.Context) {
 			utils.SendJSONError(c, http.StatusForbidden, "You are not authorized to skip this task.", err)
 		} else if strings.Contains(strings.ToLower(err.Error()), "already completed") {
 			utils.SendJSONError(c, http.StatusBadRequest, "Cannot skip an already completed task.", err)
+		} else if strings.Contains(err.Error(), "not due") || strings.Contains(err.Error(), "not active") {
+			utils.SendJSONError(c, http.StatusBadRequest, "This task cannot be checked in today.", err)
+		} else if strings.HasPrefix(err.Error(), "invalid check-in") {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid check-in details.", err)
 		} else {
 			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to skip task.", err)
 		}
 		return
 	}
+	h.publishPlanUpdated(req.UserID, checkIn.PlanID, "skip_task")
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Task marked as skipped",
-		"data":    updatedTask,
+		"data":    checkIn,
+	})
+}
+
+
+// PartialTaskHandler handles requests to mark today's occurrence of a task as partially completed.
+// POST /api/plan/task/:taskID/partial
+// Request body: as for CompleteTaskHandler; completed_amount is required
+func (h *APIHandler) PartialTaskHandler(c *gin.Context) {
+	taskID, err := parseUint(c.Param("taskID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TaskID parameter.", err)
+		return
+	}
+
+	var req checkInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+
+	if h.planService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+		return
+	}
+
+	checkIn, err := h.planService.MarkTaskPartial(taskID, req.UserID, req.CheckInInput, time.Now())
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			utils.SendJSONError(c, http.StatusNotFound, "Task or related plan not found.", err)
+		} else if strings.Contains(strings.ToLower(err.Error()), "unauthorized") {
+			utils.SendJSONError(c, http.StatusForbidden, "You are not authorized to check in this task.", err)
+		} else if strings.Contains(err.Error(), "already completed") {
+			utils.SendJSONError(c, http.StatusBadRequest, "Today's occurrences of this task are already completed.", err)
+		} else if strings.Contains(err.Error(), "not due") || strings.Contains(err.Error(), "not active") {
+			utils.SendJSONError(c, http.StatusBadRequest, "This task cannot be checked in today.", err)
+		} else if strings.HasPrefix(err.Error(), "invalid check-in") {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid check-in details.", err)
+		} else {
+			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to record partial completion.", err)
+		}
+		return
+	}
+	h.publishPlanUpdated(req.UserID, checkIn.PlanID, "partial_task")
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Task marked as partially completed",
+		"data":    checkIn,
 	})
 }
 
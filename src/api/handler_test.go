@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"project/services"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockAssessmentRepository is a mock type for the AssessmentRepository interface
+type mockAssessmentRepository struct {
+	mock.Mock
+}
+
+func (m *mockAssessmentRepository) CreateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {
+	args := m.Called(assessment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserAssessment), args.Error(1)
+}
+
+func (m *mockAssessmentRepository) GetUserAssessmentByID(id uint) (*models.UserAssessment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserAssessment), args.Error(1)
+}
+
+func (m *mockAssessmentRepository) GetUserAssessmentByUserID(userID string, statusFilter ...models.UserAssessmentStatus) (*models.UserAssessment, error) {
+	args := m.Called(userID, statusFilter[0]) // The handler and service always filter by one status
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserAssessment), args.Error(1)
+}
+
+func (m *mockAssessmentRepository) UpdateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {
+	args := m.Called(assessment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserAssessment), args.Error(1)
+}
+
+func (m *mockAssessmentRepository) ListUserAssessments(userID string, statusFilter ...models.UserAssessmentStatus) ([]*models.UserAssessment, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.UserAssessment), args.Error(1)
+}
+
+// mockSchedulerService is a mock type for the SchedulerService interface
+type mockSchedulerService struct {
+	mock.Mock
+}
+
+func (m *mockSchedulerService) ScheduleAIResponses(userID string, message string, history []models.ChatMessage, availableAIs []*config.LLMCharacter) ([]string, error) {
+	args := m.Called(userID, message)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// mockChatService is a mock type for the ChatService interface. ProcessMessageStream streams its reply as one
+// content event.
+type mockChatService struct {
+	mock.Mock
+}
+
+func (m *mockChatService) ProcessMessageStream(currentUserMessage models.ChatMessage, req services.ChatRequest, writer http.ResponseWriter, additionalContextHeader string) (string, error) {
+	args := m.Called(req.AIName, additionalContextHeader)
+	reply := args.String(0)
+	writer.Write([]byte("data: {\"content\": \"" + escapeJSONString(reply) + "\"}\n\n"))
+	return reply, args.Error(1)
+}
+
+func (m *mockChatService) GetChatHistory(userID string) ([]models.ChatMessage, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ChatMessage), args.Error(1)
+}
+
+func TestChatHandler_AssessmentAgentRepliesWhenRetakeIsTooSoon(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig.LLMCharacters = []*config.LLMCharacter{{ID: ProfileAssessmentAgentID, Name: "评估师"}}
+	config.AppConfig.LLMGroups = []*config.LLMGroup{{ID: "default", Members: []string{ProfileAssessmentAgentID}}}
+	config.AppConfig.AssessmentRetake.MinIntervalDays = 30
+	config.AppConfig.AssessmentRetake.ProgressAgentIDs = nil
+
+	userID := "user_retake"
+	completedAt := time.Now().AddDate(0, 0, -3)
+	nextAllowed := completedAt.AddDate(0, 0, 30).Format("2006-01-02")
+	completed := &models.UserAssessment{ID: 1, UserID: userID, Status: models.AssessmentStatusCompleted, CompletedAt: &completedAt}
+
+	assessmentRepo := new(mockAssessmentRepository)
+	assessmentRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(nil, nil)
+	assessmentRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusCompleted).Return(completed, nil)
+	schedulerService := new(mockSchedulerService)
+	schedulerService.On("ScheduleAIResponses", userID, "我想再做一次评估").Return([]string{ProfileAssessmentAgentID}, nil)
+	chatService := new(mockChatService)
+	chatService.On("ProcessMessageStream", "评估师", mock.MatchedBy(func(header string) bool {
+		return strings.Contains(header, "[系统指令：评估已完成]") && strings.Contains(header, nextAllowed)
+	})).Return("你的上次评估才完成不久，"+nextAllowed+" 起就可以重新评估了。", nil)
+	chatRepo := repository.NewChatRepository()
+
+	h := &APIHandler{
+		chatRepo:          chatRepo,
+		assessmentRepo:    assessmentRepo,
+		assessmentService: services.NewAssessmentService(assessmentRepo, nil),
+		schedulerService:  schedulerService,
+		chatService:       chatService,
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message": "我想再做一次评估", "user_id": "user_retake"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	h.ChatHandler(c)
+
+	chatService.AssertExpectations(t)
+	assert.Contains(t, w.Body.String(), nextAllowed+" 起就可以重新评估了")
+	assert.NotContains(t, w.Body.String(), `"error"`)
+	history, err := chatRepo.GetMessagesByUserID(userID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1, "Only the user's message is stored; the agent's reply is not an error message")
+	assessmentRepo.AssertNotCalled(t, "CreateUserAssessment", mock.Anything)
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"project/models"
+	"project/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Notification Handlers ---
+
+// notificationServiceReady reports whether the notification service is available, sending an error if not.
+func (h *APIHandler) notificationServiceReady(c *gin.Context) bool {
+	if h.notificationService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("notificationservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// GetNotificationPreferencesHandler returns a user's notification preferences, or the defaults if they have not
+// saved any.
+// GET /api/notifications/preferences?user_id=xxx
+func (h *APIHandler) GetNotificationPreferencesHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	if !h.notificationServiceReady(c) {
+		return
+	}
+
+	prefs, err := h.notificationService.GetPreferences(userID)
+	if err != nil {
+		sendServiceError(c, err, "notification preferences", "Failed to retrieve notification preferences.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Notification preferences retrieved successfully",
+		"data":    prefs,
+	})
+}
+
+// UpdateNotificationPreferencesHandler changes a user's notification preferences. Omitted fields are left
+// unchanged; empty quiet hours turn them off.
+// PUT /api/notifications/preferences
+// Request body: { "user_id": "string", "timezone": "Asia/Shanghai", "quiet_hours_start": "22:30",
+// "quiet_hours_end": "08:00", "exercise": true, "habit": true, "knowledge": true, "report": true,
+// "product_recommendation": false, "max_reminders_per_day": 5 }
+func (h *APIHandler) UpdateNotificationPreferencesHandler(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		models.NotificationPreferencesInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.notificationServiceReady(c) {
+		return
+	}
+
+	prefs, err := h.notificationService.UpdatePreferences(req.UserID, req.NotificationPreferencesInput)
+	if err != nil {
+		sendServiceError(c, err, "notification preferences", "Failed to update notification preferences.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Notification preferences updated successfully",
+		"data":    prefs,
+	})
+}
+
+// ListNotificationsHandler returns a page of a user's notification inbox, newest first, with their unread count.
+// GET /api/notifications?user_id=xxx&unread=true&before=123&limit=20
+// before is the next_before of the previous page.
+func (h *APIHandler) ListNotificationsHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	var err error
+	unreadOnly := false
+	if value := c.Query("unread"); value != "" {
+		if unreadOnly, err = strconv.ParseBool(value); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid unread parameter, expected true or false.", err)
+			return
+		}
+	}
+	var beforeID uint
+	if value := c.Query("before"); value != "" {
+		if beforeID, err = parseUint(value); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid before parameter.", err)
+			return
+		}
+	}
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid limit parameter.", err)
+			return
+		}
+	}
+	if !h.notificationServiceReady(c) {
+		return
+	}
+
+	inbox, err := h.notificationService.ListInbox(userID, unreadOnly, beforeID, limit)
+	if err != nil {
+		sendServiceError(c, err, "notification", "Failed to retrieve notifications.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Notifications retrieved successfully",
+		"data":    inbox,
+	})
+}
+
+// GetUnreadNotificationCountHandler returns the number of a user's unread notifications.
+// GET /api/notifications/unread-count?user_id=xxx
+func (h *APIHandler) GetUnreadNotificationCountHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	if !h.notificationServiceReady(c) {
+		return
+	}
+
+	count, err := h.notificationService.UnreadCount(userID)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to count unread notifications.", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Unread notification count retrieved successfully",
+		"data":    gin.H{"unread_count": count},
+	})
+}
+
+// MarkNotificationReadHandler marks one of a user's notifications as read.
+// POST /api/notifications/:notificationID/read
+// Request body: { "user_id": "string" }
+func (h *APIHandler) MarkNotificationReadHandler(c *gin.Context) {
+	notificationID, err := parseUint(c.Param("notificationID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid NotificationID parameter.", err)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.notificationServiceReady(c) {
+		return
+	}
+
+	notification, err := h.notificationService.MarkRead(req.UserID, notificationID, time.Now())
+	if err != nil {
+		sendServiceError(c, err, "notification", "Failed to mark notification as read.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Notification marked as read",
+		"data":    notification,
+	})
+}
+
+// MarkAllNotificationsReadHandler marks all of a user's notifications as read.
+// POST /api/notifications/read-all
+// Request body: { "user_id": "string" }
+func (h *APIHandler) MarkAllNotificationsReadHandler(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.notificationServiceReady(c) {
+		return
+	}
+
+	marked, err := h.notificationService.MarkAllRead(req.UserID, time.Now())
+	if err != nil {
+		sendServiceError(c, err, "notification", "Failed to mark notifications as read.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Notifications marked as read",
+		"data":    gin.H{"marked": marked},
+	})
+}
@@ -0,0 +1,467 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"project/models"
+	"project/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Plan Lifecycle Handlers ---
+
+// sendPlanError maps a plan service error to an HTTP status.
+func sendPlanError(c *gin.Context, err error, fallback string) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(strings.ToLower(msg), "not found"):
+		utils.SendJSONError(c, http.StatusNotFound, "Plan or task not found.", err)
+	case strings.Contains(msg, "unauthorized"):
+		utils.SendJSONError(c, http.StatusForbidden, "You are not authorized to modify this plan.", err)
+	case strings.HasPrefix(msg, "invalid"):
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request.", err)
+	case strings.HasPrefix(msg, "cannot") || strings.Contains(msg, "can no longer be edited"):
+		utils.SendJSONError(c, http.StatusConflict, "This action is not allowed for the plan's current status.", err)
+	default:
+		utils.SendJSONError(c, http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// planServiceReady reports whether the plan service is available, sending an error if not.
+func (h *APIHandler) planServiceReady(c *gin.Context) bool {
+	if h.planService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("planservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// UpdatePlanHandler renames a plan or changes its description or date range.
+// PATCH /api/plan/:planID
+// Request body: { "user_id": "string", "title": "string", "description": "string", "start_date": "YYYY-MM-DD", "end_date": "YYYY-MM-DD" }
+func (h *APIHandler) UpdatePlanHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		models.PlanUpdateInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	plan, err := h.planService.UpdatePlan(planID, req.UserID, req.PlanUpdateInput)
+	if err != nil {
+		sendPlanError(c, err, "Failed to update plan.")
+		return
+	}
+	h.publishPlanUpdated(req.UserID, plan.ID, "update")
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan updated successfully",
+		"data":    plan,
+	})
+}
+
+// AddPlanTaskHandler adds a task to a plan.
+// POST /api/plan/:planID/tasks
+// Request body: { "user_id": "string", "title": "string", "type": "string", "description": "string", "frequency": "string", "duration": "string",
+// "exercise_id": 3 }
+// exercise_id must be an approved exercise of the library, for exercise tasks.
+func (h *APIHandler) AddPlanTaskHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		models.PlanTaskInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	task, err := h.planService.AddTask(planID, req.UserID, req.PlanTaskInput)
+	if err != nil {
+		sendPlanError(c, err, "Failed to add task.")
+		return
+	}
+	h.publishPlanUpdated(req.UserID, task.PlanID, "add_task")
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Task added successfully",
+		"data":    task,
+	})
+}
+
+// ReorderPlanTasksHandler sets the order of a plan's tasks.
+// PUT /api/plan/:planID/tasks/order
+// Request body: { "user_id": "string", "task_ids": [3, 1, 2] }
+func (h *APIHandler) ReorderPlanTasksHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	var req struct {
+		UserID  string `json:"user_id" binding:"required"`
+		TaskIDs []uint `json:"task_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id and task_ids are required.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	plan, err := h.planService.ReorderTasks(planID, req.UserID, req.TaskIDs)
+	if err != nil {
+		sendPlanError(c, err, "Failed to reorder tasks.")
+		return
+	}
+	h.publishPlanUpdated(req.UserID, plan.ID, "reorder_tasks")
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Tasks reordered successfully",
+		"data":    plan,
+	})
+}
+
+// UpdatePlanTaskHandler edits a task.
+// PATCH /api/plan/task/:taskID
+// Request body: as for AddPlanTaskHandler; omitted fields are left unchanged, exercise_id 0 clears the exercise
+func (h *APIHandler) UpdatePlanTaskHandler(c *gin.Context) {
+	taskID, err := parseUint(c.Param("taskID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TaskID parameter.", err)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		models.PlanTaskInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	task, err := h.planService.UpdateTask(taskID, req.UserID, req.PlanTaskInput)
+	if err != nil {
+		sendPlanError(c, err, "Failed to update task.")
+		return
+	}
+	h.publishPlanUpdated(req.UserID, task.PlanID, "update_task")
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Task updated successfully",
+		"data":    task,
+	})
+}
+
+// DeletePlanTaskHandler removes a task from its plan.
+// DELETE /api/plan/task/:taskID?user_id=string
+func (h *APIHandler) DeletePlanTaskHandler(c *gin.Context) {
+	taskID, err := parseUint(c.Param("taskID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TaskID parameter.", err)
+		return
+	}
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	if err := h.planService.DeleteTask(taskID, userID); err != nil {
+		sendPlanError(c, err, "Failed to delete task.")
+		return
+	}
+	// The task is gone, so its plan is not known here; sessions reload the user's plans
+	h.publishEvent(models.EventPlanUpdated, userID, gin.H{"task_id": taskID, "action": "delete_task"})
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Task deleted successfully",
+		"data":    nil,
+	})
+}
+
+// planStatusActions maps the status action routes to the status they move a plan to.
+var planStatusActions = map[string]models.PlanStatus{
+	"pause":    models.PlanStatusPaused,
+	"resume":   models.PlanStatusActive,
+	"cancel":   models.PlanStatusCancelled,
+	"complete": models.PlanStatusCompleted,
+}
+
+// PlanStatusActionHandler pauses, resumes, cancels or completes a plan, or archives a finished one.
+// POST /api/plan/:planID/pause | /resume | /cancel | /complete | /archive
+// Request body: { "user_id": "string" }
+func (h *APIHandler) PlanStatusActionHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	action := path.Base(c.FullPath())
+	target, known := planStatusActions[action]
+	if !known && action != "archive" {
+		utils.SendJSONError(c, http.StatusNotFound, "Unknown plan action.", nil)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	if action == "archive" {
+		if err := h.planService.ArchivePlan(planID, req.UserID); err != nil {
+			sendPlanError(c, err, "Failed to archive plan.")
+			return
+		}
+		h.publishPlanUpdated(req.UserID, planID, "archive")
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "Plan archived successfully",
+			"data":    nil,
+		})
+		return
+	}
+
+	plan, err := h.planService.TransitionPlan(planID, req.UserID, target)
+	if err != nil {
+		sendPlanError(c, err, "Failed to change plan status.")
+		return
+	}
+	h.publishPlanUpdated(req.UserID, plan.ID, action)
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan is now " + string(plan.Status),
+		"data":    plan,
+	})
+}
+
+// GetPlanVersionsHandler lists the version history of a plan.
+// GET /api/plan/:planID/versions
+func (h *APIHandler) GetPlanVersionsHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	versions, err := h.planService.GetPlanVersions(planID)
+	if err != nil {
+		sendPlanError(c, err, "Failed to fetch plan versions.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan versions retrieved successfully",
+		"data":    versions,
+	})
+}
+
+// DiffPlanVersionsHandler compares two versions of a plan.
+// GET /api/plan/:planID/versions/diff?from=1&to=3
+func (h *APIHandler) DiffPlanVersionsHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: from and to must be version numbers.", errors.Join(errFrom, errTo))
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	diff, err := h.planService.DiffPlanVersions(planID, from, to)
+	if err != nil {
+		sendPlanError(c, err, "Failed to compare plan versions.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan versions compared successfully",
+		"data":    diff,
+	})
+}
+
+// RollbackPlanHandler restores a plan's content to an earlier version.
+// POST /api/plan/:planID/versions/:version/rollback
+// Request body: { "user_id": "string", "reason": "string" }
+func (h *APIHandler) RollbackPlanHandler(c *gin.Context) {
+	planID, err := parseUint(c.Param("planID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid PlanID parameter.", err)
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid version parameter.", err)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		Reason string `json:"reason"` // Optional; recorded in the new version
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.planServiceReady(c) {
+		return
+	}
+
+	plan, err := h.planService.RollbackPlan(planID, req.UserID, version, req.Reason)
+	if err != nil {
+		sendPlanError(c, err, "Failed to roll back plan.")
+		return
+	}
+	h.publishPlanUpdated(req.UserID, plan.ID, "rollback")
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan rolled back successfully",
+		"data":    plan,
+	})
+}
+
+// --- Plan Template Handlers ---
+
+// planTemplateServiceReady reports whether the plan template service is available, sending an error if not.
+func (h *APIHandler) planTemplateServiceReady(c *gin.Context) bool {
+	if h.planTemplateService == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("plantemplateservice not initialized"))
+		return false
+	}
+	return true
+}
+
+// ListPlanTemplatesHandler lists the published plan templates. With user_id, every template comes with the goals
+// it shares with the user's latest assessment, best matches first.
+// GET /api/plan/templates?user_id=xxx
+func (h *APIHandler) ListPlanTemplatesHandler(c *gin.Context) {
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		matches, err := h.planTemplateService.MatchTemplates(userID)
+		if err != nil {
+			utils.SendJSONError(c, http.StatusInternalServerError, "Failed to match plan templates.", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "Plan templates matched successfully",
+			"data":    matches,
+		})
+		return
+	}
+
+	templates, err := h.planTemplateService.ListTemplates(true)
+	if err != nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "Failed to list plan templates.", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan templates retrieved successfully",
+		"data":    templates,
+	})
+}
+
+// GetPlanTemplateHandler returns a published plan template.
+// GET /api/plan/templates/:templateID
+func (h *APIHandler) GetPlanTemplateHandler(c *gin.Context) {
+	templateID, err := parseUint(c.Param("templateID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TemplateID parameter.", err)
+		return
+	}
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+
+	template, err := h.planTemplateService.GetTemplate(templateID, true)
+	if err != nil {
+		sendPlanError(c, err, "Failed to retrieve plan template.")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan template retrieved successfully",
+		"data":    template,
+	})
+}
+
+// InstantiatePlanTemplateHandler creates a personal plan from a published template. Like a generated plan, the
+// new plan is safety reviewed before it becomes active.
+// POST /api/plan/templates/:templateID/instantiate
+// Request body: { "user_id": "string", "start_date": "YYYY-MM-DD", "timezone": "Asia/Shanghai" }
+func (h *APIHandler) InstantiatePlanTemplateHandler(c *gin.Context) {
+	templateID, err := parseUint(c.Param("templateID"))
+	if err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid TemplateID parameter.", err)
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		models.PlanTemplateInstantiateInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", err)
+		return
+	}
+	if !h.planTemplateServiceReady(c) {
+		return
+	}
+
+	plan, err := h.planTemplateService.InstantiateTemplate(templateID, req.UserID, models.PlanVersionAuthorUser, req.PlanTemplateInstantiateInput, time.Now())
+	if err != nil {
+		sendPlanError(c, err, "Failed to create plan from template.")
+		return
+	}
+	h.publishEvent(models.EventPlanCreated, req.UserID, planEventData(plan))
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Plan created from template successfully",
+		"data":    plan,
+	})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"project/config"
+	"project/services"
+	"project/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Push Handlers ---
+
+// pushRetryMillis is how long clients wait before reconnecting a dropped push stream.
+const pushRetryMillis = 3000
+
+// pushHubReady reports whether the push hub is available, sending an error if not.
+func (h *APIHandler) pushHubReady(c *gin.Context) bool {
+	if h.pushHub == nil {
+		utils.SendJSONError(c, http.StatusInternalServerError, "System configuration error.", errors.New("pushhub not initialized"))
+		return false
+	}
+	return true
+}
+
+// PushStreamHandler opens the user's push channel: a long-lived SSE stream of their events, e.g. new assistant
+// messages, notifications, plan updates and quota changes, sent to every open session of the user. Each event has
+// an ID; a reconnecting client sends the last one it received, in the Last-Event-ID header as browsers do or the
+// last_event_id parameter, and first gets the events it missed. If they are no longer available, a resync event
+// tells it to reload its data.
+// GET /api/push/stream?user_id=xxx&last_event_id=123
+func (h *APIHandler) PushStreamHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		utils.SendJSONError(c, http.StatusBadRequest, "Invalid request: user_id is required.", nil)
+		return
+	}
+	lastEventIDParam := c.GetHeader("Last-Event-ID")
+	if lastEventIDParam == "" {
+		lastEventIDParam = c.Query("last_event_id")
+	}
+	var lastEventID uint64
+	if lastEventIDParam != "" {
+		parsed, err := strconv.ParseUint(lastEventIDParam, 10, 64)
+		if err != nil {
+			utils.SendJSONError(c, http.StatusBadRequest, "Invalid last event ID.", err)
+			return
+		}
+		lastEventID = parsed
+	}
+	if !h.pushHubReady(c) {
+		return
+	}
+
+	session, backlog, resync := h.pushHub.Connect(userID, lastEventID)
+	defer h.pushHub.Disconnect(session)
+	log.Printf("INFO: Push stream opened for user '%s' (last event ID %d, %d missed events).", userID, lastEventID, len(backlog))
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !writePushFrame(c, fmt.Sprintf("retry: %d\n\n", pushRetryMillis)) {
+		return
+	}
+	if resync != 0 && !writePushFrame(c, fmt.Sprintf("id: %d\nevent: resync\ndata: {}\n\n", resync)) {
+		return
+	}
+	for _, message := range backlog {
+		if !writePushMessage(c, message) {
+			return
+		}
+	}
+
+	heartbeat := time.Duration(config.AppConfig.Push.HeartbeatSeconds) * time.Second
+	if heartbeat <= 0 {
+		heartbeat = 25 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			log.Printf("INFO: Push stream closed by user '%s'.", userID)
+			return
+		case message, open := <-session.Messages:
+			if !open {
+				// Dropped for falling behind; the client reconnects with its last event ID
+				log.Printf("WARN: Push stream of user '%s' fell behind and was closed.", userID)
+				return
+			}
+			if !writePushMessage(c, message) {
+				return
+			}
+		case <-ticker.C:
+			if !writePushFrame(c, ": ping\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// writePushMessage writes a push message as an SSE event named after its event type.
+func writePushMessage(c *gin.Context, message services.PushMessage) bool {
+	data, err := json.Marshal(message.Event)
+	if err != nil {
+		log.Printf("ERROR: Failed to marshal push event %s: %v", message.Event.ID, err)
+		return true // Skipped; the stream itself is fine
+	}
+	return writePushFrame(c, fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", message.ID, message.Event.Type, data))
+}
+
+// writePushFrame writes raw SSE data to the client and flushes it, reporting whether the client is still there.
+func writePushFrame(c *gin.Context, frame string) bool {
+	if _, err := c.Writer.Write([]byte(frame)); err != nil {
+		log.Printf("WARN: Failed to write to push stream: %v", err)
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
@@ -32,6 +32,257 @@ type LLMCharacter struct {
 	Avatar       string   `json:"avatar"`      // Path to avatar image
 	CustomPrompt string   `mapstructure:"custom_prompt" json:"custom_prompt"`
 	Tags         []string `json:"tags"`
+	Retrieval    bool     `json:"retrieval"` // Knowledge agent: answers from retrieved knowledge base articles and cites them
+}
+
+// ScoringOption maps one answer option of a question to a score and an optional label.
+type ScoringOption struct {
+	Answer string  `mapstructure:"answer" json:"answer"`
+	Score  float64 `mapstructure:"score" json:"score"`
+	Label  string  `mapstructure:"label" json:"label"` // Human readable level, e.g. "sedentary"
+}
+
+// ScoringDimension derives a numeric dimension (e.g. activity_level) from a choice question.
+type ScoringDimension struct {
+	ID         string          `mapstructure:"id" json:"id"`
+	QuestionID string          `mapstructure:"question_id" json:"question_id"`
+	Options    []ScoringOption `mapstructure:"options" json:"options"`
+}
+
+// RiskFlagRule raises a risk flag when the answer to a question mentions one of the keywords.
+// If AnyAnswer is true, the flag is raised for any answer that is not in NoneAnswers.
+type RiskFlagRule struct {
+	Flag       string   `mapstructure:"flag" json:"flag"`
+	QuestionID string   `mapstructure:"question_id" json:"question_id"`
+	Keywords   []string `mapstructure:"keywords" json:"keywords"`
+	AnyAnswer  bool     `mapstructure:"any_answer" json:"any_answer"`
+}
+
+// AssessmentScoringConfig declares how a completed assessment is turned into an AssessmentProfile.
+type AssessmentScoringConfig struct {
+	Dimensions        []ScoringDimension `mapstructure:"dimensions" json:"dimensions"`
+	RiskFlags         []RiskFlagRule     `mapstructure:"risk_flags" json:"risk_flags"`
+	GoalQuestionID    string             `mapstructure:"goal_question_id" json:"goal_question_id"`       // Multi-choice question holding the user's goals
+	ConcernQuestionID string             `mapstructure:"concern_question_id" json:"concern_question_id"` // Multi-choice question holding the user's concerns
+	NoneAnswers       []string           `mapstructure:"none_answers" json:"none_answers"`               // Answers meaning "nothing to report", e.g. "None", "无"
+}
+
+// AssessmentRetakeConfig controls periodic assessment retakes and progress comparison.
+type AssessmentRetakeConfig struct {
+	MinIntervalDays  int      `mapstructure:"min_interval_days" json:"min_interval_days"`   // Minimum days between completed assessments; 0 disables the check
+	ProgressAgentIDs []string `mapstructure:"progress_agent_ids" json:"progress_agent_ids"` // Agents that receive the latest assessment comparison as context
+}
+
+// PlanTaskTemplate describes a task that plan rules can add to a generated plan.
+type PlanTaskTemplate struct {
+	ID          string `mapstructure:"id" json:"id"`
+	Type        string `mapstructure:"type" json:"type"` // exercise, habit, knowledge or generic
+	Title       string `mapstructure:"title" json:"title"`
+	Description string `mapstructure:"description" json:"description"`
+	Frequency   string `mapstructure:"frequency" json:"frequency"`
+	Duration    string `mapstructure:"duration" json:"duration"`
+	Progression string `mapstructure:"progression" json:"progression"` // Optional ID of an ExerciseProgression; the task starts at its level 1
+}
+
+// PlanRule adds task templates to a generated plan when the answer to a question matches.
+// A rule fires if a (resolved) answer equals one of Answers or contains one of Keywords, case-insensitively.
+type PlanRule struct {
+	ID         string   `mapstructure:"id" json:"id"`
+	QuestionID string   `mapstructure:"question_id" json:"question_id"`
+	Answers    []string `mapstructure:"answers" json:"answers"`
+	Keywords   []string `mapstructure:"keywords" json:"keywords"`
+	Templates  []string `mapstructure:"templates" json:"templates"` // IDs of PlanTaskTemplates to add
+}
+
+// PlanRulesConfig declares how GeneratePlan turns the latest completed assessment into plan tasks.
+type PlanRulesConfig struct {
+	PlanTitle       string             `mapstructure:"plan_title" json:"plan_title"`
+	PlanDescription string             `mapstructure:"plan_description" json:"plan_description"`
+	Templates       []PlanTaskTemplate `mapstructure:"templates" json:"templates"`
+	Rules           []PlanRule         `mapstructure:"rules" json:"rules"`
+	BaseTemplates   []string           `mapstructure:"base_templates" json:"base_templates"` // Added to every plan, after the tasks of fired rules
+}
+
+// PlannerConfig configures structured plan generation by the planner agent.
+type PlannerConfig struct {
+	AgentID         string   `mapstructure:"agent_id" json:"agent_id"`                 // Chat agent that creates plans, e.g. hs_planner_agent
+	Model           string   `mapstructure:"model" json:"model"`                       // Optional; defaults to the agent's model
+	SystemPrompt    string   `mapstructure:"system_prompt" json:"system_prompt"`       // Instructions for JSON plan output; the schema is appended
+	MaxAttempts     int      `mapstructure:"max_attempts" json:"max_attempts"`         // LLM calls before giving up on malformed output
+	MaxTasks        int      `mapstructure:"max_tasks" json:"max_tasks"`               // Extra tasks are dropped
+	TriggerKeywords []string `mapstructure:"trigger_keywords" json:"trigger_keywords"` // Chat messages to the agent containing one of these create a plan
+	PlanLinkFormat  string   `mapstructure:"plan_link_format" json:"plan_link_format"` // fmt format with the plan ID, e.g. "/plan/%d"
+}
+
+// PlanSafetyRule checks the tasks of a new plan against the user's assessment risk flags.
+// It applies to tasks matching any of TaskKeywords (in title or description, case-insensitive) and, if set, one of TaskTypes.
+
+type PlanSafetyRule struct {
+	ID           string           `mapstructure:"id" json:"id"`
+	RiskFlags    []string         `mapstructure:"risk_flags" json:"risk_flags"` // Rule applies if the profile has any of these
+	TaskTypes    []string         `mapstructure:"task_types" json:"task_types"`
+	TaskKeywords []string         `mapstructure:"task_keywords" json:"task_keywords"`
+	Action       string           `mapstructure:"action" json:"action"`           // adjust, remove or reject
+	Reason       string           `mapstructure:"reason" json:"reason"`           // Shown to the user and stored with the review
+	Replacement  PlanTaskTemplate `mapstructure:"replacement" json:"replacement"` // For adjust: non-empty fields replace the task's
+}
+
+// PlanLLMReviewConfig configures the optional LLM safety review that follows the rule checks.
+type PlanLLMReviewConfig struct {
+	Enabled      bool   `mapstructure:"enabled" json:"enabled"`
+	AgentID      string `mapstructure:"agent_id" json:"agent_id"`           // e.g. hs_health_safety_agent
+	Model        string `mapstructure:"model" json:"model"`                 // Optional; defaults to the agent's model
+	SystemPrompt string `mapstructure:"system_prompt" json:"system_prompt"` // Must ask for {"approved": bool, "reason": string}
+}
+
+// PlanSafetyConfig configures the review every new plan passes before it becomes active.
+type PlanSafetyConfig struct {
+	Rules     []PlanSafetyRule    `mapstructure:"rules" json:"rules"`
+	LLMReview PlanLLMReviewConfig `mapstructure:"llm_review" json:"llm_review"`
+	RetryCron string              `mapstructure:"retry_cron" json:"retry_cron"` // Schedule of the job retrying failed reviews of pending plans; "*/10 * * * *" if empty
+}
+
+// PlanScheduleConfig configures when plan tasks are due.
+type PlanScheduleConfig struct {
+	DefaultTimezone string `mapstructure:"default_timezone" json:"default_timezone"` // IANA name used when the user's timezone is unknown
+}
+
+// PlanLifecycleConfig configures how plans move between statuses.
+type PlanLifecycleConfig struct {
+	MaxActivePlans int `mapstructure:"max_active_plans" json:"max_active_plans"` // Active plans per user; activating another pauses the oldest. 0 means no limit
+}
+
+// CheckInConfig configures task check-ins.
+type CheckInConfig struct {
+	FeedbackTags []string `mapstructure:"feedback_tags" json:"feedback_tags"` // Tags users may attach to check-in feedback; any tag is accepted if empty
+}
+
+// ExerciseLevel is one stage of a progressive exercise. Empty fields keep the task's current value.
+type ExerciseLevel struct {
+	Title       string `mapstructure:"title" json:"title"`
+	Description string `mapstructure:"description" json:"description"`
+	Frequency   string `mapstructure:"frequency" json:"frequency"`
+	Duration    string `mapstructure:"duration" json:"duration"` // e.g. reps, hold time or minutes
+}
+
+// ProgressionCriteria decides when a task moves to another level, judged on its check-ins of the last WindowDays days.
+type ProgressionCriteria struct {
+	WindowDays       int      `mapstructure:"window_days" json:"window_days"`               // Evaluation window; a level must also be held this long before it can change
+	MinCompletedDays int      `mapstructure:"min_completed_days" json:"min_completed_days"` // Advance: days in the window with a completed check-in
+	MaxAvgFatigue    float64  `mapstructure:"max_avg_fatigue" json:"max_avg_fatigue"`       // Advance: average reported fatigue may not exceed this; 0 disables
+	DiscomfortTags   []string `mapstructure:"discomfort_tags" json:"discomfort_tags"`       // Feedback tags reporting discomfort; any in the window blocks advancing
+	RegressOnTags    int      `mapstructure:"regress_on_tags" json:"regress_on_tags"`       // Regress: check-ins with a discomfort tag, even before the window is up; 0 disables
+	RegressBelowDays int      `mapstructure:"regress_below_days" json:"regress_below_days"` // Regress: fewer completed days in the window than this; 0 disables
+}
+
+// ExerciseProgression is an exercise with ordered levels that plan tasks move through.
+type ExerciseProgression struct {
+	ID       string              `mapstructure:"id" json:"id"`
+	Keywords []string            `mapstructure:"keywords" json:"keywords"` // Exercise tasks whose title contains one of these follow this progression, e.g. planner-created ones
+	Levels   []ExerciseLevel     `mapstructure:"levels" json:"levels"`
+	Criteria ProgressionCriteria `mapstructure:"criteria" json:"criteria"`
+}
+
+// ProgressionConfig configures the periodic evaluation that promotes or regresses exercise tasks.
+type ProgressionConfig struct {
+	Enabled   bool                  `mapstructure:"enabled" json:"enabled"`
+	Cron      string                `mapstructure:"cron" json:"cron"`         // Schedule of the evaluation job when it is first registered; "0 4 * * *" if empty
+	AgentID   string                `mapstructure:"agent_id" json:"agent_id"` // Chat agent that tells the user about level changes
+	Exercises []ExerciseProgression `mapstructure:"exercises" json:"exercises"`
+}
+
+// CalendarConfig configures the iCalendar export and subscription feed of plans.
+type CalendarConfig struct {
+	ProductID      string            `mapstructure:"product_id" json:"product_id"`           // PRODID of exported calendars
+	EventMinutes   int               `mapstructure:"event_minutes" json:"event_minutes"`     // Length of each event; 15 if 0
+	DefaultTimes   []string          `mapstructure:"default_times" json:"default_times"`     // "HH:MM" start of the nth occurrence of a day without a time window
+	AlarmMinutes   int               `mapstructure:"alarm_minutes" json:"alarm_minutes"`     // Reminder this long before each event; no reminder if 0
+	Discreet       bool              `mapstructure:"discreet" json:"discreet"`               // Use discreet titles unless the user chooses otherwise
+	DiscreetTitles map[string]string `mapstructure:"discreet_titles" json:"discreet_titles"` // Event title per task type, "default" for the rest
+	FeedBaseURL    string            `mapstructure:"feed_base_url" json:"feed_base_url"`     // Public URL prefix of subscription feeds; the request's host if empty
+}
+
+// ReminderConfig configures the proactive reminders about plan tasks sent by the reminder agent.
+type ReminderConfig struct {
+	Enabled          bool   `mapstructure:"enabled" json:"enabled"`
+	Cron             string `mapstructure:"cron" json:"cron"`                           // Schedule of the reminder job when it is first registered; "*/5 * * * *" if empty
+	AgentID          string `mapstructure:"agent_id" json:"agent_id"`                   // Chat agent that sends the reminders, e.g. hs_reminder_agent
+	Model            string `mapstructure:"model" json:"model"`                         // Optional; defaults to the agent's model
+	UseLLM           bool   `mapstructure:"use_llm" json:"use_llm"`                     // Let the agent write each reminder; the templates are used if false or the call fails
+	SystemPrompt     string `mapstructure:"system_prompt" json:"system_prompt"`         // Instructions for LLM-written reminders
+	LeadMinutes      int    `mapstructure:"lead_minutes" json:"lead_minutes"`           // Remind this long before an occurrence starts; 15 if 0
+	FollowUpMinutes  int    `mapstructure:"follow_up_minutes" json:"follow_up_minutes"` // Follow up this long after an occurrence ended without a check-in; no follow-ups if 0
+	ReminderTemplate string `mapstructure:"reminder_template" json:"reminder_template"` // {task}, {time} and {description} are replaced
+	FollowUpTemplate string `mapstructure:"follow_up_template" json:"follow_up_template"`
+}
+
+// NotificationConfig holds the default notification preferences of users who have not set their own, and the
+// delivery of notifications deferred by quiet hours.
+type NotificationConfig struct {
+	QuietHoursStart        string `mapstructure:"quiet_hours_start" json:"quiet_hours_start"`             // "HH:MM"; no quiet hours by default if empty
+	QuietHoursEnd          string `mapstructure:"quiet_hours_end" json:"quiet_hours_end"`                 // May be earlier than the start, for quiet hours overnight
+	MaxPerDay              int    `mapstructure:"max_per_day" json:"max_per_day"`                         // Notifications delivered per user and day; unlimited if 0
+	ProductRecommendations bool   `mapstructure:"product_recommendations" json:"product_recommendations"` // Whether product recommendations are on by default; other topics always are
+	DeliverCron            string `mapstructure:"deliver_cron" json:"deliver_cron"`                       // Schedule of the job delivering deferred notifications; "*/5 * * * *" if empty
+}
+
+// JobsConfig configures the background job runner. The schedule of each job is stored with it and can be changed
+// through the admin API; the cron settings here are only used when a job is first registered.
+type JobsConfig struct {
+	Enabled       bool   `mapstructure:"enabled" json:"enabled"`
+	PollSeconds   int    `mapstructure:"poll_seconds" json:"poll_seconds"`     // How often the runner looks for due jobs; 30 if 0
+	LeaseMinutes  int    `mapstructure:"lease_minutes" json:"lease_minutes"`   // Longest a run may take before it is cancelled and another instance may take over; 60 if 0
+	RetentionDays int    `mapstructure:"retention_days" json:"retention_days"` // Execution history older than this is deleted; kept forever if 0
+	CleanupCron   string `mapstructure:"cleanup_cron" json:"cleanup_cron"`     // Schedule of the history cleanup job; "30 3 * * *" if empty
+}
+
+// PushConfig configures the per-user push channel, a long-lived SSE stream that carries the user's events to every
+// open session. Events are kept in memory for resuming; deployments with several instances need sticky sessions.
+type PushConfig struct {
+	ReplaySize       int `mapstructure:"replay_size" json:"replay_size"`             // Recent events kept per user for reconnecting sessions; 100 if 0
+	ReplayMinutes    int `mapstructure:"replay_minutes" json:"replay_minutes"`       // How long events are kept for reconnecting sessions; 60 if 0
+	HeartbeatSeconds int `mapstructure:"heartbeat_seconds" json:"heartbeat_seconds"` // Keeps idle connections open through proxies; 25 if 0
+}
+
+// WebhookEndpoint is a URL that receives events as signed JSON POST requests.
+type WebhookEndpoint struct {
+	Name      string   `mapstructure:"name" json:"name"` // Unique; identifies the endpoint in queued deliveries
+	URL       string   `mapstructure:"url" json:"url"`
+	SecretEnv string   `mapstructure:"secret_env" json:"-"`    // Name of the environment variable holding the HMAC signing secret
+	Events    []string `mapstructure:"events" json:"events"` // Event types to receive, e.g. plan.created; all if empty
+}
+
+// WebhookConfig configures the delivery of application events to outbound webhooks.
+type WebhookConfig struct {
+	Enabled           bool              `mapstructure:"enabled" json:"enabled"`
+	Endpoints         []WebhookEndpoint `mapstructure:"endpoints" json:"endpoints"`
+	TimeoutSeconds    int               `mapstructure:"timeout_seconds" json:"timeout_seconds"`         // Per request; 10 if 0
+	MaxAttempts       int               `mapstructure:"max_attempts" json:"max_attempts"`               // Before a delivery becomes a dead letter; 8 if 0
+	BackoffSeconds    int               `mapstructure:"backoff_seconds" json:"backoff_seconds"`         // Wait after the first failed attempt, doubled after each further one; 30 if 0
+	MaxBackoffSeconds int               `mapstructure:"max_backoff_seconds" json:"max_backoff_seconds"` // Longest wait between attempts; 3600 if 0
+	RetryCron         string            `mapstructure:"retry_cron" json:"retry_cron"`                   // Schedule of the job retrying failed deliveries; "* * * * *" if empty
+}
+
+// RetrievalConfig configures how knowledge agents (llm_characters with retrieval) look up knowledge base articles
+// before answering. Articles are split into passages and ranked by BM25, which needs no external service.
+type RetrievalConfig struct {
+	TopK              int     `mapstructure:"top_k" json:"top_k"`                             // Passages given to the agent; 3 if 0
+	PassageChars      int     `mapstructure:"passage_chars" json:"passage_chars"`             // Longest passage; paragraphs are merged up to it; 600 if 0
+	MinScore          float64 `mapstructure:"min_score" json:"min_score"`                     // Passages scoring less are not used
+	RefreshMinutes    int     `mapstructure:"refresh_minutes" json:"refresh_minutes"`         // How long the passage index is used before articles are reloaded; 10 if 0
+	ArticleLinkFormat string  `mapstructure:"article_link_format" json:"article_link_format"` // fmt format with the article ID, e.g. "/articles/%d"
+}
+
+// ContentReviewConfig configures the editorial review of articles and exercises. Approvals expire so that
+// content is checked again from time to time.
+type ContentReviewConfig struct {
+	MaxAgeDays int    `mapstructure:"max_age_days" json:"max_age_days"` // Approved content goes back to review after this many days; never if 0
+	Cron       string `mapstructure:"cron" json:"cron"`                 // Schedule of the job sending expired content back to review; "0 5 * * *" if empty
+}
+
+// AdminConfig holds the settings of the administrator API.
+type AdminConfig struct {
+	APIToken string `mapstructure:"api_token" json:"-"` // Shared secret expected in the X-Admin-Token header; admin API is disabled if empty
 }
 
 // Config holds the application's configuration.
@@ -42,12 +293,32 @@ type Config struct {
 	Database struct {
 		DSN string // Data Source Name (e.g., "memory" or file path for SQLite)
 	}
-	LLMSystemPrompt string                 `mapstructure:"llm_system_prompt" json:"llm_system_prompt"` // Global system prompt for LLMs
-	LLMProviders    map[string]LLMProvider `mapstructure:"llm_providers"`                             // Map of provider key to provider config
-	LLMModels       map[string]string      `mapstructure:"llm_models"`                                // Map of model name to provider key
-	LLMGroups       []*LLMGroup            `mapstructure:"llm_groups"`
-	LLMCharacters   []*LLMCharacter        `mapstructure:"llm_characters"`
-	GuestChatQuota  int                    `mapstructure:"guest_chat_quota" json:"guest_chat_quota"`
+	LLMSystemPrompt   string                 `mapstructure:"llm_system_prompt" json:"llm_system_prompt"`     // Global system prompt for LLMs
+	LLMProviders      map[string]LLMProvider `mapstructure:"llm_providers"`                                  // Map of provider key to provider config
+	LLMModels         map[string]string      `mapstructure:"llm_models"`                                     // Map of model name to provider key
+	LLMTimeoutSeconds int                    `mapstructure:"llm_timeout_seconds" json:"llm_timeout_seconds"` // Per non-streaming completion (planner, safety review, reminders); 60 if 0
+	LLMGroups         []*LLMGroup            `mapstructure:"llm_groups"`
+	LLMCharacters     []*LLMCharacter        `mapstructure:"llm_characters"`
+	GuestChatQuota    int                    `mapstructure:"guest_chat_quota" json:"guest_chat_quota"`
+
+	AssessmentScoring AssessmentScoringConfig `mapstructure:"assessment_scoring" json:"assessment_scoring"`
+	AssessmentRetake  AssessmentRetakeConfig  `mapstructure:"assessment_retake" json:"assessment_retake"`
+	PlanRules         PlanRulesConfig         `mapstructure:"plan_rules" json:"plan_rules"`
+	Planner           PlannerConfig           `mapstructure:"planner" json:"planner"`
+	PlanSafety        PlanSafetyConfig        `mapstructure:"plan_safety" json:"plan_safety"`
+	PlanSchedule      PlanScheduleConfig      `mapstructure:"plan_schedule" json:"plan_schedule"`
+	CheckIn           CheckInConfig           `mapstructure:"check_in" json:"check_in"`
+	PlanLifecycle     PlanLifecycleConfig     `mapstructure:"plan_lifecycle" json:"plan_lifecycle"`
+	Progression       ProgressionConfig       `mapstructure:"progression" json:"progression"`
+	Calendar          CalendarConfig          `mapstructure:"calendar" json:"calendar"`
+	Reminders         ReminderConfig          `mapstructure:"reminders" json:"reminders"`
+	Notifications     NotificationConfig      `mapstructure:"notifications" json:"notifications"`
+	Jobs              JobsConfig              `mapstructure:"jobs" json:"jobs"`
+	Push              PushConfig              `mapstructure:"push" json:"push"`
+	Webhooks          WebhookConfig           `mapstructure:"webhooks" json:"webhooks"`
+	Retrieval         RetrievalConfig         `mapstructure:"retrieval" json:"retrieval"`
+	ContentReview     ContentReviewConfig     `mapstructure:"content_review" json:"content_review"`
+	Admin             AdminConfig             `mapstructure:"admin" json:"-"`
 }
 
 // AppConfig is the global configuration instance.
@@ -84,6 +355,11 @@ func LoadConfig() {
 		log.Printf("INFO: [Config] Server port overridden by environment variable SERVER_PORT: %s", port)
 	}
 
+	if adminToken := os.Getenv("ADMIN_API_TOKEN"); adminToken != "" {
+		AppConfig.Admin.APIToken = adminToken
+		log.Println("INFO: [Config] Admin API token loaded from environment variable ADMIN_API_TOKEN.")
+	}
+
 	// Load API keys for LLM providers from environment variables
 	for providerKey, providerConfig := range AppConfig.LLMProviders {
 		envVarNameForKey := providerConfig.APIKey // Assumes APIKey field stores the name of the environment variable
@@ -127,6 +403,7 @@ func LoadConfig() {
 				if model, ok := charMap["model"].(string); ok { char.Model = model }
 				if avatar, ok := charMap["avatar"].(string); ok { char.Avatar = avatar }
 				if cp, ok := charMap["custom_prompt"].(string); ok { char.CustomPrompt = cp }
+				if retrieval, ok := charMap["retrieval"].(bool); ok { char.Retrieval = retrieval }
 				
 				if tagsVal, ok := charMap["tags"].([]interface{}); ok {
 					for _, t := range tagsVal {
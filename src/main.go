@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -30,21 +37,69 @@ func main() {
 	runMigrations(db) // Log prefixes are handled within runMigrations
 
 	// Initialize Repositories
-	// Assuming ChatRepository and AssessmentRepository are in-memory or do not require DB for now.
-	// This might need adjustment if they are converted to use GORM.
+	// Assuming ChatRepository is in-memory or does not require DB for now.
+	// This might need adjustment if it is converted to use GORM.
 	chatRepo := repository.NewChatRepository()       
-	assessmentRepo := repository.NewAssessmentRepository() 
+	assessmentRepo := repository.NewAssessmentRepository(db) // Persisted so retake history and comparisons survive restarts
 	quotaRepo := repository.NewQuotaRepository(db)     
 	planRepo := repository.NewPlanRepository(db)       
+	assessmentProfileRepo := repository.NewAssessmentProfileRepository(db)
+	assessmentEventRepo := repository.NewAssessmentEventRepository(db)
+	planReviewRepo := repository.NewPlanReviewRepository(db)
+	checkInRepo := repository.NewCheckInRepository(db)
+	planVersionRepo := repository.NewPlanVersionRepository(db)
+	planTemplateRepo := repository.NewPlanTemplateRepository(db)
+	calendarFeedRepo := repository.NewCalendarFeedRepository(db)
+	schedulerRepo := repository.NewSchedulerRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	articleRepo := repository.NewArticleRepository(db)
+	exerciseRepo := repository.NewExerciseRepository(db)
+	contentFeedbackRepo := repository.NewContentFeedbackRepository(db)
 	log.Println("INFO: [Main] Repositories initialized.")
 
+	// Parse free-text task frequencies of existing plans into structured recurrences
+	if _, err := services.BackfillTaskRecurrences(planRepo); err != nil {
+		log.Printf("ERROR: [Main] Failed to backfill task recurrences: %v", err)
+	}
+	// Record a first version of plans created before version history existed
+	if _, err := services.BackfillPlanVersions(planRepo, planVersionRepo); err != nil {
+		log.Printf("ERROR: [Main] Failed to backfill plan versions: %v", err)
+	}
+
 	// Initialize Services
-	assessmentService := services.NewAssessmentService(assessmentRepo)
+	assessmentService := services.NewAssessmentService(assessmentRepo, assessmentEventRepo)
 	schedulerService := services.NewSchedulerService(assessmentRepo) 
-	chatService := services.NewChatService(chatRepo)                 
-	planService := services.NewPlanService(planRepo, assessmentRepo) 
+	chatService := services.NewChatService(chatRepo, services.NewBM25Retriever(articleRepo))
+	assessmentProfileService := services.NewAssessmentProfileService(assessmentProfileRepo, assessmentService)
+	llmClient := services.NewLLMClient()
+	planSafetyService := services.NewPlanSafetyService(planRepo, planReviewRepo, assessmentProfileService, llmClient)
+	planService := services.NewPlanService(planRepo, assessmentRepo, planSafetyService, checkInRepo, planVersionRepo, exerciseRepo)
+	analyticsService := services.NewAnalyticsService(assessmentEventRepo, assessmentService)
+	plannerService := services.NewPlannerService(planRepo, assessmentProfileService, planSafetyService, planVersionRepo, exerciseRepo, llmClient)
+	planTemplateService := services.NewPlanTemplateService(planTemplateRepo, planRepo, assessmentProfileService, planSafetyService, planVersionRepo, exerciseRepo)
+	calendarService := services.NewCalendarService(planRepo, calendarFeedRepo)
+	articleService := services.NewArticleService(articleRepo)
+	exerciseService := services.NewExerciseService(exerciseRepo)
+	contentFeedbackService := services.NewContentFeedbackService(contentFeedbackRepo, articleService, exerciseService)
+	// Application events go to the subscribers of the bus, such as the outbound webhooks
+	eventBus := services.NewEventBus()
+	webhookService := services.NewWebhookService(webhookRepo)
+	eventBus.Subscribe(webhookService.HandleEvent)
+	pushHub := services.NewPushHub()
+	eventBus.Subscribe(pushHub.HandleEvent)
+	notificationService := services.NewNotificationService(notificationRepo, chatRepo, eventBus)
+	progressionService := services.NewProgressionService(planRepo, checkInRepo, planVersionRepo, notificationService)
+	reminderService := services.NewReminderService(planRepo, checkInRepo, notificationService, llmClient)
+	jobRunner := services.NewJobRunner(schedulerRepo)
 	log.Println("INFO: [Main] Services initialized.")
 
+	// Register background jobs and start running them on their schedules
+	registerJobs(jobRunner, schedulerRepo, planRepo, planVersionRepo, planSafetyService, progressionService, reminderService, notificationService, webhookService, articleService, exerciseService)
+	if config.AppConfig.Jobs.Enabled {
+		jobRunner.Start()
+	}
+
 	// Initialize API Handler with all dependencies
 	apiHandler := api.NewAPIHandler(
 		chatRepo,
@@ -55,6 +110,20 @@ func main() {
 		schedulerService,
 		chatService,
 		planService, 
+		assessmentProfileService,
+		analyticsService,
+		plannerService,
+		planSafetyService,
+		planTemplateService,
+		calendarService,
+		jobRunner,
+		notificationService,
+		webhookService,
+		eventBus,
+		pushHub,
+		articleService,
+		exerciseService,
+		contentFeedbackService,
 		db,          
 	)
 	log.Println("INFO: [Main] API Handler initialized.")
@@ -81,9 +150,103 @@ func main() {
 		log.Println("WARN: [Main] Server port not configured, using default :8080.")
 		serverPort = ":8080" 
 	}
-	log.Printf("INFO: [Main] Starting server on port %s", serverPort)
-	if err := r.Run(serverPort); err != nil {
-		log.Fatalf("FATAL: [Main] Server failed to start: %v", err)
+	server := &http.Server{Addr: serverPort, Handler: r}
+	go func() {
+		log.Printf("INFO: [Main] Starting server on port %s", serverPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("FATAL: [Main] Server failed to start: %v", err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM (e.g. during a deploy) cancel running jobs first, so that they release their leases instead
+	// of blocking their tasks until jobs.lease_minutes have passed, then let open requests finish.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("INFO: [Main] Received %s, shutting down.", sig)
+	jobRunner.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("WARN: [Main] Server did not shut down cleanly: %v", err)
+	}
+	log.Println("INFO: [Main] Shutdown complete.")
+}
+
+// shutdownTimeout bounds how long open requests, such as chat streams, may take to finish on shutdown.
+const shutdownTimeout = 20 * time.Second
+
+// registerJobs registers the background jobs with the runner. A job that fails to register is logged and left out.
+func registerJobs(runner services.JobRunner, schedulerRepo repository.SchedulerRepository, planRepo repository.PlanRepository, planVersionRepo repository.PlanVersionRepository, planSafetyService services.PlanSafetyService, progressionService services.ProgressionService, reminderService services.ReminderService, notificationService services.NotificationService, webhookService services.WebhookService, articleService services.ArticleService, exerciseService services.ExerciseService) {
+	jobsConfig := config.AppConfig.Jobs
+	cleanupCron := jobsConfig.CleanupCron
+	if cleanupCron == "" {
+		cleanupCron = "30 3 * * *"
+	}
+	if err := runner.Register("cleanup_task_executions", cleanupCron, "删除过期的后台任务执行记录", services.ExecutionCleanupJob(schedulerRepo, jobsConfig.RetentionDays)); err != nil {
+		log.Printf("ERROR: [Main] Failed to register execution cleanup job: %v", err)
+	}
+
+	// Deliver notifications deferred by quiet hours once they end
+	deliverCron := config.AppConfig.Notifications.DeliverCron
+	if deliverCron == "" {
+		deliverCron = "*/5 * * * *"
+	}
+	if err := runner.Register("deliver_deferred_notifications", deliverCron, "免打扰时段结束后发送被推迟的通知", services.DeferredNotificationJob(notificationService)); err != nil {
+		log.Printf("ERROR: [Main] Failed to register deferred notification job: %v", err)
+	}
+
+	// Retry the safety review of plans left pending because their review failed
+	reviewRetryCron := config.AppConfig.PlanSafety.RetryCron
+	if reviewRetryCron == "" {
+		reviewRetryCron = "*/10 * * * *"
+	}
+	if err := runner.Register("pending_plan_reviews", reviewRetryCron, "重新审核因审核失败仍处于待审核状态的计划", services.PendingPlanReviewJob(planSafetyService, planRepo, planVersionRepo)); err != nil {
+		log.Printf("ERROR: [Main] Failed to register pending plan review job: %v", err)
+	}
+
+	// Periodically promote or regress progressive exercise tasks
+	if config.AppConfig.Progression.Enabled {
+		progressionCron := config.AppConfig.Progression.Cron
+		if progressionCron == "" {
+			progressionCron = "0 4 * * *"
+		}
+		if err := runner.Register("exercise_progression", progressionCron, "按打卡记录评估运动任务的升级与降级", services.ProgressionJob(progressionService)); err != nil {
+			log.Printf("ERROR: [Main] Failed to register exercise progression job: %v", err)
+		}
+	}
+
+	// Remind users of due tasks and follow up on missed check-ins
+	if config.AppConfig.Reminders.Enabled {
+		reminderCron := config.AppConfig.Reminders.Cron
+		if reminderCron == "" {
+			reminderCron = "*/5 * * * *"
+		}
+		if err := runner.Register("task_reminders", reminderCron, "提醒即将开始的任务，跟进未打卡的任务", services.ReminderJob(reminderService)); err != nil {
+			log.Printf("ERROR: [Main] Failed to register task reminder job: %v", err)
+		}
+	}
+
+	// Retry failed webhook deliveries with backoff
+	if config.AppConfig.Webhooks.Enabled {
+		retryCron := config.AppConfig.Webhooks.RetryCron
+		if retryCron == "" {
+			retryCron = "* * * * *"
+		}
+		if err := runner.Register("webhook_retries", retryCron, "按指数退避重试发送失败的 Webhook", services.WebhookRetryJob(webhookService)); err != nil {
+			log.Printf("ERROR: [Main] Failed to register webhook retry job: %v", err)
+		}
+	}
+
+	// Send articles and exercises whose approval has expired back to review
+	if config.AppConfig.ContentReview.MaxAgeDays > 0 {
+		reviewCron := config.AppConfig.ContentReview.Cron
+		if reviewCron == "" {
+			reviewCron = "0 5 * * *"
+		}
+		if err := runner.Register("content_re_review", reviewCron, "发布超过期限的文章和动作转回审核中，等待复审", services.ContentReviewJob(articleService, exerciseService)); err != nil {
+			log.Printf("ERROR: [Main] Failed to register content re-review job: %v", err)
+		}
 	}
 }
 
@@ -92,15 +255,36 @@ func runMigrations(db *gorm.DB) {
 	err := db.AutoMigrate(
 		&models.ChatMessage{},       
 		&models.UserAssessment{},    
-		&models.AssessmentQuestion{}, 
 		&models.GuestQuota{},         
 		&models.Plan{},               
 		&models.PlanTask{},           
+		&models.AssessmentProfile{},
+		&models.AssessmentEvent{},
+		&models.PlanReview{},
+		&models.TaskCheckIn{},
+		&models.PlanVersion{},
+		&models.PlanTemplate{},
+		&models.CalendarFeed{},
+		&models.Task{},
+		&models.TaskExecution{},
+		&models.Notification{},
+		&models.NotificationPreferences{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeadLetter{},
+		&models.Article{},
+		&models.Exercise{},
+		&models.ContentFeedback{},
 		// Add other models here as needed
 	)
 	if err != nil {
 		log.Fatalf("FATAL: [Main] Failed to auto-migrate database: %v", err)
 	}
+	// Assessment profiles used to be unique per assessment ID alone, which let profiles of different users collide
+	if db.Migrator().HasIndex(&models.AssessmentProfile{}, "idx_assessment_profiles_assessment_id") {
+		if err := db.Migrator().DropIndex(&models.AssessmentProfile{}, "idx_assessment_profiles_assessment_id"); err != nil {
+			log.Fatalf("FATAL: [Main] Failed to drop the assessment profile index: %v", err)
+		}
+	}
 	log.Println("INFO: [Main] Database migration completed.")
 }
 
@@ -117,10 +301,107 @@ func registerRoutes(r *gin.Engine, handler *api.APIHandler) {
 		planGroup := apiGroup.Group("/plan")
 		{
 			planGroup.POST("/generate", handler.GeneratePlanHandler)             
+			planGroup.GET("/templates", handler.ListPlanTemplatesHandler)
+			planGroup.GET("/templates/:templateID", handler.GetPlanTemplateHandler)
+			planGroup.POST("/templates/:templateID/instantiate", handler.InstantiatePlanTemplateHandler)
+			planGroup.GET("/calendar/subscription", handler.GetCalendarSubscriptionHandler)
+			planGroup.POST("/calendar/subscription", handler.SaveCalendarSubscriptionHandler)
+			planGroup.DELETE("/calendar/subscription", handler.DeleteCalendarSubscriptionHandler)
+			planGroup.GET("/calendar/feed/:token", handler.CalendarFeedHandler)
 			planGroup.GET("/user/:userID", handler.GetPlansForUserHandler)       
 			planGroup.GET("/:planID", handler.GetPlanDetailsHandler)             
+			planGroup.PATCH("/:planID", handler.UpdatePlanHandler)
+			planGroup.GET("/:planID/reviews", handler.GetPlanReviewsHandler)
+			planGroup.GET("/:planID/today", handler.GetTodayTasksHandler)
+			planGroup.GET("/:planID/checkins", handler.GetPlanCheckInsHandler)
+			planGroup.GET("/:planID/report", handler.GetPlanReportHandler)
+			planGroup.GET("/:planID/calendar.ics", handler.ExportPlanCalendarHandler)
 			planGroup.POST("/task/:taskID/complete", handler.CompleteTaskHandler) 
-			planGroup.POST("/task/:taskID/skip", handler.SkipTaskHandler)         
+			planGroup.POST("/task/:taskID/skip", handler.SkipTaskHandler)
+			planGroup.POST("/task/:taskID/partial", handler.PartialTaskHandler)
+			planGroup.PATCH("/task/:taskID", handler.UpdatePlanTaskHandler)
+			planGroup.DELETE("/task/:taskID", handler.DeletePlanTaskHandler)
+			planGroup.POST("/:planID/tasks", handler.AddPlanTaskHandler)
+			planGroup.PUT("/:planID/tasks/order", handler.ReorderPlanTasksHandler)
+			for _, action := range []string{"pause", "resume", "cancel", "complete", "archive"} {
+				planGroup.POST("/:planID/"+action, handler.PlanStatusActionHandler)
+			}
+			planGroup.GET("/:planID/versions", handler.GetPlanVersionsHandler)
+			planGroup.GET("/:planID/versions/diff", handler.DiffPlanVersionsHandler)
+			planGroup.POST("/:planID/versions/:version/rollback", handler.RollbackPlanHandler)
+		}
+
+		// Assessment related endpoints
+		assessmentGroup := apiGroup.Group("/assessment")
+		{
+			assessmentGroup.GET("/profile/:userID", handler.GetAssessmentProfileHandler)
+			assessmentGroup.GET("/summary/:userID", handler.GetAssessmentSummaryHandler)
+			assessmentGroup.POST("/retake", handler.RetakeAssessmentHandler)
+			assessmentGroup.GET("/history/:userID", handler.GetAssessmentHistoryHandler)
+			assessmentGroup.GET("/compare/:userID", handler.CompareAssessmentsHandler)
+		}
+
+		// Notification endpoints
+		notificationGroup := apiGroup.Group("/notifications")
+		{
+			notificationGroup.GET("", handler.ListNotificationsHandler)
+			notificationGroup.GET("/unread-count", handler.GetUnreadNotificationCountHandler)
+			notificationGroup.POST("/read-all", handler.MarkAllNotificationsReadHandler)
+			notificationGroup.POST("/:notificationID/read", handler.MarkNotificationReadHandler)
+			notificationGroup.GET("/preferences", handler.GetNotificationPreferencesHandler)
+			notificationGroup.PUT("/preferences", handler.UpdateNotificationPreferencesHandler)
+		}
+
+		// Knowledge base, open to all users
+		articleGroup := apiGroup.Group("/articles")
+		{
+			articleGroup.GET("", handler.ListArticlesHandler)
+			articleGroup.GET("/search", handler.SearchArticlesHandler)
+			articleGroup.GET("/:articleID", handler.GetArticleHandler)
+			articleGroup.POST("/:articleID/feedback", handler.SubmitArticleFeedbackHandler)
+		}
+
+		// Exercise library, open to all users
+		exerciseGroup := apiGroup.Group("/exercises")
+		{
+			exerciseGroup.GET("", handler.ListExercisesHandler)
+			exerciseGroup.GET("/:exerciseID", handler.GetExerciseHandler)
+			exerciseGroup.POST("/:exerciseID/feedback", handler.SubmitExerciseFeedbackHandler)
+		}
+
+		// Push channel: long-lived SSE stream of the user's events
+		pushGroup := apiGroup.Group("/push")
+		{
+			pushGroup.GET("/stream", handler.PushStreamHandler)
+		}
+
+		// Admin endpoints, protected by the X-Admin-Token header
+		adminGroup := apiGroup.Group("/admin", middleware.AdminAuth())
+		{
+			adminGroup.GET("/analytics/assessment-funnel", handler.GetAssessmentFunnelHandler)
+			adminGroup.GET("/plan-templates", handler.AdminListPlanTemplatesHandler)
+			adminGroup.POST("/plan-templates", handler.CreatePlanTemplateHandler)
+			adminGroup.PUT("/plan-templates/:templateID", handler.UpdatePlanTemplateHandler)
+			adminGroup.DELETE("/plan-templates/:templateID", handler.DeletePlanTemplateHandler)
+			adminGroup.GET("/articles", handler.AdminListArticlesHandler)
+			adminGroup.GET("/articles/:articleID", handler.AdminGetArticleHandler)
+			adminGroup.POST("/articles", handler.CreateArticleHandler)
+			adminGroup.PUT("/articles/:articleID", handler.UpdateArticleHandler)
+			adminGroup.DELETE("/articles/:articleID", handler.DeleteArticleHandler)
+			adminGroup.POST("/articles/:articleID/review", handler.ReviewArticleHandler)
+			adminGroup.GET("/exercises", handler.AdminListExercisesHandler)
+			adminGroup.GET("/exercises/:exerciseID", handler.AdminGetExerciseHandler)
+			adminGroup.POST("/exercises", handler.CreateExerciseHandler)
+			adminGroup.PUT("/exercises/:exerciseID", handler.UpdateExerciseHandler)
+			adminGroup.DELETE("/exercises/:exerciseID", handler.DeleteExerciseHandler)
+			adminGroup.POST("/exercises/:exerciseID/review", handler.ReviewExerciseHandler)
+			adminGroup.GET("/content-feedback", handler.ListContentFeedbackHandler)
+			adminGroup.GET("/jobs", handler.ListJobsHandler)
+			adminGroup.PATCH("/jobs/:jobID", handler.UpdateJobHandler)
+			adminGroup.POST("/jobs/:jobID/run", handler.RunJobHandler)
+			adminGroup.GET("/jobs/:jobID/executions", handler.ListJobExecutionsHandler)
+			adminGroup.GET("/webhooks/dead-letters", handler.ListWebhookDeadLettersHandler)
+			adminGroup.POST("/webhooks/dead-letters/:deadLetterID/replay", handler.ReplayWebhookDeadLetterHandler)
 		}
 		// Example for a scheduler-specific endpoint if needed in future
 		// schedulerGroup := apiGroup.Group("/scheduler")
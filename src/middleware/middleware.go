@@ -1,8 +1,12 @@
 package middleware
 
 import (
-	"fmt"
+	"crypto/subtle"
+	"errors"
 	"log"
+	"net/http"
+	"project/config"
+	"project/utils"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -54,7 +58,7 @@ func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*") // Allow all origins
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, User-Agent") // Added User-Agent
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, User-Agent, Last-Event-ID") // Added User-Agent; Last-Event-ID for resuming the push stream
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH") // Added PATCH
 
 		// Handle preflight requests (OPTIONS)
@@ -66,3 +70,22 @@ func Cors() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminAuth is a Gin middleware protecting the administrator API.
+// Requests must carry the configured admin token in the X-Admin-Token header.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := config.AppConfig.Admin.APIToken
+		if expected == "" {
+			utils.SendJSONError(c, http.StatusServiceUnavailable, "Admin API is not configured.", errors.New("admin api token not set"))
+			return
+		}
+		provided := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			log.Printf("WARN: [AdminAuth] Rejected admin request from %s to %s.", c.ClientIP(), c.Request.URL.Path)
+			utils.SendJSONError(c, http.StatusUnauthorized, "Invalid admin token.", nil)
+			return
+		}
+		c.Next()
+	}
+}
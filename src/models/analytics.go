@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// AssessmentEventType defines the kind of step recorded in the assessment flow.
+type AssessmentEventType string
+
+const (
+	AssessmentEventShown     AssessmentEventType = "shown"     // A question was presented to the user
+	AssessmentEventAnswered  AssessmentEventType = "answered"  // A question was answered
+	AssessmentEventCancelled AssessmentEventType = "cancelled" // The user cancelled the assessment at a question (e.g. declined consent)
+	AssessmentEventCompleted AssessmentEventType = "completed" // The last question was answered
+)
+
+// AssessmentEvent is a timestamped step of a user's way through the assessment questionnaire.
+// Events are the raw data of the onboarding drop-off funnel.
+type AssessmentEvent struct {
+	ID                   uint                `json:"id" gorm:"primaryKey"`
+	AssessmentID         uint                `json:"assessment_id" gorm:"index"`
+	UserID               string              `json:"user_id" gorm:"index"`
+	UserType             string              `json:"user_type" gorm:"index"`             // "guest" or "registered"
+	QuestionnaireVersion string              `json:"questionnaire_version" gorm:"index"` // Version of the question set the user saw
+	QuestionID           string              `json:"question_id"`
+	EventType            AssessmentEventType `json:"event_type" gorm:"index"`
+	CreatedAt            time.Time           `json:"created_at" gorm:"index"`
+}
+
+// TableName specifies the table name for the AssessmentEvent model.
+func (AssessmentEvent) TableName() string {
+	return "assessment_events"
+}
+
+// AssessmentEventFilter narrows down the events loaded for a report. Zero values do not filter.
+type AssessmentEventFilter struct {
+	QuestionnaireVersion string
+	UserType             string
+	Since                *time.Time
+	Until                *time.Time
+}
+
+// FunnelStep is the funnel data of one question.
+type FunnelStep struct {
+	QuestionID    string  `json:"question_id"`
+	QuestionText  string  `json:"question_text,omitempty"`
+	Shown         int     `json:"shown"`          // Assessments in which the question was shown
+	Answered      int     `json:"answered"`       // Assessments in which the question was answered
+	Cancelled     int     `json:"cancelled"`      // Assessments cancelled at this question
+	DropOff       int     `json:"drop_off"`       // Shown but never answered (including cancellations)
+	DropOffRate   float64 `json:"drop_off_rate"`  // DropOff / Shown
+	MedianSeconds float64 `json:"median_seconds"` // Median time from first showing the question to answering it
+}
+
+// AssessmentFunnelReport is the drop-off funnel of one questionnaire version and user type.
+type AssessmentFunnelReport struct {
+	QuestionnaireVersion string       `json:"questionnaire_version"`
+	UserType             string       `json:"user_type"`
+	Started              int          `json:"started"` // Assessments with at least one event
+	Completed            int          `json:"completed"`
+	Cancelled            int          `json:"cancelled"`
+	CompletionRate       float64      `json:"completion_rate"` // Completed / Started
+	Steps                []FunnelStep `json:"steps"`           // In questionnaire order
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ArticleCategory is a section of the knowledge base.
+type ArticleCategory string
+
+const (
+	ArticleCategoryMaleHealth        ArticleCategory = "male_health"        // 男性健康
+	ArticleCategoryExerciseTechnique ArticleCategory = "exercise_technique" // 运动技巧
+	ArticleCategoryNutrition         ArticleCategory = "nutrition"          // 营养调理
+	ArticleCategoryMentalHealth      ArticleCategory = "mental_health"      // 心理健康
+)
+
+// ArticleCategories lists the knowledge base categories in display order.
+var ArticleCategories = []ArticleCategory{
+	ArticleCategoryMaleHealth,
+	ArticleCategoryExerciseTechnique,
+	ArticleCategoryNutrition,
+	ArticleCategoryMentalHealth,
+}
+
+// ArticleReference is a source cited by an article.
+type ArticleReference struct {
+	Title string `json:"title"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Article is a knowledge base article, written in Markdown. Only approved articles are listed and searched.
+type Article struct {
+	ID         uint               `json:"id" gorm:"primaryKey"`
+	Title      string             `json:"title" gorm:"not null"`
+	Body       string             `json:"body" gorm:"type:text"` // Markdown
+	Category   ArticleCategory    `json:"category" gorm:"type:varchar(50);index;not null"`
+	Tags       []string           `json:"tags" gorm:"serializer:json"`
+	References []ArticleReference `json:"references" gorm:"serializer:json"`
+	ContentReview
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName specifies the table name for the Article model.
+func (Article) TableName() string {
+	return "articles"
+}
+
+// ArticleInput is the body of the admin endpoints that create or replace an article. Its review status changes
+// through ReviewInput.
+type ArticleInput struct {
+	Title      string             `json:"title"`
+	Body       string             `json:"body"`
+	Category   ArticleCategory    `json:"category"`
+	Tags       []string           `json:"tags"`
+	References []ArticleReference `json:"references"`
+}
+
+// ArticleQuery filters and pages a list of articles. Articles matching a search query come best match first,
+// others newest first.
+type ArticleQuery struct {
+	Query         string          // Search text; matches the title, tags and body
+	Status        ReviewStatus    // Optional
+	Category      ArticleCategory // Optional
+	Tag           string          // Optional
+	ReviewedAfter *time.Time      // Optional; leaves out articles reviewed at or before this time, e.g. expired approvals
+	Offset        int
+	Limit         int
+}
+
+// ArticlePage is a page of articles with the total number of matches.
+type ArticlePage struct {
+	Articles []*Article `json:"articles"`
+	Total    int64      `json:"total"`
+}
+
+// ArticleCitation is an article cited by an agent's answer, sent to the client to render as a link.
+type ArticleCitation struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
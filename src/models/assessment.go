@@ -49,11 +49,86 @@ const (
 type UserAssessment struct {
 	ID                uint                 `json:"id" gorm:"primaryKey"`
 	UserID            string               `json:"user_id" gorm:"index"`      // Identifier of the user taking the assessment
-	Answers           []UserAnswer         `json:"answers" gorm:"type:jsonb"` // User's answers, recommended to store as JSONB in DB
+	Answers           []UserAnswer         `json:"answers" gorm:"serializer:json"` // User's answers, stored as JSON
 	Status            UserAssessmentStatus `json:"status" gorm:"index"`       // Current status of the assessment (e.g., in_progress, completed)
 	CurrentQuestionID string               `json:"current_question_id,omitempty"` // ID of the current question the user is on
+	QuestionnaireVersion string            `json:"questionnaire_version,omitempty"` // Version of the question set used for this assessment
 	StartedAt         time.Time            `json:"started_at"`                // Timestamp when the assessment was started
 	CompletedAt       *time.Time           `json:"completed_at,omitempty"`    // Timestamp when the assessment was completed (pointer, allows nil)
+	Summary            string               `json:"summary,omitempty" gorm:"type:text"` // Assessment agent's summary of the results, generated on completion
+	SummaryGeneratedAt *time.Time           `json:"summary_generated_at,omitempty"`     // Timestamp when the summary was stored
 	CreatedAt         time.Time            `json:"created_at"`                // Timestamp of record creation
 	UpdatedAt         time.Time            `json:"updated_at"`                // Timestamp of last record update
-}
\ No newline at end of file
+}
+
+// ProfileDimension is a scored dimension of an AssessmentProfile (e.g. activity_level, stress_level).
+type ProfileDimension struct {
+	Score      float64 `json:"score"`
+	Label      string  `json:"label,omitempty"` // Level label from the scoring rule, e.g. "sedentary"
+	QuestionID string  `json:"question_id"`     // Question the dimension was derived from
+	Answer     string  `json:"answer"`          // The matched answer option
+}
+
+// AssessmentProfile is the structured health profile derived from a completed UserAssessment.
+// It is produced by the scoring engine and consumed by plan generation, agents and reports.
+// A profile is unique per user and assessment: assessment IDs alone have not always been stable.
+type AssessmentProfile struct {
+	ID           uint                        `json:"id" gorm:"primaryKey"`
+	AssessmentID uint                        `json:"assessment_id" gorm:"uniqueIndex:idx_profile_user_assessment,priority:2"` // Source UserAssessment
+	UserID       string                      `json:"user_id" gorm:"uniqueIndex:idx_profile_user_assessment,priority:1"`
+	Dimensions   map[string]ProfileDimension `json:"dimensions" gorm:"serializer:json"` // Keyed by dimension ID
+	RiskFlags    []string                    `json:"risk_flags" gorm:"serializer:json"` // e.g. "hypertension", "on_medication"
+	Goals        []string                    `json:"goals" gorm:"serializer:json"`      // Primary goals selected by the user
+	Concerns     []string                    `json:"concerns" gorm:"serializer:json"`   // Sexual health concerns selected by the user
+	CreatedAt    time.Time                   `json:"created_at"`
+	UpdatedAt    time.Time                   `json:"updated_at"`
+}
+
+// TableName specifies the table name for the AssessmentProfile model.
+func (AssessmentProfile) TableName() string {
+	return "assessment_profiles"
+}
+
+// HasRiskFlag reports whether the profile carries the given risk flag.
+func (p *AssessmentProfile) HasRiskFlag(flag string) bool {
+	for _, f := range p.RiskFlags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// AnswerChange describes how the answer to one question changed between two assessments.
+type AnswerChange struct {
+	QuestionID   string   `json:"question_id"`
+	QuestionText string   `json:"question_text"`
+	Before       []string `json:"before"` // Empty if the question was not answered in the earlier assessment
+	After        []string `json:"after"`  // Empty if the question was not answered in the later assessment
+	Changed      bool     `json:"changed"`
+}
+
+// DimensionChange describes how a scored profile dimension changed between two assessments.
+// Before/After are nil if the dimension could not be scored in that assessment.
+type DimensionChange struct {
+	DimensionID string   `json:"dimension_id"`
+	Before      *float64 `json:"before"`
+	After       *float64 `json:"after"`
+	BeforeLabel string   `json:"before_label,omitempty"`
+	AfterLabel  string   `json:"after_label,omitempty"`
+	Delta       *float64 `json:"delta"` // After - Before, nil unless both are scored
+}
+
+// AssessmentComparison is the change-over-time view between two completed assessments of a user.
+// It is computed on demand and not persisted.
+type AssessmentComparison struct {
+	UserID           string            `json:"user_id"`
+	FromAssessmentID uint              `json:"from_assessment_id"`
+	ToAssessmentID   uint              `json:"to_assessment_id"`
+	FromCompletedAt  *time.Time        `json:"from_completed_at,omitempty"`
+	ToCompletedAt    *time.Time        `json:"to_completed_at,omitempty"`
+	Answers          []AnswerChange    `json:"answers"`
+	Dimensions       []DimensionChange `json:"dimensions"`
+	RiskFlagsAdded   []string          `json:"risk_flags_added"`
+	RiskFlagsRemoved []string          `json:"risk_flags_removed"`
+}
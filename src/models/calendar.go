@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeed is a user's iCalendar subscription. Calendar apps poll the feed URL, which is authorized by its
+// secret token alone, and always get the user's active plans.
+type CalendarFeed struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"uniqueIndex;not null"`
+	Token     string    `json:"token" gorm:"uniqueIndex;type:varchar(64);not null"`
+	Discreet  bool      `json:"discreet"` // Events use the configured discreet titles instead of task titles
+	Timezone  string    `json:"timezone"` // IANA name events are scheduled in; the default timezone if empty
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for the CalendarFeed model.
+func (CalendarFeed) TableName() string {
+	return "calendar_feeds"
+}
+
+// CalendarOptions controls how plans are rendered as a calendar.
+type CalendarOptions struct {
+	Timezone string // IANA name; the default timezone if empty
+	Discreet *bool  // The configured calendar.discreet if nil
+}
+
+// CalendarFeedInput holds the settings of a calendar subscription. Nil fields are left unchanged.
+type CalendarFeedInput struct {
+	Discreet *bool   `json:"discreet"`
+	Timezone *string `json:"timezone"`
+	Rotate   bool    `json:"rotate"` // Issue a new token, invalidating the old feed URL
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// ReviewStatus is where an article or exercise is in the editorial review. Only approved content is shown to
+// users, offered to agents and retrieved for answers.
+type ReviewStatus string
+
+const (
+	ReviewStatusDraft    ReviewStatus = "draft"
+	ReviewStatusInReview ReviewStatus = "in_review" // Waiting for a professional, also when an approval has expired
+	ReviewStatusApproved ReviewStatus = "approved"  // Reviewed by a professional; shown to users
+	ReviewStatusRetired  ReviewStatus = "retired"   // Withdrawn; kept for the record
+)
+
+// ContentReview is the editorial review state of an article or exercise. Reviewer and date are those of the
+// last decision of a reviewer: an approval, a rejection or a retirement.
+type ContentReview struct {
+	ReviewStatus ReviewStatus `json:"review_status" gorm:"type:varchar(20);index;not null"`
+	ReviewedBy   string       `json:"reviewed_by,omitempty"` // Professional who made the last review decision
+	ReviewedAt   *time.Time   `json:"reviewed_at,omitempty"`
+	ReviewNote   string       `json:"review_note,omitempty" gorm:"type:text"` // Reason of the last status change, e.g. why it was rejected
+}
+
+// ReviewInput is the body of the admin endpoints that move an article or exercise through the editorial review.
+type ReviewInput struct {
+	Status   ReviewStatus `json:"status"`
+	Reviewer string       `json:"reviewer"` // Required to approve, reject or retire
+	Note     string       `json:"note"`
+}
+
+// ContentType identifies the kind of content users give feedback on.
+type ContentType string
+
+const (
+	ContentTypeArticle  ContentType = "article"
+	ContentTypeExercise ContentType = "exercise"
+)
+
+// ContentFeedback is a user's vote on whether an article or exercise was useful (有用/没用). Users have one vote
+// per content, which they can change.
+type ContentFeedback struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	ContentType ContentType `json:"content_type" gorm:"type:varchar(20);uniqueIndex:idx_content_feedback_user;not null"`
+	ContentID   uint        `json:"content_id" gorm:"uniqueIndex:idx_content_feedback_user;not null"`
+	UserID      string      `json:"user_id" gorm:"uniqueIndex:idx_content_feedback_user;not null"`
+	Useful      bool        `json:"useful"`
+	Comment     string      `json:"comment,omitempty" gorm:"type:text"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for the ContentFeedback model.
+func (ContentFeedback) TableName() string {
+	return "content_feedback"
+}
+
+// ContentFeedbackInput is the body of the feedback endpoints.
+type ContentFeedbackInput struct {
+	UserID  string `json:"user_id"`
+	Useful  *bool  `json:"useful"` // Required
+	Comment string `json:"comment"`
+}
+
+// ContentFeedbackSummary counts the votes on an article or exercise.
+type ContentFeedbackSummary struct {
+	ContentType ContentType `json:"content_type"`
+	ContentID   uint        `json:"content_id"`
+	Useful      int64       `json:"useful"`
+	NotUseful   int64       `json:"not_useful"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Event types published on the event bus. Events of a user are pushed to their open sessions and delivered to the
+// webhook endpoints that receive them.
+const (
+	EventReminderDue           = "reminder.due"           // A task reminder or missed check-in follow-up was delivered to the user
+	EventNotificationDelivered = "notification.delivered" // Any notification reached the user's inbox
+	EventMessageCreated        = "message.created"        // An assistant message was added to the user's chat
+	EventPlanCreated           = "plan.created"           // A plan was created and safety reviewed
+	EventPlanUpdated           = "plan.updated"           // A plan or its tasks were changed
+	EventTaskCompleted         = "task.completed"         // A task occurrence was checked in as completed
+	EventRiskRaised            = "risk.raised"            // A completed assessment raised risk flags
+	EventQuotaUpdated          = "quota.updated"          // A guest sent a message and has less quota left
+	EventQuotaExhausted        = "quota.exhausted"        // A guest used up their chat quota
+)
+
+// Event is something that happened in the application, published to the subscribers of the event bus. It is the
+// JSON payload of webhook deliveries and push messages.
+type Event struct {
+	ID         string      `json:"id"` // Unique; receivers can use it to ignore repeated deliveries
+	Type       string      `json:"type"`
+	UserID     string      `json:"user_id,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data,omitempty"` // Depends on the type
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExerciseCategory groups the exercises of the library.
+type ExerciseCategory string
+
+const (
+	ExerciseCategoryPelvicFloor ExerciseCategory = "pelvic_floor" // 盆底肌训练
+	ExerciseCategoryCardio      ExerciseCategory = "cardio"       // 有氧运动
+	ExerciseCategoryStrength    ExerciseCategory = "strength"     // 力量训练
+	ExerciseCategoryFlexibility ExerciseCategory = "flexibility"  // 拉伸与瑜伽
+	ExerciseCategoryBreathing   ExerciseCategory = "breathing"    // 呼吸与放松
+)
+
+// ExerciseCategories lists the exercise categories in display order.
+var ExerciseCategories = []ExerciseCategory{
+	ExerciseCategoryPelvicFloor,
+	ExerciseCategoryCardio,
+	ExerciseCategoryStrength,
+	ExerciseCategoryFlexibility,
+	ExerciseCategoryBreathing,
+}
+
+// ExerciseDifficulty is how demanding an exercise is.
+type ExerciseDifficulty string
+
+const (
+	ExerciseDifficultyBeginner     ExerciseDifficulty = "beginner"     // 入门
+	ExerciseDifficultyIntermediate ExerciseDifficulty = "intermediate" // 进阶
+	ExerciseDifficultyAdvanced     ExerciseDifficulty = "advanced"     // 高级
+)
+
+// ExerciseMedia is an image or video showing how to do an exercise.
+type ExerciseMedia struct {
+	Type  string `json:"type"` // image or video
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// Exercise is an entry of the exercise library. Exercise tasks of plans reference exercises so that the plan
+// detail can show how to do them. Exercises go through the same editorial review as articles; only approved
+// exercises are shown to users and offered to the planner.
+type Exercise struct {
+	ID             uint               `json:"id" gorm:"primaryKey"`
+	Name           string             `json:"name" gorm:"not null"`
+	Summary        string             `json:"summary" gorm:"type:text"`
+	Category       ExerciseCategory   `json:"category" gorm:"type:varchar(50);index;not null"`
+	Difficulty     ExerciseDifficulty `json:"difficulty" gorm:"type:varchar(20);index;not null"`
+	TargetMuscles  []string           `json:"target_muscles" gorm:"serializer:json"`
+	Steps          []string           `json:"steps" gorm:"serializer:json"` // In order
+	KeyPoints      []string           `json:"key_points" gorm:"serializer:json"`
+	CommonMistakes []string           `json:"common_mistakes" gorm:"serializer:json"`
+	Media          []ExerciseMedia    `json:"media" gorm:"serializer:json"`
+	ContentReview
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName specifies the table name for the Exercise model.
+func (Exercise) TableName() string {
+	return "exercises"
+}
+
+// ExerciseInput is the body of the admin endpoints that create or replace an exercise. Its review status changes
+// through ReviewInput.
+type ExerciseInput struct {
+	Name           string             `json:"name"`
+	Summary        string             `json:"summary"`
+	Category       ExerciseCategory   `json:"category"`
+	Difficulty     ExerciseDifficulty `json:"difficulty"`
+	TargetMuscles  []string           `json:"target_muscles"`
+	Steps          []string           `json:"steps"` // At least one
+	KeyPoints      []string           `json:"key_points"`
+	CommonMistakes []string           `json:"common_mistakes"`
+	Media          []ExerciseMedia    `json:"media"`
+}
+
+// ExerciseQuery filters the exercise library. Exercises are listed oldest first.
+type ExerciseQuery struct {
+	Status        ReviewStatus       // Optional
+	Category      ExerciseCategory   // Optional
+	Difficulty    ExerciseDifficulty // Optional
+	ReviewedAfter *time.Time         // Optional; only exercises reviewed after this time
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification categories.
+const (
+	NotificationCategoryTaskReminder  = "task_reminder"   // A plan task occurrence is about to start
+	NotificationCategoryMissedCheckIn = "missed_check_in" // A plan task occurrence passed without a check-in
+	NotificationCategoryLevelChange   = "level_change"    // An exercise task moved to another level
+)
+
+// Notification topics, which users can turn off in their NotificationPreferences.
+const (
+	NotificationTopicExercise              = "exercise"
+	NotificationTopicHabit                 = "habit"
+	NotificationTopicKnowledge             = "knowledge"
+	NotificationTopicReport                = "report"
+	NotificationTopicProductRecommendation = "product_recommendation"
+)
+
+// Notification delivery states.
+const (
+	NotificationStatusDelivered = "delivered" // Posted to the user's chat
+	NotificationStatusDeferred  = "deferred"  // Held until DeliverAt, the end of the user's quiet hours
+	NotificationStatusDropped   = "dropped"   // Not delivered: its topic is turned off, the daily maximum was reached or it expired
+)
+
+// Notification is a message the application sends to a user on its own initiative. Delivered notifications are
+// kept in the user's inbox until read, and the same text is posted to the user's chat by the sending agent.
+type Notification struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      string     `json:"user_id" gorm:"index;not null"`
+	Category    string     `json:"category" gorm:"type:varchar(50);not null"`
+	Topic       string     `json:"topic" gorm:"type:varchar(50)"` // Decides whether the user's preferences allow it
+	Status      string     `json:"status" gorm:"type:varchar(20);index"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content" gorm:"type:text"`
+	AgentID     string     `json:"agent_id,omitempty"` // Chat agent that sent it
+	PlanID      uint       `json:"plan_id,omitempty"`  // Deep link: the plan, and task within it, the notification is about
+	TaskID      uint       `json:"task_id,omitempty"`
+	DueDate     string     `json:"due_date,omitempty" gorm:"type:varchar(10)"` // Day of the task occurrence, YYYY-MM-DD in the user's timezone
+	Slot        int        `json:"slot,omitempty"`                             // Occurrence within that day
+	DedupeKey   string     `json:"-" gorm:"uniqueIndex;type:varchar(150)"`     // What the notification is about, so that it is sent only once
+	DeliverAt   *time.Time `json:"deliver_at,omitempty"`                       // When a deferred notification is due
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`                       // A notification not delivered by then is dropped; never expires if nil
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at"` // Unread if nil
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// NotificationInbox is a page of a user's delivered notifications, newest first.
+type NotificationInbox struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int64          `json:"unread_count"`          // Of all the user's notifications, not just this page
+	NextBefore    uint           `json:"next_before,omitempty"` // Pass as before to get the next page; no more pages if 0
+}
+
+// TableName specifies the table name for the Notification model.
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationPreferences are a user's choices about the messages the application sends on its own initiative.
+// Users without saved preferences get the defaults of config notifications.
+type NotificationPreferences struct {
+	ID                    uint      `json:"-" gorm:"primaryKey"`
+	UserID                string    `json:"user_id" gorm:"uniqueIndex;type:varchar(100);not null"`
+	Timezone              string    `json:"timezone"`                                 // IANA name; plan_schedule.default_timezone if empty
+	QuietHoursStart       string    `json:"quiet_hours_start" gorm:"type:varchar(5)"` // "HH:MM" in Timezone; no quiet hours if empty
+	QuietHoursEnd         string    `json:"quiet_hours_end" gorm:"type:varchar(5)"`   // May be earlier than the start, for quiet hours overnight
+	Exercise              bool      `json:"exercise"`                                 // Topic flags: notifications of a topic turned off are dropped
+	Habit                 bool      `json:"habit"`
+	Knowledge             bool      `json:"knowledge"`
+	Report                bool      `json:"report"`
+	ProductRecommendation bool      `json:"product_recommendation"`
+	MaxPerDay             int       `json:"max_reminders_per_day"` // Notifications delivered per day in Timezone; unlimited if 0
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for the NotificationPreferences model.
+func (NotificationPreferences) TableName() string {
+	return "notification_preferences"
+}
+
+// TopicEnabled reports whether notifications of the topic may be delivered. Unknown topics always may.
+func (p *NotificationPreferences) TopicEnabled(topic string) bool {
+	switch topic {
+	case NotificationTopicExercise:
+		return p.Exercise
+	case NotificationTopicHabit:
+		return p.Habit
+	case NotificationTopicKnowledge:
+		return p.Knowledge
+	case NotificationTopicReport:
+		return p.Report
+	case NotificationTopicProductRecommendation:
+		return p.ProductRecommendation
+	}
+	return true
+}
+
+// NotificationPreferencesInput holds the preferences a user can change. Nil fields are left unchanged; empty quiet
+// hours turn them off.
+type NotificationPreferencesInput struct {
+	Timezone              *string `json:"timezone"`
+	QuietHoursStart       *string `json:"quiet_hours_start"`
+	QuietHoursEnd         *string `json:"quiet_hours_end"`
+	Exercise              *bool   `json:"exercise"`
+	Habit                 *bool   `json:"habit"`
+	Knowledge             *bool   `json:"knowledge"`
+	Report                *bool   `json:"report"`
+	ProductRecommendation *bool   `json:"product_recommendation"`
+	MaxPerDay             *int    `json:"max_reminders_per_day"`
+}
@@ -13,20 +13,29 @@ const (
 	PlanStatusActive    PlanStatus = "active"
 	PlanStatusCompleted PlanStatus = "completed"
 	PlanStatusCancelled PlanStatus = "cancelled"
-	PlanStatusPending   PlanStatus = "pending" // Pending generation or user confirmation
+	PlanStatusPending   PlanStatus = "pending"  // Pending generation, safety review or user confirmation
+	PlanStatusRejected  PlanStatus = "rejected" // Rejected by the safety review, see Plan.ReviewNotes
+	PlanStatusPaused    PlanStatus = "paused"   // Paused by the user or superseded by a newer active plan; no tasks are due
 )
 
 // Plan represents a user's personalized health or habit plan.
 type Plan struct {
-	ID          uint           `gorm:"primarykey"`
-	UserID      string         `gorm:"index;not null"` // To link to the user
-	Title       string         `gorm:"not null"`
-	Description string         `gorm:"type:text"`
-	Status      PlanStatus     `gorm:"type:varchar(50);default:'pending';not null"`
-	CreatedAt   time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt   gorm.DeletedAt `gorm:"index"` // For soft deletes
-	Tasks       []PlanTask     `gorm:"foreignKey:PlanID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"` // Has many relationship
+	ID                 uint           `gorm:"primarykey"`
+	UserID             string         `gorm:"index;not null"` // To link to the user
+	Title              string         `gorm:"not null"`
+	Description        string         `gorm:"type:text"`
+	Status             PlanStatus     `gorm:"type:varchar(50);default:'pending';not null"`
+	SourceAssessmentID *uint          `gorm:"index"`            // Completed assessment the plan was generated from, if any
+	TemplateID         *uint          `gorm:"index"`            // Plan template the plan was instantiated from, if any
+	FiredRules         []string       `gorm:"serializer:json"`  // IDs of the plan rules that contributed tasks
+	ReviewNotes        string         `gorm:"type:text"`        // Safety review explanation: adjustments made or why the plan was rejected
+	StartDate          string         `gorm:"type:varchar(10)"` // YYYY-MM-DD in the user's timezone; the creation date if empty
+	EndDate            string         `gorm:"type:varchar(10)"` // YYYY-MM-DD in the user's timezone, inclusive; open-ended if empty
+	CreatedAt          time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt          gorm.DeletedAt `gorm:"index"`                                                           // For soft deletes
+	Tasks              []PlanTask     `gorm:"foreignKey:PlanID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"` // Has many relationship
+	Progress           *PlanProgress  `gorm:"-"`                                                               // Check-in report, filled in for plan detail responses
 }
 
 // TableName specifies the table name for the Plan model.
@@ -51,7 +60,8 @@ const (
 	TaskStatusPending   TaskStatus = "pending"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusSkipped   TaskStatus = "skipped"
-	TaskStatusFailed    TaskStatus = "failed" // Could be used if a task wasn't met
+	TaskStatusPartial   TaskStatus = "partial" // Only part of the planned amount was done; used for check-ins
+	TaskStatusFailed    TaskStatus = "failed"  // Could be used if a task wasn't met
 )
 
 // PlanTask represents an individual task within a Plan.
@@ -62,11 +72,17 @@ type PlanTask struct {
 	Title       string         `gorm:"not null"`
 	Description string         `gorm:"type:text"`
 	Frequency   string         // e.g., "daily", "3 times a week", "once on 2024-03-15"
+	Recurrence  *Recurrence    `gorm:"serializer:json"` // Structured schedule parsed from or set alongside Frequency; nil if unknown
 	Duration    string         // e.g., "15 minutes", "1 chapter", "3 sets of 10 reps"
 	Status      TaskStatus     `gorm:"type:varchar(50);default:'pending';not null"`
 	IsCompleted bool           `gorm:"default:false"`                           // Derived from Status == TaskStatusCompleted
 	CompletedAt gorm.NullTime  // time.Time can be nullable using gorm.NullTime or *time.Time
 	Order       int            `gorm:"default:0"` // For ordering tasks within a plan
+	Progression string         `gorm:"type:varchar(50)"` // ID of the exercise progression the task follows, see config progression.exercises
+	Level       int            `gorm:"default:0"`        // Current level of the progression, from 1; 0 if the task does not progress
+	LevelSince  string         `gorm:"type:varchar(10)"` // YYYY-MM-DD the current level started; the plan's start if empty
+	ExerciseID  *uint          `gorm:"index"`            // Exercise of the library an exercise task follows; optional
+	Exercise    *Exercise      `gorm:"-"`                // The approved exercise of ExerciseID, filled in for the plan detail
 	CreatedAt   time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
 	DeletedAt   gorm.DeletedAt `gorm:"index"` // For soft deletes
@@ -76,3 +92,22 @@ type PlanTask struct {
 func (PlanTask) TableName() string {
 	return "plan_tasks"
 }
+
+// PlanUpdateInput holds the plan fields a user can change. Nil fields are left unchanged.
+type PlanUpdateInput struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	StartDate   *string `json:"start_date"` // YYYY-MM-DD, or "" to clear
+	EndDate     *string `json:"end_date"`   // YYYY-MM-DD, or "" to clear
+}
+
+// PlanTaskInput holds the task fields a user can set. Nil fields are left unchanged when editing;
+// Title is required when adding a task.
+type PlanTaskInput struct {
+	Type        *string `json:"type"` // exercise, habit, knowledge or generic
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Frequency   *string `json:"frequency"` // Free text, parsed into the task's Recurrence
+	Duration    *string `json:"duration"`
+	ExerciseID  *uint   `json:"exercise_id"` // Exercise of the library, for exercise tasks; 0 to clear
+}
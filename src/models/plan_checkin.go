@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskCheckIn records what the user did with one occurrence of a plan task.
+// An occurrence is identified by the task, the day it was due and its slot within that day.
+type TaskCheckIn struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	TaskID    uint       `json:"task_id" gorm:"uniqueIndex:idx_task_checkin_occurrence;not null"`
+	PlanID    uint       `json:"plan_id" gorm:"index;not null"`
+	UserID    string     `json:"user_id" gorm:"index;not null"`
+	DueDate   string     `json:"due_date" gorm:"type:varchar(10);uniqueIndex:idx_task_checkin_occurrence;not null"` // YYYY-MM-DD in the user's timezone
+	Slot      int        `json:"slot" gorm:"uniqueIndex:idx_task_checkin_occurrence;not null"`                      // 1-based occurrence within the day
+	Status    TaskStatus `json:"status" gorm:"type:varchar(50);not null"`                                           // completed, partial or skipped
+	Note      string     `json:"note,omitempty" gorm:"type:text"`
+	CheckedAt time.Time  `json:"checked_at"`
+
+	CompletedAmount float64          `json:"completed_amount,omitempty"`                // Amount done, e.g. 2 (sets); mainly for partial check-ins
+	TargetAmount    float64          `json:"target_amount,omitempty"`                   // Amount planned, e.g. 3 (sets); 0 if unknown
+	Feedback        *CheckInFeedback `json:"feedback,omitempty" gorm:"serializer:json"` // How the user felt, optional
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for the TaskCheckIn model.
+func (TaskCheckIn) TableName() string {
+	return "task_check_ins"
+}
+
+// Feedback scores are on a scale from FeedbackScoreMin to FeedbackScoreMax.
+const (
+	FeedbackScoreMin = 1
+	FeedbackScoreMax = 5
+)
+
+// CheckInFeedback is the user's subjective feedback on a task occurrence. Scores are optional.
+type CheckInFeedback struct {
+	Fatigue         *int     `json:"fatigue,omitempty"`          // 1 = not tired at all … 5 = exhausted
+	Mood            *int     `json:"mood,omitempty"`             // 1 = very bad … 5 = very good
+	PerceivedEffect *int     `json:"perceived_effect,omitempty"` // 1 = no effect … 5 = clearly helpful
+	Tags            []string `json:"tags,omitempty"`             // From check_in.feedback_tags
+}
+
+// CheckInInput carries the optional details of a check-in.
+type CheckInInput struct {
+	Timezone        string           `json:"timezone"` // IANA name used to determine "today"; defaults to plan_schedule.default_timezone
+	Note            string           `json:"note"`
+	CompletedAmount float64          `json:"completed_amount"` // Required for partial check-ins
+	TargetAmount    float64          `json:"target_amount"`
+	Feedback        *CheckInFeedback `json:"feedback"`
+}
+
+// FeedbackAverages are the mean feedback scores over the check-ins that have them; nil if none do.
+type FeedbackAverages struct {
+	Fatigue         *float64 `json:"fatigue,omitempty"`
+	Mood            *float64 `json:"mood,omitempty"`
+	PerceivedEffect *float64 `json:"perceived_effect,omitempty"`
+}
+
+// CheckInSummary aggregates a set of check-ins.
+type CheckInSummary struct {
+	CheckIns       int              `json:"check_ins"`
+	Completed      int              `json:"completed"`
+	Partial        int              `json:"partial"`
+	Skipped        int              `json:"skipped"`
+	CompletionRate float64          `json:"completion_rate"` // Share of checked-in occurrences done, counting partial ones by their completed fraction
+	Feedback       FeedbackAverages `json:"feedback"`
+	Tags           map[string]int   `json:"tags,omitempty"` // Feedback tag counts
+	LastCheckInAt  *time.Time       `json:"last_check_in_at,omitempty"`
+}
+
+// TaskProgress is the check-in summary of one plan task.
+type TaskProgress struct {
+	TaskID uint   `json:"task_id"`
+	Title  string `json:"title"`
+	CheckInSummary
+}
+
+// PlanProgress is the check-in report of a plan, overall and per task.
+type PlanProgress struct {
+	PlanID uint `json:"plan_id"`
+	CheckInSummary
+	Tasks []TaskProgress `json:"tasks"`
+}
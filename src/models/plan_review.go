@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// PlanReviewOutcome is the result of the safety review of a new plan.
+type PlanReviewOutcome string
+
+const (
+	PlanReviewApproved PlanReviewOutcome = "approved" // Activated unchanged
+	PlanReviewAdjusted PlanReviewOutcome = "adjusted" // Activated after tasks were adjusted or removed
+	PlanReviewRejected PlanReviewOutcome = "rejected" // Not activated
+)
+
+// PlanAdjustment records what a safety rule did to a task.
+type PlanAdjustment struct {
+	RuleID        string `json:"rule_id"`
+	TaskTitle     string `json:"task_title"`               // Title before the adjustment
+	AdjustedTitle string `json:"adjusted_title,omitempty"` // Title after the adjustment, empty if removed
+	Action        string `json:"action"`                   // adjust, remove or reject
+	Reason        string `json:"reason"`
+}
+
+// PlanReview is the logged result of the safety review gate a plan passes before it becomes active.
+type PlanReview struct {
+	ID          uint              `json:"id" gorm:"primaryKey"`
+	PlanID      uint              `json:"plan_id" gorm:"index"`
+	UserID      string            `json:"user_id" gorm:"index"`
+	Outcome     PlanReviewOutcome `json:"outcome" gorm:"type:varchar(20)"`
+	RiskFlags   []string          `json:"risk_flags" gorm:"serializer:json"` // Risk flags of the profile the plan was checked against
+	Adjustments []PlanAdjustment  `json:"adjustments" gorm:"serializer:json"`
+	LLMReviewed bool              `json:"llm_reviewed"`
+	LLMReason   string            `json:"llm_reason,omitempty" gorm:"type:text"`
+	Explanation string            `json:"explanation" gorm:"type:text"` // User-facing summary, also stored in Plan.ReviewNotes
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// TableName specifies the table name for the PlanReview model.
+func (PlanReview) TableName() string {
+	return "plan_reviews"
+}
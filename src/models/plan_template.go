@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PlanTemplateTask is a task of a plan template.
+type PlanTemplateTask struct {
+	Type        TaskType `json:"type"` // exercise, habit, knowledge or generic
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Frequency   string   `json:"frequency"`             // Free text, must parse into a Recurrence
+	Duration    string   `json:"duration"`              // e.g. "每次5分钟"
+	Progression string   `json:"progression,omitempty"` // Optional ID of an exercise progression, see config progression.exercises
+	Level       int      `json:"level,omitempty"`       // Starting level of the progression; 1 if empty
+	ExerciseID  uint     `json:"exercise_id,omitempty"` // Optional approved exercise of the library, for exercise tasks
+}
+
+// PlanTemplate is a reusable plan managed by administrators, which users or agents instantiate as a personal Plan.
+type PlanTemplate struct {
+	ID           uint               `json:"id" gorm:"primaryKey"`
+	Title        string             `json:"title" gorm:"not null"`
+	Description  string             `json:"description" gorm:"type:text"`
+	Goals        []string           `json:"goals" gorm:"serializer:json"` // Options of the goal question (q_main_goals) the template serves
+	DurationDays int                `json:"duration_days"`                // Length of an instantiated plan; open-ended if 0
+	Tasks        []PlanTemplateTask `json:"tasks" gorm:"serializer:json"`
+	Published    bool               `json:"published"` // Only published templates are offered to users
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt     `json:"-" gorm:"index"`
+}
+
+// TableName specifies the table name for the PlanTemplate model.
+func (PlanTemplate) TableName() string {
+	return "plan_templates"
+}
+
+// PlanTemplateInput is the body of the admin endpoints that create or replace a template.
+type PlanTemplateInput struct {
+	Title        string             `json:"title"`
+	Description  string             `json:"description"`
+	Goals        []string           `json:"goals"`
+	DurationDays int                `json:"duration_days"`
+	Tasks        []PlanTemplateTask `json:"tasks"`
+	Published    *bool              `json:"published"` // true if omitted on create, unchanged if omitted on update
+}
+
+// PlanTemplateMatch is a template ranked for a user by the goals it shares with the user's assessment.
+type PlanTemplateMatch struct {
+	Template     *PlanTemplate `json:"template"`
+	MatchedGoals []string      `json:"matched_goals"`
+}
+
+// PlanTemplateInstantiateInput holds the options for creating a plan from a template.
+type PlanTemplateInstantiateInput struct {
+	StartDate string `json:"start_date"` // YYYY-MM-DD; today in Timezone if empty and the template has a duration
+	Timezone  string `json:"timezone"`   // IANA name; the default timezone if empty
+}
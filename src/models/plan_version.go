@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// PlanVersionAuthor identifies who or what made a plan change.
+type PlanVersionAuthor string
+
+const (
+	PlanVersionAuthorUser         PlanVersionAuthor = "user"          // The plan's owner, through the plan API
+	PlanVersionAuthorPlannerAgent PlanVersionAuthor = "planner_agent" // The LLM planner
+	PlanVersionAuthorSafetyReview PlanVersionAuthor = "safety_review" // The safety gate adjusted or rejected the plan
+	PlanVersionAuthorSystem       PlanVersionAuthor = "system"        // Rule-based generation, auto-completion, active plan limit, backfill
+)
+
+// PlanTaskSnapshot is the content of a task in a plan version. TaskID stays the same across versions, so the
+// task's check-ins apply to every version that contains it.
+type PlanTaskSnapshot struct {
+	TaskID      uint        `json:"task_id"`
+	Type        TaskType    `json:"type"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Frequency   string      `json:"frequency"`
+	Recurrence  *Recurrence `json:"recurrence,omitempty"`
+	Duration    string      `json:"duration"`
+	Order       int         `json:"order"`
+	Level       int         `json:"level,omitempty"`       // Level of the task's exercise progression
+	ExerciseID  uint        `json:"exercise_id,omitempty"` // Exercise of the library the task follows
+}
+
+// PlanSnapshot is the content of a plan at one version. Task progress lives in check-ins and is not part of it.
+type PlanSnapshot struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Status      PlanStatus         `json:"status"`
+	StartDate   string             `json:"start_date"`
+	EndDate     string             `json:"end_date"`
+	Tasks       []PlanTaskSnapshot `json:"tasks"`
+}
+
+// PlanVersion is a snapshot of a plan taken after each change, numbered from 1 per plan.
+type PlanVersion struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	PlanID    uint              `json:"plan_id" gorm:"uniqueIndex:idx_plan_version;not null"`
+	Version   int               `json:"version" gorm:"uniqueIndex:idx_plan_version;not null"`
+	Author    PlanVersionAuthor `json:"author" gorm:"type:varchar(30)"`
+	AuthorID  string            `json:"author_id,omitempty"` // UserID when Author is user
+	Reason    string            `json:"reason" gorm:"type:text"`
+	Snapshot  PlanSnapshot      `json:"snapshot" gorm:"serializer:json"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// TableName specifies the table name for the PlanVersion model.
+func (PlanVersion) TableName() string {
+	return "plan_versions"
+}
+
+// PlanFieldChange is one changed field between two plan versions.
+type PlanFieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// PlanTaskChange lists the changed fields of a task present in both versions.
+type PlanTaskChange struct {
+	TaskID  uint              `json:"task_id"`
+	Title   string            `json:"title"` // Title in the newer version
+	Changes []PlanFieldChange `json:"changes"`
+}
+
+// PlanVersionDiff describes how a plan changed from one version to another.
+type PlanVersionDiff struct {
+	PlanID       uint               `json:"plan_id"`
+	FromVersion  int                `json:"from_version"`
+	ToVersion    int                `json:"to_version"`
+	Changes      []PlanFieldChange  `json:"changes"`
+	AddedTasks   []PlanTaskSnapshot `json:"added_tasks"`
+	RemovedTasks []PlanTaskSnapshot `json:"removed_tasks"`
+	ChangedTasks []PlanTaskChange   `json:"changed_tasks"`
+}
+
+// Empty reports whether the two versions have the same content.
+func (d *PlanVersionDiff) Empty() bool {
+	return len(d.Changes) == 0 && len(d.AddedTasks) == 0 && len(d.RemovedTasks) == 0 && len(d.ChangedTasks) == 0
+}
@@ -0,0 +1,17 @@
+package models
+
+// LevelChange records a task moving to another level of its exercise progression.
+type LevelChange struct {
+	PlanID      uint   `json:"plan_id"`
+	TaskID      uint   `json:"task_id"`
+	Progression string `json:"progression"`
+	FromLevel   int    `json:"from_level"`
+	ToLevel     int    `json:"to_level"`
+	Title       string `json:"title"`  // Task title at the new level
+	Reason      string `json:"reason"` // User-facing explanation, also sent to the chat
+}
+
+// Promoted reports whether the task moved up a level.
+func (c LevelChange) Promoted() bool {
+	return c.ToLevel > c.FromLevel
+}
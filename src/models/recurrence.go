@@ -0,0 +1,48 @@
+package models
+
+// RecurrenceKind defines how a plan task repeats.
+type RecurrenceKind string
+
+const (
+	RecurrenceDaily        RecurrenceKind = "daily"          // Every day, TimesPerDay times
+	RecurrenceWeekdays     RecurrenceKind = "weekdays"       // On the listed Weekdays, TimesPerDay times
+	RecurrenceTimesPerWeek RecurrenceKind = "times_per_week" // TimesPerWeek times a week, at most once a day, any days
+	RecurrenceOnce         RecurrenceKind = "once"           // A single occurrence on Date, or any day until done if Date is empty
+)
+
+// TimeWindow is a time-of-day window in the user's timezone, as "HH:MM" strings.
+type TimeWindow struct {
+	Label string `json:"label,omitempty"` // e.g. "morning", "bedtime"
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Recurrence is the structured schedule of a plan task. PlanTask.Frequency keeps the human-readable text.
+type Recurrence struct {
+	Kind         RecurrenceKind `json:"kind"`
+	TimesPerDay  int            `json:"times_per_day,omitempty"`  // daily and weekdays; 0 means once
+	Weekdays     []int          `json:"weekdays,omitempty"`       // weekdays; 0 = Sunday … 6 = Saturday, as time.Weekday
+	TimesPerWeek int            `json:"times_per_week,omitempty"` // times_per_week
+	Date         string         `json:"date,omitempty"`           // once; YYYY-MM-DD in the user's timezone
+	Windows      []TimeWindow   `json:"windows,omitempty"`        // Preferred times of day, optional
+}
+
+// DueTask is a plan task that is due on a given day.
+type DueTask struct {
+	TaskID      uint             `json:"task_id"`
+	PlanID      uint             `json:"plan_id"`
+	Title       string           `json:"title"`
+	Type        TaskType         `json:"type"`
+	Date        string           `json:"date"`  // YYYY-MM-DD in the user's timezone
+	Times       int              `json:"times"` // Occurrences due that day
+	Windows     []TimeWindow     `json:"windows,omitempty"`
+	Occurrences []TaskOccurrence `json:"occurrences"` // One per due occurrence, with its check-in state
+}
+
+// TaskOccurrence is one due occurrence of a task on a given day.
+type TaskOccurrence struct {
+	Slot    int          `json:"slot"`             // 1-based occurrence within the day
+	Window  *TimeWindow  `json:"window,omitempty"` // Preferred time of day for this slot, if any
+	Status  TaskStatus   `json:"status"`           // pending until checked in
+	CheckIn *TaskCheckIn `json:"check_in,omitempty"`
+}
@@ -4,35 +4,67 @@ import (
 	"time"
 )
 
-// Task represents a scheduled task or job within the system.
-// This model seems intended for a generic scheduler, which might not be fully implemented
-// or utilized by the current "AI Bot Group Scheduler" (which is more about AI agent selection).
-// If this is for a different type of scheduler (e.g., background jobs), it should be clarified.
+// Job states kept in Task.Status.
+const (
+	JobStatusIdle    = "idle"
+	JobStatusRunning = "running" // Held by the runner instance in LockedBy until LockedUntil
+)
+
+// Execution results kept in TaskExecution.Status.
+const (
+	ExecutionStatusRunning = "running"
+	ExecutionStatusSuccess = "success"
+	ExecutionStatusFailed  = "failed"
+)
+
+// Execution triggers kept in TaskExecution.Trigger.
+const (
+	ExecutionTriggerSchedule = "schedule"
+	ExecutionTriggerManual   = "manual"
+)
+
+// Task is a background job run by the job runner on its cron schedule. The code of a job is registered under
+// its Name at startup; the row holds its schedule and run state, shared by all instances of the application.
+// (The chat SchedulerService, which picks the AI agents that answer a message, is unrelated.)
 type Task struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Name        string    `json:"name"`        // Name of the task
-	Description string    `json:"description"` // Description of what the task does
-	CronExpr    string    `json:"cron_expr"`   // Cron expression for scheduling (e.g., "0 * * * *")
-	Status      string    `json:"status"`      // Current status of the task (e.g., "pending", "running", "completed", "failed")
-	CreatedAt   time.Time `json:"created_at"`  // Timestamp of task creation
-	UpdatedAt   time.Time `json:"updated_at"`  // Timestamp of last task update
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Name        string     `json:"name" gorm:"uniqueIndex;type:varchar(100);not null"` // Name of the task, as registered with the job runner
+	Description string     `json:"description"`                                        // Description of what the task does
+	CronExpr    string     `json:"cron_expr"`                                          // Cron expression for scheduling (e.g., "0 * * * *"), see utils.ParseCron
+	Enabled     bool       `json:"enabled"`                                            // Disabled tasks only run when triggered manually
+	Status      string     `json:"status"`                                             // idle or running
+	LockedBy    string     `json:"locked_by,omitempty"`                                // Runner instance executing the task
+	LockedUntil *time.Time `json:"locked_until,omitempty"`                             // A run not finished by then is considered dead and the task may run again
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt   *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"` // Timestamp of task creation
+	UpdatedAt   time.Time  `json:"updated_at"` // Timestamp of last task update
+}
+
+// TableName specifies the table name for the Task model.
+func (Task) TableName() string {
+	return "tasks"
 }
 
 // TaskExecution records an instance of a task's execution.
-// Similar to Task, its usage in the context of the current AI agent scheduler is unclear.
 type TaskExecution struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	TaskID    uint      `json:"task_id"`     // Foreign key to the Task this execution belongs to
-	StartTime time.Time `json:"start_time"`  // When the task execution started
-	EndTime   time.Time `json:"end_time"`    // When the task execution finished
-	Status    string    `json:"status"`      // Execution status (e.g., "success", "failed")
-	Log       string    `json:"log"`         // Log output or error messages from the execution
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	TaskID    uint       `json:"task_id" gorm:"index"` // Foreign key to the Task this execution belongs to
+	Trigger   string     `json:"trigger"`              // schedule or manual
+	Runner    string     `json:"runner"`               // Runner instance that executed it
+	StartTime time.Time  `json:"start_time"`           // When the task execution started
+	EndTime   *time.Time `json:"end_time,omitempty"`   // When the task execution finished; nil while running
+	Status    string     `json:"status"`               // running, success or failed
+	Log       string     `json:"log" gorm:"type:text"` // Log output or error messages from the execution
 }
 
-// Potential_Future_Note: If these models (Task, TaskExecution) are not used by any current functionality,
-// they might be remnants of a planned feature or a misunderstanding of the "scheduler" component's role.
-// The existing `SchedulerService` in `src/services/scheduler_service.go` is focused on AI agent selection
-// for chat responses, not on running cron-like background tasks.
-// If these models are indeed for a different purpose, they should be documented as such,
-// or removed if they are unused and not planned.
-// For now, comments are translated assuming they are part of a generic task scheduling system.
+// TableName specifies the table name for the TaskExecution model.
+func (TaskExecution) TableName() string {
+	return "task_executions"
+}
+
+// JobUpdateInput holds the task settings an administrator can change. Nil fields are left unchanged.
+type JobUpdateInput struct {
+	CronExpr *string `json:"cron_expr"`
+	Enabled  *bool   `json:"enabled"`
+}
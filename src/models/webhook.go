@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookDelivery is an event waiting to be delivered to one webhook endpoint. Deliveries are deleted once the
+// endpoint accepts them, and moved to the dead letters when all attempts fail.
+type WebhookDelivery struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	EventID       string    `json:"event_id" gorm:"type:varchar(64);index"`
+	EventType     string    `json:"event_type" gorm:"type:varchar(50)"`
+	Endpoint      string    `json:"endpoint" gorm:"type:varchar(100);not null"` // Name of the endpoint in config webhooks.endpoints
+	Payload       string    `json:"payload" gorm:"type:text"`                   // JSON of the Event, signed as sent
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"index"`
+	LastError     string    `json:"last_error,omitempty" gorm:"type:text"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for the WebhookDelivery model.
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookDeadLetter is a delivery that failed all its attempts. It is kept until an administrator replays it.
+type WebhookDeadLetter struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   string    `json:"event_id" gorm:"type:varchar(64);index"`
+	EventType string    `json:"event_type" gorm:"type:varchar(50)"`
+	Endpoint  string    `json:"endpoint" gorm:"type:varchar(100);not null"`
+	Payload   string    `json:"payload" gorm:"type:text"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error" gorm:"type:text"`
+	QueuedAt  time.Time `json:"queued_at"` // When the event was first queued for the endpoint
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for the WebhookDeadLetter model.
+func (WebhookDeadLetter) TableName() string {
+	return "webhook_dead_letters"
+}
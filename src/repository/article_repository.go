@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"project/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ArticleRepository defines the interface for storing and searching knowledge base articles.
+type ArticleRepository interface {
+	CreateArticle(article *models.Article) error
+	GetArticleByID(articleID uint) (*models.Article, error) // Returns nil, nil if not found
+	UpdateArticle(article *models.Article) error
+	DeleteArticle(articleID uint) error // Soft delete
+	// ListArticles returns a page of the articles matching query.
+	ListArticles(query models.ArticleQuery) (*models.ArticlePage, error)
+}
+
+// articleSearchTable is the SQLite FTS5 table indexing the articles, keyed by article ID. SQLite tokenizes on
+// spaces, so the columns hold the CJK bigram tokens of the articles rather than their text.
+const articleSearchTable = "article_search"
+
+type articleRepository struct {
+	db  *gorm.DB
+	fts bool // The search index is available; searches fall back to LIKE if not
+}
+
+// NewArticleRepository creates a new instance of ArticleRepository, creating the full-text search index if SQLite
+// was built with FTS5 (build tag sqlite_fts5).
+func NewArticleRepository(db *gorm.DB) ArticleRepository {
+	r := &articleRepository{db: db}
+	r.initSearchIndex()
+	return r
+}
+
+// initSearchIndex creates the search index, and rebuilds it if it is missing articles, e.g. ones created while
+// the index was not available.
+func (r *articleRepository) initSearchIndex() {
+	err := r.db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + articleSearchTable + " USING fts5(title, tags, body)").Error
+	if err != nil {
+		log.Printf("WARN: [ArticleRepository] Full-text search is not available (build with -tags sqlite_fts5); searching with LIKE instead: %v", err)
+		return
+	}
+	r.fts = true
+
+	var indexed, articles int64
+	if err := r.db.Table(articleSearchTable).Count(&indexed).Error; err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to count indexed articles: %v", err)
+		return
+	}
+	if err := r.db.Model(&models.Article{}).Count(&articles).Error; err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to count articles: %v", err)
+		return
+	}
+	if indexed == articles {
+		return
+	}
+	var all []*models.Article
+	if err := r.db.Find(&all).Error; err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to load articles to index: %v", err)
+		return
+	}
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM " + articleSearchTable).Error; err != nil {
+			return err
+		}
+		for _, article := range all {
+			if err := indexArticle(tx, article); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to rebuild the search index: %v", err)
+		return
+	}
+	log.Printf("INFO: [ArticleRepository] Rebuilt the search index with %d articles.", len(all))
+}
+
+// indexArticle replaces the search index entry of an article.
+func indexArticle(tx *gorm.DB, article *models.Article) error {
+	if err := tx.Exec("DELETE FROM "+articleSearchTable+" WHERE rowid = ?", article.ID).Error; err != nil {
+		return err
+	}
+	tokens := func(text string) string { return strings.Join(utils.SearchTokens(text), " ") }
+	return tx.Exec("INSERT INTO "+articleSearchTable+" (rowid, title, tags, body) VALUES (?, ?, ?, ?)",
+		article.ID, tokens(article.Title), tokens(strings.Join(article.Tags, " ")), tokens(article.Body)).Error
+}
+
+// CreateArticle stores a new article and indexes it.
+func (r *articleRepository) CreateArticle(article *models.Article) error {
+	if article == nil {
+		log.Printf("ERROR: [ArticleRepository] CreateArticle: article cannot be nil")
+		return errors.New("article cannot be nil")
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(article).Error; err != nil {
+			return err
+		}
+		if r.fts {
+			return indexArticle(tx, article)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to create article '%s': %v", article.Title, err)
+		return fmt.Errorf("failed to create article '%s': %w", article.Title, err)
+	}
+	log.Printf("INFO: [ArticleRepository] Created article ID %d ('%s').", article.ID, article.Title)
+	return nil
+}
+
+// GetArticleByID retrieves an article by its ID.
+func (r *articleRepository) GetArticleByID(articleID uint) (*models.Article, error) {
+	var article models.Article
+	err := r.db.First(&article, articleID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [ArticleRepository] Failed to retrieve article ID %d: %v", articleID, err)
+		return nil, fmt.Errorf("failed to retrieve article ID %d: %w", articleID, err)
+	}
+	return &article, nil
+}
+
+// UpdateArticle saves changes to an existing article and reindexes it.
+func (r *articleRepository) UpdateArticle(article *models.Article) error {
+	if article == nil || article.ID == 0 {
+		log.Printf("ERROR: [ArticleRepository] UpdateArticle: article ID must be provided for update")
+		return errors.New("article ID must be provided for update")
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(article).Error; err != nil {
+			return err
+		}
+		if r.fts {
+			return indexArticle(tx, article)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to update article ID %d: %v", article.ID, err)
+		return fmt.Errorf("failed to update article ID %d: %w", article.ID, err)
+	}
+	log.Printf("INFO: [ArticleRepository] Updated article ID %d.", article.ID)
+	return nil
+}
+
+// DeleteArticle soft-deletes an article and removes it from the search index.
+func (r *articleRepository) DeleteArticle(articleID uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Article{}, articleID).Error; err != nil {
+			return err
+		}
+		if r.fts {
+			return tx.Exec("DELETE FROM "+articleSearchTable+" WHERE rowid = ?", articleID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to delete article ID %d: %v", articleID, err)
+		return fmt.Errorf("failed to delete article ID %d: %w", articleID, err)
+	}
+	log.Printf("INFO: [ArticleRepository] Deleted article ID %d.", articleID)
+	return nil
+}
+
+// ListArticles retrieves a page of articles. Searches are ranked by BM25, weighing matches in the title most and
+// in the body least; without the search index they come newest first like the other lists.
+func (r *articleRepository) ListArticles(query models.ArticleQuery) (*models.ArticlePage, error) {
+	db := r.db.Model(&models.Article{})
+	if query.Status != "" {
+		db = db.Where("articles.review_status = ?", query.Status)
+	}
+	if query.ReviewedAfter != nil {
+		db = db.Where("articles.reviewed_at > ?", *query.ReviewedAfter)
+	}
+	if query.Category != "" {
+		db = db.Where("articles.category = ?", query.Category)
+	}
+	if query.Tag != "" {
+		tag, _ := json.Marshal(query.Tag) // As stored in the JSON array
+		db = db.Where("articles.tags LIKE ? ESCAPE '\\'", "%"+likeEscape(string(tag))+"%")
+	}
+	order := "articles.updated_at desc, articles.id desc"
+	if terms := utils.SearchTerms(query.Query); len(terms) > 0 {
+		if r.fts {
+			db = db.Joins("JOIN "+articleSearchTable+" ON "+articleSearchTable+".rowid = articles.id").
+				Where(articleSearchTable+" MATCH ?", ftsMatchExpression(terms))
+			order = "bm25(" + articleSearchTable + ", 10.0, 5.0, 1.0), articles.id desc"
+		} else {
+			for _, term := range terms {
+				pattern := "%" + likeEscape(term) + "%"
+				db = db.Where("(articles.title LIKE ? ESCAPE '\\' OR articles.tags LIKE ? ESCAPE '\\' OR articles.body LIKE ? ESCAPE '\\')", pattern, pattern, pattern)
+			}
+		}
+	}
+
+	page := &models.ArticlePage{Articles: []*models.Article{}}
+	if err := db.Count(&page.Total).Error; err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to count articles for query '%s': %v", query.Query, err)
+		return nil, fmt.Errorf("failed to count articles: %w", err)
+	}
+	if err := db.Select("articles.*").Order(order).Offset(query.Offset).Limit(query.Limit).Find(&page.Articles).Error; err != nil {
+		log.Printf("ERROR: [ArticleRepository] Failed to list articles for query '%s': %v", query.Query, err)
+		return nil, fmt.Errorf("failed to list articles: %w", err)
+	}
+	return page, nil
+}
+
+// ftsMatchExpression builds an FTS5 query matching all the search terms: words and single CJK characters as
+// token prefixes, longer CJK terms as the phrase of their bigrams.
+func ftsMatchExpression(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		bigrams := utils.Bigrams(term)
+		if len(bigrams) == 1 && bigrams[0] == term {
+			parts = append(parts, `"`+term+`"*`)
+		} else {
+			parts = append(parts, `"`+strings.Join(bigrams, " ")+`"`)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// likeEscape escapes the LIKE wildcards in s, for patterns with ESCAPE '\'.
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
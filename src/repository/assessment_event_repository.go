@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// AssessmentEventRepository defines the interface for storing assessment funnel events.
+type AssessmentEventRepository interface {
+	RecordEvent(event *models.AssessmentEvent) error
+	ListEvents(filter models.AssessmentEventFilter) ([]models.AssessmentEvent, error) // Ordered by time
+}
+
+type assessmentEventRepository struct {
+	db *gorm.DB
+}
+
+// NewAssessmentEventRepository creates a new instance of AssessmentEventRepository.
+func NewAssessmentEventRepository(db *gorm.DB) AssessmentEventRepository {
+	return &assessmentEventRepository{db: db}
+}
+
+// RecordEvent stores a single assessment event.
+func (r *assessmentEventRepository) RecordEvent(event *models.AssessmentEvent) error {
+	if event == nil {
+		log.Printf("ERROR: [AssessmentEventRepository] RecordEvent: event cannot be nil")
+		return errors.New("event cannot be nil")
+	}
+	if err := r.db.Create(event).Error; err != nil {
+		log.Printf("ERROR: [AssessmentEventRepository] Failed to record '%s' event for assessment ID %d, question '%s': %v", event.EventType, event.AssessmentID, event.QuestionID, err)
+		return fmt.Errorf("failed to record assessment event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents retrieves the events matching the filter, oldest first.
+func (r *assessmentEventRepository) ListEvents(filter models.AssessmentEventFilter) ([]models.AssessmentEvent, error) {
+	query := r.db.Model(&models.AssessmentEvent{})
+	if filter.QuestionnaireVersion != "" {
+		query = query.Where("questionnaire_version = ?", filter.QuestionnaireVersion)
+	}
+	if filter.UserType != "" {
+		query = query.Where("user_type = ?", filter.UserType)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var events []models.AssessmentEvent
+	if err := query.Order("created_at asc, id asc").Find(&events).Error; err != nil {
+		log.Printf("ERROR: [AssessmentEventRepository] Failed to list events (filter %+v): %v", filter, err)
+		return nil, fmt.Errorf("failed to list assessment events: %w", err)
+	}
+	log.Printf("INFO: [AssessmentEventRepository] Listed %d assessment events (filter version='%s', user_type='%s').", len(events), filter.QuestionnaireVersion, filter.UserType)
+	return events, nil
+}
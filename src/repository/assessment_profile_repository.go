@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AssessmentProfileRepository defines the interface for storing derived assessment profiles.
+type AssessmentProfileRepository interface {
+	SaveProfile(profile *models.AssessmentProfile) error // Creates or replaces the profile of profile.UserID and profile.AssessmentID
+	GetProfile(userID string, assessmentID uint) (*models.AssessmentProfile, error)
+	GetLatestProfileByUserID(userID string) (*models.AssessmentProfile, error)
+}
+
+type assessmentProfileRepository struct {
+	db *gorm.DB
+}
+
+// NewAssessmentProfileRepository creates a new instance of AssessmentProfileRepository.
+func NewAssessmentProfileRepository(db *gorm.DB) AssessmentProfileRepository {
+	return &assessmentProfileRepository{db: db}
+}
+
+// SaveProfile upserts a profile keyed by its UserID and AssessmentID, so re-scoring an assessment replaces the old
+// profile and never the profile of another user.
+func (r *assessmentProfileRepository) SaveProfile(profile *models.AssessmentProfile) error {
+	if profile == nil {
+		log.Printf("ERROR: [AssessmentProfileRepository] SaveProfile: profile cannot be nil")
+		return errors.New("profile cannot be nil")
+	}
+	if profile.AssessmentID == 0 {
+		log.Printf("ERROR: [AssessmentProfileRepository] SaveProfile: profile must reference an assessment (AssessmentID is 0)")
+		return errors.New("profile must reference an assessment")
+	}
+	if profile.UserID == "" {
+		log.Printf("ERROR: [AssessmentProfileRepository] SaveProfile: profile of assessment ID %d has no UserID", profile.AssessmentID)
+		return errors.New("profile must reference a user")
+	}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "assessment_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"dimensions", "risk_flags", "goals", "concerns", "updated_at"}),
+	}).Create(profile).Error
+	if err != nil {
+		log.Printf("ERROR: [AssessmentProfileRepository] Failed to save profile for assessment ID %d (userID %s): %v", profile.AssessmentID, profile.UserID, err)
+		return fmt.Errorf("failed to save profile for assessment ID %d: %w", profile.AssessmentID, err)
+	}
+	log.Printf("INFO: [AssessmentProfileRepository] Saved profile for assessment ID %d (userID %s).", profile.AssessmentID, profile.UserID)
+	return nil
+}
+
+// GetProfile retrieves the profile derived from a specific assessment of a user.
+func (r *assessmentProfileRepository) GetProfile(userID string, assessmentID uint) (*models.AssessmentProfile, error) {
+	var profile models.AssessmentProfile
+	err := r.db.Where("user_id = ? AND assessment_id = ?", userID, assessmentID).First(&profile).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("INFO: [AssessmentProfileRepository] No profile found for assessment ID %d (userID %s).", assessmentID, userID)
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [AssessmentProfileRepository] Failed to retrieve profile for assessment ID %d (userID %s): %v", assessmentID, userID, err)
+		return nil, fmt.Errorf("failed to retrieve profile for assessment ID %d: %w", assessmentID, err)
+	}
+	return &profile, nil
+}
+
+// GetLatestProfileByUserID retrieves the most recently derived profile of a user. Profiles are ordered by when they
+// were first stored rather than by assessment ID.
+func (r *assessmentProfileRepository) GetLatestProfileByUserID(userID string) (*models.AssessmentProfile, error) {
+	var profile models.AssessmentProfile
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc, id desc").First(&profile).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("INFO: [AssessmentProfileRepository] No profile found for userID %s.", userID)
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [AssessmentProfileRepository] Failed to retrieve latest profile for userID %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to retrieve latest profile for userID %s: %w", userID, err)
+	}
+	return &profile, nil
+}
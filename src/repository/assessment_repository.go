@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"log"
 	"project/models"
-	"sync"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // AssessmentRepository 评估仓库接口 (保持不变)
@@ -15,40 +16,26 @@ type AssessmentRepository interface {
 	GetUserAssessmentByID(id uint) (*models.UserAssessment, error)
 	GetUserAssessmentByUserID(userID string, statusFilter ...models.UserAssessmentStatus) (*models.UserAssessment, error)
 	UpdateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error)
+	ListUserAssessments(userID string, statusFilter ...models.UserAssessmentStatus) ([]*models.UserAssessment, error) // 按创建顺序（旧→新）返回
 }
 
-// assessmentRepository 评估仓库实现 (内存版)
+// assessmentRepository 评估仓库实现 (数据库版，重启后复评历史与对比仍然可用)
 type assessmentRepository struct {
-	userAssessments  map[uint]*models.UserAssessment // 按评估ID存储
-	userIndex        map[string][]uint             // 按UserID索引评估ID列表，方便快速查找某用户的所有评估
-	nextAssessmentID uint
-	muAssessments    sync.RWMutex
+	db *gorm.DB
 }
 
 // NewAssessmentRepository 创建评估仓库实例
-func NewAssessmentRepository() AssessmentRepository {
-	return &assessmentRepository{
-		userAssessments:  make(map[uint]*models.UserAssessment),
-		userIndex:        make(map[string][]uint),
-		nextAssessmentID: 1,
-	}
+func NewAssessmentRepository(db *gorm.DB) AssessmentRepository {
+	return &assessmentRepository{db: db}
 }
 
 // CreateUserAssessment 创建一个新的用户评估记录
 func (r *assessmentRepository) CreateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {
-	r.muAssessments.Lock()
-	defer r.muAssessments.Unlock()
-
 	if assessment.UserID == "" {
 		log.Printf("ERROR: [AssessmentRepository] CreateUserAssessment: UserID cannot be empty.")
 		return nil, errors.New("user ID cannot be empty")
 	}
 
-	assessment.ID = r.nextAssessmentID
-	r.nextAssessmentID++
-	assessment.CreatedAt = time.Now()
-	assessment.UpdatedAt = time.Now()
-
 	if assessment.Status == "" {
 		assessment.Status = models.AssessmentStatusInProgress
 	}
@@ -56,96 +43,95 @@ func (r *assessmentRepository) CreateUserAssessment(assessment *models.UserAsses
 		assessment.StartedAt = time.Now()
 	}
 
-	r.userAssessments[assessment.ID] = assessment
-	r.userIndex[assessment.UserID] = append(r.userIndex[assessment.UserID], assessment.ID)
+	if err := r.db.Create(assessment).Error; err != nil {
+		log.Printf("ERROR: [AssessmentRepository] Failed to create user assessment for userID '%s': %v", assessment.UserID, err)
+		return nil, fmt.Errorf("failed to create assessment for userID '%s': %w", assessment.UserID, err)
+	}
 
 	log.Printf("INFO: [AssessmentRepository] Created user assessment: ID=%d, UserID=%s, Status=%s", assessment.ID, assessment.UserID, assessment.Status)
 	return assessment, nil
 }
 
-// GetUserAssessmentByID 根据ID获取用户评估记录
+// GetUserAssessmentByID 根据ID获取用户评估记录，未找到时返回 nil, nil
 func (r *assessmentRepository) GetUserAssessmentByID(id uint) (*models.UserAssessment, error) {
-	r.muAssessments.RLock()
-	defer r.muAssessments.RUnlock()
-
-	assessment, exists := r.userAssessments[id]
-	if !exists {
-		log.Printf("WARN: [AssessmentRepository] GetUserAssessmentByID: Assessment with ID %d not found.", id)
-		return nil, fmt.Errorf("assessment record with ID %d not found", id)
+	var assessment models.UserAssessment
+	err := r.db.First(&assessment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("WARN: [AssessmentRepository] GetUserAssessmentByID: Assessment with ID %d not found.", id)
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [AssessmentRepository] Failed to retrieve assessment ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to retrieve assessment ID %d: %w", id, err)
 	}
 	log.Printf("INFO: [AssessmentRepository] Retrieved assessment ID %d.", id)
-	return assessment, nil
+	return &assessment, nil
 }
 
 // GetUserAssessmentByUserID 获取指定用户最新的或特定状态的评估记录
 func (r *assessmentRepository) GetUserAssessmentByUserID(userID string, statusFilter ...models.UserAssessmentStatus) (*models.UserAssessment, error) {
-	r.muAssessments.RLock()
-	defer r.muAssessments.RUnlock()
-
-	assessmentIDs, userExists := r.userIndex[userID]
-	if !userExists || len(assessmentIDs) == 0 {
-		log.Printf("INFO: [AssessmentRepository] GetUserAssessmentByUserID: No assessment records found for userID '%s'.", userID)
-		return nil, nil // Changed to return nil, nil for not found, service layer will interpret
-	}
-
-	var latestMatchingAssessment *models.UserAssessment
-	var latestTimestamp time.Time
-
 	targetStatus := "" // 如果不提供statusFilter，则不按status过滤，只找最新的
+	query := r.db.Where("user_id = ?", userID)
 	if len(statusFilter) > 0 && statusFilter[0] != "" {
-		targetStatus = string(statusFilter[0]) // 将 models.UserAssessmentStatus 转为 string
+		targetStatus = string(statusFilter[0])
+		query = query.Where("status = ?", targetStatus)
 	}
 
-	for i := len(assessmentIDs) - 1; i >= 0; i-- { 
-		assessmentID := assessmentIDs[i]
-		assessment, exists := r.userAssessments[assessmentID]
-		if !exists {
-			log.Printf("ERROR: [AssessmentRepository] Data inconsistency: AssessmentID %d found in userIndex for userID '%s' but not in main userAssessments map.", assessmentID, userID)
-			continue 
-		}
-
-		if targetStatus != "" { 
-			if string(assessment.Status) == targetStatus { 
-				if latestMatchingAssessment == nil || assessment.UpdatedAt.After(latestTimestamp) {
-					latestMatchingAssessment = assessment
-					latestTimestamp = assessment.UpdatedAt
-				}
-			}
-		} else { 
-			if latestMatchingAssessment == nil || assessment.UpdatedAt.After(latestTimestamp) {
-				latestMatchingAssessment = assessment
-				latestTimestamp = assessment.UpdatedAt
-			}
+	var assessment models.UserAssessment
+	err := query.Order("updated_at desc, id desc").First(&assessment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("INFO: [AssessmentRepository] GetUserAssessmentByUserID: No assessment found for userID '%s' with status filter '%s'.", userID, targetStatus)
+			return nil, nil // Not found, service layer will interpret
 		}
+		log.Printf("ERROR: [AssessmentRepository] Failed to retrieve assessment for userID '%s' (Filter: '%s'): %v", userID, targetStatus, err)
+		return nil, fmt.Errorf("failed to retrieve assessment for userID '%s': %w", userID, err)
 	}
 
-	if latestMatchingAssessment == nil {
-		log.Printf("INFO: [AssessmentRepository] GetUserAssessmentByUserID: No assessment found for userID '%s' with status filter '%s'.", userID, targetStatus)
-		return nil, nil // Changed to return nil, nil for not found
-	}
-	
-	log.Printf("INFO: [AssessmentRepository] Retrieved assessment ID %d (Status: %s) for userID '%s' (Filter: '%s').", latestMatchingAssessment.ID, latestMatchingAssessment.Status, userID, targetStatus)
-	return latestMatchingAssessment, nil
+	log.Printf("INFO: [AssessmentRepository] Retrieved assessment ID %d (Status: %s) for userID '%s' (Filter: '%s').", assessment.ID, assessment.Status, userID, targetStatus)
+	return &assessment, nil
 }
 
 // UpdateUserAssessment 更新用户评估记录
 func (r *assessmentRepository) UpdateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {
-	r.muAssessments.Lock()
-	defer r.muAssessments.Unlock()
-
-	originalAssessment, exists := r.userAssessments[assessment.ID]
-	if !exists {
-		log.Printf("WARN: [AssessmentRepository] UpdateUserAssessment: Assessment with ID %d not found.", assessment.ID)
-		return nil, fmt.Errorf("update failed: assessment record with ID %d not found", assessment.ID)
+	var originalAssessment models.UserAssessment
+	err := r.db.First(&originalAssessment, assessment.ID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("WARN: [AssessmentRepository] UpdateUserAssessment: Assessment with ID %d not found.", assessment.ID)
+			return nil, fmt.Errorf("update failed: assessment record with ID %d not found", assessment.ID)
+		}
+		log.Printf("ERROR: [AssessmentRepository] Failed to load assessment ID %d for update: %v", assessment.ID, err)
+		return nil, fmt.Errorf("failed to load assessment ID %d for update: %w", assessment.ID, err)
 	}
 
 	// Preserve immutable fields
 	assessment.UserID = originalAssessment.UserID
 	assessment.StartedAt = originalAssessment.StartedAt
 	assessment.CreatedAt = originalAssessment.CreatedAt
-	assessment.UpdatedAt = time.Now() // Ensure update time is set
 
-	r.userAssessments[assessment.ID] = assessment
+	if err := r.db.Save(assessment).Error; err != nil {
+		log.Printf("ERROR: [AssessmentRepository] Failed to update assessment ID %d: %v", assessment.ID, err)
+		return nil, fmt.Errorf("failed to update assessment ID %d: %w", assessment.ID, err)
+	}
 	log.Printf("INFO: [AssessmentRepository] Updated user assessment: ID=%d, UserID=%s, Status=%s", assessment.ID, assessment.UserID, assessment.Status)
 	return assessment, nil
-}
\ No newline at end of file
+}
+
+// ListUserAssessments 按创建顺序（旧→新）返回用户的全部评估记录，可按状态过滤（用于复评历史与对比）
+func (r *assessmentRepository) ListUserAssessments(userID string, statusFilter ...models.UserAssessmentStatus) ([]*models.UserAssessment, error) {
+	targetStatus := ""
+	query := r.db.Where("user_id = ?", userID)
+	if len(statusFilter) > 0 && statusFilter[0] != "" {
+		targetStatus = string(statusFilter[0])
+		query = query.Where("status = ?", targetStatus)
+	}
+
+	var assessments []*models.UserAssessment
+	if err := query.Order("id asc").Find(&assessments).Error; err != nil { // ID 按创建顺序递增
+		log.Printf("ERROR: [AssessmentRepository] Failed to list assessments for userID '%s' (Filter: '%s'): %v", userID, targetStatus, err)
+		return nil, fmt.Errorf("failed to list assessments for userID '%s': %w", userID, err)
+	}
+	log.Printf("INFO: [AssessmentRepository] Listed %d assessments for userID '%s' (Filter: '%s').", len(assessments), userID, targetStatus)
+	return assessments, nil
+}
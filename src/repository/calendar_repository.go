@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeedRepository defines the interface for storing calendar subscriptions.
+type CalendarFeedRepository interface {
+	GetFeedByUserID(userID string) (*models.CalendarFeed, error)
+	GetFeedByToken(token string) (*models.CalendarFeed, error)
+	SaveFeed(feed *models.CalendarFeed) error // Creates or updates
+	DeleteFeed(userID string) error
+}
+
+type calendarFeedRepository struct {
+	db *gorm.DB
+}
+
+// NewCalendarFeedRepository creates a new instance of CalendarFeedRepository.
+func NewCalendarFeedRepository(db *gorm.DB) CalendarFeedRepository {
+	return &calendarFeedRepository{db: db}
+}
+
+// GetFeedByUserID retrieves the calendar subscription of a user.
+func (r *calendarFeedRepository) GetFeedByUserID(userID string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := r.db.Where("user_id = ?", userID).First(&feed).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [CalendarFeedRepository] Failed to retrieve calendar feed of userID %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to retrieve calendar feed of userID %s: %w", userID, err)
+	}
+	return &feed, nil
+}
+
+// GetFeedByToken retrieves the calendar subscription with the given token.
+func (r *calendarFeedRepository) GetFeedByToken(token string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	err := r.db.Where("token = ?", token).First(&feed).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [CalendarFeedRepository] Failed to retrieve calendar feed by token: %v", err)
+		return nil, fmt.Errorf("failed to retrieve calendar feed by token: %w", err)
+	}
+	return &feed, nil
+}
+
+// SaveFeed creates or updates a calendar subscription.
+func (r *calendarFeedRepository) SaveFeed(feed *models.CalendarFeed) error {
+	if feed == nil {
+		log.Printf("ERROR: [CalendarFeedRepository] SaveFeed: feed cannot be nil")
+		return errors.New("feed cannot be nil")
+	}
+	if err := r.db.Save(feed).Error; err != nil {
+		log.Printf("ERROR: [CalendarFeedRepository] Failed to save calendar feed of userID %s: %v", feed.UserID, err)
+		return fmt.Errorf("failed to save calendar feed of userID %s: %w", feed.UserID, err)
+	}
+	log.Printf("INFO: [CalendarFeedRepository] Saved calendar feed ID %d of userID %s.", feed.ID, feed.UserID)
+	return nil
+}
+
+// DeleteFeed removes the calendar subscription of a user, invalidating its URL.
+func (r *calendarFeedRepository) DeleteFeed(userID string) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error; err != nil {
+		log.Printf("ERROR: [CalendarFeedRepository] Failed to delete calendar feed of userID %s: %v", userID, err)
+		return fmt.Errorf("failed to delete calendar feed of userID %s: %w", userID, err)
+	}
+	log.Printf("INFO: [CalendarFeedRepository] Deleted calendar feed of userID %s.", userID)
+	return nil
+}
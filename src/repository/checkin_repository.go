@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// CheckInRepository defines the interface for storing check-ins of plan task occurrences.
+type CheckInRepository interface {
+	CreateCheckIn(checkIn *models.TaskCheckIn) error
+	UpdateCheckIn(checkIn *models.TaskCheckIn) error
+	GetCheckInsByTaskID(taskID uint) ([]models.TaskCheckIn, error) // Ordered by due date and slot
+	GetCheckInsByPlanID(planID uint) ([]models.TaskCheckIn, error) // Ordered by due date, task and slot
+}
+
+type checkInRepository struct {
+	db *gorm.DB
+}
+
+// NewCheckInRepository creates a new instance of CheckInRepository.
+func NewCheckInRepository(db *gorm.DB) CheckInRepository {
+	return &checkInRepository{db: db}
+}
+
+// CreateCheckIn stores the check-in of a task occurrence.
+func (r *checkInRepository) CreateCheckIn(checkIn *models.TaskCheckIn) error {
+	if checkIn == nil {
+		log.Printf("ERROR: [CheckInRepository] CreateCheckIn: check-in cannot be nil")
+		return errors.New("check-in cannot be nil")
+	}
+	if err := r.db.Create(checkIn).Error; err != nil {
+		log.Printf("ERROR: [CheckInRepository] Failed to create check-in for task ID %d on %s (slot %d): %v", checkIn.TaskID, checkIn.DueDate, checkIn.Slot, err)
+		return fmt.Errorf("failed to create check-in for task ID %d on %s: %w", checkIn.TaskID, checkIn.DueDate, err)
+	}
+	log.Printf("INFO: [CheckInRepository] Recorded check-in ID %d (%s) for task ID %d on %s (slot %d).", checkIn.ID, checkIn.Status, checkIn.TaskID, checkIn.DueDate, checkIn.Slot)
+	return nil
+}
+
+// UpdateCheckIn updates an existing check-in, e.g. when a skipped occurrence is completed after all.
+func (r *checkInRepository) UpdateCheckIn(checkIn *models.TaskCheckIn) error {
+	if checkIn == nil {
+		log.Printf("ERROR: [CheckInRepository] UpdateCheckIn: check-in cannot be nil")
+		return errors.New("check-in cannot be nil")
+	}
+	if checkIn.ID == 0 {
+		log.Printf("ERROR: [CheckInRepository] UpdateCheckIn: check-in ID must be provided for update")
+		return errors.New("check-in ID must be provided for update")
+	}
+	if err := r.db.Save(checkIn).Error; err != nil {
+		log.Printf("ERROR: [CheckInRepository] Failed to update check-in ID %d: %v", checkIn.ID, err)
+		return fmt.Errorf("failed to update check-in ID %d: %w", checkIn.ID, err)
+	}
+	log.Printf("INFO: [CheckInRepository] Updated check-in ID %d to %s.", checkIn.ID, checkIn.Status)
+	return nil
+}
+
+// GetCheckInsByTaskID retrieves all check-ins of a task.
+func (r *checkInRepository) GetCheckInsByTaskID(taskID uint) ([]models.TaskCheckIn, error) {
+	var checkIns []models.TaskCheckIn
+	if err := r.db.Where("task_id = ?", taskID).Order("due_date asc, slot asc").Find(&checkIns).Error; err != nil {
+		log.Printf("ERROR: [CheckInRepository] Failed to retrieve check-ins for task ID %d: %v", taskID, err)
+		return nil, fmt.Errorf("failed to retrieve check-ins for task ID %d: %w", taskID, err)
+	}
+	return checkIns, nil
+}
+
+// GetCheckInsByPlanID retrieves all check-ins of the tasks of a plan.
+func (r *checkInRepository) GetCheckInsByPlanID(planID uint) ([]models.TaskCheckIn, error) {
+	var checkIns []models.TaskCheckIn
+	if err := r.db.Where("plan_id = ?", planID).Order("due_date asc, task_id asc, slot asc").Find(&checkIns).Error; err != nil {
+		log.Printf("ERROR: [CheckInRepository] Failed to retrieve check-ins for plan ID %d: %v", planID, err)
+		return nil, fmt.Errorf("failed to retrieve check-ins for plan ID %d: %w", planID, err)
+	}
+	return checkIns, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ContentFeedbackRepository defines the interface for storing users' feedback on articles and exercises.
+type ContentFeedbackRepository interface {
+	SaveFeedback(feedback *models.ContentFeedback) error // Replaces the user's earlier vote on the same content
+	GetFeedbackSummary(contentType models.ContentType, contentID uint) (*models.ContentFeedbackSummary, error)
+	// ListFeedbackSummaries counts the votes on each content of a type that has any, most "not useful" votes first.
+	ListFeedbackSummaries(contentType models.ContentType) ([]*models.ContentFeedbackSummary, error)
+}
+
+type contentFeedbackRepository struct {
+	db *gorm.DB
+}
+
+// NewContentFeedbackRepository creates a new instance of ContentFeedbackRepository.
+func NewContentFeedbackRepository(db *gorm.DB) ContentFeedbackRepository {
+	return &contentFeedbackRepository{db: db}
+}
+
+// feedbackCounts selects the useful and not useful votes of the grouped feedback.
+const feedbackCounts = "content_type, content_id, " +
+	"SUM(CASE WHEN useful THEN 1 ELSE 0 END) AS useful, SUM(CASE WHEN useful THEN 0 ELSE 1 END) AS not_useful"
+
+// SaveFeedback stores a user's vote, or updates it if the user has voted on the content before.
+func (r *contentFeedbackRepository) SaveFeedback(feedback *models.ContentFeedback) error {
+	if feedback == nil {
+		log.Printf("ERROR: [ContentFeedbackRepository] SaveFeedback: feedback cannot be nil")
+		return errors.New("feedback cannot be nil")
+	}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_type"}, {Name: "content_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"useful", "comment", "updated_at"}),
+	}).Create(feedback).Error
+	if err != nil {
+		log.Printf("ERROR: [ContentFeedbackRepository] Failed to save feedback of userID %s on %s ID %d: %v", feedback.UserID, feedback.ContentType, feedback.ContentID, err)
+		return fmt.Errorf("failed to save feedback on %s ID %d: %w", feedback.ContentType, feedback.ContentID, err)
+	}
+	return nil
+}
+
+// GetFeedbackSummary counts the votes on one article or exercise.
+func (r *contentFeedbackRepository) GetFeedbackSummary(contentType models.ContentType, contentID uint) (*models.ContentFeedbackSummary, error) {
+	summary := &models.ContentFeedbackSummary{ContentType: contentType, ContentID: contentID}
+	var summaries []*models.ContentFeedbackSummary
+	err := r.db.Model(&models.ContentFeedback{}).Select(feedbackCounts).
+		Where("content_type = ? AND content_id = ?", contentType, contentID).
+		Group("content_type, content_id").Scan(&summaries).Error
+	if err != nil {
+		log.Printf("ERROR: [ContentFeedbackRepository] Failed to count feedback on %s ID %d: %v", contentType, contentID, err)
+		return nil, fmt.Errorf("failed to count feedback on %s ID %d: %w", contentType, contentID, err)
+	}
+	if len(summaries) > 0 {
+		summary.Useful, summary.NotUseful = summaries[0].Useful, summaries[0].NotUseful
+	}
+	return summary, nil
+}
+
+// ListFeedbackSummaries counts the votes on each article or exercise of contentType.
+func (r *contentFeedbackRepository) ListFeedbackSummaries(contentType models.ContentType) ([]*models.ContentFeedbackSummary, error) {
+	var summaries []*models.ContentFeedbackSummary
+	err := r.db.Model(&models.ContentFeedback{}).Select(feedbackCounts).
+		Where("content_type = ?", contentType).
+		Group("content_type, content_id").Order("not_useful desc, content_id asc").Scan(&summaries).Error
+	if err != nil {
+		log.Printf("ERROR: [ContentFeedbackRepository] Failed to list feedback on %s content: %v", contentType, err)
+		return nil, fmt.Errorf("failed to list feedback on %s content: %w", contentType, err)
+	}
+	return summaries, nil
+}
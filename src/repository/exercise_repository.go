@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// ExerciseRepository defines the interface for storing the exercise library.
+type ExerciseRepository interface {
+	CreateExercise(exercise *models.Exercise) error
+	GetExerciseByID(exerciseID uint) (*models.Exercise, error)
+	GetExercisesByIDs(exerciseIDs []uint) ([]*models.Exercise, error) // Those that exist, in no particular order
+	ListExercises(query models.ExerciseQuery) ([]*models.Exercise, error)
+	UpdateExercise(exercise *models.Exercise) error
+	DeleteExercise(exerciseID uint) error // Soft delete; tasks referencing the exercise keep its ID
+}
+
+type exerciseRepository struct {
+	db *gorm.DB
+}
+
+// NewExerciseRepository creates a new instance of ExerciseRepository.
+func NewExerciseRepository(db *gorm.DB) ExerciseRepository {
+	return &exerciseRepository{db: db}
+}
+
+// CreateExercise stores a new exercise.
+func (r *exerciseRepository) CreateExercise(exercise *models.Exercise) error {
+	if exercise == nil {
+		log.Printf("ERROR: [ExerciseRepository] CreateExercise: exercise cannot be nil")
+		return errors.New("exercise cannot be nil")
+	}
+	if err := r.db.Create(exercise).Error; err != nil {
+		log.Printf("ERROR: [ExerciseRepository] Failed to create exercise '%s': %v", exercise.Name, err)
+		return fmt.Errorf("failed to create exercise '%s': %w", exercise.Name, err)
+	}
+	log.Printf("INFO: [ExerciseRepository] Created exercise ID %d ('%s').", exercise.ID, exercise.Name)
+	return nil
+}
+
+// GetExerciseByID retrieves an exercise by its ID.
+func (r *exerciseRepository) GetExerciseByID(exerciseID uint) (*models.Exercise, error) {
+	var exercise models.Exercise
+	err := r.db.First(&exercise, exerciseID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [ExerciseRepository] Failed to retrieve exercise ID %d: %v", exerciseID, err)
+		return nil, fmt.Errorf("failed to retrieve exercise ID %d: %w", exerciseID, err)
+	}
+	return &exercise, nil
+}
+
+// GetExercisesByIDs retrieves the exercises with the given IDs.
+func (r *exerciseRepository) GetExercisesByIDs(exerciseIDs []uint) ([]*models.Exercise, error) {
+	var exercises []*models.Exercise
+	if len(exerciseIDs) == 0 {
+		return exercises, nil
+	}
+	if err := r.db.Where("id IN ?", exerciseIDs).Find(&exercises).Error; err != nil {
+		log.Printf("ERROR: [ExerciseRepository] Failed to retrieve exercises %v: %v", exerciseIDs, err)
+		return nil, fmt.Errorf("failed to retrieve exercises: %w", err)
+	}
+	return exercises, nil
+}
+
+// ListExercises retrieves the exercises matching query, oldest first.
+func (r *exerciseRepository) ListExercises(query models.ExerciseQuery) ([]*models.Exercise, error) {
+	var exercises []*models.Exercise
+	db := r.db.Order("id asc")
+	if query.Status != "" {
+		db = db.Where("review_status = ?", query.Status)
+	}
+	if query.ReviewedAfter != nil {
+		db = db.Where("reviewed_at > ?", *query.ReviewedAfter)
+	}
+	if query.Category != "" {
+		db = db.Where("category = ?", query.Category)
+	}
+	if query.Difficulty != "" {
+		db = db.Where("difficulty = ?", query.Difficulty)
+	}
+	if err := db.Find(&exercises).Error; err != nil {
+		log.Printf("ERROR: [ExerciseRepository] Failed to list exercises: %v", err)
+		return nil, fmt.Errorf("failed to list exercises: %w", err)
+	}
+	return exercises, nil
+}
+
+// UpdateExercise saves changes to an existing exercise.
+func (r *exerciseRepository) UpdateExercise(exercise *models.Exercise) error {
+	if exercise == nil || exercise.ID == 0 {
+		log.Printf("ERROR: [ExerciseRepository] UpdateExercise: exercise ID must be provided for update")
+		return errors.New("exercise ID must be provided for update")
+	}
+	if err := r.db.Save(exercise).Error; err != nil {
+		log.Printf("ERROR: [ExerciseRepository] Failed to update exercise ID %d: %v", exercise.ID, err)
+		return fmt.Errorf("failed to update exercise ID %d: %w", exercise.ID, err)
+	}
+	log.Printf("INFO: [ExerciseRepository] Updated exercise ID %d.", exercise.ID)
+	return nil
+}
+
+// DeleteExercise soft-deletes an exercise.
+func (r *exerciseRepository) DeleteExercise(exerciseID uint) error {
+	if err := r.db.Delete(&models.Exercise{}, exerciseID).Error; err != nil {
+		log.Printf("ERROR: [ExerciseRepository] Failed to delete exercise ID %d: %v", exerciseID, err)
+		return fmt.Errorf("failed to delete exercise ID %d: %w", exerciseID, err)
+	}
+	log.Printf("INFO: [ExerciseRepository] Deleted exercise ID %d.", exerciseID)
+	return nil
+}
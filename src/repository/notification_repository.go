@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationRepository defines the interface for storing notifications sent to users and their notification
+// preferences.
+type NotificationRepository interface {
+	CreateNotification(notification *models.Notification) error
+	UpdateNotification(notification *models.Notification) error
+	NotificationExists(dedupeKey string) (bool, error)
+	CountDelivered(userID string, since, until time.Time) (int64, error) // Delivered in [since, until)
+	GetDueDeferred(now time.Time) ([]models.Notification, error)         // Deferred notifications whose DeliverAt has passed
+	GetNotificationByID(id uint) (*models.Notification, error)           // Returns nil, nil if not found
+	GetDelivered(userID string, unreadOnly bool, beforeID uint, limit int) ([]models.Notification, error)
+	CountUnread(userID string) (int64, error)
+	MarkAllRead(userID string, readAt time.Time) (int64, error)
+	GetPreferences(userID string) (*models.NotificationPreferences, error) // Returns nil, nil if the user has none
+	SavePreferences(prefs *models.NotificationPreferences) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepository creates a new instance of NotificationRepository.
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// CreateNotification stores a notification. It fails if one with the same dedupe key exists.
+func (r *notificationRepository) CreateNotification(notification *models.Notification) error {
+	if notification == nil {
+		log.Printf("ERROR: [NotificationRepository] CreateNotification: notification cannot be nil")
+		return errors.New("notification cannot be nil")
+	}
+	if err := r.db.Create(notification).Error; err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to create %s notification for userID %s: %v", notification.Category, notification.UserID, err)
+		return fmt.Errorf("failed to create %s notification for userID %s: %w", notification.Category, notification.UserID, err)
+	}
+	return nil
+}
+
+// UpdateNotification saves the delivery state of a notification.
+func (r *notificationRepository) UpdateNotification(notification *models.Notification) error {
+	if notification == nil {
+		log.Printf("ERROR: [NotificationRepository] UpdateNotification: notification cannot be nil")
+		return errors.New("notification cannot be nil")
+	}
+	if err := r.db.Save(notification).Error; err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to update notification ID %d: %v", notification.ID, err)
+		return fmt.Errorf("failed to update notification ID %d: %w", notification.ID, err)
+	}
+	return nil
+}
+
+// NotificationExists reports whether a notification with the dedupe key was already sent.
+func (r *notificationRepository) NotificationExists(dedupeKey string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Notification{}).Where("dedupe_key = ?", dedupeKey).Count(&count).Error; err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to look up notification '%s': %v", dedupeKey, err)
+		return false, fmt.Errorf("failed to look up notification '%s': %w", dedupeKey, err)
+	}
+	return count > 0, nil
+}
+
+// CountDelivered counts the notifications delivered to a user in [since, until).
+func (r *notificationRepository) CountDelivered(userID string, since, until time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND status = ? AND delivered_at >= ? AND delivered_at < ?", userID, models.NotificationStatusDelivered, since, until).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to count notifications delivered to userID %s: %v", userID, err)
+		return 0, fmt.Errorf("failed to count notifications delivered to userID %s: %w", userID, err)
+	}
+	return count, nil
+}
+
+// GetDueDeferred retrieves the deferred notifications of all users that are due, oldest first.
+func (r *notificationRepository) GetDueDeferred(now time.Time) ([]models.Notification, error) {
+	var notifications []models.Notification
+	err := r.db.Where("status = ? AND deliver_at <= ?", models.NotificationStatusDeferred, now).Order("deliver_at asc, id asc").Find(&notifications).Error
+	if err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to retrieve due deferred notifications: %v", err)
+		return nil, fmt.Errorf("failed to retrieve due deferred notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// GetNotificationByID retrieves a notification by its ID.
+func (r *notificationRepository) GetNotificationByID(id uint) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.db.First(&notification, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [NotificationRepository] Failed to retrieve notification ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to retrieve notification ID %d: %w", id, err)
+	}
+	return &notification, nil
+}
+
+// GetDelivered retrieves up to limit of the notifications delivered to a user, newest first. If beforeID is not 0,
+// only those older than that notification are returned.
+func (r *notificationRepository) GetDelivered(userID string, unreadOnly bool, beforeID uint, limit int) ([]models.Notification, error) {
+	query := r.db.Where("user_id = ? AND status = ?", userID, models.NotificationStatusDelivered)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	var notifications []models.Notification
+	if err := query.Order("id desc").Limit(limit).Find(&notifications).Error; err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to retrieve notifications of userID %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to retrieve notifications of userID %s: %w", userID, err)
+	}
+	return notifications, nil
+}
+
+// CountUnread counts the notifications delivered to a user that they have not read.
+func (r *notificationRepository) CountUnread(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND status = ? AND read_at IS NULL", userID, models.NotificationStatusDelivered).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to count unread notifications of userID %s: %v", userID, err)
+		return 0, fmt.Errorf("failed to count unread notifications of userID %s: %w", userID, err)
+	}
+	return count, nil
+}
+
+// MarkAllRead marks all notifications delivered to a user as read and returns the number that were unread.
+func (r *notificationRepository) MarkAllRead(userID string, readAt time.Time) (int64, error) {
+	result := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND status = ? AND read_at IS NULL", userID, models.NotificationStatusDelivered).
+		Update("read_at", readAt)
+	if result.Error != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to mark notifications of userID %s as read: %v", userID, result.Error)
+		return 0, fmt.Errorf("failed to mark notifications of userID %s as read: %w", userID, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetPreferences retrieves a user's notification preferences.
+func (r *notificationRepository) GetPreferences(userID string) (*models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	if err := r.db.Where("user_id = ?", userID).First(&prefs).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [NotificationRepository] Failed to retrieve notification preferences of userID %s: %v", userID, err)
+		return nil, fmt.Errorf("failed to retrieve notification preferences of userID %s: %w", userID, err)
+	}
+	return &prefs, nil
+}
+
+// SavePreferences creates or updates a user's notification preferences.
+func (r *notificationRepository) SavePreferences(prefs *models.NotificationPreferences) error {
+	if prefs == nil {
+		log.Printf("ERROR: [NotificationRepository] SavePreferences: preferences cannot be nil")
+		return errors.New("preferences cannot be nil")
+	}
+	if err := r.db.Save(prefs).Error; err != nil {
+		log.Printf("ERROR: [NotificationRepository] Failed to save notification preferences of userID %s: %v", prefs.UserID, err)
+		return fmt.Errorf("failed to save notification preferences of userID %s: %w", prefs.UserID, err)
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ type PlanRepository interface {
 	CreatePlan(plan *models.Plan) error
 	GetPlanByID(planID uint) (*models.Plan, error)
 	GetPlansByUserID(userID string) ([]*models.Plan, error)
+	GetPlansByStatus(status models.PlanStatus) ([]*models.Plan, error) // All users' plans with the status, preloading their tasks
 	UpdatePlan(plan *models.Plan) error
 	DeletePlan(planID uint, hardDelete bool) error // Added hardDelete flag for soft/hard delete choice
 	CreatePlanTask(task *models.PlanTask) error
@@ -20,6 +21,8 @@ type PlanRepository interface {
 	GetTaskByID(taskID uint) (*models.PlanTask, error) // Added to support UpdatePlanTask and MarkTaskCompleted/Skipped
 	UpdatePlanTask(task *models.PlanTask) error
 	DeletePlanTask(taskID uint, hardDelete bool) error // Added hardDelete flag
+	RestorePlanTask(taskID uint) error                 // Undoes a soft delete, e.g. when rolling back to a plan version
+	GetTasksWithoutRecurrence() ([]*models.PlanTask, error) // Tasks whose Frequency has not been parsed into a Recurrence
 }
 
 type planRepository struct {
@@ -77,6 +80,17 @@ func (r *planRepository) GetPlansByUserID(userID string) ([]*models.Plan, error)
 	return plans, nil // Returns empty slice if no plans found, which is fine
 }
 
+// GetPlansByStatus retrieves the plans of all users that have the given status, preloading their tasks.
+func (r *planRepository) GetPlansByStatus(status models.PlanStatus) ([]*models.Plan, error) {
+	var plans []*models.Plan
+	err := r.db.Preload("Tasks").Where("status = ?", status).Order("id asc").Find(&plans).Error
+	if err != nil {
+		log.Printf("ERROR: [PlanRepository] Failed to retrieve %s plans: %v", status, err)
+		return nil, fmt.Errorf("failed to retrieve %s plans: %w", status, err)
+	}
+	return plans, nil
+}
+
 // UpdatePlan updates an existing plan in the database.
 func (r *planRepository) UpdatePlan(plan *models.Plan) error {
 	if plan == nil {
@@ -191,6 +205,17 @@ func (r *planRepository) UpdatePlanTask(task *models.PlanTask) error {
 	return nil
 }
 
+// GetTasksWithoutRecurrence retrieves the tasks that have no structured recurrence yet.
+func (r *planRepository) GetTasksWithoutRecurrence() ([]*models.PlanTask, error) {
+	var tasks []*models.PlanTask
+	err := r.db.Where("recurrence IS NULL").Find(&tasks).Error
+	if err != nil {
+		log.Printf("ERROR: [PlanRepository] Failed to retrieve tasks without recurrence: %v", err)
+		return nil, fmt.Errorf("failed to retrieve tasks without recurrence: %w", err)
+	}
+	return tasks, nil
+}
+
 // DeletePlanTask deletes a task by its ID.
 func (r *planRepository) DeletePlanTask(taskID uint, hardDelete bool) error {
 	var dbQuery *gorm.DB
@@ -210,3 +235,14 @@ func (r *planRepository) DeletePlanTask(taskID uint, hardDelete bool) error {
 	log.Printf("INFO: [PlanRepository] Successfully %s task ID %d.", action, taskID)
 	return nil
 }
+
+// RestorePlanTask undoes the soft delete of a task.
+func (r *planRepository) RestorePlanTask(taskID uint) error {
+	err := r.db.Unscoped().Model(&models.PlanTask{}).Where("id = ?", taskID).Update("deleted_at", nil).Error
+	if err != nil {
+		log.Printf("ERROR: [PlanRepository] Failed to restore task ID %d: %v", taskID, err)
+		return fmt.Errorf("failed to restore task ID %d: %w", taskID, err)
+	}
+	log.Printf("INFO: [PlanRepository] Successfully restored task ID %d.", taskID)
+	return nil
+}
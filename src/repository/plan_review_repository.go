@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// PlanReviewRepository defines the interface for storing plan safety reviews.
+type PlanReviewRepository interface {
+	CreateReview(review *models.PlanReview) error
+	GetReviewsByPlanID(planID uint) ([]models.PlanReview, error) // Oldest first
+}
+
+type planReviewRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanReviewRepository creates a new instance of PlanReviewRepository.
+func NewPlanReviewRepository(db *gorm.DB) PlanReviewRepository {
+	return &planReviewRepository{db: db}
+}
+
+// CreateReview stores the result of a plan safety review.
+func (r *planReviewRepository) CreateReview(review *models.PlanReview) error {
+	if review == nil {
+		log.Printf("ERROR: [PlanReviewRepository] CreateReview: review cannot be nil")
+		return errors.New("review cannot be nil")
+	}
+	if err := r.db.Create(review).Error; err != nil {
+		log.Printf("ERROR: [PlanReviewRepository] Failed to create review for plan ID %d: %v", review.PlanID, err)
+		return fmt.Errorf("failed to create review for plan ID %d: %w", review.PlanID, err)
+	}
+	log.Printf("INFO: [PlanReviewRepository] Recorded review ID %d (outcome %s) for plan ID %d.", review.ID, review.Outcome, review.PlanID)
+	return nil
+}
+
+// GetReviewsByPlanID retrieves all reviews of a plan.
+func (r *planReviewRepository) GetReviewsByPlanID(planID uint) ([]models.PlanReview, error) {
+	var reviews []models.PlanReview
+	if err := r.db.Where("plan_id = ?", planID).Order("id asc").Find(&reviews).Error; err != nil {
+		log.Printf("ERROR: [PlanReviewRepository] Failed to retrieve reviews for plan ID %d: %v", planID, err)
+		return nil, fmt.Errorf("failed to retrieve reviews for plan ID %d: %w", planID, err)
+	}
+	return reviews, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// PlanTemplateRepository defines the interface for storing plan templates.
+type PlanTemplateRepository interface {
+	CreateTemplate(template *models.PlanTemplate) error
+	GetTemplateByID(templateID uint) (*models.PlanTemplate, error)
+	ListTemplates(publishedOnly bool) ([]*models.PlanTemplate, error) // Oldest first
+	UpdateTemplate(template *models.PlanTemplate) error
+	DeleteTemplate(templateID uint) error // Soft delete; plans created from the template are kept
+}
+
+type planTemplateRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanTemplateRepository creates a new instance of PlanTemplateRepository.
+func NewPlanTemplateRepository(db *gorm.DB) PlanTemplateRepository {
+	return &planTemplateRepository{db: db}
+}
+
+// CreateTemplate stores a new plan template.
+func (r *planTemplateRepository) CreateTemplate(template *models.PlanTemplate) error {
+	if template == nil {
+		log.Printf("ERROR: [PlanTemplateRepository] CreateTemplate: template cannot be nil")
+		return errors.New("template cannot be nil")
+	}
+	if err := r.db.Create(template).Error; err != nil {
+		log.Printf("ERROR: [PlanTemplateRepository] Failed to create template '%s': %v", template.Title, err)
+		return fmt.Errorf("failed to create template '%s': %w", template.Title, err)
+	}
+	log.Printf("INFO: [PlanTemplateRepository] Created template ID %d ('%s').", template.ID, template.Title)
+	return nil
+}
+
+// GetTemplateByID retrieves a plan template by its ID.
+func (r *planTemplateRepository) GetTemplateByID(templateID uint) (*models.PlanTemplate, error) {
+	var template models.PlanTemplate
+	err := r.db.First(&template, templateID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [PlanTemplateRepository] Failed to retrieve template ID %d: %v", templateID, err)
+		return nil, fmt.Errorf("failed to retrieve template ID %d: %w", templateID, err)
+	}
+	return &template, nil
+}
+
+// ListTemplates retrieves all plan templates, or only the published ones.
+func (r *planTemplateRepository) ListTemplates(publishedOnly bool) ([]*models.PlanTemplate, error) {
+	var templates []*models.PlanTemplate
+	query := r.db.Order("id asc")
+	if publishedOnly {
+		query = query.Where("published = ?", true)
+	}
+	if err := query.Find(&templates).Error; err != nil {
+		log.Printf("ERROR: [PlanTemplateRepository] Failed to list templates: %v", err)
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	return templates, nil
+}
+
+// UpdateTemplate saves changes to an existing plan template.
+func (r *planTemplateRepository) UpdateTemplate(template *models.PlanTemplate) error {
+	if template == nil || template.ID == 0 {
+		log.Printf("ERROR: [PlanTemplateRepository] UpdateTemplate: template ID must be provided for update")
+		return errors.New("template ID must be provided for update")
+	}
+	if err := r.db.Save(template).Error; err != nil {
+		log.Printf("ERROR: [PlanTemplateRepository] Failed to update template ID %d: %v", template.ID, err)
+		return fmt.Errorf("failed to update template ID %d: %w", template.ID, err)
+	}
+	log.Printf("INFO: [PlanTemplateRepository] Updated template ID %d.", template.ID)
+	return nil
+}
+
+// DeleteTemplate soft-deletes a plan template.
+func (r *planTemplateRepository) DeleteTemplate(templateID uint) error {
+	if err := r.db.Delete(&models.PlanTemplate{}, templateID).Error; err != nil {
+		log.Printf("ERROR: [PlanTemplateRepository] Failed to delete template ID %d: %v", templateID, err)
+		return fmt.Errorf("failed to delete template ID %d: %w", templateID, err)
+	}
+	log.Printf("INFO: [PlanTemplateRepository] Deleted template ID %d.", templateID)
+	return nil
+}
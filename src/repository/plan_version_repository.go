@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+
+	"gorm.io/gorm"
+)
+
+// PlanVersionRepository defines the interface for storing plan version snapshots.
+type PlanVersionRepository interface {
+	CreateVersion(version *models.PlanVersion) error
+	GetVersionsByPlanID(planID uint) ([]models.PlanVersion, error) // Oldest first
+	GetVersion(planID uint, version int) (*models.PlanVersion, error)
+	GetLatestVersion(planID uint) (*models.PlanVersion, error)
+	GetUnversionedPlanIDs() ([]uint, error) // Plans created before versioning, without any snapshot
+}
+
+type planVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanVersionRepository creates a new instance of PlanVersionRepository.
+func NewPlanVersionRepository(db *gorm.DB) PlanVersionRepository {
+	return &planVersionRepository{db: db}
+}
+
+// CreateVersion stores a plan version snapshot.
+func (r *planVersionRepository) CreateVersion(version *models.PlanVersion) error {
+	if version == nil {
+		log.Printf("ERROR: [PlanVersionRepository] CreateVersion: version cannot be nil")
+		return errors.New("version cannot be nil")
+	}
+	if err := r.db.Create(version).Error; err != nil {
+		log.Printf("ERROR: [PlanVersionRepository] Failed to create version %d of plan ID %d: %v", version.Version, version.PlanID, err)
+		return fmt.Errorf("failed to create version %d of plan ID %d: %w", version.Version, version.PlanID, err)
+	}
+	log.Printf("INFO: [PlanVersionRepository] Recorded version %d of plan ID %d (author %s).", version.Version, version.PlanID, version.Author)
+	return nil
+}
+
+// GetVersionsByPlanID retrieves all versions of a plan.
+func (r *planVersionRepository) GetVersionsByPlanID(planID uint) ([]models.PlanVersion, error) {
+	var versions []models.PlanVersion
+	if err := r.db.Where("plan_id = ?", planID).Order("version asc").Find(&versions).Error; err != nil {
+		log.Printf("ERROR: [PlanVersionRepository] Failed to retrieve versions of plan ID %d: %v", planID, err)
+		return nil, fmt.Errorf("failed to retrieve versions of plan ID %d: %w", planID, err)
+	}
+	return versions, nil
+}
+
+// GetVersion retrieves one version of a plan.
+func (r *planVersionRepository) GetVersion(planID uint, version int) (*models.PlanVersion, error) {
+	var planVersion models.PlanVersion
+	err := r.db.Where("plan_id = ? AND version = ?", planID, version).First(&planVersion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [PlanVersionRepository] Failed to retrieve version %d of plan ID %d: %v", version, planID, err)
+		return nil, fmt.Errorf("failed to retrieve version %d of plan ID %d: %w", version, planID, err)
+	}
+	return &planVersion, nil
+}
+
+// GetLatestVersion retrieves the newest version of a plan.
+func (r *planVersionRepository) GetLatestVersion(planID uint) (*models.PlanVersion, error) {
+	var planVersion models.PlanVersion
+	err := r.db.Where("plan_id = ?", planID).Order("version desc").First(&planVersion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [PlanVersionRepository] Failed to retrieve latest version of plan ID %d: %v", planID, err)
+		return nil, fmt.Errorf("failed to retrieve latest version of plan ID %d: %w", planID, err)
+	}
+	return &planVersion, nil
+}
+
+// GetUnversionedPlanIDs retrieves the IDs of plans that have no version yet.
+func (r *planVersionRepository) GetUnversionedPlanIDs() ([]uint, error) {
+	var planIDs []uint
+	err := r.db.Model(&models.Plan{}).
+		Where("id NOT IN (?)", r.db.Model(&models.PlanVersion{}).Select("plan_id")).
+		Pluck("id", &planIDs).Error
+	if err != nil {
+		log.Printf("ERROR: [PlanVersionRepository] Failed to retrieve unversioned plans: %v", err)
+		return nil, fmt.Errorf("failed to retrieve unversioned plans: %w", err)
+	}
+	return planIDs, nil
+}
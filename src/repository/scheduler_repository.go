@@ -2,77 +2,195 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"time"
 
 	"project/models"
+
+	"gorm.io/gorm"
 )
 
-// SchedulerRepository 调度仓库接口
+// SchedulerRepository 调度仓库接口：后台任务（models.Task）及其执行记录（models.TaskExecution）
 type SchedulerRepository interface {
-	SaveTask(task models.Task) (uint, error)
+	CreateTask(task *models.Task) error
 	GetAllTasks() ([]models.Task, error)
-	GetTaskByID(id uint) (models.Task, error)
-	UpdateTask(task models.Task) error
+	GetTaskByID(id uint) (*models.Task, error)       // 不存在时返回 nil, nil
+	GetTaskByName(name string) (*models.Task, error) // 不存在时返回 nil, nil
+	UpdateTask(task *models.Task) error              // 只更新描述与调度设置，不修改执行状态
 	DeleteTask(id uint) error
+	// AcquireTask 将任务标记为由 owner 执行，直到 until；任务正被其他实例执行且租约未过期时返回 false。
+	// dueOnly 为 true（定时触发）时，任务的下次执行时间未到也返回 false
+	AcquireTask(id uint, owner string, now, until time.Time, dueOnly bool) (bool, error)
+	// ReleaseTask 结束 owner 对任务的执行，并记录本次与下次执行时间
+	ReleaseTask(id uint, owner string, lastRunAt, nextRunAt *time.Time) error
+	CreateExecution(execution *models.TaskExecution) error
+	UpdateExecution(execution *models.TaskExecution) error
+	GetExecutions(taskID uint, limit int) ([]models.TaskExecution, error) // 最新的在前
+	DeleteExecutionsBefore(before time.Time) (int64, error)
 }
 
 // schedulerRepository 调度仓库实现
 type schedulerRepository struct {
-	// 这里可以添加数据库连接等
-	tasks []models.Task // 临时存储，实际应使用数据库
+	db *gorm.DB
 }
 
 // NewSchedulerRepository 创建调度仓库实例
-func NewSchedulerRepository() SchedulerRepository {
-	return &schedulerRepository{
-		tasks: make([]models.Task, 0),
-	}
+func NewSchedulerRepository(db *gorm.DB) SchedulerRepository {
+	return &schedulerRepository{db: db}
 }
 
-// SaveTask 保存任务
-func (r *schedulerRepository) SaveTask(task models.Task) (uint, error) {
-	// 模拟ID自增
-	task.ID = uint(len(r.tasks) + 1)
-	r.tasks = append(r.tasks, task)
-	return task.ID, nil
+// CreateTask 保存新任务
+func (r *schedulerRepository) CreateTask(task *models.Task) error {
+	if task == nil {
+		log.Printf("ERROR: [SchedulerRepository] CreateTask: task cannot be nil")
+		return errors.New("task cannot be nil")
+	}
+	if err := r.db.Create(task).Error; err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to create task '%s': %v", task.Name, err)
+		return fmt.Errorf("failed to create task '%s': %w", task.Name, err)
+	}
+	log.Printf("INFO: [SchedulerRepository] Created task ID %d ('%s').", task.ID, task.Name)
+	return nil
 }
 
 // GetAllTasks 获取所有任务
 func (r *schedulerRepository) GetAllTasks() ([]models.Task, error) {
-	return r.tasks, nil
+	var tasks []models.Task
+	if err := r.db.Order("id asc").Find(&tasks).Error; err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to retrieve tasks: %v", err)
+		return nil, fmt.Errorf("failed to retrieve tasks: %w", err)
+	}
+	return tasks, nil
 }
 
 // GetTaskByID 根据ID获取任务
-func (r *schedulerRepository) GetTaskByID(id uint) (models.Task, error) {
-	for _, task := range r.tasks {
-		if task.ID == id {
-			return task, nil
+func (r *schedulerRepository) GetTaskByID(id uint) (*models.Task, error) {
+	var task models.Task
+	if err := r.db.First(&task, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
 		}
+		log.Printf("ERROR: [SchedulerRepository] Failed to retrieve task ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to retrieve task ID %d: %w", id, err)
 	}
-
-	return models.Task{}, errors.New("任务不存在")
+	return &task, nil
 }
 
-// UpdateTask 更新任务
-func (r *schedulerRepository) UpdateTask(task models.Task) error {
-	for i, t := range r.tasks {
-		if t.ID == task.ID {
-			r.tasks[i] = task
-			return nil
+// GetTaskByName 根据名称获取任务
+func (r *schedulerRepository) GetTaskByName(name string) (*models.Task, error) {
+	var task models.Task
+	if err := r.db.Where("name = ?", name).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
 		}
+		log.Printf("ERROR: [SchedulerRepository] Failed to retrieve task '%s': %v", name, err)
+		return nil, fmt.Errorf("failed to retrieve task '%s': %w", name, err)
 	}
+	return &task, nil
+}
 
-	return errors.New("任务不存在")
+// UpdateTask 更新任务的描述与调度设置。执行状态只通过 AcquireTask 与 ReleaseTask 修改，以免覆盖正在执行的任务
+func (r *schedulerRepository) UpdateTask(task *models.Task) error {
+	if task == nil {
+		log.Printf("ERROR: [SchedulerRepository] UpdateTask: task cannot be nil")
+		return errors.New("task cannot be nil")
+	}
+	err := r.db.Model(task).Select("description", "cron_expr", "enabled", "next_run_at").Updates(task).Error
+	if err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to update task ID %d: %v", task.ID, err)
+		return fmt.Errorf("failed to update task ID %d: %w", task.ID, err)
+	}
+	return nil
 }
 
-// DeleteTask 删除任务
+// DeleteTask 删除任务及其执行记录
 func (r *schedulerRepository) DeleteTask(id uint) error {
-	for i, task := range r.tasks {
-		if task.ID == id {
-			// 从切片中删除元素
-			r.tasks = append(r.tasks[:i], r.tasks[i+1:]...)
-			return nil
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", id).Delete(&models.TaskExecution{}).Error; err != nil {
+			return err
 		}
+		return tx.Delete(&models.Task{}, id).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to delete task ID %d: %v", id, err)
+		return fmt.Errorf("failed to delete task ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// AcquireTask 获取任务的执行权。条件更新保证多个实例中只有一个能获取成功；
+// 定时触发时还要求下次执行时间已到，以免其他实例刚执行完并释放的任务按旧的任务列表再次执行
+func (r *schedulerRepository) AcquireTask(id uint, owner string, now, until time.Time, dueOnly bool) (bool, error) {
+	query := r.db.Model(&models.Task{}).
+		Where("id = ? AND (status <> ? OR locked_until IS NULL OR locked_until < ?)", id, models.JobStatusRunning, now)
+	if dueOnly {
+		query = query.Where("next_run_at IS NOT NULL AND next_run_at <= ?", now)
+	}
+	result := query.Updates(map[string]interface{}{"status": models.JobStatusRunning, "locked_by": owner, "locked_until": until})
+	if result.Error != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to acquire task ID %d: %v", id, result.Error)
+		return false, fmt.Errorf("failed to acquire task ID %d: %w", id, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseTask 释放任务的执行权，lastRunAt 为 nil 时不修改上次执行时间。租约已被其他实例接管时不做修改
+func (r *schedulerRepository) ReleaseTask(id uint, owner string, lastRunAt, nextRunAt *time.Time) error {
+	updates := map[string]interface{}{"status": models.JobStatusIdle, "locked_by": "", "locked_until": nil, "next_run_at": nextRunAt}
+	if lastRunAt != nil {
+		updates["last_run_at"] = lastRunAt
+	}
+	err := r.db.Model(&models.Task{}).Where("id = ? AND locked_by = ?", id, owner).Updates(updates).Error
+	if err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to release task ID %d: %v", id, err)
+		return fmt.Errorf("failed to release task ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// CreateExecution 保存执行记录
+func (r *schedulerRepository) CreateExecution(execution *models.TaskExecution) error {
+	if execution == nil {
+		log.Printf("ERROR: [SchedulerRepository] CreateExecution: execution cannot be nil")
+		return errors.New("execution cannot be nil")
+	}
+	if err := r.db.Create(execution).Error; err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to create execution of task ID %d: %v", execution.TaskID, err)
+		return fmt.Errorf("failed to create execution of task ID %d: %w", execution.TaskID, err)
 	}
+	return nil
+}
 
-	return errors.New("任务不存在")
+// UpdateExecution 更新执行记录
+func (r *schedulerRepository) UpdateExecution(execution *models.TaskExecution) error {
+	if execution == nil {
+		log.Printf("ERROR: [SchedulerRepository] UpdateExecution: execution cannot be nil")
+		return errors.New("execution cannot be nil")
+	}
+	if err := r.db.Save(execution).Error; err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to update execution ID %d: %v", execution.ID, err)
+		return fmt.Errorf("failed to update execution ID %d: %w", execution.ID, err)
+	}
+	return nil
+}
+
+// GetExecutions 获取任务最近的执行记录
+func (r *schedulerRepository) GetExecutions(taskID uint, limit int) ([]models.TaskExecution, error) {
+	var executions []models.TaskExecution
+	if err := r.db.Where("task_id = ?", taskID).Order("start_time desc, id desc").Limit(limit).Find(&executions).Error; err != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to retrieve executions of task ID %d: %v", taskID, err)
+		return nil, fmt.Errorf("failed to retrieve executions of task ID %d: %w", taskID, err)
+	}
+	return executions, nil
+}
+
+// DeleteExecutionsBefore 删除在 before 之前开始且已结束的执行记录，返回删除条数
+func (r *schedulerRepository) DeleteExecutionsBefore(before time.Time) (int64, error) {
+	result := r.db.Where("start_time < ? AND status <> ?", before, models.ExecutionStatusRunning).Delete(&models.TaskExecution{})
+	if result.Error != nil {
+		log.Printf("ERROR: [SchedulerRepository] Failed to delete executions before %s: %v", before.Format(time.RFC3339), result.Error)
+		return 0, fmt.Errorf("failed to delete executions before %s: %w", before.Format(time.RFC3339), result.Error)
+	}
+	return result.RowsAffected, nil
 }
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookRepository defines the interface for the queue of webhook deliveries and their dead letters.
+type WebhookRepository interface {
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+	DeleteDelivery(id uint) error
+	GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) // Oldest due first
+	// MoveToDeadLetter stores the dead letter and deletes the delivery it replaces, in one transaction.
+	MoveToDeadLetter(delivery *models.WebhookDelivery, deadLetter *models.WebhookDeadLetter) error
+	GetDeadLetters(limit int) ([]models.WebhookDeadLetter, error) // Newest first
+	GetDeadLetterByID(id uint) (*models.WebhookDeadLetter, error) // Returns nil, nil if not found
+	// ReplayDeadLetter queues the delivery and deletes the dead letter it replaces, in one transaction.
+	ReplayDeadLetter(deadLetter *models.WebhookDeadLetter, delivery *models.WebhookDelivery) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository.
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// CreateDelivery queues a webhook delivery.
+func (r *webhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	if delivery == nil {
+		log.Printf("ERROR: [WebhookRepository] CreateDelivery: delivery cannot be nil")
+		return errors.New("delivery cannot be nil")
+	}
+	if err := r.db.Create(delivery).Error; err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to queue event %s for endpoint '%s': %v", delivery.EventID, delivery.Endpoint, err)
+		return fmt.Errorf("failed to queue event %s for endpoint '%s': %w", delivery.EventID, delivery.Endpoint, err)
+	}
+	return nil
+}
+
+// UpdateDelivery saves the attempts of a delivery.
+func (r *webhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	if delivery == nil {
+		log.Printf("ERROR: [WebhookRepository] UpdateDelivery: delivery cannot be nil")
+		return errors.New("delivery cannot be nil")
+	}
+	if err := r.db.Save(delivery).Error; err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to update delivery ID %d: %v", delivery.ID, err)
+		return fmt.Errorf("failed to update delivery ID %d: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// DeleteDelivery removes a delivery from the queue.
+func (r *webhookRepository) DeleteDelivery(id uint) error {
+	if err := r.db.Delete(&models.WebhookDelivery{}, id).Error; err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to delete delivery ID %d: %v", id, err)
+		return fmt.Errorf("failed to delete delivery ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// GetDueDeliveries retrieves up to limit deliveries whose next attempt is due.
+func (r *webhookRepository) GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.Where("next_attempt_at <= ?", now).Order("next_attempt_at asc, id asc").Limit(limit).Find(&deliveries).Error; err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to retrieve due deliveries: %v", err)
+		return nil, fmt.Errorf("failed to retrieve due deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// MoveToDeadLetter replaces a delivery that failed all its attempts with a dead letter.
+func (r *webhookRepository) MoveToDeadLetter(delivery *models.WebhookDelivery, deadLetter *models.WebhookDeadLetter) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(deadLetter).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.WebhookDelivery{}, delivery.ID).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to move delivery ID %d to the dead letters: %v", delivery.ID, err)
+		return fmt.Errorf("failed to move delivery ID %d to the dead letters: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// GetDeadLetters retrieves up to limit dead letters.
+func (r *webhookRepository) GetDeadLetters(limit int) ([]models.WebhookDeadLetter, error) {
+	var deadLetters []models.WebhookDeadLetter
+	if err := r.db.Order("id desc").Limit(limit).Find(&deadLetters).Error; err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to retrieve dead letters: %v", err)
+		return nil, fmt.Errorf("failed to retrieve dead letters: %w", err)
+	}
+	return deadLetters, nil
+}
+
+// GetDeadLetterByID retrieves a dead letter by its ID.
+func (r *webhookRepository) GetDeadLetterByID(id uint) (*models.WebhookDeadLetter, error) {
+	var deadLetter models.WebhookDeadLetter
+	if err := r.db.First(&deadLetter, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not found
+		}
+		log.Printf("ERROR: [WebhookRepository] Failed to retrieve dead letter ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to retrieve dead letter ID %d: %w", id, err)
+	}
+	return &deadLetter, nil
+}
+
+// ReplayDeadLetter queues a dead letter for delivery again.
+func (r *webhookRepository) ReplayDeadLetter(deadLetter *models.WebhookDeadLetter, delivery *models.WebhookDelivery) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(delivery).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.WebhookDeadLetter{}, deadLetter.ID).Error
+	})
+	if err != nil {
+		log.Printf("ERROR: [WebhookRepository] Failed to replay dead letter ID %d: %v", deadLetter.ID, err)
+		return fmt.Errorf("failed to replay dead letter ID %d: %w", deadLetter.ID, err)
+	}
+	return nil
+}
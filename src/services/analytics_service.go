@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"project/repository"
+	"sort"
+	"time"
+)
+
+// AnalyticsService builds reports for administrators from recorded events.
+type AnalyticsService interface {
+	GetAssessmentFunnel(filter models.AssessmentEventFilter) ([]models.AssessmentFunnelReport, error)
+}
+
+type analyticsService struct {
+	eventRepo         repository.AssessmentEventRepository
+	assessmentService AssessmentService // Source of question order and text
+}
+
+// NewAnalyticsService creates a new instance of AnalyticsService.
+func NewAnalyticsService(eventRepo repository.AssessmentEventRepository, assessmentService AssessmentService) AnalyticsService {
+	return &analyticsService{
+		eventRepo:         eventRepo,
+		assessmentService: assessmentService,
+	}
+}
+
+// GetAssessmentFunnel returns the assessment drop-off funnel, one report per questionnaire version and user type.
+func (s *analyticsService) GetAssessmentFunnel(filter models.AssessmentEventFilter) ([]models.AssessmentFunnelReport, error) {
+	if s.eventRepo == nil {
+		return nil, errors.New("assessment event repository not initialized")
+	}
+	events, err := s.eventRepo.ListEvents(filter)
+	if err != nil {
+		errMsg := "failed to load assessment events for the funnel report"
+		log.Printf("ERROR: [AnalyticsService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+
+	var questions []models.AssessmentQuestion
+	if s.assessmentService != nil {
+		questions = s.assessmentService.GetQuestions()
+	}
+	reports := buildFunnelReports(questions, events)
+	log.Printf("INFO: [AnalyticsService] Built %d assessment funnel reports from %d events.", len(reports), len(events))
+	return reports, nil
+}
+
+// funnelSession identifies one assessment session in the events. Assessment IDs are only unique per user, since
+// they have not always survived restarts.
+type funnelSession struct {
+	userID       string
+	assessmentID uint
+}
+
+// funnelQuestionStats accumulates the per-question data of one report.
+type funnelQuestionStats struct {
+	firstShown map[funnelSession]time.Time // First time the question was shown in each session
+	answered   map[funnelSession]bool
+	cancelled  map[funnelSession]bool
+	durations  []float64 // Seconds from first shown to first answered
+}
+
+// buildFunnelReports groups events by questionnaire version and user type and computes the funnel of each group.
+// Events must be ordered by time. Steps follow the order of questions; questions unknown to the current
+// questionnaire (e.g. from an older version) are appended in the order they were first seen.
+func buildFunnelReports(questions []models.AssessmentQuestion, events []models.AssessmentEvent) []models.AssessmentFunnelReport {
+	type groupKey struct{ version, userType string }
+	type group struct {
+		started, completed, cancelled map[funnelSession]bool
+		stats                         map[string]*funnelQuestionStats
+		seenOrder                     []string
+	}
+
+	groups := make(map[groupKey]*group)
+	var keys []groupKey
+	for _, event := range events {
+		key := groupKey{event.QuestionnaireVersion, event.UserType}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{started: map[funnelSession]bool{}, completed: map[funnelSession]bool{}, cancelled: map[funnelSession]bool{}, stats: map[string]*funnelQuestionStats{}}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		session := funnelSession{event.UserID, event.AssessmentID}
+		g.started[session] = true
+
+		stats, ok := g.stats[event.QuestionID]
+		if !ok {
+			stats = &funnelQuestionStats{firstShown: map[funnelSession]time.Time{}, answered: map[funnelSession]bool{}, cancelled: map[funnelSession]bool{}}
+			g.stats[event.QuestionID] = stats
+			g.seenOrder = append(g.seenOrder, event.QuestionID)
+		}
+
+		switch event.EventType {
+		case models.AssessmentEventShown:
+			if _, shown := stats.firstShown[session]; !shown {
+				stats.firstShown[session] = event.CreatedAt
+			}
+		case models.AssessmentEventAnswered:
+			if !stats.answered[session] {
+				stats.answered[session] = true
+				if shownAt, shown := stats.firstShown[session]; shown {
+					stats.durations = append(stats.durations, event.CreatedAt.Sub(shownAt).Seconds())
+				}
+			}
+		case models.AssessmentEventCancelled:
+			stats.cancelled[session] = true
+			g.cancelled[session] = true
+		case models.AssessmentEventCompleted:
+			g.completed[session] = true
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].version != keys[j].version {
+			return keys[i].version < keys[j].version
+		}
+		return keys[i].userType < keys[j].userType
+	})
+
+	reports := make([]models.AssessmentFunnelReport, 0, len(keys))
+	for _, key := range keys {
+		g := groups[key]
+		report := models.AssessmentFunnelReport{
+			QuestionnaireVersion: key.version,
+			UserType:             key.userType,
+			Started:              len(g.started),
+			Completed:            len(g.completed),
+			Cancelled:            len(g.cancelled),
+			Steps:                []models.FunnelStep{},
+		}
+		if report.Started > 0 {
+			report.CompletionRate = float64(report.Completed) / float64(report.Started)
+		}
+
+		order := make([]string, 0, len(g.seenOrder))
+		texts := make(map[string]string, len(questions))
+		for _, q := range questions {
+			texts[q.ID] = q.Text
+			if _, ok := g.stats[q.ID]; ok {
+				order = append(order, q.ID)
+			}
+		}
+		for _, questionID := range g.seenOrder {
+			if _, known := texts[questionID]; !known {
+				order = append(order, questionID)
+			}
+		}
+
+		for _, questionID := range order {
+			stats := g.stats[questionID]
+			step := models.FunnelStep{
+				QuestionID:    questionID,
+				QuestionText:  texts[questionID],
+				Shown:         len(stats.firstShown),
+				Answered:      len(stats.answered),
+				Cancelled:     len(stats.cancelled),
+				MedianSeconds: median(stats.durations),
+			}
+			for session := range stats.firstShown {
+				if !stats.answered[session] {
+					step.DropOff++
+				}
+			}
+			if step.Shown > 0 {
+				step.DropOffRate = float64(step.DropOff) / float64(step.Shown)
+			}
+			report.Steps = append(report.Steps, step)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// median returns the median of the values, or 0 if there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
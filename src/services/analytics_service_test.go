@@ -0,0 +1,143 @@
+package services
+
+import (
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAssessmentEventRepository is a mock type for the AssessmentEventRepository interface
+type MockAssessmentEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockAssessmentEventRepository) RecordEvent(event *models.AssessmentEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockAssessmentEventRepository) ListEvents(filter models.AssessmentEventFilter) ([]models.AssessmentEvent, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.AssessmentEvent), args.Error(1)
+}
+
+func funnelEvent(assessmentID uint, userType, questionID string, eventType models.AssessmentEventType, at time.Time) models.AssessmentEvent {
+	return models.AssessmentEvent{AssessmentID: assessmentID, UserType: userType, QuestionnaireVersion: "v1", QuestionID: questionID, EventType: eventType, CreatedAt: at}
+}
+
+func TestBuildFunnelReports(t *testing.T) {
+	questions := getDefaultAssessmentQuestions()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	events := []models.AssessmentEvent{
+		// Guest 1 answers the welcome question after 10s and consent after 30s, then completes.
+		funnelEvent(1, "guest", "q_welcome", models.AssessmentEventShown, start),
+		funnelEvent(1, "guest", "q_welcome", models.AssessmentEventAnswered, start.Add(10*time.Second)),
+		funnelEvent(1, "guest", "q_privacy_consent", models.AssessmentEventShown, start.Add(10*time.Second)),
+		funnelEvent(1, "guest", "q_privacy_consent", models.AssessmentEventAnswered, start.Add(40*time.Second)),
+		funnelEvent(1, "guest", "q_privacy_consent", models.AssessmentEventCompleted, start.Add(40*time.Second)),
+		// Guest 2 answers the welcome question after 20s and declines consent.
+		funnelEvent(2, "guest", "q_welcome", models.AssessmentEventShown, start),
+		funnelEvent(2, "guest", "q_welcome", models.AssessmentEventAnswered, start.Add(20*time.Second)),
+		funnelEvent(2, "guest", "q_privacy_consent", models.AssessmentEventShown, start.Add(20*time.Second)),
+		funnelEvent(2, "guest", "q_privacy_consent", models.AssessmentEventCancelled, start.Add(25*time.Second)),
+		// Guest 3 sees the welcome question twice and leaves; only the first showing counts.
+		funnelEvent(3, "guest", "q_welcome", models.AssessmentEventShown, start),
+		funnelEvent(3, "guest", "q_welcome", models.AssessmentEventShown, start.Add(time.Hour)),
+		// A registered user declines at the welcome question.
+		funnelEvent(4, "registered", "q_welcome", models.AssessmentEventShown, start),
+		funnelEvent(4, "registered", "q_welcome", models.AssessmentEventCancelled, start.Add(5*time.Second)),
+	}
+
+	reports := buildFunnelReports(questions, events)
+
+	assert.Len(t, reports, 2)
+	guest := reports[0]
+	assert.Equal(t, "guest", guest.UserType)
+	assert.Equal(t, "v1", guest.QuestionnaireVersion)
+	assert.Equal(t, 3, guest.Started)
+	assert.Equal(t, 1, guest.Completed)
+	assert.Equal(t, 1, guest.Cancelled)
+	assert.InDelta(t, 1.0/3.0, guest.CompletionRate, 0.0001)
+	assert.Len(t, guest.Steps, 2)
+
+	welcome := guest.Steps[0]
+	assert.Equal(t, "q_welcome", welcome.QuestionID)
+	assert.Equal(t, 3, welcome.Shown)
+	assert.Equal(t, 2, welcome.Answered)
+	assert.Equal(t, 1, welcome.DropOff)
+	assert.Equal(t, 15.0, welcome.MedianSeconds) // median of 10s and 20s
+
+	consent := guest.Steps[1]
+	assert.Equal(t, "q_privacy_consent", consent.QuestionID)
+	assert.Equal(t, 2, consent.Shown)
+	assert.Equal(t, 1, consent.Cancelled)
+	assert.Equal(t, 1, consent.DropOff)
+	assert.Equal(t, 0.5, consent.DropOffRate)
+	assert.Equal(t, 30.0, consent.MedianSeconds)
+
+	registered := reports[1]
+	assert.Equal(t, "registered", registered.UserType)
+	assert.Equal(t, 1, registered.Cancelled)
+	assert.Equal(t, 1.0, registered.Steps[0].DropOffRate)
+
+	t.Run("Sessions of different users with the same assessment ID stay apart", func(t *testing.T) {
+		first := funnelEvent(1, "guest", "q_welcome", models.AssessmentEventShown, start)
+		first.UserID = "guest_a"
+		second := funnelEvent(1, "guest", "q_welcome", models.AssessmentEventShown, start.Add(24*time.Hour))
+		second.UserID = "guest_b"
+		answered := funnelEvent(1, "guest", "q_welcome", models.AssessmentEventAnswered, start.Add(24*time.Hour+10*time.Second))
+		answered.UserID = "guest_b"
+
+		reports := buildFunnelReports(questions, []models.AssessmentEvent{first, second, answered})
+
+		assert.Equal(t, 2, reports[0].Started)
+		assert.Equal(t, 2, reports[0].Steps[0].Shown)
+		assert.Equal(t, 1, reports[0].Steps[0].DropOff)
+		assert.Equal(t, 10.0, reports[0].Steps[0].MedianSeconds)
+	})
+}
+
+func TestAssessmentService_RecordsFunnelEvents(t *testing.T) {
+	mockRepo := new(MockAssessmentRepository)
+	mockEventRepo := new(MockAssessmentEventRepository)
+	service := NewAssessmentService(mockRepo, mockEventRepo)
+	userID := "guest_funnel"
+
+	t.Run("Declining at the welcome question records a cancellation", func(t *testing.T) {
+		assessment := &models.UserAssessment{ID: 5, UserID: userID, Status: models.AssessmentStatusInProgress, CurrentQuestionID: "q_welcome", QuestionnaireVersion: "v1"}
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(assessment, nil).Once()
+		mockRepo.On("UpdateUserAssessment", assessment).Return(assessment, nil).Once()
+		mockEventRepo.On("RecordEvent", mock.MatchedBy(func(e *models.AssessmentEvent) bool {
+			return e.AssessmentID == 5 && e.QuestionID == "q_welcome" && e.EventType == models.AssessmentEventCancelled && e.UserType == "guest"
+		})).Return(nil).Once()
+
+		_, _, userVisibleErr := service.SubmitAnswer(userID, "q_welcome", []string{"No, next time"})
+
+		assert.Error(t, userVisibleErr)
+		mockEventRepo.AssertExpectations(t)
+	})
+
+	t.Run("Answering records the answer and the next question shown", func(t *testing.T) {
+		assessment := &models.UserAssessment{ID: 6, UserID: userID, Status: models.AssessmentStatusInProgress, CurrentQuestionID: "q_welcome", QuestionnaireVersion: "v1"}
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(assessment, nil).Once()
+		mockRepo.On("UpdateUserAssessment", assessment).Return(assessment, nil).Once()
+		mockEventRepo.On("RecordEvent", mock.MatchedBy(func(e *models.AssessmentEvent) bool {
+			return e.AssessmentID == 6 && e.QuestionID == "q_welcome" && e.EventType == models.AssessmentEventAnswered
+		})).Return(nil).Once()
+		mockEventRepo.On("RecordEvent", mock.MatchedBy(func(e *models.AssessmentEvent) bool {
+			return e.AssessmentID == 6 && e.EventType == models.AssessmentEventShown
+		})).Return(nil).Once()
+
+		nextQuestion, _, userVisibleErr := service.SubmitAnswer(userID, "q_welcome", []string{"Yes, I'm ready"})
+
+		assert.NoError(t, userVisibleErr)
+		assert.NotNil(t, nextQuestion)
+		mockEventRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+	"time"
+)
+
+// maxArticlePage is the largest page of articles a list or search returns.
+const maxArticlePage = 100
+
+// ArticleService manages the knowledge base articles. Users only see approved articles.
+type ArticleService interface {
+	CreateArticle(input models.ArticleInput) (*models.Article, error)
+	UpdateArticle(articleID uint, input models.ArticleInput) (*models.Article, error) // Replaces the article's content
+	DeleteArticle(articleID uint) error
+	GetArticle(articleID uint, approvedOnly bool) (*models.Article, error)
+	// ListArticles returns a page of the articles matching query, best search matches first.
+	ListArticles(query models.ArticleQuery) (*models.ArticlePage, error)
+	ReviewArticle(articleID uint, input models.ReviewInput) (*models.Article, error) // Moves the article through the editorial review
+	// ExpireReviews sends the articles approved more than content_review.max_age_days ago back to review,
+	// returning how many.
+	ExpireReviews(now time.Time) (int, error)
+}
+
+type articleService struct {
+	articleRepo repository.ArticleRepository
+}
+
+// NewArticleService creates a new instance of ArticleService.
+func NewArticleService(articleRepo repository.ArticleRepository) ArticleService {
+	return &articleService{articleRepo: articleRepo}
+}
+
+func (s *articleService) CreateArticle(input models.ArticleInput) (*models.Article, error) {
+	article := &models.Article{ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusDraft}}
+	if err := applyArticleInput(article, input); err != nil {
+		return nil, err
+	}
+	if err := s.articleRepo.CreateArticle(article); err != nil {
+		errMsg := fmt.Sprintf("failed to create article '%s'", article.Title)
+		log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return article, nil
+}
+
+func (s *articleService) UpdateArticle(articleID uint, input models.ArticleInput) (*models.Article, error) {
+	article, err := s.GetArticle(articleID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyArticleInput(article, input); err != nil {
+		return nil, err
+	}
+	reviewAfterEdit(&article.ContentReview)
+	if err := s.articleRepo.UpdateArticle(article); err != nil {
+		errMsg := fmt.Sprintf("failed to update article ID %d", articleID)
+		log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return article, nil
+}
+
+func (s *articleService) DeleteArticle(articleID uint) error {
+	if _, err := s.GetArticle(articleID, false); err != nil {
+		return err
+	}
+	if err := s.articleRepo.DeleteArticle(articleID); err != nil {
+		errMsg := fmt.Sprintf("failed to delete article ID %d", articleID)
+		log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return nil
+}
+
+func (s *articleService) GetArticle(articleID uint, approvedOnly bool) (*models.Article, error) {
+	article, err := s.articleRepo.GetArticleByID(articleID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch article ID %d", articleID)
+		log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if article == nil || (approvedOnly && !currentlyApproved(&article.ContentReview, time.Now())) {
+		return nil, fmt.Errorf("article %d not found", articleID)
+	}
+	return article, nil
+}
+
+func (s *articleService) ListArticles(query models.ArticleQuery) (*models.ArticlePage, error) {
+	if query.Limit < 1 || query.Limit > maxArticlePage {
+		return nil, fmt.Errorf("invalid limit %d: must be between 1 and %d", query.Limit, maxArticlePage)
+	}
+	if query.Offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d: cannot be negative", query.Offset)
+	}
+	if query.Category != "" && !validArticleCategory(query.Category) {
+		return nil, fmt.Errorf("invalid category '%s'", query.Category)
+	}
+	if query.Status != "" && !validReviewStatus(query.Status) {
+		return nil, fmt.Errorf("invalid review status '%s'", query.Status)
+	}
+	query.Query = strings.TrimSpace(query.Query)
+	query.Tag = strings.TrimSpace(query.Tag)
+	if query.Status == models.ReviewStatusApproved {
+		query.ReviewedAfter = approvalCutoff(time.Now())
+	}
+
+	page, err := s.articleRepo.ListArticles(query)
+	if err != nil {
+		errMsg := "failed to list articles"
+		log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return page, nil
+}
+
+func (s *articleService) ReviewArticle(articleID uint, input models.ReviewInput) (*models.Article, error) {
+	article, err := s.GetArticle(articleID, false)
+	if err != nil {
+		return nil, err
+	}
+	from := article.ReviewStatus
+	if err := applyReview(&article.ContentReview, fmt.Sprintf("article %d", articleID), input, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.articleRepo.UpdateArticle(article); err != nil {
+		errMsg := fmt.Sprintf("failed to save the review of article ID %d", articleID)
+		log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	log.Printf("INFO: [ArticleService] Article ID %d moved from %s to %s by '%s'.", articleID, from, article.ReviewStatus, input.Reviewer)
+	return article, nil
+}
+
+func (s *articleService) ExpireReviews(now time.Time) (int, error) {
+	var approved []*models.Article
+	for offset := 0; ; offset += maxArticlePage {
+		page, err := s.articleRepo.ListArticles(models.ArticleQuery{Status: models.ReviewStatusApproved, Offset: offset, Limit: maxArticlePage})
+		if err != nil {
+			errMsg := "failed to list approved articles"
+			log.Printf("ERROR: [ArticleService] %s: %v", errMsg, err)
+			return 0, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		approved = append(approved, page.Articles...)
+		if len(page.Articles) < maxArticlePage {
+			break
+		}
+	}
+	expired := 0
+	for _, article := range approved {
+		if !reviewExpired(&article.ContentReview, now) {
+			continue
+		}
+		if err := s.articleRepo.UpdateArticle(article); err != nil {
+			log.Printf("ERROR: [ArticleService] Failed to send article ID %d back to review: %v", article.ID, err)
+			continue
+		}
+		expired++
+		log.Printf("INFO: [ArticleService] Approval of article ID %d expired; sent back to review.", article.ID)
+	}
+	return expired, nil
+}
+
+// ArticleLink returns the client link to an article.
+func ArticleLink(articleID uint) string {
+	format := config.AppConfig.Retrieval.ArticleLinkFormat
+	if format == "" {
+		format = "/articles/%d"
+	}
+	return fmt.Sprintf(format, articleID)
+}
+
+// applyArticleInput validates input and copies it onto article.
+func applyArticleInput(article *models.Article, input models.ArticleInput) error {
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return errors.New("invalid article: title is required")
+	}
+	if strings.TrimSpace(input.Body) == "" {
+		return errors.New("invalid article: body is required")
+	}
+	if !validArticleCategory(input.Category) {
+		return fmt.Errorf("invalid article: unknown category '%s'", input.Category)
+	}
+	tags := []string{}
+	for _, tag := range input.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	references := make([]models.ArticleReference, 0, len(input.References))
+	for i, reference := range input.References {
+		reference.Title = strings.TrimSpace(reference.Title)
+		reference.URL = strings.TrimSpace(reference.URL)
+		if reference.Title == "" {
+			return fmt.Errorf("invalid article: reference %d has no title", i+1)
+		}
+		references = append(references, reference)
+	}
+
+	article.Title = title
+	article.Body = input.Body
+	article.Category = input.Category
+	article.Tags = tags
+	article.References = references
+	return nil
+}
+
+func validArticleCategory(category models.ArticleCategory) bool {
+	for _, c := range models.ArticleCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
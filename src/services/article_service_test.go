@@ -0,0 +1,139 @@
+package services
+
+import (
+	"project/models"
+	"project/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockArticleRepository is a mock type for the ArticleRepository type
+type MockArticleRepository struct {
+	mock.Mock
+}
+
+func (m *MockArticleRepository) CreateArticle(article *models.Article) error {
+	args := m.Called(article)
+	return args.Error(0)
+}
+
+func (m *MockArticleRepository) GetArticleByID(articleID uint) (*models.Article, error) {
+	args := m.Called(articleID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Article), args.Error(1)
+}
+
+func (m *MockArticleRepository) UpdateArticle(article *models.Article) error {
+	args := m.Called(article)
+	return args.Error(0)
+}
+
+func (m *MockArticleRepository) DeleteArticle(articleID uint) error {
+	args := m.Called(articleID)
+	return args.Error(0)
+}
+
+func (m *MockArticleRepository) ListArticles(query models.ArticleQuery) (*models.ArticlePage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ArticlePage), args.Error(1)
+}
+
+func TestSearchTokens(t *testing.T) {
+	assert.Equal(t, []string{"凯格尔", "kegel", "练习", "10", "分钟"}, utils.SearchTerms("凯格尔(Kegel)练习：10 分钟！"))
+	assert.Equal(t, []string{"盆底", "底肌"}, utils.Bigrams("盆底肌"))
+	assert.Equal(t, []string{"肾"}, utils.Bigrams("肾"))
+	assert.Equal(t, []string{"kegel"}, utils.Bigrams("kegel"))
+	assert.Equal(t, []string{"盆底", "底肌", "肌", "pc", "肌肉", "肉"}, utils.SearchTokens("盆底肌 PC肌肉"),
+		"every character starts a token")
+	assert.Empty(t, utils.SearchTokens("，。!?"))
+}
+
+func articleTestInput() models.ArticleInput {
+	return models.ArticleInput{
+		Title:      " 盆底肌训练入门 ",
+		Body:       "## 什么是盆底肌\n……",
+		Category:   models.ArticleCategoryExerciseTechnique,
+		Tags:       []string{"凯格尔", " 盆底肌 ", "", "凯格尔"},
+		References: []models.ArticleReference{{Title: " 中国男科诊疗指南 ", URL: "https://example.org/guide"}},
+	}
+}
+
+func TestApplyArticleInput(t *testing.T) {
+	article := &models.Article{}
+	assert.NoError(t, applyArticleInput(article, articleTestInput()))
+	assert.Equal(t, "盆底肌训练入门", article.Title)
+	assert.Equal(t, []string{"凯格尔", "盆底肌"}, article.Tags, "trimmed, without empty or repeated tags")
+	assert.Equal(t, "中国男科诊疗指南", article.References[0].Title)
+
+	for name, change := range map[string]func(*models.ArticleInput){
+		"no title":           func(in *models.ArticleInput) { in.Title = " " },
+		"no body":            func(in *models.ArticleInput) { in.Body = "" },
+		"unknown category":   func(in *models.ArticleInput) { in.Category = "gossip" },
+		"untitled reference": func(in *models.ArticleInput) { in.References = []models.ArticleReference{{URL: "https://example.org"}} },
+	} {
+		input := articleTestInput()
+		change(&input)
+		err := applyArticleInput(&models.Article{}, input)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "invalid article", name)
+		}
+	}
+}
+
+func TestArticleService_GetArticle(t *testing.T) {
+	mockArticleRepo := new(MockArticleRepository)
+	service := NewArticleService(mockArticleRepo)
+	mockArticleRepo.On("GetArticleByID", uint(1)).Return(&models.Article{ID: 1, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusApproved}}, nil)
+	mockArticleRepo.On("GetArticleByID", uint(2)).Return(&models.Article{ID: 2, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusInReview}}, nil)
+	mockArticleRepo.On("GetArticleByID", uint(3)).Return(nil, nil)
+
+	article, err := service.GetArticle(1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), article.ID)
+
+	_, err = service.GetArticle(2, true)
+	assert.EqualError(t, err, "article 2 not found", "users only see approved articles")
+	article, err = service.GetArticle(2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), article.ID)
+
+	_, err = service.GetArticle(3, false)
+	assert.EqualError(t, err, "article 3 not found")
+}
+
+func TestArticleService_ListArticles(t *testing.T) {
+	mockArticleRepo := new(MockArticleRepository)
+	service := NewArticleService(mockArticleRepo)
+	page := &models.ArticlePage{Articles: []*models.Article{{ID: 4}}, Total: 7}
+	mockArticleRepo.On("ListArticles", models.ArticleQuery{
+		Query: "盆底肌", Status: models.ReviewStatusApproved, Category: models.ArticleCategoryExerciseTechnique, Tag: "凯格尔", Offset: 1, Limit: 1,
+	}).Return(page, nil).Once()
+
+	result, err := service.ListArticles(models.ArticleQuery{
+		Query: " 盆底肌 ", Status: models.ReviewStatusApproved, Category: models.ArticleCategoryExerciseTechnique, Tag: "凯格尔 ", Offset: 1, Limit: 1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
+	mockArticleRepo.AssertExpectations(t)
+
+	for name, query := range map[string]models.ArticleQuery{
+		"no limit":         {},
+		"limit too large":  {Limit: 101},
+		"negative offset":  {Limit: 20, Offset: -1},
+		"unknown category": {Limit: 20, Category: "gossip"},
+		"unknown status":   {Limit: 20, Status: "published"},
+	} {
+		_, err := service.ListArticles(query)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "invalid", name)
+		}
+	}
+	mockArticleRepo.AssertNumberOfCalls(t, "ListArticles", 1)
+}
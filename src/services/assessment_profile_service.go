@@ -0,0 +1,406 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+)
+
+// AssessmentProfileService turns completed assessments into structured AssessmentProfiles.
+type AssessmentProfileService interface {
+	BuildProfile(assessment *models.UserAssessment) (*models.AssessmentProfile, error) // Scores and persists the profile
+	GetProfileForAssessment(userID string, assessmentID uint) (*models.AssessmentProfile, error)
+	GetLatestProfile(userID string) (*models.AssessmentProfile, error)
+	CompareAssessments(userID string, fromAssessmentID, toAssessmentID uint) (*models.AssessmentComparison, error) // IDs of 0 compare the last two completed assessments
+	BuildProgressContext(userID string) (string, error)                                                            // Context header describing the latest changes, "" if there is nothing to compare
+}
+
+type assessmentProfileService struct {
+	profileRepo       repository.AssessmentProfileRepository
+	assessmentService AssessmentService // Source of question definitions for answer resolution
+}
+
+// NewAssessmentProfileService creates a new instance of AssessmentProfileService.
+func NewAssessmentProfileService(profileRepo repository.AssessmentProfileRepository, assessmentService AssessmentService) AssessmentProfileService {
+	return &assessmentProfileService{
+		profileRepo:       profileRepo,
+		assessmentService: assessmentService,
+	}
+}
+
+// BuildProfile scores a completed assessment using the configured scoring rules and persists the result.
+func (s *assessmentProfileService) BuildProfile(assessment *models.UserAssessment) (*models.AssessmentProfile, error) {
+	if assessment == nil {
+		return nil, errors.New("assessment cannot be nil")
+	}
+	if assessment.Status != models.AssessmentStatusCompleted {
+		log.Printf("WARN: [AssessmentProfileService] Refusing to score assessment ID %d with status '%s'.", assessment.ID, assessment.Status)
+		return nil, fmt.Errorf("assessment %d is not completed", assessment.ID)
+	}
+
+	var questions []models.AssessmentQuestion
+	if s.assessmentService != nil {
+		questions = s.assessmentService.GetQuestions()
+	}
+	profile := scoreAssessment(config.AppConfig.AssessmentScoring, questions, assessment)
+
+	if err := s.profileRepo.SaveProfile(profile); err != nil {
+		errMsg := fmt.Sprintf("failed to save profile for assessment %d (userID %s)", assessment.ID, assessment.UserID)
+		log.Printf("ERROR: [AssessmentProfileService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	log.Printf("INFO: [AssessmentProfileService] Built profile for assessment ID %d (userID %s): %d dimensions, risk flags %v.", assessment.ID, assessment.UserID, len(profile.Dimensions), profile.RiskFlags)
+	return profile, nil
+}
+
+// GetProfileForAssessment retrieves the profile derived from a specific assessment of a user.
+func (s *assessmentProfileService) GetProfileForAssessment(userID string, assessmentID uint) (*models.AssessmentProfile, error) {
+	profile, err := s.profileRepo.GetProfile(userID, assessmentID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to get profile for assessment %d", assessmentID)
+		log.Printf("ERROR: [AssessmentProfileService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return profile, nil // nil, nil if not found
+}
+
+// GetLatestProfile retrieves the most recent profile of a user.
+func (s *assessmentProfileService) GetLatestProfile(userID string) (*models.AssessmentProfile, error) {
+	if userID == "" {
+		return nil, errors.New("userID cannot be empty")
+	}
+	profile, err := s.profileRepo.GetLatestProfileByUserID(userID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to get latest profile for userID %s", userID)
+		log.Printf("ERROR: [AssessmentProfileService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return profile, nil // nil, nil if not found
+}
+
+// CompareAssessments compares two completed assessments of a user, answer by answer and dimension by dimension.
+// If both IDs are 0, the two most recent completed assessments are compared.
+func (s *assessmentProfileService) CompareAssessments(userID string, fromAssessmentID, toAssessmentID uint) (*models.AssessmentComparison, error) {
+	if userID == "" {
+		return nil, errors.New("userID cannot be empty")
+	}
+	if s.assessmentService == nil {
+		return nil, errors.New("assessment service not initialized")
+	}
+
+	var from, to *models.UserAssessment
+	if fromAssessmentID == 0 && toAssessmentID == 0 {
+		completed, err := s.assessmentService.ListAssessments(userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(completed) < 2 {
+			return nil, fmt.Errorf("at least two completed assessments are needed for a comparison, userID %s has %d", userID, len(completed))
+		}
+		from, to = completed[len(completed)-2], completed[len(completed)-1]
+	} else {
+		var err error
+		if from, err = s.assessmentService.GetUserAssessment(userID, fromAssessmentID); err != nil {
+			return nil, err
+		}
+		if to, err = s.assessmentService.GetUserAssessment(userID, toAssessmentID); err != nil {
+			return nil, err
+		}
+		if from == nil || to == nil {
+			return nil, fmt.Errorf("assessment %d or %d not found for userID %s", fromAssessmentID, toAssessmentID, userID)
+		}
+	}
+	if from.Status != models.AssessmentStatusCompleted || to.Status != models.AssessmentStatusCompleted {
+		return nil, fmt.Errorf("only completed assessments can be compared (assessment %d is '%s', assessment %d is '%s')", from.ID, from.Status, to.ID, to.Status)
+	}
+
+	fromProfile, err := s.profileForComparison(from)
+	if err != nil {
+		return nil, err
+	}
+	toProfile, err := s.profileForComparison(to)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := compareAssessments(config.AppConfig.AssessmentScoring, s.assessmentService.GetQuestions(), from, to, fromProfile, toProfile)
+	log.Printf("INFO: [AssessmentProfileService] Compared assessments %d -> %d for userID %s: %d answers changed.", from.ID, to.ID, userID, countChangedAnswers(comparison.Answers))
+	return comparison, nil
+}
+
+// profileForComparison returns the stored profile of an assessment, building it first if the assessment
+// was completed before scoring was available.
+func (s *assessmentProfileService) profileForComparison(assessment *models.UserAssessment) (*models.AssessmentProfile, error) {
+	profile, err := s.GetProfileForAssessment(assessment.UserID, assessment.ID)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		return profile, nil
+	}
+	return s.BuildProfile(assessment)
+}
+
+// BuildProgressContext builds a system instruction describing how the user changed between the last two completed
+// assessments, so agents such as the data analyst can discuss progress. It returns "" if there is nothing to compare.
+func (s *assessmentProfileService) BuildProgressContext(userID string) (string, error) {
+	if s.assessmentService == nil {
+		return "", errors.New("assessment service not initialized")
+	}
+	completed, err := s.assessmentService.ListAssessments(userID)
+	if err != nil {
+		return "", err
+	}
+	if len(completed) < 2 {
+		return "", nil
+	}
+	comparison, err := s.CompareAssessments(userID, 0, 0)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n[系统指令：评估对比]\n")
+	sb.WriteString("用户最近完成了一次复评。以下是与上一次评估相比的变化，请在合适时结合这些变化与用户讨论进展，肯定进步，温和地指出需要关注的方面。\n")
+	for _, dim := range comparison.Dimensions {
+		if dim.Delta == nil || *dim.Delta == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- 指标 %s：%s → %s（变化 %+g）\n", dim.DimensionID, dimensionText(dim.Before, dim.BeforeLabel), dimensionText(dim.After, dim.AfterLabel), *dim.Delta))
+	}
+	for _, change := range comparison.Answers {
+		if !change.Changed {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- %s：「%s」→「%s」\n", change.QuestionText, strings.Join(change.Before, "；"), strings.Join(change.After, "；")))
+	}
+	if len(comparison.RiskFlagsAdded) > 0 {
+		sb.WriteString(fmt.Sprintf("- 新出现的风险标记：%s\n", strings.Join(comparison.RiskFlagsAdded, ", ")))
+	}
+	if len(comparison.RiskFlagsRemoved) > 0 {
+		sb.WriteString(fmt.Sprintf("- 已消除的风险标记：%s\n", strings.Join(comparison.RiskFlagsRemoved, ", ")))
+	}
+	return sb.String(), nil
+}
+
+// compareAssessments computes the answer, dimension and risk flag changes between two assessments.
+// Answers are listed in questionnaire order and dimensions in the order of the scoring rules; consent questions are left out.
+func compareAssessments(rules config.AssessmentScoringConfig, questions []models.AssessmentQuestion, from, to *models.UserAssessment, fromProfile, toProfile *models.AssessmentProfile) *models.AssessmentComparison {
+	comparison := &models.AssessmentComparison{
+		UserID:           to.UserID,
+		FromAssessmentID: from.ID,
+		ToAssessmentID:   to.ID,
+		FromCompletedAt:  from.CompletedAt,
+		ToCompletedAt:    to.CompletedAt,
+		Answers:          []models.AnswerChange{},
+		Dimensions:       []models.DimensionChange{},
+		RiskFlagsAdded:   []string{},
+		RiskFlagsRemoved: []string{},
+	}
+
+	fromAnswers := answersByQuestion(from)
+	toAnswers := answersByQuestion(to)
+	for i := range questions {
+		question := &questions[i]
+		if question.QuestionType == models.QuestionTypeConfirmation {
+			continue
+		}
+		rawBefore, answeredBefore := fromAnswers[question.ID]
+		rawAfter, answeredAfter := toAnswers[question.ID]
+		if !answeredBefore && !answeredAfter {
+			continue
+		}
+		before := resolveAnswerOptions(question, rawBefore)
+		after := resolveAnswerOptions(question, rawAfter)
+		comparison.Answers = append(comparison.Answers, models.AnswerChange{
+			QuestionID:   question.ID,
+			QuestionText: question.Text,
+			Before:       before,
+			After:        after,
+			Changed:      !sameAnswers(before, after),
+		})
+	}
+
+	for _, dim := range rules.Dimensions { // Configured order keeps the output stable
+		change := models.DimensionChange{DimensionID: dim.ID}
+		if d, ok := fromProfile.Dimensions[dim.ID]; ok {
+			score := d.Score
+			change.Before, change.BeforeLabel = &score, d.Label
+		}
+		if d, ok := toProfile.Dimensions[dim.ID]; ok {
+			score := d.Score
+			change.After, change.AfterLabel = &score, d.Label
+		}
+		if change.Before == nil && change.After == nil {
+			continue
+		}
+		if change.Before != nil && change.After != nil {
+			delta := *change.After - *change.Before
+			change.Delta = &delta
+		}
+		comparison.Dimensions = append(comparison.Dimensions, change)
+	}
+
+	for _, flag := range toProfile.RiskFlags {
+		if !fromProfile.HasRiskFlag(flag) {
+			comparison.RiskFlagsAdded = append(comparison.RiskFlagsAdded, flag)
+		}
+	}
+	for _, flag := range fromProfile.RiskFlags {
+		if !toProfile.HasRiskFlag(flag) {
+			comparison.RiskFlagsRemoved = append(comparison.RiskFlagsRemoved, flag)
+		}
+	}
+	return comparison
+}
+
+func answersByQuestion(assessment *models.UserAssessment) map[string][]string {
+	answers := make(map[string][]string, len(assessment.Answers))
+	for _, ans := range assessment.Answers {
+		answers[ans.QuestionID] = ans.Answer
+	}
+	return answers
+}
+
+// sameAnswers compares two resolved answers ignoring case, surrounding spaces and selection order.
+func sameAnswers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalized := make(map[string]int, len(a))
+	for _, v := range a {
+		normalized[strings.ToLower(strings.TrimSpace(v))]++
+	}
+	for _, v := range b {
+		key := strings.ToLower(strings.TrimSpace(v))
+		if normalized[key] == 0 {
+			return false
+		}
+		normalized[key]--
+	}
+	return true
+}
+
+func countChangedAnswers(changes []models.AnswerChange) int {
+	count := 0
+	for _, c := range changes {
+		if c.Changed {
+			count++
+		}
+	}
+	return count
+}
+
+func dimensionText(score *float64, label string) string {
+	if score == nil {
+		return "无"
+	}
+	if label != "" {
+		return fmt.Sprintf("%g (%s)", *score, label)
+	}
+	return fmt.Sprintf("%g", *score)
+}
+
+// scoreAssessment applies the scoring rules to the answers of an assessment.
+// It is a pure function so that the rules can be tested without a repository.
+func scoreAssessment(rules config.AssessmentScoringConfig, questions []models.AssessmentQuestion, assessment *models.UserAssessment) *models.AssessmentProfile {
+	questionsByID := make(map[string]*models.AssessmentQuestion, len(questions))
+	for i := range questions {
+		questionsByID[questions[i].ID] = &questions[i]
+	}
+	answers := make(map[string][]string, len(assessment.Answers))
+	for _, ans := range assessment.Answers {
+		answers[ans.QuestionID] = resolveAnswerOptions(questionsByID[ans.QuestionID], ans.Answer)
+	}
+
+	profile := &models.AssessmentProfile{
+		AssessmentID: assessment.ID,
+		UserID:       assessment.UserID,
+		Dimensions:   make(map[string]models.ProfileDimension),
+		RiskFlags:    []string{},
+		Goals:        []string{},
+		Concerns:     []string{},
+	}
+
+	for _, dim := range rules.Dimensions {
+		values := answers[dim.QuestionID]
+		if len(values) == 0 {
+			continue
+		}
+		for _, opt := range dim.Options {
+			if strings.EqualFold(opt.Answer, values[0]) {
+				profile.Dimensions[dim.ID] = models.ProfileDimension{
+					Score:      opt.Score,
+					Label:      opt.Label,
+					QuestionID: dim.QuestionID,
+					Answer:     opt.Answer,
+				}
+				break
+			}
+		}
+		if _, scored := profile.Dimensions[dim.ID]; !scored {
+			log.Printf("WARN: [AssessmentProfileService] Answer %q to '%s' matches no option of dimension '%s' (assessment ID %d).", values[0], dim.QuestionID, dim.ID, assessment.ID)
+		}
+	}
+
+	for _, rule := range rules.RiskFlags {
+		values := answers[rule.QuestionID]
+		if len(values) == 0 || isNoneAnswer(rules.NoneAnswers, values) {
+			continue
+		}
+		raised := rule.AnyAnswer
+		text := strings.ToLower(strings.Join(values, " "))
+		for _, kw := range rule.Keywords {
+			if strings.Contains(text, strings.ToLower(kw)) {
+				raised = true
+				break
+			}
+		}
+		if raised && !contains(profile.RiskFlags, rule.Flag) {
+			profile.RiskFlags = append(profile.RiskFlags, rule.Flag)
+		}
+	}
+
+	if rules.GoalQuestionID != "" {
+		profile.Goals = selectedOptions(answers[rules.GoalQuestionID], rules.NoneAnswers)
+	}
+	if rules.ConcernQuestionID != "" {
+		profile.Concerns = selectedOptions(answers[rules.ConcernQuestionID], rules.NoneAnswers)
+	}
+	return profile
+}
+
+// isNoneAnswer reports whether the answers only say "nothing to report" (e.g. "None", "无").
+func isNoneAnswer(noneAnswers []string, values []string) bool {
+	for _, v := range values {
+		trimmed := strings.TrimSpace(strings.TrimRight(v, ".。!！"))
+		matched := trimmed == ""
+		for _, none := range noneAnswers {
+			if strings.EqualFold(trimmed, none) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedOptions returns the distinct multi-choice selections without "none" answers.
+func selectedOptions(values []string, noneAnswers []string) []string {
+	selected := []string{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || isNoneAnswer(noneAnswers, []string{v}) || contains(selected, v) {
+			continue
+		}
+		selected = append(selected, v)
+	}
+	return selected
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"project/config"
+	"project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAssessmentProfileRepository is a mock type for the AssessmentProfileRepository interface
+type MockAssessmentProfileRepository struct {
+	mock.Mock
+}
+
+func (m *MockAssessmentProfileRepository) SaveProfile(profile *models.AssessmentProfile) error {
+	args := m.Called(profile)
+	return args.Error(0)
+}
+
+func (m *MockAssessmentProfileRepository) GetProfile(userID string, assessmentID uint) (*models.AssessmentProfile, error) {
+	args := m.Called(userID, assessmentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AssessmentProfile), args.Error(1)
+}
+
+func (m *MockAssessmentProfileRepository) GetLatestProfileByUserID(userID string) (*models.AssessmentProfile, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AssessmentProfile), args.Error(1)
+}
+
+// testScoringConfig mirrors the relevant part of config.yaml.
+func testScoringConfig() config.AssessmentScoringConfig {
+	return config.AssessmentScoringConfig{
+		GoalQuestionID:    "q_main_goals",
+		ConcernQuestionID: "q_sex_ability_concerns",
+		NoneAnswers:       []string{"None", "无", "No significant concerns"},
+		Dimensions: []config.ScoringDimension{
+			{ID: "activity_level", QuestionID: "q_exercise_habits", Options: []config.ScoringOption{
+				{Answer: "Almost never", Score: 0, Label: "sedentary"},
+				{Answer: "1-2 times a week", Score: 1, Label: "light"},
+				{Answer: "3-4 times a week", Score: 2, Label: "moderate"},
+			}},
+			{ID: "stress_level", QuestionID: "q_stress_level", Options: []config.ScoringOption{
+				{Answer: "2 (Low)", Score: 2, Label: "low"},
+				{Answer: "4 (High)", Score: 4, Label: "high"},
+			}},
+		},
+		RiskFlags: []config.RiskFlagRule{
+			{Flag: "hypertension", QuestionID: "q_chronic_diseases", Keywords: []string{"hypertension", "高血压"}},
+			{Flag: "chronic_disease", QuestionID: "q_chronic_diseases", AnyAnswer: true},
+			{Flag: "on_medication", QuestionID: "q_medications", AnyAnswer: true},
+		},
+	}
+}
+
+func completedAssessment(answers ...models.UserAnswer) *models.UserAssessment {
+	return &models.UserAssessment{ID: 7, UserID: "profileUser", Status: models.AssessmentStatusCompleted, Answers: answers}
+}
+
+func TestScoreAssessment(t *testing.T) {
+	questions := getDefaultAssessmentQuestions()
+
+	t.Run("Scores dimensions, flags and goals from answers", func(t *testing.T) {
+		assessment := completedAssessment(
+			models.UserAnswer{QuestionID: "q_exercise_habits", Answer: []string{"Almost never"}},
+			models.UserAnswer{QuestionID: "q_stress_level", Answer: []string{"4"}}, // Resolved to "4 (High)"
+			models.UserAnswer{QuestionID: "q_chronic_diseases", Answer: []string{"有高血压，在控制中"}},
+			models.UserAnswer{QuestionID: "q_medications", Answer: []string{"None"}},
+			models.UserAnswer{QuestionID: "q_main_goals", Answer: []string{"A, F"}},
+			models.UserAnswer{QuestionID: "q_sex_ability_concerns", Answer: []string{"No significant concerns"}},
+		)
+
+		profile := scoreAssessment(testScoringConfig(), questions, assessment)
+
+		assert.Equal(t, uint(7), profile.AssessmentID)
+		assert.Equal(t, "profileUser", profile.UserID)
+		assert.Equal(t, 0.0, profile.Dimensions["activity_level"].Score)
+		assert.Equal(t, "sedentary", profile.Dimensions["activity_level"].Label)
+		assert.Equal(t, 4.0, profile.Dimensions["stress_level"].Score)
+		assert.ElementsMatch(t, []string{"hypertension", "chronic_disease"}, profile.RiskFlags)
+		assert.False(t, profile.HasRiskFlag("on_medication"))
+		assert.Equal(t, []string{"Gain scientific sexual health knowledge", "Develop a personalized exercise plan"}, profile.Goals)
+		assert.Empty(t, profile.Concerns)
+	})
+
+	t.Run("Option letters resolve for single choice questions", func(t *testing.T) {
+		assessment := completedAssessment(models.UserAnswer{QuestionID: "q_exercise_habits", Answer: []string{"c."}})
+
+		profile := scoreAssessment(testScoringConfig(), questions, assessment)
+
+		assert.Equal(t, "3-4 times a week", profile.Dimensions["activity_level"].Answer)
+		assert.Equal(t, 2.0, profile.Dimensions["activity_level"].Score)
+	})
+
+	t.Run("Unmatched answers leave the dimension unscored", func(t *testing.T) {
+		assessment := completedAssessment(models.UserAnswer{QuestionID: "q_exercise_habits", Answer: []string{"depends on the weather"}})
+
+		profile := scoreAssessment(testScoringConfig(), questions, assessment)
+
+		_, scored := profile.Dimensions["activity_level"]
+		assert.False(t, scored)
+	})
+}
+
+func TestAssessmentProfileService_BuildProfile(t *testing.T) {
+	config.AppConfig.AssessmentScoring = testScoringConfig()
+	mockProfileRepo := new(MockAssessmentProfileRepository)
+	service := NewAssessmentProfileService(mockProfileRepo, NewAssessmentService(nil, nil))
+
+	t.Run("Persists the scored profile", func(t *testing.T) {
+		assessment := completedAssessment(models.UserAnswer{QuestionID: "q_exercise_habits", Answer: []string{"1-2 times a week"}})
+		mockProfileRepo.On("SaveProfile", mock.MatchedBy(func(p *models.AssessmentProfile) bool {
+			return p.AssessmentID == assessment.ID && p.Dimensions["activity_level"].Label == "light"
+		})).Return(nil).Once()
+
+		profile, err := service.BuildProfile(assessment)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, profile)
+		mockProfileRepo.AssertExpectations(t)
+	})
+
+	t.Run("Rejects assessments that are not completed", func(t *testing.T) {
+		assessment := &models.UserAssessment{ID: 8, UserID: "profileUser", Status: models.AssessmentStatusInProgress}
+
+		profile, err := service.BuildProfile(assessment)
+
+		assert.Error(t, err)
+		assert.Nil(t, profile)
+		mockProfileRepo.AssertNotCalled(t, "SaveProfile", mock.MatchedBy(func(p *models.AssessmentProfile) bool { return p.AssessmentID == 8 }))
+	})
+
+	t.Run("Repository failure is returned", func(t *testing.T) {
+		assessment := completedAssessment()
+		mockProfileRepo.On("SaveProfile", mock.AnythingOfType("*models.AssessmentProfile")).Return(errors.New("DB error")).Once()
+
+		profile, err := service.BuildProfile(assessment)
+
+		assert.Error(t, err)
+		assert.Nil(t, profile)
+		assert.Contains(t, err.Error(), "failed to save profile")
+	})
+}
+
+func TestCompareAssessments(t *testing.T) {
+	rules := testScoringConfig()
+	questions := getDefaultAssessmentQuestions()
+	before := completedAssessment(
+		models.UserAnswer{QuestionID: "q_exercise_habits", Answer: []string{"Almost never"}},
+		models.UserAnswer{QuestionID: "q_stress_level", Answer: []string{"4"}},
+		models.UserAnswer{QuestionID: "q_chronic_diseases", Answer: []string{"高血压"}},
+		models.UserAnswer{QuestionID: "q_main_goals", Answer: []string{"A, F"}},
+	)
+	after := completedAssessment(
+		models.UserAnswer{QuestionID: "q_exercise_habits", Answer: []string{"C"}},
+		models.UserAnswer{QuestionID: "q_stress_level", Answer: []string{"2 (Low)"}},
+		models.UserAnswer{QuestionID: "q_chronic_diseases", Answer: []string{"None"}},
+		models.UserAnswer{QuestionID: "q_main_goals", Answer: []string{"F, A"}},
+	)
+	after.ID = 9
+
+	comparison := compareAssessments(rules, questions, before, after, scoreAssessment(rules, questions, before), scoreAssessment(rules, questions, after))
+
+	assert.Equal(t, uint(7), comparison.FromAssessmentID)
+	assert.Equal(t, uint(9), comparison.ToAssessmentID)
+
+	changes := make(map[string]models.AnswerChange)
+	for _, c := range comparison.Answers {
+		changes[c.QuestionID] = c
+	}
+	assert.Equal(t, []string{"Almost never"}, changes["q_exercise_habits"].Before)
+	assert.Equal(t, []string{"3-4 times a week"}, changes["q_exercise_habits"].After)
+	assert.True(t, changes["q_exercise_habits"].Changed)
+	assert.False(t, changes["q_main_goals"].Changed) // Same selections in a different order
+
+	assert.Len(t, comparison.Dimensions, 2)
+	assert.Equal(t, "activity_level", comparison.Dimensions[0].DimensionID)
+	assert.Equal(t, 2.0, *comparison.Dimensions[0].Delta)
+	assert.Equal(t, "stress_level", comparison.Dimensions[1].DimensionID)
+	assert.Equal(t, 4.0, *comparison.Dimensions[1].Before)
+	assert.Equal(t, 2.0, *comparison.Dimensions[1].After)
+
+	assert.Empty(t, comparison.RiskFlagsAdded)
+	assert.ElementsMatch(t, []string{"hypertension", "chronic_disease"}, comparison.RiskFlagsRemoved)
+}
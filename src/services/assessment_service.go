@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"project/config"
 	"project/models"
 	"project/repository"
 	"sort"
@@ -16,17 +17,39 @@ type AssessmentService interface {
 	StartOrContinueAssessment(userID string) (question *models.AssessmentQuestion, assessment *models.UserAssessment, userVisibleError error)
 	SubmitAnswer(userID string, questionID string, answerValues []string) (question *models.AssessmentQuestion, assessment *models.UserAssessment, userVisibleError error)
 	GetAssessmentResult(userID string) (*models.UserAssessment, error)
+	GetQuestions() []models.AssessmentQuestion                                     // Ordered question definitions, e.g. for scoring and summaries
+	BuildSummaryContext(assessment *models.UserAssessment) string                  // Context header asking the assessment agent to summarize the answers
+	SaveSummary(assessmentID uint, summary string) (*models.UserAssessment, error) // Stores the generated summary on the assessment
+	StartRetake(userID string) (question *models.AssessmentQuestion, assessment *models.UserAssessment, userVisibleError error)
+	ListAssessments(userID string) ([]*models.UserAssessment, error)                    // Completed assessments, oldest first
+	GetUserAssessment(userID string, assessmentID uint) (*models.UserAssessment, error) // Returns nil, nil if not found or owned by another user
+}
+
+// AssessmentQuestionnaireVersion identifies the current question set. Bump it whenever questions are added, removed
+// or reworded, so that funnel analytics can tell the versions apart.
+const AssessmentQuestionnaireVersion = "v1"
+
+// RetakeTooSoonError is returned when a user starts a new assessment before AssessmentRetake.MinIntervalDays have
+// passed since their last completed one. Its message tells the user when a retake will be possible.
+type RetakeTooSoonError struct {
+	CompletedAt time.Time // When the last assessment was completed
+	NextAllowed time.Time // From when a new assessment can be started
+}
+
+func (e *RetakeTooSoonError) Error() string {
+	return fmt.Sprintf("你已于 %s 完成健康评估，%s 起可以重新评估，查看自己的变化。", e.CompletedAt.Format("2006-01-02"), e.NextAllowed.Format("2006-01-02"))
 }
 
 // assessmentService implements the AssessmentService interface.
 type assessmentService struct {
 	repo          repository.AssessmentRepository
+	eventRepo     repository.AssessmentEventRepository // Optional; funnel events are not recorded if nil
 	questions     []models.AssessmentQuestion          // Ordered list of assessment questions
 	questionsByID map[string]*models.AssessmentQuestion // Quick lookup for questions by ID
 }
 
 // NewAssessmentService creates a new instance of AssessmentService.
-func NewAssessmentService(repo repository.AssessmentRepository) AssessmentService {
+func NewAssessmentService(repo repository.AssessmentRepository, eventRepo repository.AssessmentEventRepository) AssessmentService {
 	definedQuestions := getDefaultAssessmentQuestions() // Get question definitions from helper
 
 	// Ensure questions are sorted by their Order field
@@ -45,6 +68,7 @@ func NewAssessmentService(repo repository.AssessmentRepository) AssessmentServic
 	
 	return &assessmentService{
 		repo:          repo,
+		eventRepo:     eventRepo,
 		questions:     definedQuestions, 
 		questionsByID: questionsMap,     
 	}
@@ -78,6 +102,8 @@ func getDefaultAssessmentQuestions() []models.AssessmentQuestion {
 
 // StartOrContinueAssessment finds an in-progress assessment for the user or starts a new one.
 // It returns the next question to be asked, the current state of the assessment, and any user-visible error.
+// A new assessment is only started once AssessmentRetake.MinIntervalDays have passed since the last completed one;
+// before that the last completed assessment is returned with a *RetakeTooSoonError.
 func (s *assessmentService) StartOrContinueAssessment(userID string) (*models.AssessmentQuestion, *models.UserAssessment, error) {
 	// Attempt to retrieve an existing in-progress assessment for the user.
 	assessment, err := s.repo.GetUserAssessmentByUserID(userID, models.AssessmentStatusInProgress)
@@ -88,12 +114,16 @@ func (s *assessmentService) StartOrContinueAssessment(userID string) (*models.As
 	}
 	
 	if assessment == nil { // No in-progress assessment found, create a new one.
+		if lastCompleted, err := s.checkRetakeInterval(userID); err != nil {
+			return nil, lastCompleted, err
+		}
 		log.Printf("INFO: [AssessmentService] No in-progress assessment found for userID '%s', creating a new one.", userID)
 		newAssessment := &models.UserAssessment{
-			UserID:    userID,
-			Status:    models.AssessmentStatusInProgress,
-			StartedAt: time.Now(),
-			Answers:   make([]models.UserAnswer, 0),
+			UserID:               userID,
+			Status:               models.AssessmentStatusInProgress,
+			StartedAt:            time.Now(),
+			Answers:              make([]models.UserAnswer, 0),
+			QuestionnaireVersion: AssessmentQuestionnaireVersion,
 		}
 		assessment, err = s.repo.CreateUserAssessment(newAssessment)
 		if err != nil {
@@ -197,6 +227,7 @@ func (s *assessmentService) StartOrContinueAssessment(userID string) (*models.As
 	}
 
 	log.Printf("INFO: [AssessmentService] For userID '%s' (assessmentID %d), next question to show is ID '%s'.", userID, updatedAssessment.ID, nextQuestionToShow.ID)
+	s.recordEvent(updatedAssessment, nextQuestionToShow.ID, models.AssessmentEventShown)
 	return nextQuestionToShow, updatedAssessment, nil
 }
 
@@ -244,6 +275,9 @@ func (s *assessmentService) SubmitAnswer(userID string, questionID string, answe
 		if _, updateErr := s.repo.UpdateUserAssessment(assessment); updateErr != nil {
 			log.Printf("ERROR: [AssessmentService] Failed to update assessment %d for user %s after special answer processing (e.g. cancellation): %v", assessment.ID, userID, updateErr)
 		}
+		if assessment.Status == models.AssessmentStatusCancelled {
+			s.recordEvent(assessment, questionID, models.AssessmentEventCancelled)
+		}
 		return nil, assessment, userVisibleMsg 
 	}
 
@@ -298,8 +332,12 @@ func (s *assessmentService) SubmitAnswer(userID string, questionID string, answe
 		return questionDef, assessment, fmt.Errorf("%s: %w", errMsg, errUpdate)
 	}
 
+	s.recordEvent(updatedAssessment, questionID, models.AssessmentEventAnswered)
 	if updatedAssessment.Status == models.AssessmentStatusCompleted || updatedAssessment.Status == models.AssessmentStatusCancelled {
 		log.Printf("INFO: [AssessmentService] Assessment %d for userID %s is now %s.", updatedAssessment.ID, userID, updatedAssessment.Status)
+		if updatedAssessment.Status == models.AssessmentStatusCompleted {
+			s.recordEvent(updatedAssessment, questionID, models.AssessmentEventCompleted)
+		}
 		return nil, updatedAssessment, nil 
 	}
 
@@ -309,9 +347,41 @@ func (s *assessmentService) SubmitAnswer(userID string, questionID string, answe
 	    return nil, updatedAssessment, fmt.Errorf("internal error: failed to determine next question for assessment %d", updatedAssessment.ID)
 	}
 	log.Printf("INFO: [AssessmentService] For userID '%s' (assessmentID %d), after answering '%s', next question to show is '%s'.", userID, updatedAssessment.ID, questionID, nextQuestionToShow.ID)
+	s.recordEvent(updatedAssessment, nextQuestionToShow.ID, models.AssessmentEventShown)
 	return nextQuestionToShow, updatedAssessment, nil
 }
 
+// recordEvent stores a funnel event for the assessment. Failures are logged and never interrupt the assessment flow.
+func (s *assessmentService) recordEvent(assessment *models.UserAssessment, questionID string, eventType models.AssessmentEventType) {
+	if s.eventRepo == nil || assessment == nil {
+		return
+	}
+	version := assessment.QuestionnaireVersion
+	if version == "" {
+		version = AssessmentQuestionnaireVersion
+	}
+	event := &models.AssessmentEvent{
+		AssessmentID:         assessment.ID,
+		UserID:               assessment.UserID,
+		UserType:             userTypeOf(assessment.UserID),
+		QuestionnaireVersion: version,
+		QuestionID:           questionID,
+		EventType:            eventType,
+		CreatedAt:            time.Now(),
+	}
+	if err := s.eventRepo.RecordEvent(event); err != nil {
+		log.Printf("WARN: [AssessmentService] Failed to record '%s' event for assessment ID %d, question '%s': %v", eventType, assessment.ID, questionID, err)
+	}
+}
+
+// userTypeOf derives the user type from the user ID; guest IDs carry the "guest_" prefix.
+func userTypeOf(userID string) string {
+	if strings.HasPrefix(userID, "guest_") {
+		return "guest"
+	}
+	return "registered"
+}
+
 // processSpecialAnswers handles answers to questions that might alter assessment flow (e.g., cancellation).
 // It modifies the assessment status directly if needed and returns a user-facing error message if applicable.
 func processSpecialAnswers(assessment *models.UserAssessment, question *models.AssessmentQuestion, answerValues []string) error {
@@ -352,6 +422,214 @@ func (s *assessmentService) GetAssessmentResult(userID string) (*models.UserAsse
 	return assessment, nil
 }
 
+// StartRetake starts a new assessment for a user who has already completed one, so that progress can be compared over time.
+// An assessment that is still in progress is continued instead. Like any new assessment, retakes are refused with a
+// *RetakeTooSoonError until AssessmentRetake.MinIntervalDays have passed since the last completed assessment.
+func (s *assessmentService) StartRetake(userID string) (*models.AssessmentQuestion, *models.UserAssessment, error) {
+	if userID == "" {
+		return nil, nil, errors.New("userID cannot be empty")
+	}
+	return s.StartOrContinueAssessment(userID)
+}
+
+// checkRetakeInterval is called before a new assessment is started. It returns the user's last completed assessment
+// and a *RetakeTooSoonError if AssessmentRetake.MinIntervalDays have not passed since it was completed.
+func (s *assessmentService) checkRetakeInterval(userID string) (*models.UserAssessment, error) {
+	lastCompleted, err := s.repo.GetUserAssessmentByUserID(userID, models.AssessmentStatusCompleted)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to get completed assessment for userID %s", userID)
+		log.Printf("ERROR: [AssessmentService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if lastCompleted == nil {
+		return nil, nil
+	}
+	minInterval := config.AppConfig.AssessmentRetake.MinIntervalDays
+	if lastCompleted.CompletedAt != nil && minInterval > 0 {
+		nextAllowed := lastCompleted.CompletedAt.AddDate(0, 0, minInterval)
+		if time.Now().Before(nextAllowed) {
+			log.Printf("INFO: [AssessmentService] UserID '%s' requested a new assessment before %s (last assessment ID %d).", userID, nextAllowed.Format("2006-01-02"), lastCompleted.ID)
+			return lastCompleted, &RetakeTooSoonError{CompletedAt: *lastCompleted.CompletedAt, NextAllowed: nextAllowed}
+		}
+	}
+	log.Printf("INFO: [AssessmentService] UserID '%s' starts a retake (previous assessment ID %d).", userID, lastCompleted.ID)
+	return lastCompleted, nil
+}
+
+// ListAssessments returns the completed assessments of a user, oldest first.
+func (s *assessmentService) ListAssessments(userID string) ([]*models.UserAssessment, error) {
+	if userID == "" {
+		return nil, errors.New("userID cannot be empty")
+	}
+	assessments, err := s.repo.ListUserAssessments(userID, models.AssessmentStatusCompleted)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to list completed assessments for userID %s", userID)
+		log.Printf("ERROR: [AssessmentService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return assessments, nil
+}
+
+// GetUserAssessment retrieves an assessment by ID, making sure it belongs to the user.
+func (s *assessmentService) GetUserAssessment(userID string, assessmentID uint) (*models.UserAssessment, error) {
+	assessment, err := s.repo.GetUserAssessmentByID(assessmentID)
+	if err != nil || assessment == nil {
+		log.Printf("INFO: [AssessmentService] Assessment ID %d not found for userID '%s': %v", assessmentID, userID, err)
+		return nil, nil
+	}
+	if assessment.UserID != userID {
+		log.Printf("WARN: [AssessmentService] UserID '%s' requested assessment ID %d owned by '%s'.", userID, assessmentID, assessment.UserID)
+		return nil, nil
+	}
+	return assessment, nil
+}
+
+// GetQuestions returns a copy of the ordered assessment question definitions.
+func (s *assessmentService) GetQuestions() []models.AssessmentQuestion {
+	questions := make([]models.AssessmentQuestion, len(s.questions))
+	copy(questions, s.questions)
+	return questions
+}
+
+// BuildSummaryContext builds the system instruction that asks the assessment agent to summarize a completed assessment.
+// Each answered question is listed with its text, so the agent summarizes the actual answers instead of improvising.
+func (s *assessmentService) BuildSummaryContext(assessment *models.UserAssessment) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n[系统指令：评估已完成]\n")
+	sb.WriteString("用户已完成健康评估。请按照你的角色设定，根据以下问答为用户生成一份简洁的评估总结：")
+	sb.WriteString("概括用户的整体情况，指出需要关注的健康风险，并结合用户的目标给出下一步建议（例如生成个性化计划）。")
+	sb.WriteString("不要逐条复述问答，也不要做出医学诊断。\n\n[评估问答]\n")
+
+	answered := 0
+	for _, ans := range assessment.Answers {
+		question, ok := s.questionsByID[ans.QuestionID]
+		if !ok || question.QuestionType == models.QuestionTypeConfirmation {
+			continue // Consent questions carry no information for the summary
+		}
+		answerText := strings.Join(resolveAnswerOptions(question, ans.Answer), "；")
+		if strings.TrimSpace(answerText) == "" {
+			continue
+		}
+		answered++
+		sb.WriteString(fmt.Sprintf("%d. 问题：%s\n   回答：%s\n", answered, question.Text, answerText))
+	}
+	if answered == 0 {
+		sb.WriteString("（无有效回答）\n")
+	}
+	log.Printf("INFO: [AssessmentService] Built summary context for assessment ID %d (userID '%s') with %d answered questions.", assessment.ID, assessment.UserID, answered)
+	return sb.String()
+}
+
+// SaveSummary stores the generated summary on a completed assessment.
+func (s *assessmentService) SaveSummary(assessmentID uint, summary string) (*models.UserAssessment, error) {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return nil, errors.New("summary cannot be empty")
+	}
+	assessment, err := s.repo.GetUserAssessmentByID(assessmentID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to get assessment %d for saving summary", assessmentID)
+		log.Printf("ERROR: [AssessmentService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if assessment == nil {
+		return nil, fmt.Errorf("assessment %d not found", assessmentID)
+	}
+	if assessment.Status != models.AssessmentStatusCompleted {
+		log.Printf("WARN: [AssessmentService] Refusing to store summary for assessment ID %d with status '%s'.", assessmentID, assessment.Status)
+		return nil, fmt.Errorf("assessment %d is not completed", assessmentID)
+	}
+
+	now := time.Now()
+	assessment.Summary = summary
+	assessment.SummaryGeneratedAt = &now
+	updated, err := s.repo.UpdateUserAssessment(assessment)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to save summary for assessment %d", assessmentID)
+		log.Printf("ERROR: [AssessmentService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	log.Printf("INFO: [AssessmentService] Stored summary (%d chars) for assessment ID %d (userID '%s').", len(summary), assessmentID, updated.UserID)
+	return updated, nil
+}
+
+// resolveAnswerOptions maps raw answers onto the question's options where possible.
+// Users reply in free text, so "B", "b." or "4" are resolved to "1-2 times a week" or "4 (High)",
+// and a multi-choice reply such as "A, C" is expanded into the selected options.
+// Answers that cannot be matched to an option are returned trimmed but otherwise unchanged.
+func resolveAnswerOptions(question *models.AssessmentQuestion, rawAnswers []string) []string {
+	resolved := make([]string, 0, len(rawAnswers))
+	for _, raw := range rawAnswers {
+		answer := strings.TrimSpace(raw)
+		if question == nil || len(question.Options) == 0 || answer == "" {
+			resolved = append(resolved, answer)
+			continue
+		}
+		if question.QuestionType == models.QuestionTypeMultiChoice {
+			if multi := matchMultipleOptions(question.Options, answer); len(multi) > 0 {
+				resolved = append(resolved, multi...)
+				continue
+			}
+		}
+		resolved = append(resolved, matchOption(question.Options, answer))
+	}
+	return resolved
+}
+
+// matchMultipleOptions resolves a multi-choice reply either from the option texts it mentions
+// or from a list of option letters ("A, C" / "AC"). It returns nil when neither applies.
+func matchMultipleOptions(options []string, answer string) []string {
+	lower := strings.ToLower(answer)
+	var mentioned []string
+	for _, opt := range options {
+		if strings.Contains(lower, strings.ToLower(opt)) {
+			mentioned = append(mentioned, opt)
+		}
+	}
+	if len(mentioned) > 0 {
+		return mentioned
+	}
+
+	letters := strings.FieldsFunc(lower, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ';' || r == '.' || r == ' '
+	})
+	if len(letters) == 1 && len(letters[0]) > 1 {
+		letters = strings.Split(letters[0], "") // "ac" -> "a", "c"
+	}
+	var selected []string
+	for _, l := range letters {
+		if len(l) != 1 || l[0] < 'a' || int(l[0]-'a') >= len(options) {
+			return nil
+		}
+		if opt := options[l[0]-'a']; !contains(selected, opt) {
+			selected = append(selected, opt)
+		}
+	}
+	return selected
+}
+
+// matchOption returns the option matching answer (case-insensitive exact match, option letter,
+// or option prefix such as "4" for "4 (High)"), or answer itself when nothing matches.
+func matchOption(options []string, answer string) string {
+	lower := strings.ToLower(answer)
+	for _, opt := range options {
+		if strings.ToLower(opt) == lower {
+			return opt
+		}
+	}
+	letter := strings.TrimRight(lower, ".)、 ")
+	if len(letter) == 1 && letter[0] >= 'a' && int(letter[0]-'a') < len(options) {
+		return options[letter[0]-'a']
+	}
+	for _, opt := range options {
+		optLower := strings.ToLower(opt)
+		if strings.HasPrefix(optLower, lower+" ") || strings.HasPrefix(optLower, lower+",") || strings.Contains(lower, optLower) {
+			return opt
+		}
+	}
+	return answer
+}
+
 // contains is a helper function to check if a string slice contains a specific item.
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"fmt"
+	"project/config"
 	"project/models"
 	"project/repository"
 	"testing"
@@ -69,17 +70,33 @@ func (m *MockAssessmentRepository) UpdateUserAssessment(assessment *models.UserA
 	return args.Get(0).(*models.UserAssessment), args.Error(1)
 }
 
+func (m *MockAssessmentRepository) ListUserAssessments(userID string, statusFilter ...models.UserAssessmentStatus) ([]*models.UserAssessment, error) {
+	var statusArg interface{}
+	if len(statusFilter) > 0 {
+		statusArg = statusFilter[0]
+	} else {
+		statusArg = mock.AnythingOfType("models.UserAssessmentStatus")
+	}
+	args := m.Called(userID, statusArg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.UserAssessment), args.Error(1)
+}
+
 func TestAssessmentService_StartOrContinueAssessment(t *testing.T) {
 	mockRepo := new(MockAssessmentRepository)
 	// Note: getDefaultAssessmentQuestions() is part of the service, not mocked here.
 	// If questions were from a repo, that would also need mocking.
-	service := NewAssessmentService(mockRepo)
+	service := NewAssessmentService(mockRepo, nil)
 
 	userID := "testUser1"
 
 	t.Run("Start new assessment if none in progress", func(t *testing.T) {
 		// Mock GetUserAssessmentByUserID to return no assessment (nil, nil for "not found")
 		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(nil, nil).Once()
+		// No completed assessment either, so the retake interval does not apply
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusCompleted).Return(nil, nil).Once()
 
 		// Mock CreateUserAssessment
 		expectedCreatedAssessment := &models.UserAssessment{
@@ -201,7 +218,7 @@ func TestAssessmentService_StartOrContinueAssessment(t *testing.T) {
 
 func TestAssessmentService_SubmitAnswer(t *testing.T) {
 	mockRepo := new(MockAssessmentRepository)
-	service := NewAssessmentService(mockRepo)
+	service := NewAssessmentService(mockRepo, nil)
 	userID := "testUserSubmit"
 
 	t.Run("Submit valid answer", func(t *testing.T) {
@@ -337,6 +354,119 @@ func TestAssessmentService_SubmitAnswer(t *testing.T) {
 	})
 }
 
+func TestAssessmentService_Summary(t *testing.T) {
+	mockRepo := new(MockAssessmentRepository)
+	service := NewAssessmentService(mockRepo, nil)
+	userID := "testUserSummary"
+
+	t.Run("Summary context lists answered questions with their text", func(t *testing.T) {
+		assessment := &models.UserAssessment{
+			ID: 1, UserID: userID, Status: models.AssessmentStatusCompleted,
+			Answers: []models.UserAnswer{
+				{QuestionID: "q_welcome", Answer: []string{"Yes, I'm ready"}},
+				{QuestionID: "q_exercise_habits", Answer: []string{"B"}},
+			},
+		}
+
+		header := service.BuildSummaryContext(assessment)
+
+		assert.Contains(t, header, "[系统指令：评估已完成]")
+		assert.Contains(t, header, "How often do you currently exercise?")
+		assert.Contains(t, header, "1-2 times a week")           // Option letter resolved to the option text
+		assert.NotContains(t, header, "Are you ready to start?") // Consent questions are left out
+	})
+
+	t.Run("Save summary on completed assessment", func(t *testing.T) {
+		completed := &models.UserAssessment{ID: 2, UserID: userID, Status: models.AssessmentStatusCompleted}
+		mockRepo.On("GetUserAssessmentByID", uint(2)).Return(completed, nil).Once()
+		mockRepo.On("UpdateUserAssessment", mock.MatchedBy(func(a *models.UserAssessment) bool {
+			return a.ID == 2 && a.Summary == "Overall you are in good shape." && a.SummaryGeneratedAt != nil
+		})).Return(completed, nil).Once()
+
+		updated, err := service.SaveSummary(2, "  Overall you are in good shape.\n")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Overall you are in good shape.", updated.Summary)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Refuse summary for assessment in progress", func(t *testing.T) {
+		inProgress := &models.UserAssessment{ID: 3, UserID: userID, Status: models.AssessmentStatusInProgress}
+		mockRepo.On("GetUserAssessmentByID", uint(3)).Return(inProgress, nil).Once()
+
+		updated, err := service.SaveSummary(3, "summary")
+
+		assert.Error(t, err)
+		assert.Nil(t, updated)
+		mockRepo.AssertNotCalled(t, "UpdateUserAssessment", inProgress)
+	})
+
+	t.Run("Refuse empty summary", func(t *testing.T) {
+		updated, err := service.SaveSummary(4, "   ")
+
+		assert.Error(t, err)
+		assert.Nil(t, updated)
+	})
+}
+
+func TestAssessmentService_StartRetake(t *testing.T) {
+	mockRepo := new(MockAssessmentRepository)
+	service := NewAssessmentService(mockRepo, nil)
+	userID := "testUserRetake"
+	config.AppConfig.AssessmentRetake.MinIntervalDays = 30
+
+	t.Run("Refuse retake before the minimum interval", func(t *testing.T) {
+		completedAt := time.Now().AddDate(0, 0, -10)
+		lastCompleted := &models.UserAssessment{ID: 1, UserID: userID, Status: models.AssessmentStatusCompleted, CompletedAt: &completedAt}
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(nil, nil).Once()
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusCompleted).Return(lastCompleted, nil).Once()
+
+		question, assessment, userVisibleErr := service.StartRetake(userID)
+
+		assert.Error(t, userVisibleErr)
+		assert.Contains(t, userVisibleErr.Error(), "起可以重新评估")
+		var tooSoon *RetakeTooSoonError
+		assert.True(t, errors.As(userVisibleErr, &tooSoon))
+		assert.Nil(t, question)
+		assert.Equal(t, lastCompleted, assessment)
+		mockRepo.AssertNotCalled(t, "CreateUserAssessment", mock.Anything)
+	})
+
+	t.Run("Starting an assessment from chat respects the minimum interval", func(t *testing.T) {
+		completedAt := time.Now().AddDate(0, 0, -10)
+		lastCompleted := &models.UserAssessment{ID: 1, UserID: userID, Status: models.AssessmentStatusCompleted, CompletedAt: &completedAt}
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(nil, nil).Once()
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusCompleted).Return(lastCompleted, nil).Once()
+
+		question, assessment, userVisibleErr := service.StartOrContinueAssessment(userID)
+
+		var tooSoon *RetakeTooSoonError
+		assert.True(t, errors.As(userVisibleErr, &tooSoon))
+		assert.Equal(t, completedAt.AddDate(0, 0, 30), tooSoon.NextAllowed)
+		assert.Nil(t, question)
+		assert.Equal(t, lastCompleted, assessment)
+		mockRepo.AssertNotCalled(t, "CreateUserAssessment", mock.Anything)
+	})
+
+	t.Run("Start a new assessment once the interval has passed", func(t *testing.T) {
+		completedAt := time.Now().AddDate(0, 0, -31)
+		lastCompleted := &models.UserAssessment{ID: 1, UserID: userID, Status: models.AssessmentStatusCompleted, CompletedAt: &completedAt}
+		newAssessment := &models.UserAssessment{ID: 2, UserID: userID, Status: models.AssessmentStatusInProgress}
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusInProgress).Return(nil, nil).Once()
+		mockRepo.On("GetUserAssessmentByUserID", userID, models.AssessmentStatusCompleted).Return(lastCompleted, nil).Once()
+		mockRepo.On("CreateUserAssessment", mock.AnythingOfType("*models.UserAssessment")).Return(newAssessment, nil).Once()
+		mockRepo.On("UpdateUserAssessment", newAssessment).Return(newAssessment, nil).Once()
+
+		question, assessment, userVisibleErr := service.StartRetake(userID)
+
+		assert.NoError(t, userVisibleErr)
+		assert.NotNil(t, question)
+		assert.Equal(t, "q_welcome", question.ID)
+		assert.Equal(t, uint(2), assessment.ID)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
 // Note: This MockAssessmentRepository is defined here for simplicity.
 // In a larger project, it might be generated by mockery and live in a mocks/ sub-package
 // or a repository/mocks package.
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+	"time"
+)
+
+// CalendarService exports plans as iCalendar documents and manages the users' calendar subscriptions.
+type CalendarService interface {
+	// ExportPlan renders the tasks of a plan as an iCalendar document.
+	ExportPlan(planID uint, opts models.CalendarOptions, now time.Time) ([]byte, error)
+	GetFeed(userID string) (*models.CalendarFeed, error) // nil if the user has no subscription
+	// SaveFeed creates the user's subscription on first use and updates its settings.
+	SaveFeed(userID string, input models.CalendarFeedInput) (*models.CalendarFeed, error)
+	DeleteFeed(userID string) error
+	// RenderFeed renders the active plans of the subscription with the given token.
+	RenderFeed(token string, now time.Time) ([]byte, error)
+}
+
+type calendarService struct {
+	planRepo repository.PlanRepository
+	feedRepo repository.CalendarFeedRepository
+}
+
+// NewCalendarService creates a new instance of CalendarService.
+func NewCalendarService(planRepo repository.PlanRepository, feedRepo repository.CalendarFeedRepository) CalendarService {
+	return &calendarService{
+		planRepo: planRepo,
+		feedRepo: feedRepo,
+	}
+}
+
+func (s *calendarService) ExportPlan(planID uint, opts models.CalendarOptions, now time.Time) ([]byte, error) {
+	plan, err := s.planRepo.GetPlanByID(planID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch plan ID %d for calendar export", planID)
+		log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("plan with ID %d not found", planID)
+	}
+	discreet := config.AppConfig.Calendar.Discreet
+	if opts.Discreet != nil {
+		discreet = *opts.Discreet
+	}
+	name := plan.Title
+	if discreet {
+		name = discreetTitle("")
+	}
+	render := calendarRender{name: name, loc: LoadUserLocation(opts.Timezone), discreet: discreet, now: now}
+	log.Printf("INFO: [CalendarService] Exporting plan ID %d as a calendar (%s, discreet: %t).", planID, render.loc, discreet)
+	return renderCalendar(render, []*models.Plan{plan}), nil
+}
+
+func (s *calendarService) GetFeed(userID string) (*models.CalendarFeed, error) {
+	if userID == "" {
+		return nil, errors.New("invalid request: userID cannot be empty")
+	}
+	feed, err := s.feedRepo.GetFeedByUserID(userID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch calendar feed of userID %s", userID)
+		log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return feed, nil
+}
+
+func (s *calendarService) SaveFeed(userID string, input models.CalendarFeedInput) (*models.CalendarFeed, error) {
+	feed, err := s.GetFeed(userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Timezone != nil && *input.Timezone != "" {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone '%s'", *input.Timezone)
+		}
+	}
+	if feed == nil {
+		feed = &models.CalendarFeed{UserID: userID, Discreet: config.AppConfig.Calendar.Discreet}
+	}
+	if feed.Token == "" || input.Rotate {
+		if feed.Token, err = newFeedToken(); err != nil {
+			errMsg := fmt.Sprintf("failed to generate calendar feed token for userID %s", userID)
+			log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+	}
+	if input.Discreet != nil {
+		feed.Discreet = *input.Discreet
+	}
+	if input.Timezone != nil {
+		feed.Timezone = *input.Timezone
+	}
+	if err := s.feedRepo.SaveFeed(feed); err != nil {
+		errMsg := fmt.Sprintf("failed to save calendar feed of userID %s", userID)
+		log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return feed, nil
+}
+
+func (s *calendarService) DeleteFeed(userID string) error {
+	feed, err := s.GetFeed(userID)
+	if err != nil {
+		return err
+	}
+	if feed == nil {
+		return fmt.Errorf("calendar feed of userID %s not found", userID)
+	}
+	if err := s.feedRepo.DeleteFeed(userID); err != nil {
+		errMsg := fmt.Sprintf("failed to delete calendar feed of userID %s", userID)
+		log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return nil
+}
+
+func (s *calendarService) RenderFeed(token string, now time.Time) ([]byte, error) {
+	token = strings.TrimSuffix(token, ".ics")
+	if token == "" {
+		return nil, errors.New("calendar feed not found")
+	}
+	feed, err := s.feedRepo.GetFeedByToken(token)
+	if err != nil {
+		errMsg := "failed to fetch calendar feed"
+		log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if feed == nil {
+		return nil, errors.New("calendar feed not found")
+	}
+	plans, err := s.planRepo.GetPlansByUserID(feed.UserID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch plans of userID %s for calendar feed", feed.UserID)
+		log.Printf("ERROR: [CalendarService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	active := make([]*models.Plan, 0, len(plans))
+	for _, plan := range plans {
+		if plan.Status == models.PlanStatusActive {
+			active = append(active, plan)
+		}
+	}
+	name := "我的健康计划"
+	if feed.Discreet {
+		name = discreetTitle("")
+	}
+	render := calendarRender{name: name, loc: LoadUserLocation(feed.Timezone), discreet: feed.Discreet, feed: true, now: now}
+	log.Printf("INFO: [CalendarService] Rendering calendar feed of userID %s with %d active plans.", feed.UserID, len(active))
+	return renderCalendar(render, active), nil
+}
+
+// newFeedToken returns a random, URL-safe token for a subscription URL.
+func newFeedToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCalendarFeedRepository is a mock type for the CalendarFeedRepository type
+type MockCalendarFeedRepository struct {
+	mock.Mock
+}
+
+func (m *MockCalendarFeedRepository) GetFeedByUserID(userID string) (*models.CalendarFeed, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CalendarFeed), args.Error(1)
+}
+
+func (m *MockCalendarFeedRepository) GetFeedByToken(token string) (*models.CalendarFeed, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CalendarFeed), args.Error(1)
+}
+
+func (m *MockCalendarFeedRepository) SaveFeed(feed *models.CalendarFeed) error {
+	args := m.Called(feed)
+	return args.Error(0)
+}
+
+func (m *MockCalendarFeedRepository) DeleteFeed(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func testCalendarConfig() config.CalendarConfig {
+	return config.CalendarConfig{
+		EventMinutes:   15,
+		DefaultTimes:   []string{"20:00", "08:00"},
+		AlarmMinutes:   10,
+		DiscreetTitles: map[string]string{"exercise": "运动", "default": "个人日程"},
+	}
+}
+
+// unfoldICS joins folded iCalendar lines.
+func unfoldICS(ics []byte) string {
+	return strings.ReplaceAll(string(ics), "\r\n ", "")
+}
+
+func TestIcsWriterFoldsLongLines(t *testing.T) {
+	w := &icsWriter{}
+	w.text("DESCRIPTION", strings.Repeat("收缩盆底肌, 保持5秒; ", 10))
+
+	for _, line := range strings.Split(strings.TrimSuffix(w.sb.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+		assert.True(t, strings.ToValidUTF8(line, "?") == line, "lines are not split inside a character")
+	}
+	assert.Contains(t, unfoldICS([]byte(w.sb.String())), `收缩盆底肌\, 保持5秒\; 收缩`)
+}
+
+func TestRenderCalendar(t *testing.T) {
+	original := config.AppConfig.Calendar
+	config.AppConfig.Calendar = testCalendarConfig()
+	defer func() { config.AppConfig.Calendar = original }()
+
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	plan := &models.Plan{ID: 3, Title: "盆底肌计划", StartDate: "2024-03-14", EndDate: "2024-04-10", Tasks: []models.PlanTask{
+		{ID: 31, Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日2次（早晚各一次）", Order: 1,
+			Recurrence: &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2, Windows: []models.TimeWindow{{Label: "morning", Start: "07:30", End: "10:00"}}}},
+		{ID: 32, Type: models.TaskTypeExercise, Title: "快走", Order: 2, Recurrence: &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}},
+		{ID: 33, Type: models.TaskTypeKnowledge, Title: "阅读科普", Order: 3, Recurrence: &models.Recurrence{Kind: models.RecurrenceOnce}, IsCompleted: true},
+		{ID: 34, Type: models.TaskTypeHabit, Title: "早睡", Order: 4, Status: models.TaskStatusSkipped},
+	}}
+	now := time.Date(2024, 3, 15, 3, 0, 0, 0, time.UTC)
+
+	t.Run("Events and reminders", func(t *testing.T) {
+		ics := unfoldICS(renderCalendar(calendarRender{name: plan.Title, loc: loc, now: now}, []*models.Plan{plan}))
+
+		assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+		assert.Contains(t, ics, "BEGIN:VTIMEZONE\r\nTZID:Asia/Shanghai\r\n")
+		assert.Contains(t, ics, "TZOFFSETTO:+0800")
+		assert.Equal(t, 3, strings.Count(ics, "BEGIN:VEVENT"), "two daily slots and the walks; completed and skipped tasks are left out")
+		assert.Contains(t, ics, "UID:plan3-task31-1@aphrodite-bot\r\n")
+		assert.Contains(t, ics, "DTSTART;TZID=Asia/Shanghai:20240314T073000\r\nDTEND;TZID=Asia/Shanghai:20240314T074500\r\n")
+		assert.Contains(t, ics, "DTSTART;TZID=Asia/Shanghai:20240314T080000\r\n", "the second slot has no window and uses the second default time")
+		assert.Contains(t, ics, "RRULE:FREQ=DAILY;UNTIL=20240410T155959Z\r\n")
+		assert.Contains(t, ics, "DTSTART;TZID=Asia/Shanghai:20240315T200000\r\n", "times per week start on the first spread weekday")
+		assert.Contains(t, ics, "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=")
+		assert.Contains(t, ics, "SUMMARY:凯格尔运动\r\n")
+		assert.Contains(t, ics, `DESCRIPTION:频率：每日2次（早晚各一次）`)
+		assert.Contains(t, ics, "BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:凯格尔运动\r\nTRIGGER:-PT10M\r\nEND:VALARM")
+		assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	})
+
+	t.Run("Discreet titles", func(t *testing.T) {
+		ics := unfoldICS(renderCalendar(calendarRender{name: "个人日程", loc: loc, discreet: true, now: now}, []*models.Plan{plan}))
+
+		assert.NotContains(t, ics, "凯格尔")
+		assert.NotContains(t, ics, "DESCRIPTION:频率")
+		assert.Equal(t, 3, strings.Count(ics, "SUMMARY:运动\r\n"))
+	})
+
+	t.Run("UTC has no timezone definition", func(t *testing.T) {
+		ics := unfoldICS(renderCalendar(calendarRender{name: plan.Title, loc: time.UTC, now: now}, []*models.Plan{plan}))
+
+		assert.NotContains(t, ics, "VTIMEZONE")
+		assert.Contains(t, ics, "DTSTART:20240314T073000Z\r\n")
+	})
+}
+
+func TestSpreadWeekdays(t *testing.T) {
+	assert.Equal(t, []int{1}, spreadWeekdays(1))
+	assert.Equal(t, []int{1, 4}, spreadWeekdays(2))
+	assert.Equal(t, []int{1, 3, 5}, spreadWeekdays(3))
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 0}, spreadWeekdays(9))
+	assert.Nil(t, spreadWeekdays(0))
+}
+
+func TestCalendarService_RenderFeed(t *testing.T) {
+	original := config.AppConfig.Calendar
+	config.AppConfig.Calendar = testCalendarConfig()
+	defer func() { config.AppConfig.Calendar = original }()
+	now := time.Date(2024, 3, 15, 3, 0, 0, 0, time.UTC)
+
+	t.Run("Only active plans", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockFeedRepo := new(MockCalendarFeedRepository)
+		service := NewCalendarService(mockPlanRepo, mockFeedRepo)
+		mockFeedRepo.On("GetFeedByToken", "secret").Return(&models.CalendarFeed{UserID: "feedUser", Token: "secret", Discreet: true, Timezone: "UTC"}, nil).Once()
+		mockPlanRepo.On("GetPlansByUserID", "feedUser").Return([]*models.Plan{
+			{ID: 1, Status: models.PlanStatusActive, StartDate: "2024-03-01", Tasks: []models.PlanTask{{ID: 11, Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日"}}},
+			{ID: 2, Status: models.PlanStatusPaused, StartDate: "2024-02-01", Tasks: []models.PlanTask{{ID: 21, Title: "旧任务", Frequency: "每日"}}},
+		}, nil).Once()
+
+		body, err := service.RenderFeed("secret.ics", now)
+
+		assert.NoError(t, err)
+		ics := unfoldICS(body)
+		assert.Contains(t, ics, "UID:plan1-task11-1@aphrodite-bot")
+		assert.NotContains(t, ics, "plan2-")
+		assert.Contains(t, ics, "SUMMARY:运动")
+		assert.Contains(t, ics, "REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+		mockFeedRepo.AssertExpectations(t)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockFeedRepo := new(MockCalendarFeedRepository)
+		service := NewCalendarService(mockPlanRepo, mockFeedRepo)
+		mockFeedRepo.On("GetFeedByToken", "revoked").Return(nil, nil).Once()
+
+		_, err := service.RenderFeed("revoked.ics", now)
+
+		assert.EqualError(t, err, "calendar feed not found")
+		mockPlanRepo.AssertNotCalled(t, "GetPlansByUserID", mock.Anything)
+	})
+}
+
+func TestCalendarService_SaveFeed(t *testing.T) {
+	mockFeedRepo := new(MockCalendarFeedRepository)
+	service := NewCalendarService(nil, mockFeedRepo)
+	existing := &models.CalendarFeed{ID: 1, UserID: "feedUser", Token: "old", Discreet: true}
+	mockFeedRepo.On("GetFeedByUserID", "feedUser").Return(existing, nil)
+	mockFeedRepo.On("SaveFeed", existing).Return(nil)
+
+	discreet := false
+	feed, err := service.SaveFeed("feedUser", models.CalendarFeedInput{Discreet: &discreet})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", feed.Token, "the token is kept unless rotated")
+	assert.False(t, feed.Discreet)
+
+	feed, err = service.SaveFeed("feedUser", models.CalendarFeedInput{Rotate: true})
+	assert.NoError(t, err)
+	assert.Len(t, feed.Token, 48)
+	assert.NotEqual(t, "old", feed.Token)
+
+	timezone := "Mars/Olympus"
+	_, err = service.SaveFeed("feedUser", models.CalendarFeedInput{Timezone: &timezone})
+	assert.EqualError(t, err, "invalid timezone 'Mars/Olympus'")
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -36,15 +37,17 @@ type ChatRequest struct {
 	AIName       string `json:"aiName"`
 	History      []models.ChatMessage `json:"history"` // 应该是纯粹的过去历史记录
 	Index        int    `json:"index"`   // 通常为0，表示当前消息是最新
+	Retrieval    bool   `json:"retrieval"` // 知识型智能体：先检索知识库文章，依据文章回答并标注引用
 	// ResponseCallback func(string) // 当前未使用
 }
 
 type chatService struct {
-	chatRepo repository.ChatRepository // <<< ADDED FIELD
+	chatRepo  repository.ChatRepository // <<< ADDED FIELD
+	retriever Retriever                 // Optional; grounds the answers of knowledge agents in the knowledge base
 }
 
-func NewChatService(chatRepo repository.ChatRepository) ChatService { // <<< MODIFIED PARAMETER
-	return &chatService{chatRepo: chatRepo} // <<< INITIALIZE FIELD
+func NewChatService(chatRepo repository.ChatRepository, retriever Retriever) ChatService { // <<< MODIFIED PARAMETER
+	return &chatService{chatRepo: chatRepo, retriever: retriever} // <<< INITIALIZE FIELD
 }
 
 func (s *chatService) ProcessMessageStream(
@@ -54,30 +57,11 @@ func (s *chatService) ProcessMessageStream(
 	additionalContextHeader string, // 接收此参数
 ) (string, error) {
 
-	providerKey, modelExists := config.AppConfig.LLMModels[req.Model]
-	if !modelExists {
-		errMsg := fmt.Sprintf("model '%s' not found in LLMModels configuration", req.Model)
-		log.Printf("ERROR: [ChatService] ProcessMessageStream: %s", errMsg)
-		return "", errors.New(errMsg) // Or a more specific error type
-	}
-	providerConfig, providerExists := config.AppConfig.LLMProviders[providerKey]
-	if !providerExists {
-		errMsg := fmt.Sprintf("provider key '%s' for model '%s' not found in LLMProviders configuration", providerKey, req.Model)
-		log.Printf("ERROR: [ChatService] ProcessMessageStream: %s", errMsg)
-		return "", errors.New(errMsg)
-	}
-	apiKey := providerConfig.APIKey
-	baseURL := providerConfig.BaseURL
-	if apiKey == "" || baseURL == "" {
-		errMsg := fmt.Sprintf("API key or BaseURL is empty for provider '%s' (model '%s')", providerKey, req.Model)
-		log.Printf("ERROR: [ChatService] ProcessMessageStream: %s", errMsg)
-		return "", errors.New(errMsg)
+	client, err := newOpenAIClient(req.Model)
+	if err != nil {
+		return "", err
 	}
 
-	oclient := openai.DefaultConfig(apiKey)
-	oclient.BaseURL = baseURL
-	client := openai.NewClientWithConfig(oclient)
-
 	systemPrompt := ""
 	if req.CustomPrompt != "" {
 		systemPrompt = req.CustomPrompt
@@ -106,6 +90,16 @@ func (s *chatService) ProcessMessageStream(
 		})
 	}
 
+	// Knowledge agents answer from the retrieved articles and cite them
+	var passages []Passage
+	if req.Retrieval && s.retriever != nil {
+		passages = s.retrievePassages(req, currentUserMessage.Content)
+		llmMessages = append(llmMessages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: retrievalContext(passages),
+		})
+	}
+
 	// 添加历史消息 (req.History 是纯历史)
 	historyLimit := 10 // 可配置
 	actualHistory := req.History
@@ -207,6 +201,9 @@ func (s *chatService) ProcessMessageStream(
 	// No specific action for streamErr here, as it's returned.
 
 	finalReply := fullResponseContent.String()
+	if streamErr == nil && len(passages) > 0 {
+		s.sendCitations(writer, req, citedArticles(finalReply, passages))
+	}
 	if streamErr != nil {
 		log.Printf("WARN: [ChatService] AI '%s' (UserID: %s) stream completed with error. Partial reply length: %d. Error: %v", req.AIName, req.UserID, len(finalReply), streamErr)
 	} else {
@@ -215,6 +212,43 @@ func (s *chatService) ProcessMessageStream(
 	return finalReply, streamErr // Return accumulated content and any stream error
 }
 
+// retrievePassages looks up the knowledge base passages relevant to a message. Failures are logged; the agent is
+// then told that nothing relevant was found.
+func (s *chatService) retrievePassages(req ChatRequest, message string) []Passage {
+	topK := config.AppConfig.Retrieval.TopK
+	if topK <= 0 {
+		topK = 3
+	}
+	passages, err := s.retriever.Retrieve(message, topK)
+	if err != nil {
+		log.Printf("ERROR: [ChatService] Retrieval failed for AI '%s', UserID '%s': %v", req.AIName, req.UserID, err)
+		return nil
+	}
+	log.Printf("INFO: [ChatService] Retrieved %d passages for AI '%s', UserID '%s'.", len(passages), req.AIName, req.UserID)
+	return passages
+}
+
+// sendCitations sends the articles cited by a knowledge agent's reply as an SSE event, for the client to render
+// as links.
+func (s *chatService) sendCitations(writer http.ResponseWriter, req ChatRequest, citations []models.ArticleCitation) {
+	if len(citations) == 0 {
+		log.Printf("WARN: [ChatService] AI '%s' (UserID: %s) cited none of the retrieved articles.", req.AIName, req.UserID)
+		return
+	}
+	payload, err := json.Marshal(map[string]interface{}{"event": "citations", "articles": citations})
+	if err != nil {
+		log.Printf("ERROR: [ChatService] Failed to marshal citations for AI '%s': %v", req.AIName, err)
+		return
+	}
+	if _, err := writer.Write([]byte("data: " + string(payload) + "\n\n")); err != nil {
+		log.Printf("ERROR: [ChatService] Failed to write citations to client for AI '%s' (UserID: %s): %v", req.AIName, req.UserID, err)
+		return
+	}
+	if flusher, ok := writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func (s *chatService) GetChatHistory(userID string) ([]models.ChatMessage, error) {
 	log.Printf("INFO: [ChatService] GetChatHistory called for userID: %s", userID)
 	if s.chatRepo == nil {
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"project/repository"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFeedbackComment is the longest comment a user can leave with a vote, in characters.
+const maxFeedbackComment = 500
+
+// ContentFeedbackService collects users' "useful / not useful" votes on articles and exercises, which editors use
+// to find content that needs work.
+type ContentFeedbackService interface {
+	// SubmitFeedback records a user's vote on an approved article or exercise, returning the votes on it.
+	SubmitFeedback(contentType models.ContentType, contentID uint, input models.ContentFeedbackInput) (*models.ContentFeedbackSummary, error)
+	ListFeedbackSummaries(contentType models.ContentType) ([]*models.ContentFeedbackSummary, error)
+}
+
+type contentFeedbackService struct {
+	feedbackRepo    repository.ContentFeedbackRepository
+	articleService  ArticleService
+	exerciseService ExerciseService
+}
+
+// NewContentFeedbackService creates a new instance of ContentFeedbackService.
+func NewContentFeedbackService(feedbackRepo repository.ContentFeedbackRepository, articleService ArticleService, exerciseService ExerciseService) ContentFeedbackService {
+	return &contentFeedbackService{
+		feedbackRepo:    feedbackRepo,
+		articleService:  articleService,
+		exerciseService: exerciseService,
+	}
+}
+
+func (s *contentFeedbackService) SubmitFeedback(contentType models.ContentType, contentID uint, input models.ContentFeedbackInput) (*models.ContentFeedbackSummary, error) {
+	userID := strings.TrimSpace(input.UserID)
+	if userID == "" {
+		return nil, errors.New("invalid feedback: user_id is required")
+	}
+	if input.Useful == nil {
+		return nil, errors.New("invalid feedback: useful is required")
+	}
+	comment := strings.TrimSpace(input.Comment)
+	if utf8.RuneCountInString(comment) > maxFeedbackComment {
+		return nil, fmt.Errorf("invalid feedback: comment is longer than %d characters", maxFeedbackComment)
+	}
+	// Users can only vote on content they can see
+	switch contentType {
+	case models.ContentTypeArticle:
+		if _, err := s.articleService.GetArticle(contentID, true); err != nil {
+			return nil, err
+		}
+	case models.ContentTypeExercise:
+		if _, err := s.exerciseService.GetExercise(contentID, true); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid content type '%s'", contentType)
+	}
+
+	feedback := &models.ContentFeedback{ContentType: contentType, ContentID: contentID, UserID: userID, Useful: *input.Useful, Comment: comment}
+	if err := s.feedbackRepo.SaveFeedback(feedback); err != nil {
+		errMsg := fmt.Sprintf("failed to save feedback of userID %s on %s ID %d", userID, contentType, contentID)
+		log.Printf("ERROR: [ContentFeedbackService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	log.Printf("INFO: [ContentFeedbackService] UserID %s voted %s ID %d useful=%t.", userID, contentType, contentID, *input.Useful)
+
+	summary, err := s.feedbackRepo.GetFeedbackSummary(contentType, contentID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to count feedback on %s ID %d", contentType, contentID)
+		log.Printf("ERROR: [ContentFeedbackService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return summary, nil
+}
+
+func (s *contentFeedbackService) ListFeedbackSummaries(contentType models.ContentType) ([]*models.ContentFeedbackSummary, error) {
+	if contentType != models.ContentTypeArticle && contentType != models.ContentTypeExercise {
+		return nil, fmt.Errorf("invalid content type '%s'", contentType)
+	}
+	summaries, err := s.feedbackRepo.ListFeedbackSummaries(contentType)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to list feedback on %s content", contentType)
+		log.Printf("ERROR: [ContentFeedbackService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"project/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockContentFeedbackRepository is a mock type for the ContentFeedbackRepository type
+type MockContentFeedbackRepository struct {
+	mock.Mock
+}
+
+func (m *MockContentFeedbackRepository) SaveFeedback(feedback *models.ContentFeedback) error {
+	args := m.Called(feedback)
+	return args.Error(0)
+}
+
+func (m *MockContentFeedbackRepository) GetFeedbackSummary(contentType models.ContentType, contentID uint) (*models.ContentFeedbackSummary, error) {
+	args := m.Called(contentType, contentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ContentFeedbackSummary), args.Error(1)
+}
+
+func (m *MockContentFeedbackRepository) ListFeedbackSummaries(contentType models.ContentType) ([]*models.ContentFeedbackSummary, error) {
+	args := m.Called(contentType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ContentFeedbackSummary), args.Error(1)
+}
+
+func TestContentFeedbackService_SubmitFeedback(t *testing.T) {
+	mockFeedbackRepo := new(MockContentFeedbackRepository)
+	mockArticleRepo := new(MockArticleRepository)
+	mockExerciseRepo := new(MockExerciseRepository)
+	service := NewContentFeedbackService(mockFeedbackRepo, NewArticleService(mockArticleRepo), NewExerciseService(mockExerciseRepo))
+	useful, notUseful := true, false
+
+	mockArticleRepo.On("GetArticleByID", uint(1)).Return(&models.Article{ID: 1, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusApproved}}, nil)
+	mockArticleRepo.On("GetArticleByID", uint(2)).Return(&models.Article{ID: 2, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusRetired}}, nil)
+	mockExerciseRepo.On("GetExerciseByID", uint(3)).Return(&models.Exercise{ID: 3, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusApproved}}, nil)
+
+	t.Run("Vote on an article", func(t *testing.T) {
+		summary := &models.ContentFeedbackSummary{ContentType: models.ContentTypeArticle, ContentID: 1, Useful: 4, NotUseful: 1}
+		mockFeedbackRepo.On("SaveFeedback", mock.MatchedBy(func(f *models.ContentFeedback) bool {
+			return f.ContentType == models.ContentTypeArticle && f.ContentID == 1 && f.UserID == "user1" && f.Useful && f.Comment == "讲得很清楚"
+		})).Return(nil).Once()
+		mockFeedbackRepo.On("GetFeedbackSummary", models.ContentTypeArticle, uint(1)).Return(summary, nil).Once()
+
+		result, err := service.SubmitFeedback(models.ContentTypeArticle, 1, models.ContentFeedbackInput{UserID: " user1 ", Useful: &useful, Comment: " 讲得很清楚 "})
+		assert.NoError(t, err)
+		assert.Equal(t, summary, result)
+	})
+
+	t.Run("Vote on an exercise", func(t *testing.T) {
+		mockFeedbackRepo.On("SaveFeedback", mock.MatchedBy(func(f *models.ContentFeedback) bool {
+			return f.ContentType == models.ContentTypeExercise && f.ContentID == 3 && !f.Useful
+		})).Return(nil).Once()
+		mockFeedbackRepo.On("GetFeedbackSummary", models.ContentTypeExercise, uint(3)).Return(&models.ContentFeedbackSummary{NotUseful: 1}, nil).Once()
+
+		_, err := service.SubmitFeedback(models.ContentTypeExercise, 3, models.ContentFeedbackInput{UserID: "user1", Useful: &notUseful})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Retired content cannot be voted on", func(t *testing.T) {
+		_, err := service.SubmitFeedback(models.ContentTypeArticle, 2, models.ContentFeedbackInput{UserID: "user1", Useful: &useful})
+		assert.EqualError(t, err, "article 2 not found")
+	})
+
+	for name, input := range map[string]models.ContentFeedbackInput{
+		"no user":      {Useful: &useful},
+		"no vote":      {UserID: "user1"},
+		"long comment": {UserID: "user1", Useful: &useful, Comment: strings.Repeat("好", maxFeedbackComment+1)},
+	} {
+		_, err := service.SubmitFeedback(models.ContentTypeArticle, 1, input)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "invalid feedback", name)
+		}
+	}
+	_, err := service.SubmitFeedback("video", 1, models.ContentFeedbackInput{UserID: "user1", Useful: &useful})
+	assert.EqualError(t, err, "invalid content type 'video'")
+
+	t.Run("Storage failure", func(t *testing.T) {
+		mockFeedbackRepo.On("SaveFeedback", mock.Anything).Return(errors.New("db down")).Once()
+		_, err := service.SubmitFeedback(models.ContentTypeArticle, 1, models.ContentFeedbackInput{UserID: "user2", Useful: &useful})
+		assert.ErrorContains(t, err, "failed to save feedback")
+	})
+	mockFeedbackRepo.AssertExpectations(t)
+}
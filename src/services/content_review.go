@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"project/config"
+	"project/models"
+	"strings"
+	"time"
+)
+
+// reviewTransitions is the editorial state machine of articles and exercises: the statuses content may move to
+// from each status. Rejected content goes back to draft; retired content can only be revived as a draft.
+var reviewTransitions = map[models.ReviewStatus][]models.ReviewStatus{
+	models.ReviewStatusDraft:    {models.ReviewStatusInReview, models.ReviewStatusRetired},
+	models.ReviewStatusInReview: {models.ReviewStatusDraft, models.ReviewStatusApproved, models.ReviewStatusRetired},
+	models.ReviewStatusApproved: {models.ReviewStatusInReview, models.ReviewStatusRetired},
+	models.ReviewStatusRetired:  {models.ReviewStatusDraft},
+}
+
+func validReviewStatus(status models.ReviewStatus) bool {
+	_, ok := reviewTransitions[status]
+	return ok
+}
+
+// applyReview moves content to input.Status; label names the content in errors, e.g. "article 3". Approving,
+// rejecting and retiring are decisions of a reviewer, who is recorded with the date.
+func applyReview(review *models.ContentReview, label string, input models.ReviewInput, now time.Time) error {
+	if !validReviewStatus(input.Status) {
+		return fmt.Errorf("invalid review: unknown status '%s'", input.Status)
+	}
+	from, to := review.ReviewStatus, input.Status
+	allowed := false
+	for _, status := range reviewTransitions[from] {
+		allowed = allowed || status == to
+	}
+	if !allowed {
+		return fmt.Errorf("cannot move %s from %s to %s", label, from, to)
+	}
+	reviewer := strings.TrimSpace(input.Reviewer)
+	decision := to == models.ReviewStatusApproved || to == models.ReviewStatusRetired || from == models.ReviewStatusInReview
+	if decision && reviewer == "" {
+		return fmt.Errorf("invalid review: reviewer is required to move %s to %s", label, to)
+	}
+
+	review.ReviewStatus = to
+	review.ReviewNote = strings.TrimSpace(input.Note)
+	if decision {
+		reviewedAt := now
+		review.ReviewedBy, review.ReviewedAt = reviewer, &reviewedAt
+	}
+	return nil
+}
+
+// reviewAfterEdit sends approved content back to review when its content changes, since the approval covered
+// the previous content.
+func reviewAfterEdit(review *models.ContentReview) {
+	if review.ReviewStatus == models.ReviewStatusApproved {
+		review.ReviewStatus = models.ReviewStatusInReview
+		review.ReviewNote = "内容已修改，需要重新审核"
+	}
+}
+
+// reviewExpired reports whether content was approved more than content_review.max_age_days ago, and if so sends it
+// back to review. Approvals never expire if max_age_days is 0.
+func reviewExpired(review *models.ContentReview, now time.Time) bool {
+	if !approvalExpired(review, now) {
+		return false
+	}
+	review.ReviewStatus = models.ReviewStatusInReview
+	review.ReviewNote = fmt.Sprintf("审核已超过 %d 天，需要重新审核", config.AppConfig.ContentReview.MaxAgeDays)
+	return true
+}
+
+// approvalExpired reports whether content was approved more than content_review.max_age_days ago, without
+// changing its review.
+func approvalExpired(review *models.ContentReview, now time.Time) bool {
+	maxAgeDays := config.AppConfig.ContentReview.MaxAgeDays
+	if maxAgeDays <= 0 || review.ReviewStatus != models.ReviewStatusApproved || review.ReviewedAt == nil {
+		return false
+	}
+	return now.Sub(*review.ReviewedAt) >= time.Duration(maxAgeDays)*24*time.Hour
+}
+
+// approvalCutoff returns the time at or before which approvals have expired, or nil if they never expire. Lists of
+// approved content pass it as ReviewedAfter, so that users don't see content waiting for the review job.
+func approvalCutoff(now time.Time) *time.Time {
+	maxAgeDays := config.AppConfig.ContentReview.MaxAgeDays
+	if maxAgeDays <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-time.Duration(maxAgeDays) * 24 * time.Hour)
+	return &cutoff
+}
+
+// currentlyApproved reports whether content is approved and its approval has not expired.
+func currentlyApproved(review *models.ContentReview, now time.Time) bool {
+	return review.ReviewStatus == models.ReviewStatusApproved && !approvalExpired(review, now)
+}
+
+// ContentReviewJob returns the job sending articles and exercises whose approval has expired back to review.
+func ContentReviewJob(articleService ArticleService, exerciseService ExerciseService) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		now := time.Now()
+		articles, err := articleService.ExpireReviews(now)
+		if err != nil {
+			return err
+		}
+		exercises, err := exerciseService.ExpireReviews(now)
+		if err != nil {
+			return err
+		}
+		out.Printf("%d articles and %d exercises sent back to review.", articles, exercises)
+		return nil
+	}
+}
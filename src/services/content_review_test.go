@@ -0,0 +1,166 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApplyReview(t *testing.T) {
+	now := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	review := &models.ContentReview{ReviewStatus: models.ReviewStatusDraft}
+
+	// Submitting a draft is not a decision, so no reviewer is needed
+	assert.NoError(t, applyReview(review, "article 1", models.ReviewInput{Status: models.ReviewStatusInReview, Note: " 请审核 "}, now))
+	assert.Equal(t, models.ReviewStatusInReview, review.ReviewStatus)
+	assert.Equal(t, "请审核", review.ReviewNote)
+	assert.Nil(t, review.ReviewedAt)
+
+	err := applyReview(review, "article 1", models.ReviewInput{Status: models.ReviewStatusApproved}, now)
+	assert.EqualError(t, err, "invalid review: reviewer is required to move article 1 to approved")
+	assert.NoError(t, applyReview(review, "article 1", models.ReviewInput{Status: models.ReviewStatusApproved, Reviewer: " 王医生 "}, now))
+	assert.Equal(t, models.ReviewStatusApproved, review.ReviewStatus)
+	assert.Equal(t, "王医生", review.ReviewedBy)
+	if assert.NotNil(t, review.ReviewedAt) {
+		assert.Equal(t, now, *review.ReviewedAt)
+	}
+
+	err = applyReview(review, "article 1", models.ReviewInput{Status: models.ReviewStatusDraft, Reviewer: "王医生"}, now)
+	assert.EqualError(t, err, "cannot move article 1 from approved to draft")
+	err = applyReview(review, "article 1", models.ReviewInput{Status: "published", Reviewer: "王医生"}, now)
+	assert.EqualError(t, err, "invalid review: unknown status 'published'")
+
+	assert.NoError(t, applyReview(review, "article 1", models.ReviewInput{Status: models.ReviewStatusRetired, Reviewer: "李编辑"}, now.Add(time.Hour)))
+	assert.Equal(t, "李编辑", review.ReviewedBy)
+	err = applyReview(review, "article 1", models.ReviewInput{Status: models.ReviewStatusApproved, Reviewer: "王医生"}, now)
+	assert.EqualError(t, err, "cannot move article 1 from retired to approved", "retired content is revived as a draft")
+
+	t.Run("Editing approved content sends it back to review", func(t *testing.T) {
+		review := &models.ContentReview{ReviewStatus: models.ReviewStatusApproved}
+		reviewAfterEdit(review)
+		assert.Equal(t, models.ReviewStatusInReview, review.ReviewStatus)
+
+		draft := &models.ContentReview{ReviewStatus: models.ReviewStatusDraft}
+		reviewAfterEdit(draft)
+		assert.Equal(t, models.ReviewStatusDraft, draft.ReviewStatus)
+	})
+}
+
+func TestContentService_ExpireReviews(t *testing.T) {
+	original := config.AppConfig.ContentReview
+	config.AppConfig.ContentReview = config.ContentReviewConfig{MaxAgeDays: 365}
+	defer func() { config.AppConfig.ContentReview = original }()
+
+	now := time.Date(2024, 3, 15, 5, 0, 0, 0, time.UTC)
+	approvedAt := func(daysAgo int) models.ContentReview {
+		reviewedAt := now.AddDate(0, 0, -daysAgo)
+		return models.ContentReview{ReviewStatus: models.ReviewStatusApproved, ReviewedBy: "王医生", ReviewedAt: &reviewedAt}
+	}
+
+	t.Run("Articles", func(t *testing.T) {
+		mockArticleRepo := new(MockArticleRepository)
+		service := NewArticleService(mockArticleRepo)
+		stale := &models.Article{ID: 1, ContentReview: approvedAt(400)}
+		fresh := &models.Article{ID: 2, ContentReview: approvedAt(30)}
+		mockArticleRepo.On("ListArticles", models.ArticleQuery{Status: models.ReviewStatusApproved, Limit: maxArticlePage}).
+			Return(&models.ArticlePage{Articles: []*models.Article{stale, fresh}, Total: 2}, nil).Once()
+		mockArticleRepo.On("UpdateArticle", stale).Return(nil).Once()
+
+		expired, err := service.ExpireReviews(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, models.ReviewStatusInReview, stale.ReviewStatus)
+		assert.Equal(t, "审核已超过 365 天，需要重新审核", stale.ReviewNote)
+		assert.Equal(t, models.ReviewStatusApproved, fresh.ReviewStatus)
+		mockArticleRepo.AssertExpectations(t)
+	})
+
+	t.Run("Exercises", func(t *testing.T) {
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewExerciseService(mockExerciseRepo)
+		stale := &models.Exercise{ID: 3, ContentReview: approvedAt(365)}
+		mockExerciseRepo.On("ListExercises", models.ExerciseQuery{Status: models.ReviewStatusApproved}).
+			Return([]*models.Exercise{stale}, nil).Once()
+		mockExerciseRepo.On("UpdateExercise", stale).Return(nil).Once()
+
+		expired, err := service.ExpireReviews(now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.Equal(t, models.ReviewStatusInReview, stale.ReviewStatus)
+		mockExerciseRepo.AssertExpectations(t)
+	})
+
+	t.Run("Approvals never expire without max_age_days", func(t *testing.T) {
+		config.AppConfig.ContentReview.MaxAgeDays = 0
+		review := approvedAt(1000)
+		assert.False(t, reviewExpired(&review, now))
+	})
+}
+
+func TestContentService_ExpiredApprovalsAreHidden(t *testing.T) {
+	original := config.AppConfig.ContentReview
+	config.AppConfig.ContentReview = config.ContentReviewConfig{MaxAgeDays: 365}
+	defer func() { config.AppConfig.ContentReview = original }()
+
+	// The review job has not run yet, so the content is still marked approved
+	now := time.Now()
+	approvedAt := func(daysAgo int) models.ContentReview {
+		reviewedAt := now.AddDate(0, 0, -daysAgo)
+		return models.ContentReview{ReviewStatus: models.ReviewStatusApproved, ReviewedBy: "王医生", ReviewedAt: &reviewedAt}
+	}
+
+	t.Run("Articles", func(t *testing.T) {
+		mockArticleRepo := new(MockArticleRepository)
+		service := NewArticleService(mockArticleRepo)
+		mockArticleRepo.On("GetArticleByID", uint(1)).Return(&models.Article{ID: 1, ContentReview: approvedAt(400)}, nil)
+		mockArticleRepo.On("ListArticles", mock.MatchedBy(func(query models.ArticleQuery) bool {
+			return query.ReviewedAfter != nil && query.ReviewedAfter.Before(now.AddDate(0, 0, -364)) && query.ReviewedAfter.After(now.AddDate(0, 0, -366))
+		})).Return(&models.ArticlePage{}, nil).Once()
+
+		_, err := service.GetArticle(1, true)
+		assert.EqualError(t, err, "article 1 not found", "users don't see articles whose approval has expired")
+		_, err = service.GetArticle(1, false)
+		assert.NoError(t, err)
+		_, err = service.ListArticles(models.ArticleQuery{Status: models.ReviewStatusApproved, Limit: 20})
+		assert.NoError(t, err)
+		mockArticleRepo.AssertExpectations(t)
+	})
+
+	t.Run("Exercises", func(t *testing.T) {
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewExerciseService(mockExerciseRepo)
+		stale := &models.Exercise{ID: 3, ContentReview: approvedAt(365)}
+		fresh := &models.Exercise{ID: 4, ContentReview: approvedAt(30)}
+		mockExerciseRepo.On("GetExerciseByID", uint(3)).Return(stale, nil)
+		mockExerciseRepo.On("GetExercisesByIDs", []uint{3, 4}).Return([]*models.Exercise{stale, fresh}, nil).Once()
+
+		_, err := service.GetExercise(3, true)
+		assert.EqualError(t, err, "exercise 3 not found")
+		exerciseID := func(id uint) *uint { return &id }
+		tasks := []models.PlanTask{{ID: 1, ExerciseID: exerciseID(3)}, {ID: 2, ExerciseID: exerciseID(4)}}
+		service.AttachExercises(tasks)
+		assert.Nil(t, tasks[0].Exercise, "plans don't show exercises whose approval has expired")
+		assert.Equal(t, fresh, tasks[1].Exercise)
+	})
+}
+
+func TestArticleService_ReviewArticle(t *testing.T) {
+	mockArticleRepo := new(MockArticleRepository)
+	service := NewArticleService(mockArticleRepo)
+	article := &models.Article{ID: 1, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusInReview}}
+	mockArticleRepo.On("GetArticleByID", uint(1)).Return(article, nil)
+	mockArticleRepo.On("UpdateArticle", mock.AnythingOfType("*models.Article")).Return(nil).Once()
+
+	reviewed, err := service.ReviewArticle(1, models.ReviewInput{Status: models.ReviewStatusDraft, Reviewer: "王医生", Note: "参考文献过旧"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReviewStatusDraft, reviewed.ReviewStatus)
+	assert.Equal(t, "王医生", reviewed.ReviewedBy, "rejecting is a decision")
+
+	_, err = service.ReviewArticle(1, models.ReviewInput{Status: models.ReviewStatusApproved, Reviewer: "王医生"})
+	assert.EqualError(t, err, "cannot move article 1 from draft to approved")
+	mockArticleRepo.AssertExpectations(t)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"project/models"
+	"sync"
+	"time"
+)
+
+// EventHandler receives the events published on an EventBus. It runs in the publisher's goroutine, so it must
+// return quickly; slow work such as network calls belongs in the background.
+type EventHandler func(event models.Event)
+
+// EventBus passes application events, such as a plan being created, from the services and handlers that publish
+// them to the subscribers, such as the WebhookService.
+type EventBus interface {
+	Subscribe(handler EventHandler)
+	// Publish assigns the event an ID and time if it has none and passes it to every subscriber. A subscriber that
+	// panics is logged and does not affect the publisher or the other subscribers.
+	Publish(event models.Event)
+}
+
+type eventBus struct {
+	mu       sync.RWMutex
+	handlers []EventHandler
+}
+
+// NewEventBus creates a new instance of EventBus.
+func NewEventBus() EventBus {
+	return &eventBus{}
+}
+
+func (b *eventBus) Subscribe(handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *eventBus) Publish(event models.Event) {
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("ERROR: [EventBus] Subscriber panicked on %s event %s: %v", event.Type, event.ID, r)
+				}
+			}()
+			handler(event)
+		}()
+	}
+}
+
+// newEventID returns a random ID for an event.
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("evt-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// publishEvent publishes an event on the bus, which is optional for the services that use it.
+func publishEvent(events EventBus, eventType, userID string, data interface{}) {
+	if events == nil {
+		return
+	}
+	events.Publish(models.Event{Type: eventType, UserID: userID, Data: data})
+}
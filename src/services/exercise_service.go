@@ -0,0 +1,297 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"project/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExerciseService manages the exercise library. Users, plans and the planner only see approved exercises.
+type ExerciseService interface {
+	CreateExercise(input models.ExerciseInput) (*models.Exercise, error)
+	UpdateExercise(exerciseID uint, input models.ExerciseInput) (*models.Exercise, error) // Replaces the exercise's content
+	DeleteExercise(exerciseID uint) error
+	GetExercise(exerciseID uint, approvedOnly bool) (*models.Exercise, error)
+	ListExercises(query models.ExerciseQuery) ([]*models.Exercise, error)
+	ReviewExercise(exerciseID uint, input models.ReviewInput) (*models.Exercise, error) // Moves the exercise through the editorial review
+	// ExpireReviews sends the exercises approved more than content_review.max_age_days ago back to review,
+	// returning how many.
+	ExpireReviews(now time.Time) (int, error)
+	// AttachExercises fills in the approved exercises that tasks follow, so that a plan shows how to do its
+	// exercise tasks. Failures are logged only; the tasks then show without instructions.
+	AttachExercises(tasks []models.PlanTask)
+}
+
+type exerciseService struct {
+	exerciseRepo repository.ExerciseRepository
+}
+
+// NewExerciseService creates a new instance of ExerciseService.
+func NewExerciseService(exerciseRepo repository.ExerciseRepository) ExerciseService {
+	return &exerciseService{exerciseRepo: exerciseRepo}
+}
+
+func (s *exerciseService) CreateExercise(input models.ExerciseInput) (*models.Exercise, error) {
+	exercise := &models.Exercise{ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusDraft}}
+	if err := applyExerciseInput(exercise, input); err != nil {
+		return nil, err
+	}
+	if err := s.exerciseRepo.CreateExercise(exercise); err != nil {
+		errMsg := fmt.Sprintf("failed to create exercise '%s'", exercise.Name)
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return exercise, nil
+}
+
+func (s *exerciseService) UpdateExercise(exerciseID uint, input models.ExerciseInput) (*models.Exercise, error) {
+	exercise, err := s.GetExercise(exerciseID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyExerciseInput(exercise, input); err != nil {
+		return nil, err
+	}
+	reviewAfterEdit(&exercise.ContentReview)
+	if err := s.exerciseRepo.UpdateExercise(exercise); err != nil {
+		errMsg := fmt.Sprintf("failed to update exercise ID %d", exerciseID)
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return exercise, nil
+}
+
+func (s *exerciseService) DeleteExercise(exerciseID uint) error {
+	if _, err := s.GetExercise(exerciseID, false); err != nil {
+		return err
+	}
+	if err := s.exerciseRepo.DeleteExercise(exerciseID); err != nil {
+		errMsg := fmt.Sprintf("failed to delete exercise ID %d", exerciseID)
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return nil
+}
+
+func (s *exerciseService) GetExercise(exerciseID uint, approvedOnly bool) (*models.Exercise, error) {
+	exercise, err := s.exerciseRepo.GetExerciseByID(exerciseID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch exercise ID %d", exerciseID)
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if exercise == nil || (approvedOnly && !currentlyApproved(&exercise.ContentReview, time.Now())) {
+		return nil, fmt.Errorf("exercise %d not found", exerciseID)
+	}
+	return exercise, nil
+}
+
+func (s *exerciseService) ListExercises(query models.ExerciseQuery) ([]*models.Exercise, error) {
+	if query.Category != "" && !validExerciseCategory(query.Category) {
+		return nil, fmt.Errorf("invalid category '%s'", query.Category)
+	}
+	if query.Difficulty != "" && !validExerciseDifficulty(query.Difficulty) {
+		return nil, fmt.Errorf("invalid difficulty '%s'", query.Difficulty)
+	}
+	if query.Status != "" && !validReviewStatus(query.Status) {
+		return nil, fmt.Errorf("invalid review status '%s'", query.Status)
+	}
+	if query.Status == models.ReviewStatusApproved {
+		query.ReviewedAfter = approvalCutoff(time.Now())
+	}
+	exercises, err := s.exerciseRepo.ListExercises(query)
+	if err != nil {
+		errMsg := "failed to list exercises"
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return exercises, nil
+}
+
+func (s *exerciseService) ReviewExercise(exerciseID uint, input models.ReviewInput) (*models.Exercise, error) {
+	exercise, err := s.GetExercise(exerciseID, false)
+	if err != nil {
+		return nil, err
+	}
+	from := exercise.ReviewStatus
+	if err := applyReview(&exercise.ContentReview, fmt.Sprintf("exercise %d", exerciseID), input, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.exerciseRepo.UpdateExercise(exercise); err != nil {
+		errMsg := fmt.Sprintf("failed to save the review of exercise ID %d", exerciseID)
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	log.Printf("INFO: [ExerciseService] Exercise ID %d moved from %s to %s by '%s'.", exerciseID, from, exercise.ReviewStatus, input.Reviewer)
+	return exercise, nil
+}
+
+func (s *exerciseService) ExpireReviews(now time.Time) (int, error) {
+	approved, err := s.exerciseRepo.ListExercises(models.ExerciseQuery{Status: models.ReviewStatusApproved})
+	if err != nil {
+		errMsg := "failed to list approved exercises"
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return 0, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	expired := 0
+	for _, exercise := range approved {
+		if !reviewExpired(&exercise.ContentReview, now) {
+			continue
+		}
+		if err := s.exerciseRepo.UpdateExercise(exercise); err != nil {
+			log.Printf("ERROR: [ExerciseService] Failed to send exercise ID %d back to review: %v", exercise.ID, err)
+			continue
+		}
+		expired++
+		log.Printf("INFO: [ExerciseService] Approval of exercise ID %d expired; sent back to review.", exercise.ID)
+	}
+	return expired, nil
+}
+
+func (s *exerciseService) AttachExercises(tasks []models.PlanTask) {
+	var ids []uint
+	for _, task := range tasks {
+		if task.ExerciseID != nil {
+			ids = append(ids, *task.ExerciseID)
+		}
+	}
+	approved, err := approvedExercises(s.exerciseRepo, ids)
+	if err != nil {
+		return
+	}
+	for i := range tasks {
+		if tasks[i].ExerciseID != nil {
+			tasks[i].Exercise = approved[*tasks[i].ExerciseID]
+		}
+	}
+}
+
+// applyExerciseInput validates input and copies it onto exercise.
+func applyExerciseInput(exercise *models.Exercise, input models.ExerciseInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return errors.New("invalid exercise: name is required")
+	}
+	if !validExerciseCategory(input.Category) {
+		return fmt.Errorf("invalid exercise: unknown category '%s'", input.Category)
+	}
+	if !validExerciseDifficulty(input.Difficulty) {
+		return fmt.Errorf("invalid exercise: unknown difficulty '%s'", input.Difficulty)
+	}
+	steps := trimmedItems(input.Steps)
+	if len(steps) == 0 {
+		return errors.New("invalid exercise: at least one step is required")
+	}
+	media := make([]models.ExerciseMedia, 0, len(input.Media))
+	for i, item := range input.Media {
+		item.URL, item.Title = strings.TrimSpace(item.URL), strings.TrimSpace(item.Title)
+		if item.Type != "image" && item.Type != "video" {
+			return fmt.Errorf("invalid exercise: media %d has unknown type '%s'", i+1, item.Type)
+		}
+		if item.URL == "" {
+			return fmt.Errorf("invalid exercise: media %d has no url", i+1)
+		}
+		media = append(media, item)
+	}
+
+	exercise.Name = name
+	exercise.Summary = strings.TrimSpace(input.Summary)
+	exercise.Category = input.Category
+	exercise.Difficulty = input.Difficulty
+	exercise.TargetMuscles = trimmedItems(input.TargetMuscles)
+	exercise.Steps = steps
+	exercise.KeyPoints = trimmedItems(input.KeyPoints)
+	exercise.CommonMistakes = trimmedItems(input.CommonMistakes)
+	exercise.Media = media
+	return nil
+}
+
+// trimmedItems returns the non-blank items, trimmed, keeping their order.
+func trimmedItems(items []string) []string {
+	trimmed := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
+
+func validExerciseCategory(category models.ExerciseCategory) bool {
+	for _, c := range models.ExerciseCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func validExerciseDifficulty(difficulty models.ExerciseDifficulty) bool {
+	switch difficulty {
+	case models.ExerciseDifficultyBeginner, models.ExerciseDifficultyIntermediate, models.ExerciseDifficultyAdvanced:
+		return true
+	}
+	return false
+}
+
+// approvedExercises returns the approved exercises among exerciseIDs whose approval has not expired, by ID. Without an exercise repository
+// there are none.
+func approvedExercises(exerciseRepo repository.ExerciseRepository, exerciseIDs []uint) (map[uint]*models.Exercise, error) {
+	approved := make(map[uint]*models.Exercise)
+	if exerciseRepo == nil || len(exerciseIDs) == 0 {
+		return approved, nil
+	}
+	exercises, err := exerciseRepo.GetExercisesByIDs(exerciseIDs)
+	if err != nil {
+		errMsg := "failed to fetch exercises of the library"
+		log.Printf("ERROR: [ExerciseService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	now := time.Now()
+	for _, exercise := range exercises {
+		if currentlyApproved(&exercise.ContentReview, now) {
+			approved[exercise.ID] = exercise
+		}
+	}
+	return approved, nil
+}
+
+// missingExercise returns the first of exerciseIDs that is not an approved exercise of the library, or 0 if
+// they all are. Without an exercise repository references cannot be checked and every ID counts as missing.
+func missingExercise(exerciseRepo repository.ExerciseRepository, exerciseIDs []uint) (uint, error) {
+	approved, err := approvedExercises(exerciseRepo, exerciseIDs)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range exerciseIDs {
+		if approved[id] == nil {
+			return id, nil
+		}
+	}
+	return 0, nil
+}
+
+// describeExerciseLibrary lists the exercises for the planner agent, which may only choose exercises from it.
+func describeExerciseLibrary(exercises map[uint]*models.Exercise) string {
+	ids := make([]uint, 0, len(exercises))
+	for id := range exercises {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var sb strings.Builder
+	sb.WriteString("[动作库]\n运动类任务（type 为 exercise）只能从以下动作中选择，并在任务中用整数字段 exercise_id 填写动作ID：\n")
+	for _, id := range ids {
+		exercise := exercises[id]
+		sb.WriteString(fmt.Sprintf("- [%d] %s（%s）", id, exercise.Name, exercise.Difficulty))
+		if len(exercise.TargetMuscles) > 0 {
+			sb.WriteString("，目标肌群：" + strings.Join(exercise.TargetMuscles, "、"))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockExerciseRepository is a mock type for the ExerciseRepository type
+type MockExerciseRepository struct {
+	mock.Mock
+}
+
+func (m *MockExerciseRepository) CreateExercise(exercise *models.Exercise) error {
+	args := m.Called(exercise)
+	return args.Error(0)
+}
+
+func (m *MockExerciseRepository) GetExerciseByID(exerciseID uint) (*models.Exercise, error) {
+	args := m.Called(exerciseID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Exercise), args.Error(1)
+}
+
+func (m *MockExerciseRepository) GetExercisesByIDs(exerciseIDs []uint) ([]*models.Exercise, error) {
+	args := m.Called(exerciseIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Exercise), args.Error(1)
+}
+
+func (m *MockExerciseRepository) ListExercises(query models.ExerciseQuery) ([]*models.Exercise, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Exercise), args.Error(1)
+}
+
+func (m *MockExerciseRepository) UpdateExercise(exercise *models.Exercise) error {
+	args := m.Called(exercise)
+	return args.Error(0)
+}
+
+func (m *MockExerciseRepository) DeleteExercise(exerciseID uint) error {
+	args := m.Called(exerciseID)
+	return args.Error(0)
+}
+
+func exerciseTestInput() models.ExerciseInput {
+	return models.ExerciseInput{
+		Name:           " 凯格尔基础收缩 ",
+		Category:       models.ExerciseCategoryPelvicFloor,
+		Difficulty:     models.ExerciseDifficultyBeginner,
+		TargetMuscles:  []string{"盆底肌", " "},
+		Steps:          []string{"平躺，双膝弯曲", "", " 收缩盆底肌并保持3秒 "},
+		CommonMistakes: []string{"憋气"},
+		Media:          []models.ExerciseMedia{{Type: "video", URL: " https://example.org/kegel.mp4 "}},
+	}
+}
+
+func TestApplyExerciseInput(t *testing.T) {
+	exercise := &models.Exercise{}
+	assert.NoError(t, applyExerciseInput(exercise, exerciseTestInput()))
+	assert.Equal(t, "凯格尔基础收缩", exercise.Name)
+	assert.Equal(t, []string{"平躺，双膝弯曲", "收缩盆底肌并保持3秒"}, exercise.Steps, "trimmed, in order, without blank steps")
+	assert.Equal(t, []string{"盆底肌"}, exercise.TargetMuscles)
+	assert.Equal(t, []string{}, exercise.KeyPoints)
+	assert.Equal(t, "https://example.org/kegel.mp4", exercise.Media[0].URL)
+
+	for name, change := range map[string]func(*models.ExerciseInput){
+		"no name":            func(in *models.ExerciseInput) { in.Name = "" },
+		"unknown category":   func(in *models.ExerciseInput) { in.Category = "dance" },
+		"unknown difficulty": func(in *models.ExerciseInput) { in.Difficulty = "expert" },
+		"no steps":           func(in *models.ExerciseInput) { in.Steps = []string{" "} },
+		"unknown media type": func(in *models.ExerciseInput) { in.Media[0].Type = "gif" },
+		"media without url":  func(in *models.ExerciseInput) { in.Media[0].URL = "" },
+	} {
+		input := exerciseTestInput()
+		change(&input)
+		err := applyExerciseInput(&models.Exercise{}, input)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "invalid exercise", name)
+		}
+	}
+}
+
+func TestExerciseService_GetExercise(t *testing.T) {
+	mockExerciseRepo := new(MockExerciseRepository)
+	service := NewExerciseService(mockExerciseRepo)
+	mockExerciseRepo.On("GetExerciseByID", uint(1)).Return(&models.Exercise{ID: 1, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusApproved}}, nil)
+	mockExerciseRepo.On("GetExerciseByID", uint(2)).Return(&models.Exercise{ID: 2, ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusDraft}}, nil)
+
+	exercise, err := service.GetExercise(1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), exercise.ID)
+
+	_, err = service.GetExercise(2, true)
+	assert.EqualError(t, err, "exercise 2 not found", "users only see approved exercises")
+	_, err = service.GetExercise(2, false)
+	assert.NoError(t, err)
+}
+
+func TestPlanService_ExerciseTasks(t *testing.T) {
+	userID := "exerciseUser"
+	approved := &models.Exercise{ID: 3, Name: "凯格尔基础收缩", ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusApproved}}
+	draft := &models.Exercise{ID: 4, Name: "反向凯格尔", ContentReview: models.ContentReview{ReviewStatus: models.ReviewStatusDraft}}
+	exerciseID := func(id uint) *uint { return &id }
+
+	t.Run("Plan detail embeds the approved exercises", func(t *testing.T) {
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewExerciseService(mockExerciseRepo)
+		tasks := []models.PlanTask{
+			{ID: 1, Type: models.TaskTypeExercise, ExerciseID: exerciseID(3)},
+			{ID: 2, Type: models.TaskTypeExercise, ExerciseID: exerciseID(4)},
+			{ID: 3, Type: models.TaskTypeHabit},
+		}
+		mockExerciseRepo.On("GetExercisesByIDs", []uint{3, 4}).Return([]*models.Exercise{approved, draft}, nil).Once()
+
+		service.AttachExercises(tasks)
+
+		assert.Equal(t, approved, tasks[0].Exercise)
+		assert.Nil(t, tasks[1].Exercise, "exercises that are not approved are not shown")
+		assert.Nil(t, tasks[2].Exercise)
+	})
+
+	t.Run("Tasks can only follow approved exercises", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, mockExerciseRepo)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(&models.Plan{ID: 5, UserID: userID, Status: models.PlanStatusActive}, nil)
+		mockExerciseRepo.On("GetExercisesByIDs", []uint{3}).Return([]*models.Exercise{approved}, nil).Once()
+		mockExerciseRepo.On("GetExercisesByIDs", []uint{4}).Return([]*models.Exercise{draft}, nil).Once()
+		mockPlanRepo.On("CreatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool {
+			return task.ExerciseID != nil && *task.ExerciseID == 3
+		})).Return(nil).Once()
+
+		task, err := service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("凯格尔"), Type: strPtr("exercise"), ExerciseID: exerciseID(3)})
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), *task.ExerciseID)
+
+		_, err = service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("反向凯格尔"), Type: strPtr("exercise"), ExerciseID: exerciseID(4)})
+		assert.EqualError(t, err, "invalid task: exercise 4 is not in the exercise library")
+
+		_, err = service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("早睡"), Type: strPtr("habit"), ExerciseID: exerciseID(3)})
+		assert.EqualError(t, err, "invalid task: only exercise tasks can follow an exercise")
+		mockPlanRepo.AssertNumberOfCalls(t, "CreatePlanTask", 1)
+	})
+
+	t.Run("Templates can only follow approved exercises", func(t *testing.T) {
+		mockTemplateRepo := new(MockPlanTemplateRepository)
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewPlanTemplateService(mockTemplateRepo, nil, nil, nil, nil, mockExerciseRepo)
+		mockExerciseRepo.On("GetExercisesByIDs", []uint{4}).Return([]*models.Exercise{draft}, nil).Once()
+
+		_, err := service.CreateTemplate(models.PlanTemplateInput{Title: "盆底肌计划", Tasks: []models.PlanTemplateTask{
+			{Type: models.TaskTypeHabit, Title: "早睡", Frequency: "每日"},
+			{Type: models.TaskTypeExercise, Title: "反向凯格尔", Frequency: "每日", ExerciseID: 4},
+		}})
+
+		assert.EqualError(t, err, "invalid template: task 2: exercise 4 is not in the exercise library")
+		mockTemplateRepo.AssertNotCalled(t, "CreateTemplate", mock.Anything)
+	})
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"fmt"
+	"project/config"
+	"project/models"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsLocalFormat = "20060102T150405"
+	icsUTCFormat   = "20060102T150405Z"
+)
+
+// icsWeekdays are the RFC 5545 weekday codes, indexed by time.Weekday.
+var icsWeekdays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// icsWriter builds an iCalendar document, escaping values and folding long lines as RFC 5545 requires.
+type icsWriter struct {
+	sb strings.Builder
+}
+
+// line writes a content line, folded at 75 octets without splitting UTF-8 characters.
+func (w *icsWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.sb.WriteString(s[:cut])
+		w.sb.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // Continuation lines start with a space
+	}
+	w.sb.WriteString(s)
+	w.sb.WriteString("\r\n")
+}
+
+// text writes a property with a TEXT value.
+func (w *icsWriter) text(name, value string) {
+	w.line(name + ":" + icsEscape(value))
+}
+
+// icsEscape escapes a TEXT value.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// calendarRender holds what is needed to render plans as a calendar.
+type calendarRender struct {
+	name     string // X-WR-CALNAME
+	loc      *time.Location
+	discreet bool
+	feed     bool // Rendered for a subscription; tells clients how often to refresh
+	now      time.Time
+}
+
+// renderCalendar renders the tasks of plans as an iCalendar document. Each daily occurrence of a task becomes a
+// recurring event, with a reminder if calendar.alarm_minutes is set.
+func renderCalendar(r calendarRender, plans []*models.Plan) []byte {
+	cfg := config.AppConfig.Calendar
+	productID := cfg.ProductID
+	if productID == "" {
+		productID = "-//Aphrodite-Bot//Plan Calendar//ZH"
+	}
+	w := &icsWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.text("PRODID", productID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.text("X-WR-CALNAME", r.name)
+	w.text("X-WR-TIMEZONE", r.loc.String())
+	if r.feed {
+		w.line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+		w.line("X-PUBLISHED-TTL:PT1H")
+	}
+
+	var events []calendarEvent
+	for _, plan := range plans {
+		events = append(events, planEvents(plan, r.loc, r.now)...)
+	}
+	if r.loc != time.UTC && len(events) > 0 {
+		from, to := events[0].start, events[0].until
+		for _, event := range events {
+			if event.start.Before(from) {
+				from = event.start
+			}
+			if to.IsZero() || event.until.IsZero() {
+				to = time.Time{}
+			} else if event.until.After(to) {
+				to = event.until
+			}
+		}
+		if to.IsZero() || to.After(from.AddDate(2, 0, 0)) {
+			to = from.AddDate(2, 0, 0) // Beyond this, clients keep using the last observance
+		}
+		writeTimezone(w, r.loc, from.AddDate(0, 0, -1), to)
+	}
+	for _, event := range events {
+		writeEvent(w, event, r)
+	}
+	w.line("END:VCALENDAR")
+	return []byte(w.sb.String())
+}
+
+// calendarEvent is one daily occurrence slot of a task, possibly repeating.
+type calendarEvent struct {
+	plan  *models.Plan
+	task  *models.PlanTask
+	slot  int
+	start time.Time // First occurrence, in the user's timezone
+	rrule string    // Empty for a single occurrence
+	until time.Time // End of the plan's last day; zero if open-ended
+}
+
+// planEvents lists the events of a plan's tasks. Tasks that will not occur again (skipped, or one-off tasks
+// already done) are left out.
+func planEvents(plan *models.Plan, loc *time.Location, now time.Time) []calendarEvent {
+	start := calendarDate(plan.StartDate, loc)
+	if start.IsZero() && !plan.CreatedAt.IsZero() {
+		start = calendarDate(plan.CreatedAt.In(loc).Format("2006-01-02"), loc)
+	}
+	if start.IsZero() {
+		start = calendarDate(now.In(loc).Format("2006-01-02"), loc)
+	}
+	var until time.Time
+	if end := calendarDate(plan.EndDate, loc); !end.IsZero() {
+		until = end.AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	tasks := make([]*models.PlanTask, 0, len(plan.Tasks))
+	for i := range plan.Tasks {
+		tasks = append(tasks, &plan.Tasks[i])
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Order < tasks[j].Order })
+
+	var events []calendarEvent
+	for _, task := range tasks {
+		if task.Status == models.TaskStatusSkipped {
+			continue
+		}
+		rec := taskRecurrence(task)
+		first, rrule, times := start, "", 1
+		switch rec.Kind {
+		case models.RecurrenceDaily:
+			rrule, times = "FREQ=DAILY", maxInt(rec.TimesPerDay, 1)
+		case models.RecurrenceWeekdays, models.RecurrenceTimesPerWeek:
+			weekdays := rec.Weekdays
+			if rec.Kind == models.RecurrenceTimesPerWeek {
+				weekdays = spreadWeekdays(rec.TimesPerWeek)
+			} else {
+				times = maxInt(rec.TimesPerDay, 1)
+			}
+			if len(weekdays) == 0 {
+				rrule = "FREQ=DAILY"
+				break
+			}
+			codes := make([]string, 0, len(weekdays))
+			for _, d := range weekdays {
+				codes = append(codes, icsWeekdays[d%7])
+			}
+			rrule = "FREQ=WEEKLY;BYDAY=" + strings.Join(codes, ",")
+			for !containsWeekday(weekdays, first.Weekday()) {
+				first = first.AddDate(0, 0, 1) // DTSTART must be an occurrence of the rule
+			}
+		case models.RecurrenceOnce:
+			if task.IsCompleted || task.Status == models.TaskStatusCompleted {
+				continue
+			}
+			if date := calendarDate(rec.Date, loc); !date.IsZero() {
+				first = date
+			}
+		}
+		if !until.IsZero() && first.After(until) {
+			continue
+		}
+		for slot := 1; slot <= times; slot++ {
+			events = append(events, calendarEvent{
+				plan:  plan,
+				task:  task,
+				slot:  slot,
+				start: slotStart(first, rec, slot),
+				rrule: rrule,
+				until: until,
+			})
+		}
+	}
+	return events
+}
+
+// writeEvent writes a VEVENT, with a VALARM if reminders are configured.
+func writeEvent(w *icsWriter, event calendarEvent, r calendarRender) {
+	cfg := config.AppConfig.Calendar
+	minutes := cfg.EventMinutes
+	if minutes <= 0 {
+		minutes = 15
+	}
+	summary := event.task.Title
+	if r.discreet {
+		summary = discreetTitle(event.task.Type)
+	}
+
+	w.line("BEGIN:VEVENT")
+	w.text("UID", fmt.Sprintf("plan%d-task%d-%d@aphrodite-bot", event.plan.ID, event.task.ID, event.slot))
+	w.line("DTSTAMP:" + r.now.UTC().Format(icsUTCFormat))
+	if !event.task.UpdatedAt.IsZero() {
+		w.line("LAST-MODIFIED:" + event.task.UpdatedAt.UTC().Format(icsUTCFormat))
+	}
+	w.line("DTSTART" + icsTime(event.start, r.loc))
+	w.line("DTEND" + icsTime(event.start.Add(time.Duration(minutes)*time.Minute), r.loc))
+	if event.rrule != "" {
+		rrule := event.rrule
+		if !event.until.IsZero() {
+			rrule += ";UNTIL=" + event.until.UTC().Format(icsUTCFormat)
+		}
+		w.line("RRULE:" + rrule)
+	}
+	w.text("SUMMARY", summary)
+	if !r.discreet {
+		var details []string
+		if event.task.Description != "" {
+			details = append(details, event.task.Description)
+		}
+		if event.task.Frequency != "" {
+			details = append(details, "频率："+event.task.Frequency)
+		}
+		if event.task.Duration != "" {
+			details = append(details, "时长："+event.task.Duration)
+		}
+		if len(details) > 0 {
+			w.text("DESCRIPTION", strings.Join(details, "\n"))
+		}
+	}
+	w.line("TRANSP:TRANSPARENT") // Tasks do not block the user's time
+	if cfg.AlarmMinutes > 0 {
+		w.line("BEGIN:VALARM")
+		w.line("ACTION:DISPLAY")
+		w.text("DESCRIPTION", summary)
+		w.line(fmt.Sprintf("TRIGGER:-PT%dM", cfg.AlarmMinutes))
+		w.line("END:VALARM")
+	}
+	w.line("END:VEVENT")
+}
+
+// writeTimezone writes a VTIMEZONE for loc with each UTC offset change between from and to.
+func writeTimezone(w *icsWriter, loc *time.Location, from, to time.Time) {
+	w.line("BEGIN:VTIMEZONE")
+	w.text("TZID", loc.String())
+	t := from.In(loc)
+	name, offset := t.Zone()
+	writeObservance(w, t.IsDST(), "19700101T000000", offset, offset, name)
+	for t.Before(to) {
+		next := t.Add(15 * time.Minute).In(loc)
+		nextName, nextOffset := next.Zone()
+		if nextOffset != offset {
+			// DTSTART of an observance is the local time before the change
+			onset := next.In(time.FixedZone("", offset)).Format(icsLocalFormat)
+			writeObservance(w, next.IsDST(), onset, offset, nextOffset, nextName)
+			offset = nextOffset
+		}
+		t = next
+	}
+	w.line("END:VTIMEZONE")
+}
+
+func writeObservance(w *icsWriter, dst bool, onset string, from, to int, name string) {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + onset)
+	w.line("TZOFFSETFROM:" + icsOffset(from))
+	w.line("TZOFFSETTO:" + icsOffset(to))
+	w.text("TZNAME", name)
+	w.line("END:" + kind)
+}
+
+// icsOffset formats a UTC offset in seconds as "+0800".
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// icsTime formats a DTSTART or DTEND value, including the property's TZID parameter outside UTC.
+func icsTime(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return ":" + t.UTC().Format(icsUTCFormat)
+	}
+	return ";TZID=" + loc.String() + ":" + t.In(loc).Format(icsLocalFormat)
+}
+
+// calendarDate parses a YYYY-MM-DD date as midnight in loc, returning the zero time if it is empty or invalid.
+func calendarDate(date string, loc *time.Location) time.Time {
+	if date == "" {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// slotStart returns the start time of the slot-th occurrence on day: the start of its time window, or else the
+// configured default time for that slot, or 20:00.
+func slotStart(day time.Time, rec *models.Recurrence, slot int) time.Time {
+	clock := "20:00"
+	if slot <= len(rec.Windows) && rec.Windows[slot-1].Start != "" {
+		clock = rec.Windows[slot-1].Start
+	} else if defaults := config.AppConfig.Calendar.DefaultTimes; len(defaults) > 0 {
+		clock = defaults[(slot-1)%len(defaults)]
+	}
+	var hour, minute int
+	if _, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute); err != nil {
+		hour, minute = 20, 0
+	}
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hour, minute, 0, 0, day.Location())
+}
+
+// spreadWeekdays picks n weekdays spread over the week from Monday, for tasks due a number of times a week on any days.
+func spreadWeekdays(n int) []int {
+	if n <= 0 {
+		return nil
+	}
+	if n > 7 {
+		n = 7
+	}
+	weekdays := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		weekdays = append(weekdays, (i*7/n+1)%7) // Offset from Monday, as time.Weekday
+	}
+	return weekdays
+}
+
+func containsWeekday(weekdays []int, weekday time.Weekday) bool {
+	for _, d := range weekdays {
+		if time.Weekday(d%7) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// discreetTitle returns the configured discreet event title for a task type.
+func discreetTitle(taskType models.TaskType) string {
+	titles := config.AppConfig.Calendar.DiscreetTitles
+	if title := titles[string(taskType)]; title != "" {
+		return title
+	}
+	if title := titles["default"]; title != "" {
+		return title
+	}
+	return "个人日程"
+}
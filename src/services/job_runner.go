@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"project/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JobFunc is the code of a background job. It should stop when ctx is done; lines written to out are kept with
+// the execution record.
+type JobFunc func(ctx context.Context, out *JobLog) error
+
+// JobLog collects the output of one job execution and echoes it to the application log.
+type JobLog struct {
+	job string
+	mu  sync.Mutex
+	sb  strings.Builder
+}
+
+// Printf adds a timestamped line to the execution log.
+func (l *JobLog) Printf(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	log.Printf("INFO: [JobRunner] %s: %s", l.job, line)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(&l.sb, "%s %s\n", time.Now().Format("2006-01-02 15:04:05"), line)
+}
+
+// String returns the collected log.
+func (l *JobLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sb.String()
+}
+
+// JobRunner runs registered background jobs on the cron schedules stored with their models.Task rows. A job runs
+// on at most one instance at a time: the runner takes a lease on the task row before each run. Every run is
+// recorded as a models.TaskExecution with its status and log.
+type JobRunner interface {
+	// Register adds a job. Its task is created with cronExpr the first time; after that the stored schedule,
+	// which administrators may have changed, is kept.
+	Register(name, cronExpr, description string, fn JobFunc) error
+	// Start polls for due jobs every jobs.poll_seconds in the background.
+	Start()
+	// Stop stops polling, cancels running jobs and waits for them to record their result and release their tasks.
+	// A scheduled job interrupted this way stays due, so that the next instance runs it again.
+	Stop()
+	// RunNow starts a job immediately, whether enabled or not, and returns its running execution.
+	RunNow(taskID uint) (*models.TaskExecution, error)
+	ListJobs() ([]models.Task, error)
+	// UpdateJob changes the schedule of a job or enables or disables it.
+	UpdateJob(taskID uint, input models.JobUpdateInput, now time.Time) (*models.Task, error)
+	GetExecutions(taskID uint, limit int) ([]models.TaskExecution, error)
+}
+
+type jobRunner struct {
+	repo     repository.SchedulerRepository
+	instance string // Identifies this process in task leases and executions
+
+	mu      sync.Mutex
+	jobs    map[string]JobFunc
+	running map[uint]bool // Tasks executing in this process
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+	ctx      context.Context // Parent of the jobs' contexts; cancelled by Stop
+	cancel   context.CancelFunc
+}
+
+// NewJobRunner creates a new instance of JobRunner.
+func NewJobRunner(repo repository.SchedulerRepository) JobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobRunner{
+		repo:     repo,
+		instance: runnerInstanceID(),
+		jobs:     make(map[string]JobFunc),
+		running:  make(map[uint]bool),
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// runnerInstanceID returns "host-pid-random", unique per process.
+func runnerInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+func (r *jobRunner) Register(name, cronExpr, description string, fn JobFunc) error {
+	if name == "" || fn == nil {
+		return errors.New("job name and function are required")
+	}
+	if _, err := utils.ParseCron(cronExpr); err != nil {
+		log.Printf("ERROR: [JobRunner] Cannot register job '%s': %v", name, err)
+		return err
+	}
+
+	task, err := r.repo.GetTaskByName(name)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to register job '%s'", name)
+		log.Printf("ERROR: [JobRunner] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	now := time.Now()
+	if task == nil {
+		task = &models.Task{Name: name, Description: description, CronExpr: cronExpr, Enabled: true, Status: models.JobStatusIdle, NextRunAt: nextJobRun(cronExpr, now)}
+		if err := r.repo.CreateTask(task); err != nil {
+			return fmt.Errorf("failed to register job '%s': %w", name, err)
+		}
+	} else if task.Description != description || task.NextRunAt == nil {
+		task.Description = description
+		if task.NextRunAt == nil {
+			task.NextRunAt = nextJobRun(task.CronExpr, now)
+		}
+		if err := r.repo.UpdateTask(task); err != nil {
+			return fmt.Errorf("failed to register job '%s': %w", name, err)
+		}
+	}
+
+	r.mu.Lock()
+	r.jobs[name] = fn
+	r.mu.Unlock()
+	log.Printf("INFO: [JobRunner] Registered job '%s' (task ID %d, schedule '%s', enabled %t).", name, task.ID, task.CronExpr, task.Enabled)
+	return nil
+}
+
+func (r *jobRunner) Start() {
+	interval := time.Duration(config.AppConfig.Jobs.PollSeconds) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	log.Printf("INFO: [JobRunner] Instance '%s' checking for due jobs every %s.", r.instance, interval)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		r.runDue(time.Now())
+		for {
+			select {
+			case <-r.stop:
+				return
+			case now := <-ticker.C:
+				r.runDue(now)
+			}
+		}
+	}()
+}
+
+func (r *jobRunner) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.cancel()
+	})
+	r.wg.Wait()
+	log.Printf("INFO: [JobRunner] Stopped.")
+}
+
+// runDue starts every enabled, registered job whose next run is due.
+func (r *jobRunner) runDue(now time.Time) {
+	tasks, err := r.repo.GetAllTasks()
+	if err != nil {
+		log.Printf("ERROR: [JobRunner] Failed to check for due jobs: %v", err)
+		return
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		fn := r.job(task.Name)
+		if fn == nil || !task.Enabled || task.NextRunAt == nil || task.NextRunAt.After(now) {
+			continue
+		}
+		if _, err := r.begin(task, fn, models.ExecutionTriggerSchedule, now); err != nil {
+			log.Printf("INFO: [JobRunner] Skipped due job '%s': %v", task.Name, err)
+		}
+	}
+}
+
+func (r *jobRunner) job(name string) JobFunc {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[name]
+}
+
+// begin takes the task's lease, records a running execution and runs the job in the background.
+func (r *jobRunner) begin(task *models.Task, fn JobFunc, trigger string, now time.Time) (*models.TaskExecution, error) {
+	r.mu.Lock()
+	if r.running[task.ID] {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cannot run job '%s': it is already running", task.Name)
+	}
+	r.running[task.ID] = true
+	r.mu.Unlock()
+	done := func() {
+		r.mu.Lock()
+		delete(r.running, task.ID)
+		r.mu.Unlock()
+	}
+
+	// Scheduled runs only start while the task is still due: another instance may have run it since it was listed.
+	// Manual runs start whenever no other instance holds the lease.
+	scheduled := trigger == models.ExecutionTriggerSchedule
+	acquired, err := r.repo.AcquireTask(task.ID, r.instance, now, now.Add(jobLease()), scheduled)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	if !acquired {
+		done()
+		if scheduled {
+			return nil, fmt.Errorf("cannot run job '%s': it is running or has already run on another instance", task.Name)
+		}
+		return nil, fmt.Errorf("cannot run job '%s': it is already running on another instance", task.Name)
+	}
+
+	execution := &models.TaskExecution{TaskID: task.ID, Trigger: trigger, Runner: r.instance, StartTime: now, Status: models.ExecutionStatusRunning}
+	if err := r.repo.CreateExecution(execution); err != nil {
+		if releaseErr := r.repo.ReleaseTask(task.ID, r.instance, nil, task.NextRunAt); releaseErr != nil {
+			log.Printf("ERROR: [JobRunner] Failed to release job '%s': %v", task.Name, releaseErr)
+		}
+		done()
+		return nil, fmt.Errorf("failed to record execution of job '%s': %w", task.Name, err)
+	}
+	log.Printf("INFO: [JobRunner] Started job '%s' (execution ID %d, %s).", task.Name, execution.ID, trigger)
+
+	started := *execution
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer done()
+		r.finish(*task, fn, execution)
+	}()
+	return &started, nil
+}
+
+// finish runs the job, then records its result and releases the task with its next run time.
+func (r *jobRunner) finish(task models.Task, fn JobFunc, execution *models.TaskExecution) {
+	out := &JobLog{job: task.Name}
+	ctx, cancel := context.WithTimeout(r.ctx, jobLease())
+	defer cancel()
+
+	err := runJob(ctx, fn, out)
+	end := time.Now()
+	execution.EndTime = &end
+	execution.Status = models.ExecutionStatusSuccess
+	if err != nil {
+		execution.Status = models.ExecutionStatusFailed
+		out.Printf("ERROR: %v", err)
+	}
+	execution.Log = out.String()
+	if err := r.repo.UpdateExecution(execution); err != nil {
+		log.Printf("ERROR: [JobRunner] Failed to record result of job '%s': %v", task.Name, err)
+	}
+
+	// The schedule may have been changed while the job was running
+	if current, err := r.repo.GetTaskByID(task.ID); err == nil && current != nil {
+		task = *current
+	}
+	next := nextJobRun(task.CronExpr, end)
+	if err != nil && r.ctx.Err() != nil && execution.Trigger == models.ExecutionTriggerSchedule {
+		// Interrupted by Stop: the job stays due and runs again on the next instance.
+		next = task.NextRunAt
+	}
+	if err := r.repo.ReleaseTask(task.ID, r.instance, &execution.StartTime, next); err != nil {
+		log.Printf("ERROR: [JobRunner] Failed to release job '%s': %v", task.Name, err)
+	}
+	log.Printf("INFO: [JobRunner] Job '%s' finished with status %s in %s.", task.Name, execution.Status, end.Sub(execution.StartTime).Round(time.Millisecond))
+}
+
+// runJob runs fn, turning a panic into an error so that one broken job cannot stop the runner.
+func runJob(ctx context.Context, fn JobFunc, out *JobLog) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return fn(ctx, out)
+}
+
+func (r *jobRunner) RunNow(taskID uint) (*models.TaskExecution, error) {
+	task, err := r.repo.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, fmt.Errorf("job %d not found", taskID)
+	}
+	fn := r.job(task.Name)
+	if fn == nil {
+		return nil, fmt.Errorf("cannot run job '%s': it is not registered in this instance", task.Name)
+	}
+	return r.begin(task, fn, models.ExecutionTriggerManual, time.Now())
+}
+
+func (r *jobRunner) ListJobs() ([]models.Task, error) {
+	return r.repo.GetAllTasks()
+}
+
+func (r *jobRunner) UpdateJob(taskID uint, input models.JobUpdateInput, now time.Time) (*models.Task, error) {
+	task, err := r.repo.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, fmt.Errorf("job %d not found", taskID)
+	}
+	if input.CronExpr != nil {
+		cronExpr := strings.TrimSpace(*input.CronExpr)
+		if _, err := utils.ParseCron(cronExpr); err != nil {
+			return nil, err
+		}
+		task.CronExpr = cronExpr
+	}
+	if input.Enabled != nil {
+		task.Enabled = *input.Enabled
+	}
+	task.NextRunAt = nextJobRun(task.CronExpr, now)
+
+	if err := r.repo.UpdateTask(task); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: [JobRunner] Job '%s' updated: schedule '%s', enabled %t.", task.Name, task.CronExpr, task.Enabled)
+	return task, nil
+}
+
+func (r *jobRunner) GetExecutions(taskID uint, limit int) ([]models.TaskExecution, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return r.repo.GetExecutions(taskID, limit)
+}
+
+// nextJobRun returns the next time after t matching a cron expression in the default timezone, or nil if there is
+// none or the expression is invalid.
+func nextJobRun(cronExpr string, t time.Time) *time.Time {
+	schedule, err := utils.ParseCron(cronExpr)
+	if err != nil {
+		log.Printf("ERROR: [JobRunner] %v", err)
+		return nil
+	}
+	next := schedule.Next(t.In(LoadUserLocation("")))
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+func jobLease() time.Duration {
+	if minutes := config.AppConfig.Jobs.LeaseMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return time.Hour
+}
+
+// ExecutionCleanupJob returns the background job that deletes execution history older than retentionDays.
+func ExecutionCleanupJob(repo repository.SchedulerRepository, retentionDays int) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		if retentionDays <= 0 {
+			out.Printf("Execution history is kept forever; nothing to do.")
+			return nil
+		}
+		deleted, err := repo.DeleteExecutionsBefore(time.Now().AddDate(0, 0, -retentionDays))
+		if err != nil {
+			return err
+		}
+		out.Printf("Deleted %d executions older than %d days.", deleted, retentionDays)
+		return nil
+	}
+}
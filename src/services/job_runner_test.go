@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/models"
+	"project/repository"
+	"project/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// MockSchedulerRepository is a mock type for the SchedulerRepository type
+type MockSchedulerRepository struct {
+	mock.Mock
+}
+
+func (m *MockSchedulerRepository) CreateTask(task *models.Task) error {
+	args := m.Called(task)
+	return args.Error(0)
+}
+
+func (m *MockSchedulerRepository) GetAllTasks() ([]models.Task, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Task), args.Error(1)
+}
+
+func (m *MockSchedulerRepository) GetTaskByID(id uint) (*models.Task, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Task), args.Error(1)
+}
+
+func (m *MockSchedulerRepository) GetTaskByName(name string) (*models.Task, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Task), args.Error(1)
+}
+
+func (m *MockSchedulerRepository) UpdateTask(task *models.Task) error {
+	args := m.Called(task)
+	return args.Error(0)
+}
+
+func (m *MockSchedulerRepository) DeleteTask(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSchedulerRepository) AcquireTask(id uint, owner string, now, until time.Time, dueOnly bool) (bool, error) {
+	args := m.Called(id, owner, now, until, dueOnly)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSchedulerRepository) ReleaseTask(id uint, owner string, lastRunAt, nextRunAt *time.Time) error {
+	args := m.Called(id, owner, lastRunAt, nextRunAt)
+	return args.Error(0)
+}
+
+func (m *MockSchedulerRepository) CreateExecution(execution *models.TaskExecution) error {
+	args := m.Called(execution)
+	return args.Error(0)
+}
+
+func (m *MockSchedulerRepository) UpdateExecution(execution *models.TaskExecution) error {
+	args := m.Called(execution)
+	return args.Error(0)
+}
+
+func (m *MockSchedulerRepository) GetExecutions(taskID uint, limit int) ([]models.TaskExecution, error) {
+	args := m.Called(taskID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.TaskExecution), args.Error(1)
+}
+
+func (m *MockSchedulerRepository) DeleteExecutionsBefore(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestParseCron(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	from := time.Date(2024, 3, 15, 10, 17, 30, 0, loc) // A Friday
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"Daily", "0 4 * * *", time.Date(2024, 3, 16, 4, 0, 0, 0, loc)},
+		{"Later the same hour", "*/15 * * * *", time.Date(2024, 3, 15, 10, 30, 0, 0, loc)},
+		{"Weekday names", "30 9 * * mon,wed", time.Date(2024, 3, 18, 9, 30, 0, 0, loc)},
+		{"Sunday as 7", "0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, loc)},
+		{"Hour range with step", "0 8-20/6 * * *", time.Date(2024, 3, 15, 14, 0, 0, 0, loc)},
+		{"Either day field", "0 12 1 * sat", time.Date(2024, 3, 16, 12, 0, 0, 0, loc)},
+		{"Day of month and month", "0 0 1 jun *", time.Date(2024, 6, 1, 0, 0, 0, 0, loc)},
+		{"Descriptor", "@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, loc)},
+		{"Every", "@every 90m", from.Add(90 * time.Minute)},
+		{"Never", "0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := utils.ParseCron(tt.expr)
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(schedule.Next(from)), "got %s", schedule.Next(from))
+		})
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every 10s"} {
+		_, err := utils.ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+	valid, err := utils.ParseCronExpression("0 4 * * *")
+	assert.True(t, valid)
+	assert.NoError(t, err)
+}
+
+func TestJobRunner_Register(t *testing.T) {
+	t.Run("New job", func(t *testing.T) {
+		mockRepo := new(MockSchedulerRepository)
+		runner := NewJobRunner(mockRepo)
+		mockRepo.On("GetTaskByName", "cleanup").Return(nil, nil).Once()
+		mockRepo.On("CreateTask", mock.MatchedBy(func(task *models.Task) bool {
+			return task.CronExpr == "0 4 * * *" && task.Enabled && task.NextRunAt != nil && task.NextRunAt.Hour() == 4
+		})).Return(nil).Once()
+
+		assert.NoError(t, runner.Register("cleanup", "0 4 * * *", "清理", func(context.Context, *JobLog) error { return nil }))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Keeps the stored schedule", func(t *testing.T) {
+		mockRepo := new(MockSchedulerRepository)
+		runner := NewJobRunner(mockRepo)
+		next := time.Now().Add(time.Hour)
+		mockRepo.On("GetTaskByName", "cleanup").Return(&models.Task{ID: 1, Name: "cleanup", Description: "清理", CronExpr: "0 6 * * *", NextRunAt: &next}, nil).Once()
+
+		assert.NoError(t, runner.Register("cleanup", "0 4 * * *", "清理", func(context.Context, *JobLog) error { return nil }))
+		mockRepo.AssertNotCalled(t, "CreateTask", mock.Anything)
+		mockRepo.AssertNotCalled(t, "UpdateTask", mock.Anything)
+	})
+
+	t.Run("Invalid cron", func(t *testing.T) {
+		runner := NewJobRunner(new(MockSchedulerRepository))
+		err := runner.Register("cleanup", "every day", "清理", func(context.Context, *JobLog) error { return nil })
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid cron expression")
+	})
+}
+
+func TestJobRunner_RunDue(t *testing.T) {
+	now := time.Date(2024, 3, 15, 4, 0, 10, 0, time.UTC)
+	due := now.Add(-10 * time.Second)
+	later := now.Add(time.Hour)
+
+	mockRepo := new(MockSchedulerRepository)
+	runner := NewJobRunner(mockRepo).(*jobRunner)
+	var mu sync.Mutex
+	ran := map[string]bool{}
+	mark := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran[name] = true
+	}
+	runner.jobs["progression"] = func(ctx context.Context, out *JobLog) error {
+		mark("progression")
+		out.Printf("3 level changes.")
+		return nil
+	}
+	runner.jobs["broken"] = func(ctx context.Context, out *JobLog) error {
+		mark("broken")
+		panic("nil map")
+	}
+	runner.jobs["later"] = func(ctx context.Context, out *JobLog) error {
+		mark("later")
+		return nil
+	}
+	runner.jobs["disabled"] = func(ctx context.Context, out *JobLog) error {
+		mark("disabled")
+		return nil
+	}
+	runner.jobs["elsewhere"] = func(ctx context.Context, out *JobLog) error {
+		mark("elsewhere")
+		return nil
+	}
+	tasks := []models.Task{
+		{ID: 1, Name: "progression", CronExpr: "0 4 * * *", Enabled: true, NextRunAt: &due},
+		{ID: 2, Name: "broken", CronExpr: "0 4 * * *", Enabled: true, NextRunAt: &due},
+		{ID: 3, Name: "later", CronExpr: "0 5 * * *", Enabled: true, NextRunAt: &later},
+		{ID: 4, Name: "disabled", CronExpr: "0 4 * * *", Enabled: false, NextRunAt: &due},
+		{ID: 5, Name: "elsewhere", CronExpr: "0 4 * * *", Enabled: true, NextRunAt: &due},
+		{ID: 6, Name: "unregistered", CronExpr: "0 4 * * *", Enabled: true, NextRunAt: &due},
+	}
+	mockRepo.On("GetAllTasks").Return(tasks, nil).Once()
+	mockRepo.On("AcquireTask", uint(1), runner.instance, now, mock.Anything, true).Return(true, nil).Once()
+	mockRepo.On("AcquireTask", uint(2), runner.instance, now, mock.Anything, true).Return(true, nil).Once()
+	mockRepo.On("AcquireTask", uint(5), runner.instance, now, mock.Anything, true).Return(false, nil).Once()
+	mockRepo.On("CreateExecution", mock.AnythingOfType("*models.TaskExecution")).Return(nil).Twice()
+	var results []models.TaskExecution
+	mockRepo.On("UpdateExecution", mock.AnythingOfType("*models.TaskExecution")).Run(func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, *args.Get(0).(*models.TaskExecution))
+	}).Return(nil).Twice()
+	mockRepo.On("GetTaskByID", uint(1)).Return(&tasks[0], nil).Once()
+	mockRepo.On("GetTaskByID", uint(2)).Return(&tasks[1], nil).Once()
+	mockRepo.On("ReleaseTask", mock.Anything, runner.instance, mock.Anything, mock.MatchedBy(func(next *time.Time) bool {
+		return next != nil && next.After(now)
+	})).Return(nil).Twice()
+
+	runner.runDue(now)
+	runner.wg.Wait()
+
+	assert.Equal(t, map[string]bool{"progression": true, "broken": true}, ran, "only due, enabled jobs this instance could lease run")
+	assert.Len(t, results, 2)
+	for _, execution := range results {
+		assert.NotNil(t, execution.EndTime)
+		assert.Equal(t, models.ExecutionTriggerSchedule, execution.Trigger)
+		switch execution.TaskID {
+		case 1:
+			assert.Equal(t, models.ExecutionStatusSuccess, execution.Status)
+			assert.Contains(t, execution.Log, "3 level changes.")
+		case 2:
+			assert.Equal(t, models.ExecutionStatusFailed, execution.Status)
+			assert.Contains(t, execution.Log, "job panicked: nil map")
+		}
+	}
+	assert.Empty(t, runner.running)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestJobRunner_TwoInstancesShareDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:job_runner_two_instances?mode=memory&cache=shared"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Task{}, &models.TaskExecution{}))
+	repo := repository.NewSchedulerRepository(db)
+
+	var mu sync.Mutex
+	runs := 0
+	report := func(ctx context.Context, out *JobLog) error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return nil
+	}
+	a := NewJobRunner(repo).(*jobRunner)
+	b := NewJobRunner(repo).(*jobRunner)
+	assert.NoError(t, a.Register("report", "0 4 * * *", "报表", report))
+	assert.NoError(t, b.Register("report", "0 4 * * *", "报表", report))
+	now := time.Now()
+	assert.NoError(t, db.Model(&models.Task{}).Where("name = ?", "report").Update("next_run_at", now.Add(-time.Minute)).Error)
+
+	// B lists the due task, then A runs and releases it before B tries to take the lease.
+	stale, err := repo.GetAllTasks()
+	assert.NoError(t, err)
+	assert.Len(t, stale, 1)
+	a.runDue(now)
+	a.wg.Wait()
+	_, err = b.begin(&stale[0], b.job("report"), models.ExecutionTriggerSchedule, now)
+	assert.EqualError(t, err, "cannot run job 'report': it is running or has already run on another instance")
+	b.runDue(now)
+	b.wg.Wait()
+	assert.Equal(t, 1, runs, "a due job runs on one instance only")
+
+	// Running a job by hand does not depend on its schedule.
+	_, err = b.RunNow(stale[0].ID)
+	assert.NoError(t, err)
+	b.wg.Wait()
+	assert.Equal(t, 2, runs)
+	executions, err := repo.GetExecutions(stale[0].ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, executions, 2)
+}
+
+func TestJobRunner_StopReleasesInterruptedJob(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:job_runner_stop?mode=memory&cache=shared"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.Task{}, &models.TaskExecution{}))
+	repo := repository.NewSchedulerRepository(db)
+
+	started := make(chan struct{})
+	a := NewJobRunner(repo).(*jobRunner)
+	assert.NoError(t, a.Register("progression", "0 4 * * *", "进阶评估", func(ctx context.Context, out *JobLog) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	b := NewJobRunner(repo).(*jobRunner)
+	resumed := false
+	assert.NoError(t, b.Register("progression", "0 4 * * *", "进阶评估", func(ctx context.Context, out *JobLog) error {
+		resumed = true
+		return nil
+	}))
+	now := time.Now()
+	due := now.Add(-time.Minute)
+	assert.NoError(t, db.Model(&models.Task{}).Where("name = ?", "progression").Update("next_run_at", due).Error)
+
+	a.runDue(now)
+	<-started
+	a.Stop()
+
+	task, err := repo.GetTaskByName("progression")
+	assert.NoError(t, err)
+	assert.Equal(t, models.JobStatusIdle, task.Status)
+	assert.Empty(t, task.LockedBy)
+	assert.True(t, due.Equal(*task.NextRunAt), "an interrupted job stays due")
+	b.runDue(time.Now())
+	b.wg.Wait()
+	assert.True(t, resumed, "another instance runs the interrupted job without waiting for the lease to expire")
+	executions, err := repo.GetExecutions(task.ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, executions, 2) {
+		assert.Equal(t, models.ExecutionStatusSuccess, executions[0].Status)
+		assert.Equal(t, models.ExecutionStatusFailed, executions[1].Status)
+		assert.Contains(t, executions[1].Log, "context canceled")
+	}
+}
+
+func TestJobRunner_RunNow(t *testing.T) {
+	t.Run("Already running in this instance", func(t *testing.T) {
+		mockRepo := new(MockSchedulerRepository)
+		runner := NewJobRunner(mockRepo).(*jobRunner)
+		release := make(chan struct{})
+		runner.jobs["report"] = func(ctx context.Context, out *JobLog) error {
+			<-release
+			return errors.New("no data")
+		}
+		task := &models.Task{ID: 7, Name: "report", CronExpr: "@daily"}
+		mockRepo.On("GetTaskByID", uint(7)).Return(task, nil)
+		mockRepo.On("AcquireTask", uint(7), runner.instance, mock.Anything, mock.Anything, false).Return(true, nil).Once()
+		mockRepo.On("CreateExecution", mock.AnythingOfType("*models.TaskExecution")).Return(nil).Once()
+		var result models.TaskExecution
+		mockRepo.On("UpdateExecution", mock.AnythingOfType("*models.TaskExecution")).Run(func(args mock.Arguments) {
+			result = *args.Get(0).(*models.TaskExecution)
+		}).Return(nil).Once()
+		mockRepo.On("ReleaseTask", uint(7), runner.instance, mock.Anything, mock.Anything).Return(nil).Once()
+
+		execution, err := runner.RunNow(7)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ExecutionStatusRunning, execution.Status)
+		assert.Equal(t, models.ExecutionTriggerManual, execution.Trigger)
+
+		_, err = runner.RunNow(7)
+		assert.EqualError(t, err, "cannot run job 'report': it is already running")
+
+		close(release)
+		runner.wg.Wait()
+		assert.Equal(t, models.ExecutionStatusFailed, result.Status)
+		assert.Contains(t, result.Log, "ERROR: no data")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Not registered", func(t *testing.T) {
+		mockRepo := new(MockSchedulerRepository)
+		runner := NewJobRunner(mockRepo)
+		mockRepo.On("GetTaskByID", uint(8)).Return(&models.Task{ID: 8, Name: "retired"}, nil).Once()
+
+		_, err := runner.RunNow(8)
+		assert.EqualError(t, err, "cannot run job 'retired': it is not registered in this instance")
+		mockRepo.AssertNotCalled(t, "AcquireTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestJobRunner_UpdateJob(t *testing.T) {
+	mockRepo := new(MockSchedulerRepository)
+	runner := NewJobRunner(mockRepo)
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	mockRepo.On("GetTaskByID", uint(1)).Return(&models.Task{ID: 1, Name: "cleanup", CronExpr: "0 4 * * *", Enabled: true}, nil)
+	mockRepo.On("UpdateTask", mock.AnythingOfType("*models.Task")).Return(nil).Once()
+
+	cronExpr, enabled := "0 * * * *", false
+	task, err := runner.UpdateJob(1, models.JobUpdateInput{CronExpr: &cronExpr, Enabled: &enabled}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "0 * * * *", task.CronExpr)
+	assert.False(t, task.Enabled)
+	assert.True(t, now.Add(time.Hour).Equal(*task.NextRunAt))
+
+	invalid := "sometimes"
+	_, err = runner.UpdateJob(1, models.JobUpdateInput{CronExpr: &invalid}, now)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid cron expression")
+	mockRepo.AssertExpectations(t)
+}
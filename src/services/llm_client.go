@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// LLMClient performs non-streaming chat completions, e.g. for structured output that is parsed rather than shown.
+type LLMClient interface {
+	// Complete sends the messages to the given model and returns the reply. With jsonMode the model is asked
+	// to answer with a single JSON object. The call is abandoned after llm_timeout_seconds.
+	Complete(model string, messages []openai.ChatCompletionMessage, jsonMode bool) (string, error)
+}
+
+type llmClient struct{}
+
+// NewLLMClient creates an LLMClient backed by the providers in config.AppConfig.
+func NewLLMClient() LLMClient {
+	return &llmClient{}
+}
+
+func (c *llmClient) Complete(model string, messages []openai.ChatCompletionMessage, jsonMode bool) (string, error) {
+	client, err := newOpenAIClient(model)
+	if err != nil {
+		return "", err
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+	}
+	if jsonMode {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+
+	timeout := time.Duration(config.AppConfig.LLMTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to create chat completion for model %s", model)
+		log.Printf("ERROR: [LLMClient] %s: %v", errMsg, err)
+		return "", fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if len(resp.Choices) == 0 {
+		log.Printf("ERROR: [LLMClient] Chat completion for model %s returned no choices.", model)
+		return "", fmt.Errorf("chat completion for model %s returned no choices", model)
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// newOpenAIClient builds an OpenAI-compatible client for a model from the LLMModels and LLMProviders configuration.
+func newOpenAIClient(model string) (*openai.Client, error) {
+	providerKey, modelExists := config.AppConfig.LLMModels[model]
+	if !modelExists {
+		errMsg := fmt.Sprintf("model '%s' not found in LLMModels configuration", model)
+		log.Printf("ERROR: [LLMClient] %s", errMsg)
+		return nil, errors.New(errMsg)
+	}
+	providerConfig, providerExists := config.AppConfig.LLMProviders[providerKey]
+	if !providerExists {
+		errMsg := fmt.Sprintf("provider key '%s' for model '%s' not found in LLMProviders configuration", providerKey, model)
+		log.Printf("ERROR: [LLMClient] %s", errMsg)
+		return nil, errors.New(errMsg)
+	}
+	if providerConfig.APIKey == "" || providerConfig.BaseURL == "" {
+		errMsg := fmt.Sprintf("API key or BaseURL is empty for provider '%s' (model '%s')", providerKey, model)
+		log.Printf("ERROR: [LLMClient] %s", errMsg)
+		return nil, errors.New(errMsg)
+	}
+
+	clientConfig := openai.DefaultConfig(providerConfig.APIKey)
+	clientConfig.BaseURL = providerConfig.BaseURL
+	return openai.NewClientWithConfig(clientConfig), nil
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+	"time"
+)
+
+// NotificationService delivers the messages the application sends on its own initiative, such as reminders and
+// reports, according to each user's models.NotificationPreferences. Every producer sends through it.
+type NotificationService interface {
+	// Send delivers a notification to the user's chat from its agent, unless the user's preferences hold it back:
+	// it is dropped if its topic is turned off or the user's daily maximum is reached, and deferred to the end of
+	// quiet hours during them. The notification is saved with its resulting Status.
+	Send(notification *models.Notification, now time.Time) error
+	// Sent reports whether a notification with the dedupe key exists, whatever its status.
+	Sent(dedupeKey string) (bool, error)
+	// DeliverDeferred sends the deferred notifications that are due and returns the number delivered.
+	DeliverDeferred(ctx context.Context, now time.Time) (int, error)
+	// GetPreferences returns a user's preferences, or the configured defaults if they have not saved any.
+	GetPreferences(userID string) (*models.NotificationPreferences, error)
+	UpdatePreferences(userID string, input models.NotificationPreferencesInput) (*models.NotificationPreferences, error)
+	// ListInbox returns up to limit of the notifications delivered to a user, newest first, starting before the
+	// notification with ID beforeID if it is not 0.
+	ListInbox(userID string, unreadOnly bool, beforeID uint, limit int) (*models.NotificationInbox, error)
+	UnreadCount(userID string) (int64, error)
+	// MarkRead marks one of the user's notifications as read. Marking a read notification again keeps its ReadAt.
+	MarkRead(userID string, notificationID uint, now time.Time) (*models.Notification, error)
+	// MarkAllRead marks all of a user's notifications as read and returns the number that were unread.
+	MarkAllRead(userID string, now time.Time) (int64, error)
+}
+
+// maxInboxPage caps the notifications returned by one ListInbox call.
+const maxInboxPage = 100
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+	chatRepo         repository.ChatRepository // Optional; delivered notifications are posted to the user's chat
+	events           EventBus                  // Optional; delivered reminders are published as reminder.due events
+}
+
+// NewNotificationService creates a new instance of NotificationService.
+func NewNotificationService(notificationRepo repository.NotificationRepository, chatRepo repository.ChatRepository, events EventBus) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		chatRepo:         chatRepo,
+		events:           events,
+	}
+}
+
+// defaultNotificationPreferences returns the preferences of a user who has not saved any.
+func defaultNotificationPreferences(userID string) *models.NotificationPreferences {
+	cfg := config.AppConfig.Notifications
+	return &models.NotificationPreferences{
+		UserID:                userID,
+		QuietHoursStart:       cfg.QuietHoursStart,
+		QuietHoursEnd:         cfg.QuietHoursEnd,
+		Exercise:              true,
+		Habit:                 true,
+		Knowledge:             true,
+		Report:                true,
+		ProductRecommendation: cfg.ProductRecommendations,
+		MaxPerDay:             cfg.MaxPerDay,
+	}
+}
+
+func (s *notificationService) GetPreferences(userID string) (*models.NotificationPreferences, error) {
+	if s.notificationRepo == nil {
+		return nil, errors.New("notificationrepo not initialized")
+	}
+	prefs, err := s.notificationRepo.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		return defaultNotificationPreferences(userID), nil
+	}
+	return prefs, nil
+}
+
+func (s *notificationService) UpdatePreferences(userID string, input models.NotificationPreferencesInput) (*models.NotificationPreferences, error) {
+	if userID == "" {
+		return nil, errors.New("userID cannot be empty")
+	}
+	prefs, err := s.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyNotificationPreferences(prefs, input); err != nil {
+		return nil, err
+	}
+	if err := s.notificationRepo.SavePreferences(prefs); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: [NotificationService] Updated notification preferences of userID '%s'.", userID)
+	return prefs, nil
+}
+
+func (s *notificationService) ListInbox(userID string, unreadOnly bool, beforeID uint, limit int) (*models.NotificationInbox, error) {
+	if s.notificationRepo == nil {
+		return nil, errors.New("notificationrepo not initialized")
+	}
+	if userID == "" {
+		return nil, errors.New("userID cannot be empty")
+	}
+	if limit < 1 || limit > maxInboxPage {
+		return nil, fmt.Errorf("invalid limit %d: must be between 1 and %d", limit, maxInboxPage)
+	}
+	notifications, err := s.notificationRepo.GetDelivered(userID, unreadOnly, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	inbox := &models.NotificationInbox{Notifications: notifications, UnreadCount: unread}
+	if inbox.Notifications == nil {
+		inbox.Notifications = []models.Notification{}
+	}
+	if len(notifications) == limit {
+		inbox.NextBefore = notifications[len(notifications)-1].ID
+	}
+	return inbox, nil
+}
+
+func (s *notificationService) UnreadCount(userID string) (int64, error) {
+	if s.notificationRepo == nil {
+		return 0, errors.New("notificationrepo not initialized")
+	}
+	return s.notificationRepo.CountUnread(userID)
+}
+
+func (s *notificationService) MarkRead(userID string, notificationID uint, now time.Time) (*models.Notification, error) {
+	if s.notificationRepo == nil {
+		return nil, errors.New("notificationrepo not initialized")
+	}
+	notification, err := s.notificationRepo.GetNotificationByID(notificationID)
+	if err != nil {
+		return nil, err
+	}
+	// Deferred and dropped notifications never reached the inbox
+	if notification == nil || notification.Status != models.NotificationStatusDelivered {
+		return nil, fmt.Errorf("notification %d not found", notificationID)
+	}
+	if notification.UserID != userID {
+		log.Printf("WARN: [NotificationService] Unauthorized attempt by userID '%s' to read notification ID %d (belongs to userID '%s').", userID, notificationID, notification.UserID)
+		return nil, fmt.Errorf("unauthorized to read notification %d", notificationID)
+	}
+	if notification.ReadAt != nil {
+		return notification, nil
+	}
+	read := now
+	notification.ReadAt = &read
+	if err := s.notificationRepo.UpdateNotification(notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+func (s *notificationService) MarkAllRead(userID string, now time.Time) (int64, error) {
+	if s.notificationRepo == nil {
+		return 0, errors.New("notificationrepo not initialized")
+	}
+	if userID == "" {
+		return 0, errors.New("userID cannot be empty")
+	}
+	marked, err := s.notificationRepo.MarkAllRead(userID, now)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("INFO: [NotificationService] Marked %d notifications of userID '%s' as read.", marked, userID)
+	return marked, nil
+}
+
+// applyNotificationPreferences validates the input and copies its non-nil fields to prefs.
+func applyNotificationPreferences(prefs *models.NotificationPreferences, input models.NotificationPreferencesInput) error {
+	if input.Timezone != nil {
+		timezone := strings.TrimSpace(*input.Timezone)
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				return fmt.Errorf("invalid timezone '%s'", timezone)
+			}
+		}
+		prefs.Timezone = timezone
+	}
+	if input.QuietHoursStart != nil {
+		prefs.QuietHoursStart = strings.TrimSpace(*input.QuietHoursStart)
+	}
+	if input.QuietHoursEnd != nil {
+		prefs.QuietHoursEnd = strings.TrimSpace(*input.QuietHoursEnd)
+	}
+	if (prefs.QuietHoursStart == "") != (prefs.QuietHoursEnd == "") {
+		return errors.New("invalid quiet hours: both start and end are required")
+	}
+	for _, clock := range []string{prefs.QuietHoursStart, prefs.QuietHoursEnd} {
+		if _, ok := clockMinutes(clock); clock != "" && !ok {
+			return fmt.Errorf("invalid quiet hours: '%s' is not a HH:MM time", clock)
+		}
+	}
+	if input.MaxPerDay != nil {
+		if *input.MaxPerDay < 0 {
+			return errors.New("invalid max_reminders_per_day: must not be negative")
+		}
+		prefs.MaxPerDay = *input.MaxPerDay
+	}
+	for _, flag := range []struct {
+		input *bool
+		pref  *bool
+	}{
+		{input.Exercise, &prefs.Exercise},
+		{input.Habit, &prefs.Habit},
+		{input.Knowledge, &prefs.Knowledge},
+		{input.Report, &prefs.Report},
+		{input.ProductRecommendation, &prefs.ProductRecommendation},
+	} {
+		if flag.input != nil {
+			*flag.pref = *flag.input
+		}
+	}
+	return nil
+}
+
+// clockMinutes parses "HH:MM" into minutes after midnight.
+func clockMinutes(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// quietHoursEnd returns the end of the quiet hours now falls in, or nil if it is outside them.
+func quietHoursEnd(prefs *models.NotificationPreferences, now time.Time) *time.Time {
+	start, okStart := clockMinutes(prefs.QuietHoursStart)
+	end, okEnd := clockMinutes(prefs.QuietHoursEnd)
+	if !okStart || !okEnd || start == end {
+		return nil
+	}
+	local := now.In(LoadUserLocation(prefs.Timezone))
+	minute := local.Hour()*60 + local.Minute()
+	quiet := minute >= start && minute < end
+	if start > end { // Overnight
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return nil
+	}
+	y, m, d := local.Date()
+	until := time.Date(y, m, d, end/60, end%60, 0, 0, local.Location())
+	if !until.After(local) {
+		until = time.Date(y, m, d+1, end/60, end%60, 0, 0, local.Location())
+	}
+	return &until
+}
+
+// decideNotification sets the status of a notification due now from the user's preferences, given how many
+// notifications they were delivered today.
+func decideNotification(notification *models.Notification, prefs *models.NotificationPreferences, deliveredToday int64, now time.Time) {
+	notification.DeliverAt = nil
+	switch {
+	case notification.ExpiresAt != nil && !now.Before(*notification.ExpiresAt):
+		notification.Status = models.NotificationStatusDropped
+	case !prefs.TopicEnabled(notification.Topic):
+		notification.Status = models.NotificationStatusDropped
+	case prefs.MaxPerDay > 0 && deliveredToday >= int64(prefs.MaxPerDay):
+		notification.Status = models.NotificationStatusDropped
+	default:
+		until := quietHoursEnd(prefs, now)
+		switch {
+		case until == nil:
+			notification.Status = models.NotificationStatusDelivered
+			delivered := now
+			notification.DeliveredAt = &delivered
+		case notification.ExpiresAt != nil && !until.Before(*notification.ExpiresAt):
+			notification.Status = models.NotificationStatusDropped // Would be out of date by the end of quiet hours
+		default:
+			notification.Status = models.NotificationStatusDeferred
+			notification.DeliverAt = until
+		}
+	}
+}
+
+// deliveredToday counts the notifications delivered to the user on the calendar day of now in their timezone.
+func (s *notificationService) deliveredToday(prefs *models.NotificationPreferences, now time.Time) (int64, error) {
+	if prefs.MaxPerDay <= 0 {
+		return 0, nil // Not needed
+	}
+	local := now.In(LoadUserLocation(prefs.Timezone))
+	y, m, d := local.Date()
+	dayStart := time.Date(y, m, d, 0, 0, 0, 0, local.Location())
+	return s.notificationRepo.CountDelivered(prefs.UserID, dayStart, dayStart.AddDate(0, 0, 1))
+}
+
+func (s *notificationService) Send(notification *models.Notification, now time.Time) error {
+	if s.notificationRepo == nil {
+		return errors.New("notificationrepo not initialized")
+	}
+	prefs, err := s.GetPreferences(notification.UserID)
+	if err != nil {
+		return err
+	}
+	delivered, err := s.deliveredToday(prefs, now)
+	if err != nil {
+		return err
+	}
+	decideNotification(notification, prefs, delivered, now)
+	// Recorded first, so that a failing chat cannot lead to the notification being sent again
+	if err := s.notificationRepo.CreateNotification(notification); err != nil {
+		return err
+	}
+	s.logDecision(notification)
+	if notification.Status == models.NotificationStatusDelivered {
+		s.post(notification, now)
+	}
+	return nil
+}
+
+func (s *notificationService) Sent(dedupeKey string) (bool, error) {
+	if s.notificationRepo == nil {
+		return false, errors.New("notificationrepo not initialized")
+	}
+	return s.notificationRepo.NotificationExists(dedupeKey)
+}
+
+func (s *notificationService) DeliverDeferred(ctx context.Context, now time.Time) (int, error) {
+	if s.notificationRepo == nil {
+		return 0, errors.New("notificationrepo not initialized")
+	}
+	notifications, err := s.notificationRepo.GetDueDeferred(now)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for i := range notifications {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		notification := &notifications[i]
+		// The preferences may have changed since it was deferred
+		prefs, err := s.GetPreferences(notification.UserID)
+		if err != nil {
+			log.Printf("ERROR: [NotificationService] Failed to deliver deferred notification ID %d: %v", notification.ID, err)
+			continue
+		}
+		count, err := s.deliveredToday(prefs, now)
+		if err != nil {
+			log.Printf("ERROR: [NotificationService] Failed to deliver deferred notification ID %d: %v", notification.ID, err)
+			continue
+		}
+		decideNotification(notification, prefs, count, now)
+		if err := s.notificationRepo.UpdateNotification(notification); err != nil {
+			log.Printf("ERROR: [NotificationService] Failed to deliver deferred notification ID %d: %v", notification.ID, err)
+			continue
+		}
+		s.logDecision(notification)
+		if notification.Status == models.NotificationStatusDelivered {
+			s.post(notification, now)
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+func (s *notificationService) logDecision(notification *models.Notification) {
+	switch notification.Status {
+	case models.NotificationStatusDeferred:
+		log.Printf("INFO: [NotificationService] Deferred %s notification ID %d for userID '%s' to %s (quiet hours).", notification.Category, notification.ID, notification.UserID, notification.DeliverAt.Format(time.RFC3339))
+	case models.NotificationStatusDropped:
+		log.Printf("INFO: [NotificationService] Dropped %s notification ID %d for userID '%s' (topic '%s' off, daily maximum reached or expired).", notification.Category, notification.ID, notification.UserID, notification.Topic)
+	default:
+		log.Printf("INFO: [NotificationService] Delivered %s notification ID %d to userID '%s'.", notification.Category, notification.ID, notification.UserID)
+	}
+}
+
+// post adds a delivered notification to the user's chat as a message from its agent, and publishes it.
+func (s *notificationService) post(notification *models.Notification, now time.Time) {
+	publishEvent(s.events, models.EventNotificationDelivered, notification.UserID, notification)
+	switch notification.Category {
+	case models.NotificationCategoryTaskReminder, models.NotificationCategoryMissedCheckIn:
+		publishEvent(s.events, models.EventReminderDue, notification.UserID, notification)
+	}
+	if s.chatRepo == nil {
+		return
+	}
+	message := models.ChatMessage{
+		UserID:    notification.UserID,
+		Role:      "assistant",
+		Name:      agentName(notification.AgentID),
+		Content:   notification.Content,
+		Timestamp: now,
+	}
+	if err := s.chatRepo.SaveMessage(message); err != nil {
+		log.Printf("ERROR: [NotificationService] Failed to post notification ID %d to the chat of userID '%s': %v", notification.ID, notification.UserID, err)
+		return
+	}
+	publishEvent(s.events, models.EventMessageCreated, notification.UserID, message)
+}
+
+// notificationTopic returns the preference topic of notifications about a plan task. Generic tasks count as habits.
+func notificationTopic(taskType models.TaskType) string {
+	switch taskType {
+	case models.TaskTypeExercise:
+		return models.NotificationTopicExercise
+	case models.TaskTypeKnowledge:
+		return models.NotificationTopicKnowledge
+	}
+	return models.NotificationTopicHabit
+}
+
+// DeferredNotificationJob returns the background job that delivers deferred notifications once quiet hours end.
+func DeferredNotificationJob(service NotificationService) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		delivered, err := service.DeliverDeferred(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		out.Printf("%d deferred notifications delivered.", delivered)
+		return nil
+	}
+}
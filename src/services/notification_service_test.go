@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockNotificationRepository is a mock type for the NotificationRepository type
+type MockNotificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockNotificationRepository) CreateNotification(notification *models.Notification) error {
+	args := m.Called(notification)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) UpdateNotification(notification *models.Notification) error {
+	args := m.Called(notification)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) NotificationExists(dedupeKey string) (bool, error) {
+	args := m.Called(dedupeKey)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) CountDelivered(userID string, since, until time.Time) (int64, error) {
+	args := m.Called(userID, since, until)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetDueDeferred(now time.Time) ([]models.Notification, error) {
+	args := m.Called(now)
+	var notifications []models.Notification
+	if args.Get(0) != nil {
+		notifications = args.Get(0).([]models.Notification)
+	}
+	return notifications, args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetNotificationByID(id uint) (*models.Notification, error) {
+	args := m.Called(id)
+	var notification *models.Notification
+	if args.Get(0) != nil {
+		notification = args.Get(0).(*models.Notification)
+	}
+	return notification, args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetDelivered(userID string, unreadOnly bool, beforeID uint, limit int) ([]models.Notification, error) {
+	args := m.Called(userID, unreadOnly, beforeID, limit)
+	var notifications []models.Notification
+	if args.Get(0) != nil {
+		notifications = args.Get(0).([]models.Notification)
+	}
+	return notifications, args.Error(1)
+}
+
+func (m *MockNotificationRepository) CountUnread(userID string) (int64, error) {
+	args := m.Called(userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) MarkAllRead(userID string, readAt time.Time) (int64, error) {
+	args := m.Called(userID, readAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetPreferences(userID string) (*models.NotificationPreferences, error) {
+	args := m.Called(userID)
+	var prefs *models.NotificationPreferences
+	if args.Get(0) != nil {
+		prefs = args.Get(0).(*models.NotificationPreferences)
+	}
+	return prefs, args.Error(1)
+}
+
+func (m *MockNotificationRepository) SavePreferences(prefs *models.NotificationPreferences) error {
+	args := m.Called(prefs)
+	return args.Error(0)
+}
+
+// MockNotificationService is a mock type for the NotificationService type
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) Send(notification *models.Notification, now time.Time) error {
+	args := m.Called(notification, now)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) Sent(dedupeKey string) (bool, error) {
+	args := m.Called(dedupeKey)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationService) DeliverDeferred(ctx context.Context, now time.Time) (int, error) {
+	args := m.Called(ctx, now)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationService) GetPreferences(userID string) (*models.NotificationPreferences, error) {
+	args := m.Called(userID)
+	var prefs *models.NotificationPreferences
+	if args.Get(0) != nil {
+		prefs = args.Get(0).(*models.NotificationPreferences)
+	}
+	return prefs, args.Error(1)
+}
+
+func (m *MockNotificationService) UpdatePreferences(userID string, input models.NotificationPreferencesInput) (*models.NotificationPreferences, error) {
+	args := m.Called(userID, input)
+	var prefs *models.NotificationPreferences
+	if args.Get(0) != nil {
+		prefs = args.Get(0).(*models.NotificationPreferences)
+	}
+	return prefs, args.Error(1)
+}
+
+func (m *MockNotificationService) ListInbox(userID string, unreadOnly bool, beforeID uint, limit int) (*models.NotificationInbox, error) {
+	args := m.Called(userID, unreadOnly, beforeID, limit)
+	var inbox *models.NotificationInbox
+	if args.Get(0) != nil {
+		inbox = args.Get(0).(*models.NotificationInbox)
+	}
+	return inbox, args.Error(1)
+}
+
+func (m *MockNotificationService) UnreadCount(userID string) (int64, error) {
+	args := m.Called(userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationService) MarkRead(userID string, notificationID uint, now time.Time) (*models.Notification, error) {
+	args := m.Called(userID, notificationID, now)
+	var notification *models.Notification
+	if args.Get(0) != nil {
+		notification = args.Get(0).(*models.Notification)
+	}
+	return notification, args.Error(1)
+}
+
+func (m *MockNotificationService) MarkAllRead(userID string, now time.Time) (int64, error) {
+	args := m.Called(userID, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func notificationTestPreferences() *models.NotificationPreferences {
+	return &models.NotificationPreferences{
+		UserID: "notifyUser", Timezone: "Asia/Shanghai", QuietHoursStart: "22:30", QuietHoursEnd: "08:00",
+		Exercise: true, Habit: true, Knowledge: true, Report: true, MaxPerDay: 3,
+	}
+}
+
+func TestQuietHoursEnd(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	prefs := notificationTestPreferences()
+
+	assert.Nil(t, quietHoursEnd(prefs, time.Date(2024, 3, 15, 12, 0, 0, 0, loc)))
+	assert.Equal(t, time.Date(2024, 3, 16, 8, 0, 0, 0, loc), *quietHoursEnd(prefs, time.Date(2024, 3, 15, 23, 0, 0, 0, loc)), "overnight, before midnight")
+	assert.Equal(t, time.Date(2024, 3, 15, 8, 0, 0, 0, loc), *quietHoursEnd(prefs, time.Date(2024, 3, 15, 6, 0, 0, 0, loc)), "overnight, after midnight")
+	assert.Nil(t, quietHoursEnd(prefs, time.Date(2024, 3, 15, 8, 0, 0, 0, loc)), "the end is not quiet")
+	assert.Equal(t, time.Date(2024, 3, 16, 8, 0, 0, 0, loc), *quietHoursEnd(prefs, time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)), "in the user's timezone")
+
+	prefs.QuietHoursStart, prefs.QuietHoursEnd = "13:00", "14:00"
+	assert.Equal(t, time.Date(2024, 3, 15, 14, 0, 0, 0, loc), *quietHoursEnd(prefs, time.Date(2024, 3, 15, 13, 30, 0, 0, loc)))
+
+	prefs.QuietHoursStart, prefs.QuietHoursEnd = "", ""
+	assert.Nil(t, quietHoursEnd(prefs, time.Date(2024, 3, 15, 23, 0, 0, 0, loc)), "no quiet hours")
+}
+
+func TestDecideNotification(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	day := time.Date(2024, 3, 15, 12, 0, 0, 0, loc)
+	night := time.Date(2024, 3, 15, 23, 0, 0, 0, loc)
+	endOfDay := time.Date(2024, 3, 16, 0, 0, 0, 0, loc)
+
+	t.Run("Delivered", func(t *testing.T) {
+		n := &models.Notification{Topic: models.NotificationTopicExercise}
+		decideNotification(n, notificationTestPreferences(), 2, day)
+		assert.Equal(t, models.NotificationStatusDelivered, n.Status)
+		assert.Equal(t, day, *n.DeliveredAt)
+	})
+
+	t.Run("Topic turned off", func(t *testing.T) {
+		prefs := notificationTestPreferences()
+		prefs.Exercise = false
+		n := &models.Notification{Topic: models.NotificationTopicExercise}
+		decideNotification(n, prefs, 0, day)
+		assert.Equal(t, models.NotificationStatusDropped, n.Status)
+
+		n = &models.Notification{Topic: models.NotificationTopicProductRecommendation}
+		decideNotification(n, notificationTestPreferences(), 0, day)
+		assert.Equal(t, models.NotificationStatusDropped, n.Status, "product recommendations are opt-in")
+	})
+
+	t.Run("Daily maximum reached", func(t *testing.T) {
+		n := &models.Notification{Topic: models.NotificationTopicHabit}
+		decideNotification(n, notificationTestPreferences(), 3, day)
+		assert.Equal(t, models.NotificationStatusDropped, n.Status)
+
+		prefs := notificationTestPreferences()
+		prefs.MaxPerDay = 0
+		decideNotification(n, prefs, 30, day)
+		assert.Equal(t, models.NotificationStatusDelivered, n.Status, "no maximum")
+	})
+
+	t.Run("Quiet hours", func(t *testing.T) {
+		n := &models.Notification{Topic: models.NotificationTopicReport}
+		decideNotification(n, notificationTestPreferences(), 0, night)
+		assert.Equal(t, models.NotificationStatusDeferred, n.Status)
+		assert.Equal(t, time.Date(2024, 3, 16, 8, 0, 0, 0, loc), *n.DeliverAt)
+		assert.Nil(t, n.DeliveredAt)
+
+		n = &models.Notification{Topic: models.NotificationTopicHabit, ExpiresAt: &endOfDay}
+		decideNotification(n, notificationTestPreferences(), 0, night)
+		assert.Equal(t, models.NotificationStatusDropped, n.Status, "a reminder for today is out of date by the morning")
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		n := &models.Notification{Topic: models.NotificationTopicHabit, ExpiresAt: &endOfDay}
+		decideNotification(n, notificationTestPreferences(), 0, endOfDay.Add(9*time.Hour))
+		assert.Equal(t, models.NotificationStatusDropped, n.Status)
+	})
+}
+
+func TestApplyNotificationPreferences(t *testing.T) {
+	str := func(s string) *string { return &s }
+	yes, no := true, false
+	negative, five := -1, 5
+
+	prefs := notificationTestPreferences()
+	err := applyNotificationPreferences(prefs, models.NotificationPreferencesInput{
+		Timezone: str(" Europe/Berlin "), QuietHoursStart: str(""), QuietHoursEnd: str(""), MaxPerDay: &five,
+		Exercise: &no, ProductRecommendation: &yes,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", prefs.Timezone)
+	assert.Empty(t, prefs.QuietHoursStart)
+	assert.Equal(t, 5, prefs.MaxPerDay)
+	assert.False(t, prefs.Exercise)
+	assert.True(t, prefs.Habit, "unchanged")
+	assert.True(t, prefs.ProductRecommendation)
+
+	for name, input := range map[string]models.NotificationPreferencesInput{
+		"unknown timezone":    {Timezone: str("Mars/Olympus")},
+		"half of quiet hours": {QuietHoursStart: str("")},
+		"not a time":          {QuietHoursEnd: str("8am")},
+		"negative maximum":    {MaxPerDay: &negative},
+	} {
+		err := applyNotificationPreferences(notificationTestPreferences(), input)
+		assert.Error(t, err, name)
+		assert.Contains(t, err.Error(), "invalid", name)
+	}
+}
+
+func TestNotificationService_Send(t *testing.T) {
+	originalNotifications, originalSchedule := config.AppConfig.Notifications, config.AppConfig.PlanSchedule
+	config.AppConfig.Notifications = config.NotificationConfig{QuietHoursStart: "22:30", QuietHoursEnd: "08:00", MaxPerDay: 5}
+	config.AppConfig.PlanSchedule.DefaultTimezone = "Asia/Shanghai"
+	defer func() {
+		config.AppConfig.Notifications, config.AppConfig.PlanSchedule = originalNotifications, originalSchedule
+	}()
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, loc)
+
+	t.Run("Delivered with the default preferences", func(t *testing.T) {
+		mockNotificationRepo := new(MockNotificationRepository)
+		mockChatRepo := new(MockChatRepository)
+		events := NewEventBus()
+		var published []models.Event
+		events.Subscribe(func(event models.Event) { published = append(published, event) })
+		service := NewNotificationService(mockNotificationRepo, mockChatRepo, events)
+		mockNotificationRepo.On("GetPreferences", "notifyUser").Return(nil, nil).Once()
+		mockNotificationRepo.On("CountDelivered", "notifyUser", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(int64(4), nil).Once()
+		mockNotificationRepo.On("CreateNotification", mock.MatchedBy(func(n *models.Notification) bool {
+			return n.Status == models.NotificationStatusDelivered
+		})).Return(nil).Once()
+		mockChatRepo.On("SaveMessage", mock.MatchedBy(func(msg models.ChatMessage) bool {
+			return msg.UserID == "notifyUser" && msg.Role == "assistant" && msg.Content == "该拉伸啦。"
+		})).Return(errors.New("chat unavailable")).Once()
+
+		err := service.Send(&models.Notification{UserID: "notifyUser", Category: models.NotificationCategoryTaskReminder, Topic: models.NotificationTopicExercise, Content: "该拉伸啦。"}, now)
+
+		assert.NoError(t, err, "a failing chat does not fail the notification, which is recorded")
+		if assert.Len(t, published, 2, "no message.created, as the chat message was not saved") {
+			assert.Equal(t, models.EventNotificationDelivered, published[0].Type)
+			assert.Equal(t, models.EventReminderDue, published[1].Type)
+			assert.Equal(t, "notifyUser", published[1].UserID)
+			assert.NotEmpty(t, published[1].ID)
+		}
+		mockNotificationRepo.AssertExpectations(t)
+		mockChatRepo.AssertExpectations(t)
+	})
+
+	t.Run("Product recommendations are off by default", func(t *testing.T) {
+		mockNotificationRepo := new(MockNotificationRepository)
+		mockChatRepo := new(MockChatRepository)
+		service := NewNotificationService(mockNotificationRepo, mockChatRepo, nil)
+		mockNotificationRepo.On("GetPreferences", "notifyUser").Return(nil, nil).Once()
+		mockNotificationRepo.On("CountDelivered", "notifyUser", mock.Anything, mock.Anything).Return(int64(0), nil).Once()
+		mockNotificationRepo.On("CreateNotification", mock.MatchedBy(func(n *models.Notification) bool {
+			return n.Status == models.NotificationStatusDropped
+		})).Return(nil).Once()
+
+		err := service.Send(&models.Notification{UserID: "notifyUser", Topic: models.NotificationTopicProductRecommendation}, now)
+
+		assert.NoError(t, err)
+		mockNotificationRepo.AssertExpectations(t)
+		mockChatRepo.AssertNotCalled(t, "SaveMessage", mock.Anything)
+	})
+}
+
+func TestNotificationService_DeliverDeferred(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Date(2024, 3, 16, 8, 5, 0, 0, loc)
+	mockNotificationRepo := new(MockNotificationRepository)
+	mockChatRepo := new(MockChatRepository)
+	service := NewNotificationService(mockNotificationRepo, mockChatRepo, nil)
+
+	deliverAt := time.Date(2024, 3, 16, 8, 0, 0, 0, loc)
+	mockNotificationRepo.On("GetDueDeferred", now).Return([]models.Notification{
+		{ID: 1, UserID: "notifyUser", Topic: models.NotificationTopicReport, Status: models.NotificationStatusDeferred, DeliverAt: &deliverAt, Content: "周报"},
+		{ID: 2, UserID: "otherUser", Topic: models.NotificationTopicKnowledge, Status: models.NotificationStatusDeferred, DeliverAt: &deliverAt},
+	}, nil).Once()
+	mockNotificationRepo.On("GetPreferences", "notifyUser").Return(notificationTestPreferences(), nil).Once()
+	otherPrefs := notificationTestPreferences()
+	otherPrefs.UserID, otherPrefs.Knowledge = "otherUser", false // Turned off after it was deferred
+	mockNotificationRepo.On("GetPreferences", "otherUser").Return(otherPrefs, nil).Once()
+	mockNotificationRepo.On("CountDelivered", mock.Anything, time.Date(2024, 3, 16, 0, 0, 0, 0, loc), time.Date(2024, 3, 17, 0, 0, 0, 0, loc)).Return(int64(0), nil).Twice()
+	mockNotificationRepo.On("UpdateNotification", mock.MatchedBy(func(n *models.Notification) bool {
+		return n.ID == 1 && n.Status == models.NotificationStatusDelivered && n.DeliverAt == nil
+	})).Return(nil).Once()
+	mockNotificationRepo.On("UpdateNotification", mock.MatchedBy(func(n *models.Notification) bool {
+		return n.ID == 2 && n.Status == models.NotificationStatusDropped
+	})).Return(nil).Once()
+	mockChatRepo.On("SaveMessage", mock.MatchedBy(func(msg models.ChatMessage) bool { return msg.UserID == "notifyUser" })).Return(nil).Once()
+
+	delivered, err := service.DeliverDeferred(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	mockNotificationRepo.AssertExpectations(t)
+	mockChatRepo.AssertExpectations(t)
+}
+
+func TestNotificationService_ListInbox(t *testing.T) {
+	mockNotificationRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockNotificationRepo, nil, nil)
+
+	t.Run("Full page", func(t *testing.T) {
+		mockNotificationRepo.On("GetDelivered", "inboxUser", true, uint(0), 2).Return([]models.Notification{{ID: 9}, {ID: 7}}, nil).Once()
+		mockNotificationRepo.On("CountUnread", "inboxUser").Return(int64(3), nil).Once()
+
+		inbox, err := service.ListInbox("inboxUser", true, 0, 2)
+
+		assert.NoError(t, err)
+		assert.Len(t, inbox.Notifications, 2)
+		assert.Equal(t, int64(3), inbox.UnreadCount)
+		assert.Equal(t, uint(7), inbox.NextBefore)
+	})
+
+	t.Run("Last page", func(t *testing.T) {
+		mockNotificationRepo.On("GetDelivered", "inboxUser", false, uint(7), 2).Return(nil, nil).Once()
+		mockNotificationRepo.On("CountUnread", "inboxUser").Return(int64(3), nil).Once()
+
+		inbox, err := service.ListInbox("inboxUser", false, 7, 2)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, inbox.Notifications, "an empty list, not null")
+		assert.Zero(t, inbox.NextBefore)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		_, err := service.ListInbox("inboxUser", false, 0, 500)
+		assert.EqualError(t, err, "invalid limit 500: must be between 1 and 100")
+	})
+	mockNotificationRepo.AssertExpectations(t)
+}
+
+func TestNotificationService_MarkRead(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	mockNotificationRepo := new(MockNotificationRepository)
+	service := NewNotificationService(mockNotificationRepo, nil, nil)
+
+	mockNotificationRepo.On("GetNotificationByID", uint(1)).Return(&models.Notification{ID: 1, UserID: "inboxUser", Status: models.NotificationStatusDelivered}, nil).Once()
+	mockNotificationRepo.On("UpdateNotification", mock.MatchedBy(func(n *models.Notification) bool {
+		return n.ID == 1 && n.ReadAt != nil && n.ReadAt.Equal(now)
+	})).Return(nil).Once()
+	notification, err := service.MarkRead("inboxUser", 1, now)
+	assert.NoError(t, err)
+	assert.Equal(t, now, *notification.ReadAt)
+
+	mockNotificationRepo.On("GetNotificationByID", uint(2)).Return(&models.Notification{ID: 2, UserID: "inboxUser", Status: models.NotificationStatusDelivered, ReadAt: &earlier}, nil).Once()
+	notification, err = service.MarkRead("inboxUser", 2, now)
+	assert.NoError(t, err)
+	assert.Equal(t, earlier, *notification.ReadAt, "already read")
+
+	mockNotificationRepo.On("GetNotificationByID", uint(3)).Return(&models.Notification{ID: 3, UserID: "otherUser", Status: models.NotificationStatusDelivered}, nil).Once()
+	_, err = service.MarkRead("inboxUser", 3, now)
+	assert.EqualError(t, err, "unauthorized to read notification 3")
+
+	mockNotificationRepo.On("GetNotificationByID", uint(4)).Return(&models.Notification{ID: 4, UserID: "inboxUser", Status: models.NotificationStatusDeferred}, nil).Once()
+	_, err = service.MarkRead("inboxUser", 4, now)
+	assert.EqualError(t, err, "notification 4 not found", "not in the inbox yet")
+
+	mockNotificationRepo.On("GetNotificationByID", uint(5)).Return(nil, nil).Once()
+	_, err = service.MarkRead("inboxUser", 5, now)
+	assert.EqualError(t, err, "notification 5 not found")
+
+	mockNotificationRepo.AssertExpectations(t)
+}
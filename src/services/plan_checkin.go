@@ -0,0 +1,208 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project/config"
+	"project/models"
+	"time"
+)
+
+// taskRecurrence returns the structured schedule of a task, parsing its Frequency text if it was never migrated.
+// Tasks whose frequency cannot be understood are treated as a single occurrence that stays due until it is done,
+// which is how every task behaved before check-ins existed.
+func taskRecurrence(task *models.PlanTask) *models.Recurrence {
+	if task.Recurrence != nil {
+		return task.Recurrence
+	}
+	if rec := ParseFrequency(task.Frequency); rec != nil {
+		return rec
+	}
+	return &models.Recurrence{Kind: models.RecurrenceOnce}
+}
+
+// taskOccurrences lists the occurrences of a task that are due on the calendar day of today (already in the
+// user's timezone), each matched with its check-in. Flexible schedules (times per week, undated once) only
+// count completions from earlier days, so an occurrence checked in today stays in today's list.
+func taskOccurrences(task *models.PlanTask, rec *models.Recurrence, checkIns []models.TaskCheckIn, today time.Time) []models.TaskOccurrence {
+	date := today.Format("2006-01-02")
+	weekStart := WeekStart(today).Format("2006-01-02")
+
+	completedInPeriod := 0
+	todays := make(map[int]*models.TaskCheckIn)
+	for i := range checkIns {
+		checkIn := &checkIns[i]
+		if checkIn.DueDate == date {
+			todays[checkIn.Slot] = checkIn
+			continue
+		}
+		if checkIn.Status != models.TaskStatusCompleted || checkIn.DueDate > date {
+			continue
+		}
+		if rec.Kind == models.RecurrenceOnce || (rec.Kind == models.RecurrenceTimesPerWeek && checkIn.DueDate >= weekStart) {
+			completedInPeriod++
+		}
+	}
+	if len(checkIns) == 0 && rec.Kind == models.RecurrenceOnce && task.IsCompleted {
+		completedInPeriod = 1 // Completed before check-ins were recorded
+	}
+
+	times := DueCount(rec, today, completedInPeriod)
+	occurrences := make([]models.TaskOccurrence, 0, times)
+	for slot := 1; slot <= times; slot++ {
+		occurrence := models.TaskOccurrence{Slot: slot, Status: models.TaskStatusPending}
+		if slot <= len(rec.Windows) {
+			window := rec.Windows[slot-1]
+			occurrence.Window = &window
+		}
+		if checkIn, ok := todays[slot]; ok {
+			occurrence.Status = checkIn.Status
+			occurrence.CheckIn = checkIn
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences
+}
+
+// nextOccurrence picks the occurrence a new check-in with the given status applies to: the first one not yet
+// completed when completing or recording partial progress, the first one without a check-in when skipping.
+// It returns nil if there is none.
+func nextOccurrence(occurrences []models.TaskOccurrence, status models.TaskStatus) *models.TaskOccurrence {
+	for i := range occurrences {
+		switch {
+		case status == models.TaskStatusSkipped && occurrences[i].CheckIn == nil:
+			return &occurrences[i]
+		case status != models.TaskStatusSkipped && occurrences[i].Status != models.TaskStatusCompleted:
+			return &occurrences[i]
+		}
+	}
+	return nil
+}
+
+// validateCheckIn checks the amounts and feedback of a check-in. Partial check-ins need a completed amount
+// below the target, if one is given.
+func validateCheckIn(status models.TaskStatus, input models.CheckInInput) error {
+	if input.CompletedAmount < 0 || input.TargetAmount < 0 {
+		return errors.New("invalid check-in: amounts cannot be negative")
+	}
+	if status == models.TaskStatusPartial {
+		if input.CompletedAmount == 0 {
+			return errors.New("invalid check-in: completed_amount is required for partial completion")
+		}
+		if input.TargetAmount > 0 && input.CompletedAmount >= input.TargetAmount {
+			return errors.New("invalid check-in: completed_amount must be less than target_amount for partial completion")
+		}
+	}
+	if input.Feedback == nil {
+		return nil
+	}
+	scores := []struct {
+		name  string
+		value *int
+	}{{"fatigue", input.Feedback.Fatigue}, {"mood", input.Feedback.Mood}, {"perceived_effect", input.Feedback.PerceivedEffect}}
+	for _, score := range scores {
+		if score.value != nil && (*score.value < models.FeedbackScoreMin || *score.value > models.FeedbackScoreMax) {
+			return fmt.Errorf("invalid check-in: %s must be between %d and %d", score.name, models.FeedbackScoreMin, models.FeedbackScoreMax)
+		}
+	}
+	allowed := config.AppConfig.CheckIn.FeedbackTags
+	for _, tag := range input.Feedback.Tags {
+		if len(allowed) > 0 && !contains(allowed, tag) {
+			return fmt.Errorf("invalid check-in: unknown feedback tag '%s'", tag)
+		}
+	}
+	return nil
+}
+
+// buildPlanProgress summarizes the check-ins of a plan, overall and per task in plan order.
+func buildPlanProgress(plan *models.Plan, checkIns []models.TaskCheckIn) *models.PlanProgress {
+	checkInsByTask := make(map[uint][]models.TaskCheckIn)
+	for _, checkIn := range checkIns {
+		checkInsByTask[checkIn.TaskID] = append(checkInsByTask[checkIn.TaskID], checkIn)
+	}
+	progress := &models.PlanProgress{
+		PlanID:         plan.ID,
+		CheckInSummary: summarizeCheckIns(checkIns),
+		Tasks:          make([]models.TaskProgress, 0, len(plan.Tasks)),
+	}
+	for _, task := range plan.Tasks {
+		progress.Tasks = append(progress.Tasks, models.TaskProgress{
+			TaskID:         task.ID,
+			Title:          task.Title,
+			CheckInSummary: summarizeCheckIns(checkInsByTask[task.ID]),
+		})
+	}
+	return progress
+}
+
+// summarizeCheckIns counts check-ins by status and averages their feedback.
+func summarizeCheckIns(checkIns []models.TaskCheckIn) models.CheckInSummary {
+	summary := models.CheckInSummary{}
+	var done float64
+	var fatigue, mood, effect scoreMean
+	for i := range checkIns {
+		checkIn := &checkIns[i]
+		summary.CheckIns++
+		switch checkIn.Status {
+		case models.TaskStatusCompleted:
+			summary.Completed++
+			done++
+		case models.TaskStatusPartial:
+			summary.Partial++
+			done += partialFraction(checkIn)
+		case models.TaskStatusSkipped:
+			summary.Skipped++
+		}
+		if checkIn.Feedback != nil {
+			fatigue.add(checkIn.Feedback.Fatigue)
+			mood.add(checkIn.Feedback.Mood)
+			effect.add(checkIn.Feedback.PerceivedEffect)
+			for _, tag := range checkIn.Feedback.Tags {
+				if summary.Tags == nil {
+					summary.Tags = make(map[string]int)
+				}
+				summary.Tags[tag]++
+			}
+		}
+		if summary.LastCheckInAt == nil || checkIn.CheckedAt.After(*summary.LastCheckInAt) {
+			checkedAt := checkIn.CheckedAt
+			summary.LastCheckInAt = &checkedAt
+		}
+	}
+	if summary.CheckIns > 0 {
+		summary.CompletionRate = done / float64(summary.CheckIns)
+	}
+	summary.Feedback = models.FeedbackAverages{Fatigue: fatigue.mean(), Mood: mood.mean(), PerceivedEffect: effect.mean()}
+	return summary
+}
+
+// partialFraction is the share of the target a partial check-in achieved; half if the target is unknown.
+func partialFraction(checkIn *models.TaskCheckIn) float64 {
+	if checkIn.TargetAmount <= 0 {
+		return 0.5
+	}
+	if checkIn.CompletedAmount >= checkIn.TargetAmount {
+		return 1
+	}
+	return checkIn.CompletedAmount / checkIn.TargetAmount
+}
+
+// scoreMean accumulates optional feedback scores.
+type scoreMean struct {
+	sum, count int
+}
+
+func (m *scoreMean) add(score *int) {
+	if score != nil {
+		m.sum += *score
+		m.count++
+	}
+}
+
+func (m *scoreMean) mean() *float64 {
+	if m.count == 0 {
+		return nil
+	}
+	mean := float64(m.sum) / float64(m.count)
+	return &mean
+}
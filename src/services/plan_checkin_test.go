@@ -0,0 +1,165 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCheckInRepository is a mock type for the CheckInRepository interface
+type MockCheckInRepository struct {
+	mock.Mock
+}
+
+func (m *MockCheckInRepository) CreateCheckIn(checkIn *models.TaskCheckIn) error {
+	args := m.Called(checkIn)
+	if checkIn.ID == 0 {
+		checkIn.ID = 100
+	}
+	return args.Error(0)
+}
+
+func (m *MockCheckInRepository) UpdateCheckIn(checkIn *models.TaskCheckIn) error {
+	args := m.Called(checkIn)
+	return args.Error(0)
+}
+
+func (m *MockCheckInRepository) GetCheckInsByTaskID(taskID uint) ([]models.TaskCheckIn, error) {
+	args := m.Called(taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.TaskCheckIn), args.Error(1)
+}
+
+func (m *MockCheckInRepository) GetCheckInsByPlanID(planID uint) ([]models.TaskCheckIn, error) {
+	args := m.Called(planID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.TaskCheckIn), args.Error(1)
+}
+
+func TestTaskOccurrences(t *testing.T) {
+	wednesday := time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Daily slots carry their window and check-in", func(t *testing.T) {
+		task := &models.PlanTask{ID: 1}
+		rec := &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2, Windows: []models.TimeWindow{windowMorning, windowEvening}}
+		checkIns := []models.TaskCheckIn{
+			{ID: 1, DueDate: "2024-03-12", Slot: 1, Status: models.TaskStatusCompleted},
+			{ID: 2, DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusSkipped},
+		}
+
+		occurrences := taskOccurrences(task, rec, checkIns, wednesday)
+
+		assert.Len(t, occurrences, 2)
+		assert.Equal(t, models.TaskStatusSkipped, occurrences[0].Status)
+		assert.Equal(t, uint(2), occurrences[0].CheckIn.ID)
+		assert.Equal(t, "morning", occurrences[0].Window.Label)
+		assert.Equal(t, models.TaskStatusPending, occurrences[1].Status)
+		assert.Equal(t, "evening", occurrences[1].Window.Label)
+		assert.Nil(t, occurrences[1].CheckIn)
+		assert.Equal(t, 1, nextOccurrence(occurrences, models.TaskStatusCompleted).Slot) // A skipped slot can still be completed
+		assert.Equal(t, 2, nextOccurrence(occurrences, models.TaskStatusSkipped).Slot)
+	})
+
+	t.Run("Times per week counts earlier completions of the same week only", func(t *testing.T) {
+		task := &models.PlanTask{ID: 1}
+		rec := &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 2}
+		checkIns := []models.TaskCheckIn{
+			{DueDate: "2024-03-08", Slot: 1, Status: models.TaskStatusCompleted}, // Last week
+			{DueDate: "2024-03-11", Slot: 1, Status: models.TaskStatusCompleted},
+			{DueDate: "2024-03-12", Slot: 1, Status: models.TaskStatusSkipped},
+		}
+		assert.Len(t, taskOccurrences(task, rec, checkIns, wednesday), 1)
+
+		checkIns = append(checkIns, models.TaskCheckIn{DueDate: "2024-03-12", Slot: 1, Status: models.TaskStatusCompleted})
+		assert.Empty(t, taskOccurrences(task, rec, checkIns, wednesday))
+
+		// Completed today: still listed with its state
+		checkIns = []models.TaskCheckIn{{DueDate: "2024-03-11", Slot: 1, Status: models.TaskStatusCompleted}, {DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusCompleted}}
+		occurrences := taskOccurrences(task, rec, checkIns, wednesday)
+		assert.Len(t, occurrences, 1)
+		assert.Equal(t, models.TaskStatusCompleted, occurrences[0].Status)
+		assert.Nil(t, nextOccurrence(occurrences, models.TaskStatusCompleted))
+	})
+
+	t.Run("One-off tasks completed before check-ins existed are not due", func(t *testing.T) {
+		task := &models.PlanTask{ID: 1, Frequency: "看心情", IsCompleted: true}
+
+		assert.Equal(t, models.RecurrenceOnce, taskRecurrence(task).Kind)
+		assert.Empty(t, taskOccurrences(task, taskRecurrence(task), nil, wednesday))
+	})
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestValidateCheckIn(t *testing.T) {
+	config.AppConfig.CheckIn = config.CheckInConfig{FeedbackTags: []string{"轻松", "吃力"}}
+	defer func() { config.AppConfig.CheckIn = config.CheckInConfig{} }()
+
+	tests := []struct {
+		name    string
+		status  models.TaskStatus
+		input   models.CheckInInput
+		wantErr string
+	}{
+		{"Completion without details", models.TaskStatusCompleted, models.CheckInInput{}, ""},
+		{"Partial with amount", models.TaskStatusPartial, models.CheckInInput{CompletedAmount: 2, TargetAmount: 3}, ""},
+		{"Partial without amount", models.TaskStatusPartial, models.CheckInInput{TargetAmount: 3}, "invalid check-in: completed_amount is required for partial completion"},
+		{"Partial reaching the target", models.TaskStatusPartial, models.CheckInInput{CompletedAmount: 3, TargetAmount: 3}, "invalid check-in: completed_amount must be less than target_amount for partial completion"},
+		{"Negative amount", models.TaskStatusCompleted, models.CheckInInput{CompletedAmount: -1}, "invalid check-in: amounts cannot be negative"},
+		{"Valid feedback", models.TaskStatusCompleted, models.CheckInInput{Feedback: &models.CheckInFeedback{Fatigue: intPtr(2), Mood: intPtr(5), Tags: []string{"轻松"}}}, ""},
+		{"Score out of range", models.TaskStatusSkipped, models.CheckInInput{Feedback: &models.CheckInFeedback{PerceivedEffect: intPtr(6)}}, "invalid check-in: perceived_effect must be between 1 and 5"},
+		{"Unknown tag", models.TaskStatusCompleted, models.CheckInInput{Feedback: &models.CheckInFeedback{Tags: []string{"无聊"}}}, "invalid check-in: unknown feedback tag '无聊'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCheckIn(tt.status, tt.input)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPlanProgress(t *testing.T) {
+	plan := &models.Plan{ID: 4, Tasks: []models.PlanTask{{ID: 1, Title: "凯格尔运动"}, {ID: 2, Title: "快走"}}}
+	latest := time.Date(2024, 3, 13, 21, 0, 0, 0, time.UTC)
+	checkIns := []models.TaskCheckIn{
+		{TaskID: 1, Status: models.TaskStatusCompleted, CheckedAt: latest.Add(-48 * time.Hour),
+			Feedback: &models.CheckInFeedback{Fatigue: intPtr(2), Mood: intPtr(4), Tags: []string{"轻松"}}},
+		{TaskID: 1, Status: models.TaskStatusPartial, CompletedAmount: 2, TargetAmount: 4, CheckedAt: latest,
+			Feedback: &models.CheckInFeedback{Fatigue: intPtr(4), Tags: []string{"吃力", "轻松"}}},
+		{TaskID: 1, Status: models.TaskStatusSkipped, CheckedAt: latest.Add(-24 * time.Hour)},
+		{TaskID: 1, Status: models.TaskStatusPartial, CompletedAmount: 1, CheckedAt: latest.Add(-72 * time.Hour)}, // Unknown target counts half
+	}
+
+	progress := buildPlanProgress(plan, checkIns)
+
+	assert.Equal(t, uint(4), progress.PlanID)
+	assert.Equal(t, 4, progress.CheckIns)
+	assert.Equal(t, 1, progress.Completed)
+	assert.Equal(t, 2, progress.Partial)
+	assert.Equal(t, 1, progress.Skipped)
+	assert.InDelta(t, 0.5, progress.CompletionRate, 0.0001) // (1 + 0.5 + 0 + 0.5) / 4
+	assert.InDelta(t, 3.0, *progress.Feedback.Fatigue, 0.0001)
+	assert.InDelta(t, 4.0, *progress.Feedback.Mood, 0.0001)
+	assert.Nil(t, progress.Feedback.PerceivedEffect)
+	assert.Equal(t, map[string]int{"轻松": 2, "吃力": 1}, progress.Tags)
+	assert.Equal(t, latest, *progress.LastCheckInAt)
+
+	assert.Len(t, progress.Tasks, 2)
+	assert.Equal(t, 4, progress.Tasks[0].CheckIns)
+	assert.Equal(t, "快走", progress.Tasks[1].Title)
+	assert.Equal(t, 0, progress.Tasks[1].CheckIns)
+	assert.Zero(t, progress.Tasks[1].CompletionRate)
+	assert.Nil(t, progress.Tasks[1].LastCheckInAt)
+}
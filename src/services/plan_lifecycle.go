@@ -0,0 +1,544 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+// planTransitions lists, for each target status, the statuses a user may move a plan from.
+var planTransitions = map[models.PlanStatus][]models.PlanStatus{
+	models.PlanStatusPaused:    {models.PlanStatusActive},
+	models.PlanStatusActive:    {models.PlanStatusPaused}, // Resume
+	models.PlanStatusCancelled: {models.PlanStatusPending, models.PlanStatusActive, models.PlanStatusPaused},
+	models.PlanStatusCompleted: {models.PlanStatusActive, models.PlanStatusPaused},
+}
+
+// planTransitionReasons describes each user transition in the plan's version history.
+var planTransitionReasons = map[models.PlanStatus]string{
+	models.PlanStatusPaused:    "暂停计划",
+	models.PlanStatusActive:    "恢复计划",
+	models.PlanStatusCancelled: "取消计划",
+	models.PlanStatusCompleted: "完成计划",
+}
+
+// planEditable reports whether the plan and its tasks may still be changed.
+func planEditable(plan *models.Plan) bool {
+	switch plan.Status {
+	case models.PlanStatusPending, models.PlanStatusActive, models.PlanStatusPaused:
+		return true
+	}
+	return false
+}
+
+// UpdatePlan changes the title, description or date range of a plan owned by userID.
+func (s *planService) UpdatePlan(planID uint, userID string, input models.PlanUpdateInput) (*models.Plan, error) {
+	plan, err := s.getEditablePlan(planID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			return nil, errors.New("invalid plan: title cannot be empty")
+		}
+		plan.Title = title
+	}
+	if input.Description != nil {
+		plan.Description = *input.Description
+	}
+	for _, field := range []struct {
+		name   string
+		value  *string
+		target *string
+	}{{"start_date", input.StartDate, &plan.StartDate}, {"end_date", input.EndDate, &plan.EndDate}} {
+		if field.value == nil {
+			continue
+		}
+		if *field.value != "" {
+			if _, err := time.Parse("2006-01-02", *field.value); err != nil {
+				return nil, fmt.Errorf("invalid plan: %s must be YYYY-MM-DD", field.name)
+			}
+		}
+		*field.target = *field.value
+	}
+	if plan.StartDate != "" && plan.EndDate != "" && plan.EndDate < plan.StartDate {
+		return nil, errors.New("invalid plan: end_date is before start_date")
+	}
+
+	if err := s.planRepo.UpdatePlan(plan); err != nil {
+		errMsg := fmt.Sprintf("failed to update plan ID %d for userID '%s'", planID, userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, "修改计划信息")
+	log.Printf("INFO: [PlanService] Plan ID %d updated by userID '%s'.", planID, userID)
+	return plan, nil
+}
+
+// AddTask appends a task to a plan owned by userID. The task's Recurrence is parsed from its frequency. The task
+// passes the safety rules the plan's review applied.
+func (s *planService) AddTask(planID uint, userID string, input models.PlanTaskInput) (*models.PlanTask, error) {
+	plan, err := s.getEditablePlan(planID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Title == nil || strings.TrimSpace(*input.Title) == "" {
+		return nil, errors.New("invalid task: title is required")
+	}
+	task := &models.PlanTask{PlanID: plan.ID, Type: models.TaskTypeGeneric, Status: models.TaskStatusPending, Order: 1}
+	for _, existing := range plan.Tasks {
+		if existing.Order >= task.Order {
+			task.Order = existing.Order + 1
+		}
+	}
+	if err := applyTaskInput(task, input); err != nil {
+		return nil, err
+	}
+	if err := s.checkTaskExercise(task); err != nil {
+		return nil, err
+	}
+	if err := s.checkTaskSafety(plan, task); err != nil {
+		return nil, err
+	}
+
+	if err := s.planRepo.CreatePlanTask(task); err != nil {
+		errMsg := fmt.Sprintf("failed to add task to plan ID %d for userID '%s'", planID, userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	plan.Tasks = append(plan.Tasks, *task)
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, "新增任务："+task.Title)
+	log.Printf("INFO: [PlanService] Task ID %d ('%s') added to plan ID %d by userID '%s'.", task.ID, task.Title, planID, userID)
+	return task, nil
+}
+
+// UpdateTask edits a task of a plan owned by userID. The edited task passes the safety rules again.
+func (s *planService) UpdateTask(taskID uint, userID string, input models.PlanTaskInput) (*models.PlanTask, error) {
+	task, plan, err := s.getOwnedTask(taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !planEditable(plan) {
+		return nil, fmt.Errorf("plan %d is %s and can no longer be edited", plan.ID, plan.Status)
+	}
+	if err := applyTaskInput(task, input); err != nil {
+		return nil, err
+	}
+	if err := s.checkTaskExercise(task); err != nil {
+		return nil, err
+	}
+	if err := s.checkTaskSafety(plan, task); err != nil {
+		return nil, err
+	}
+
+	if err := s.planRepo.UpdatePlanTask(task); err != nil {
+		errMsg := fmt.Sprintf("failed to update task ID %d for userID '%s'", taskID, userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	for i := range plan.Tasks {
+		if plan.Tasks[i].ID == task.ID {
+			plan.Tasks[i] = *task
+		}
+	}
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, "修改任务："+task.Title)
+	log.Printf("INFO: [PlanService] Task ID %d updated by userID '%s'.", taskID, userID)
+	return task, nil
+}
+
+// ReorderTasks sets the order of a plan's tasks. taskIDs must list every task of the plan exactly once.
+func (s *planService) ReorderTasks(planID uint, userID string, taskIDs []uint) (*models.Plan, error) {
+	plan, err := s.getEditablePlan(planID, userID)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[uint]int, len(taskIDs))
+	for i, id := range taskIDs {
+		if _, dup := positions[id]; dup {
+			return nil, fmt.Errorf("invalid task order: task %d is listed twice", id)
+		}
+		positions[id] = i + 1
+	}
+	if len(positions) != len(plan.Tasks) {
+		return nil, fmt.Errorf("invalid task order: expected %d task IDs, got %d", len(plan.Tasks), len(positions))
+	}
+	for _, task := range plan.Tasks {
+		if _, ok := positions[task.ID]; !ok {
+			return nil, fmt.Errorf("invalid task order: task %d is missing", task.ID)
+		}
+	}
+
+	for i := range plan.Tasks {
+		task := &plan.Tasks[i]
+		if task.Order == positions[task.ID] {
+			continue
+		}
+		task.Order = positions[task.ID]
+		if err := s.planRepo.UpdatePlanTask(task); err != nil {
+			errMsg := fmt.Sprintf("failed to reorder task ID %d of plan ID %d", task.ID, planID)
+			log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+	}
+	sort.SliceStable(plan.Tasks, func(i, j int) bool { return plan.Tasks[i].Order < plan.Tasks[j].Order })
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, "调整任务顺序")
+	log.Printf("INFO: [PlanService] Tasks of plan ID %d reordered by userID '%s'.", planID, userID)
+	return plan, nil
+}
+
+// DeleteTask removes a task from a plan owned by userID. Its check-ins are kept.
+func (s *planService) DeleteTask(taskID uint, userID string) error {
+	task, plan, err := s.getOwnedTask(taskID, userID)
+	if err != nil {
+		return err
+	}
+	if !planEditable(plan) {
+		return fmt.Errorf("plan %d is %s and can no longer be edited", plan.ID, plan.Status)
+	}
+	if err := s.planRepo.DeletePlanTask(taskID, false); err != nil {
+		errMsg := fmt.Sprintf("failed to delete task ID %d for userID '%s'", taskID, userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	remaining := make([]models.PlanTask, 0, len(plan.Tasks))
+	for _, other := range plan.Tasks {
+		if other.ID != taskID {
+			remaining = append(remaining, other)
+		}
+	}
+	plan.Tasks = remaining
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, "删除任务："+task.Title)
+	log.Printf("INFO: [PlanService] Task ID %d deleted from plan ID %d by userID '%s'.", taskID, plan.ID, userID)
+	return nil
+}
+
+// TransitionPlan pauses, resumes, cancels or completes a plan owned by userID, following planTransitions.
+// Resuming a plan may pause the user's other active plans, see enforceActivePlanLimit.
+func (s *planService) TransitionPlan(planID uint, userID string, to models.PlanStatus) (*models.Plan, error) {
+	plan, err := s.getOwnedPlan(planID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status == to {
+		log.Printf("INFO: [PlanService] Plan ID %d is already %s. No action taken for userID '%s'.", planID, to, userID)
+		return plan, nil
+	}
+	allowed := false
+	for _, from := range planTransitions[to] {
+		if plan.Status == from {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		log.Printf("WARN: [PlanService] UserID '%s' attempted to move plan ID %d from %s to %s.", userID, planID, plan.Status, to)
+		return nil, fmt.Errorf("cannot change plan %d from %s to %s", planID, plan.Status, to)
+	}
+
+	from := plan.Status
+	plan.Status = to
+	if err := s.planRepo.UpdatePlan(plan); err != nil {
+		plan.Status = from
+		errMsg := fmt.Sprintf("failed to change plan ID %d to %s for userID '%s'", planID, to, userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, planTransitionReasons[to])
+	log.Printf("INFO: [PlanService] Plan ID %d moved from %s to %s by userID '%s'.", planID, from, to, userID)
+	if to == models.PlanStatusActive {
+		enforceActivePlanLimit(s.planRepo, s.versionRepo, plan)
+	}
+	return plan, nil
+}
+
+// ArchivePlan hides a finished (completed, cancelled or rejected) plan owned by userID by soft-deleting it.
+func (s *planService) ArchivePlan(planID uint, userID string) error {
+	plan, err := s.getOwnedPlan(planID, userID)
+	if err != nil {
+		return err
+	}
+	if planEditable(plan) {
+		return fmt.Errorf("cannot archive plan %d while it is %s", planID, plan.Status)
+	}
+	if err := s.planRepo.DeletePlan(planID, false); err != nil {
+		errMsg := fmt.Sprintf("failed to archive plan ID %d for userID '%s'", planID, userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	log.Printf("INFO: [PlanService] Plan ID %d archived by userID '%s'.", planID, userID)
+	return nil
+}
+
+// getOwnedPlan fetches a plan, making sure it belongs to userID.
+func (s *planService) getOwnedPlan(planID uint, userID string) (*models.Plan, error) {
+	plan, err := s.GetPlanDetails(planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.UserID != userID {
+		log.Printf("WARN: [PlanService] Unauthorized attempt by userID '%s' to modify plan ID %d (belongs to userID '%s').", userID, planID, plan.UserID)
+		return nil, fmt.Errorf("unauthorized to modify plan %d", planID)
+	}
+	return plan, nil
+}
+
+// getEditablePlan fetches a plan owned by userID that may still be changed.
+func (s *planService) getEditablePlan(planID uint, userID string) (*models.Plan, error) {
+	plan, err := s.getOwnedPlan(planID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !planEditable(plan) {
+		return nil, fmt.Errorf("plan %d is %s and can no longer be edited", planID, plan.Status)
+	}
+	return plan, nil
+}
+
+// checkTaskExercise checks that the exercise a task follows is an approved exercise of the library.
+func (s *planService) checkTaskExercise(task *models.PlanTask) error {
+	if task.ExerciseID == nil {
+		return nil
+	}
+	missing, err := missingExercise(s.exerciseRepo, []uint{*task.ExerciseID})
+	if err != nil {
+		return err
+	}
+	if missing != 0 {
+		return fmt.Errorf("invalid task: exercise %d is not in the exercise library", missing)
+	}
+	return nil
+}
+
+// checkTaskSafety applies the safety rules to a task the user adds or edits, as the plan's safety review would:
+// the task is adjusted in place, and a task the rules remove or reject is refused. Without a safety service tasks
+// are not checked.
+func (s *planService) checkTaskSafety(plan *models.Plan, task *models.PlanTask) error {
+	if s.safetyService == nil {
+		return nil
+	}
+	checked, adjustments, err := s.safetyService.CheckTasks(plan.UserID, []models.PlanTask{*task})
+	if err != nil {
+		return err
+	}
+	for _, adjustment := range adjustments {
+		if adjustment.Action == "remove" || adjustment.Action == "reject" {
+			return fmt.Errorf("invalid task: %s", adjustment.Reason)
+		}
+	}
+	if len(checked) == 0 {
+		return fmt.Errorf("invalid task: '%s' does not pass the safety rules", task.Title)
+	}
+	adjusted := checked[0]
+	task.Type, task.Title, task.Description = adjusted.Type, adjusted.Title, adjusted.Description
+	task.Frequency, task.Recurrence, task.Duration = adjusted.Frequency, adjusted.Recurrence, adjusted.Duration
+	if task.Type != models.TaskTypeExercise {
+		task.ExerciseID = nil
+	}
+	if len(adjustments) > 0 {
+		log.Printf("INFO: [PlanService] Safety rules adjusted task '%s' of plan ID %d.", task.Title, plan.ID)
+	}
+	return nil
+}
+
+// applyTaskInput copies the set fields of input onto task, validating the type and title. Only exercise tasks
+// may follow an exercise of the library.
+func applyTaskInput(task *models.PlanTask, input models.PlanTaskInput) error {
+	if input.Type != nil {
+		taskType := planTaskType(*input.Type)
+		if taskType == models.TaskTypeGeneric && *input.Type != string(models.TaskTypeGeneric) {
+			return fmt.Errorf("invalid task: unknown type '%s'", *input.Type)
+		}
+		task.Type = taskType
+	}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if title == "" {
+			return errors.New("invalid task: title cannot be empty")
+		}
+		task.Title = title
+	}
+	if input.Description != nil {
+		task.Description = *input.Description
+	}
+	if input.Frequency != nil {
+		task.Frequency = *input.Frequency
+		task.Recurrence = ParseFrequency(task.Frequency)
+		if task.Recurrence == nil {
+			log.Printf("WARN: [PlanService] Could not parse frequency '%s' of task '%s'; it will be due until done once.", task.Frequency, task.Title)
+		}
+	}
+	if input.Duration != nil {
+		task.Duration = *input.Duration
+	}
+	if input.ExerciseID != nil {
+		task.ExerciseID, task.Exercise = nil, nil
+		if *input.ExerciseID != 0 {
+			exerciseID := *input.ExerciseID
+			task.ExerciseID = &exerciseID
+		}
+	}
+	if task.ExerciseID != nil && task.Type != models.TaskTypeExercise {
+		return errors.New("invalid task: only exercise tasks can follow an exercise")
+	}
+	return nil
+}
+
+// enforceActivePlanLimit pauses the user's oldest other active plans so that, with plan, at most
+// plan_lifecycle.max_active_plans are active. Failures are logged; plan itself stays active.
+func enforceActivePlanLimit(planRepo repository.PlanRepository, versionRepo repository.PlanVersionRepository, plan *models.Plan) {
+	maxActive := config.AppConfig.PlanLifecycle.MaxActivePlans
+	if maxActive <= 0 || planRepo == nil {
+		return
+	}
+	plans, err := planRepo.GetPlansByUserID(plan.UserID) // Newest first
+	if err != nil {
+		log.Printf("ERROR: [PlanService] Could not check the active plans of userID '%s': %v", plan.UserID, err)
+		return
+	}
+	kept := 1 // plan itself
+	for _, other := range plans {
+		if other.ID == plan.ID || other.Status != models.PlanStatusActive {
+			continue
+		}
+		if kept < maxActive {
+			kept++
+			continue
+		}
+		other.Status = models.PlanStatusPaused
+		if err := planRepo.UpdatePlan(other); err != nil {
+			log.Printf("ERROR: [PlanService] Failed to pause plan ID %d of userID '%s': %v", other.ID, plan.UserID, err)
+			continue
+		}
+		recordPlanVersion(versionRepo, other, models.PlanVersionAuthorSystem, "", fmt.Sprintf("同时进行的计划数已达上限，为计划「%s」让路而暂停", plan.Title))
+		log.Printf("INFO: [PlanService] Paused plan ID %d of userID '%s' to make room for active plan ID %d.", other.ID, plan.UserID, plan.ID)
+	}
+}
+
+// completePlanIfDone marks plan completed once all of its tasks are done, see planTasksDone.
+// task is the task just checked in, whose copy in plan.Tasks may be stale. Failures are logged only.
+func (s *planService) completePlanIfDone(plan *models.Plan, task *models.PlanTask, today time.Time) {
+	for i := range plan.Tasks {
+		if plan.Tasks[i].ID == task.ID {
+			plan.Tasks[i] = *task
+		}
+	}
+	if !planMayBeDone(plan, today) {
+		return
+	}
+	checkIns, err := s.checkInRepo.GetCheckInsByPlanID(plan.ID)
+	if err != nil {
+		log.Printf("WARN: [PlanService] Could not check whether plan ID %d is done: %v", plan.ID, err)
+		return
+	}
+	if !planTasksDone(plan, checkIns, today) {
+		return
+	}
+	plan.Status = models.PlanStatusCompleted
+	if err := s.planRepo.UpdatePlan(plan); err != nil {
+		log.Printf("ERROR: [PlanService] Failed to auto-complete plan ID %d: %v", plan.ID, err)
+		return
+	}
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorSystem, "", "所有任务均已完成，计划自动完成")
+	log.Printf("INFO: [PlanService] All tasks of plan ID %d are done; plan completed.", plan.ID)
+}
+
+// planMayBeDone is a cheap pre-check for planTasksDone: a plan with tasks can only be done on or after its end date,
+// and an open-ended plan only if all of its tasks occur once.
+func planMayBeDone(plan *models.Plan, today time.Time) bool {
+	if len(plan.Tasks) == 0 {
+		return false
+	}
+	if plan.EndDate != "" {
+		return today.Format("2006-01-02") >= plan.EndDate
+	}
+	for i := range plan.Tasks {
+		if plan.Tasks[i].Status != models.TaskStatusSkipped && taskRecurrence(&plan.Tasks[i]).Kind != models.RecurrenceOnce {
+			return false
+		}
+	}
+	return true
+}
+
+// planTasksDone reports whether every task of a plan is done within the plan's date range: one-off tasks are
+// completed, and every occurrence of a recurring task due from the start date to the end date has a completed
+// check-in (for times-per-week tasks, as many as the week's days in range allow). today is in the user's timezone.
+func planTasksDone(plan *models.Plan, checkIns []models.TaskCheckIn, today time.Time) bool {
+	if !planMayBeDone(plan, today) {
+		return false
+	}
+	completed := make(map[uint]map[string]int)
+	for _, checkIn := range checkIns {
+		if checkIn.Status != models.TaskStatusCompleted {
+			continue
+		}
+		if completed[checkIn.TaskID] == nil {
+			completed[checkIn.TaskID] = make(map[string]int)
+		}
+		completed[checkIn.TaskID][checkIn.DueDate]++
+	}
+
+	start := plan.StartDate
+	if start == "" && !plan.CreatedAt.IsZero() {
+		start = plan.CreatedAt.In(today.Location()).Format("2006-01-02")
+	}
+	if start == "" || start > plan.EndDate {
+		start = plan.EndDate
+	}
+	for i := range plan.Tasks {
+		task := &plan.Tasks[i]
+		if task.Status == models.TaskStatusSkipped { // Skipped as a whole before check-ins existed
+			continue
+		}
+		if !taskDoneInRange(task, taskRecurrence(task), completed[task.ID], start, plan.EndDate, today.Location()) {
+			return false
+		}
+	}
+	return true
+}
+
+// taskDoneInRange checks one task for planTasksDone. done maps due dates to completed check-ins.
+func taskDoneInRange(task *models.PlanTask, rec *models.Recurrence, done map[string]int, start, end string, loc *time.Location) bool {
+	if rec.Kind == models.RecurrenceOnce {
+		return task.IsCompleted || len(done) > 0
+	}
+	first, err := time.ParseInLocation("2006-01-02", start, loc)
+	if err != nil {
+		return false
+	}
+	last, err := time.ParseInLocation("2006-01-02", end, loc)
+	if err != nil {
+		return false
+	}
+
+	weekDays := make(map[string]int) // Week start -> days of the week within range
+	weekDone := make(map[string]int) // Week start -> completed occurrences
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if rec.Kind == models.RecurrenceTimesPerWeek {
+			week := WeekStart(day).Format("2006-01-02")
+			weekDays[week]++
+			weekDone[week] += done[date]
+			continue
+		}
+		if done[date] < DueCount(rec, day, 0) {
+			return false
+		}
+	}
+	for week, days := range weekDays {
+		required := rec.TimesPerWeek
+		if days < required {
+			required = days
+		}
+		if weekDone[week] < required {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func strPtr(v string) *string { return &v }
+
+func TestPlanService_TransitionPlan(t *testing.T) {
+	userID := "lifecycleUser"
+
+	t.Run("Pause an active plan", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(1)).Return(&models.Plan{ID: 1, UserID: userID, Status: models.PlanStatusActive}, nil).Once()
+		mockPlanRepo.On("UpdatePlan", mock.MatchedBy(func(p *models.Plan) bool { return p.Status == models.PlanStatusPaused })).Return(nil).Once()
+
+		plan, err := service.TransitionPlan(1, userID, models.PlanStatusPaused)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PlanStatusPaused, plan.Status)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("Completed plans cannot be resumed", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(1)).Return(&models.Plan{ID: 1, UserID: userID, Status: models.PlanStatusCompleted}, nil).Once()
+
+		plan, err := service.TransitionPlan(1, userID, models.PlanStatusActive)
+
+		assert.Nil(t, plan)
+		assert.EqualError(t, err, "cannot change plan 1 from completed to active")
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlan", mock.Anything)
+	})
+
+	t.Run("Other users cannot cancel the plan", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(1)).Return(&models.Plan{ID: 1, UserID: "someoneElse", Status: models.PlanStatusActive}, nil).Once()
+
+		_, err := service.TransitionPlan(1, userID, models.PlanStatusCancelled)
+
+		assert.EqualError(t, err, "unauthorized to modify plan 1")
+	})
+
+	t.Run("Resuming pauses the other active plan when only one may be active", func(t *testing.T) {
+		config.AppConfig.PlanLifecycle.MaxActivePlans = 1
+		defer func() { config.AppConfig.PlanLifecycle.MaxActivePlans = 0 }()
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		paused := &models.Plan{ID: 1, UserID: userID, Status: models.PlanStatusPaused}
+		other := &models.Plan{ID: 2, UserID: userID, Status: models.PlanStatusActive}
+		mockPlanRepo.On("GetPlanByID", uint(1)).Return(paused, nil).Once()
+		mockPlanRepo.On("UpdatePlan", paused).Return(nil).Once()
+		mockPlanRepo.On("GetPlansByUserID", userID).Return([]*models.Plan{other, paused}, nil).Once()
+		mockPlanRepo.On("UpdatePlan", other).Return(nil).Once()
+
+		plan, err := service.TransitionPlan(1, userID, models.PlanStatusActive)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PlanStatusActive, plan.Status)
+		assert.Equal(t, models.PlanStatusPaused, other.Status)
+		mockPlanRepo.AssertExpectations(t)
+	})
+}
+
+func TestPlanService_EditPlan(t *testing.T) {
+	userID := "editUser"
+	newPlan := func() *models.Plan {
+		return &models.Plan{ID: 5, UserID: userID, Title: "旧标题", Status: models.PlanStatusActive, Tasks: []models.PlanTask{
+			{ID: 51, PlanID: 5, Title: "凯格尔运动", Order: 1},
+			{ID: 52, PlanID: 5, Title: "快走", Order: 2},
+		}}
+	}
+
+	t.Run("Rename and set the date range", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil).Once()
+		mockPlanRepo.On("UpdatePlan", mock.MatchedBy(func(p *models.Plan) bool {
+			return p.Title == "四周计划" && p.StartDate == "2024-03-01" && p.EndDate == "2024-03-28"
+		})).Return(nil).Once()
+
+		_, err := service.UpdatePlan(5, userID, models.PlanUpdateInput{Title: strPtr(" 四周计划 "), StartDate: strPtr("2024-03-01"), EndDate: strPtr("2024-03-28")})
+
+		assert.NoError(t, err)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("End date before start date is rejected", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil).Once()
+
+		_, err := service.UpdatePlan(5, userID, models.PlanUpdateInput{StartDate: strPtr("2024-03-10"), EndDate: strPtr("2024-03-01")})
+
+		assert.EqualError(t, err, "invalid plan: end_date is before start_date")
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlan", mock.Anything)
+	})
+
+	t.Run("Added tasks go last and get a recurrence", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil).Once()
+		mockPlanRepo.On("CreatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool {
+			return task.PlanID == 5 && task.Order == 3 && task.Type == models.TaskTypeExercise &&
+				task.Recurrence != nil && task.Recurrence.TimesPerWeek == 3
+		})).Return(nil).Once()
+
+		task, err := service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("平板支撑"), Type: strPtr("exercise"), Frequency: strPtr("每周3次")})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "平板支撑", task.Title)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown task types are rejected", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil).Once()
+
+		_, err := service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("冥想"), Type: strPtr("meditation")})
+
+		assert.EqualError(t, err, "invalid task: unknown type 'meditation'")
+	})
+
+	t.Run("Added and edited tasks pass the safety rules", func(t *testing.T) {
+		config.AppConfig.PlanSafety = config.PlanSafetyConfig{Rules: testSafetyRules()}
+		defer func() { config.AppConfig.PlanSafety = config.PlanSafetyConfig{} }()
+		mockPlanRepo := new(MockPlanRepository)
+		mockProfileRepo := new(MockAssessmentProfileRepository)
+		safety := NewPlanSafetyService(mockPlanRepo, nil, NewAssessmentProfileService(mockProfileRepo, nil), nil)
+		service := NewPlanService(mockPlanRepo, nil, safety, nil, nil, nil)
+		mockProfileRepo.On("GetLatestProfileByUserID", userID).Return(&models.AssessmentProfile{RiskFlags: []string{"hypertension"}}, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil)
+		mockPlanRepo.On("CreatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool {
+			return task.Title == "中低强度有氧运动" && task.Order == 3
+		})).Return(nil).Once()
+
+		task, err := service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("HIIT 训练"), Type: strPtr("exercise"), Frequency: strPtr("每周3次")})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "每周3次", task.Frequency)
+		mockPlanRepo.AssertExpectations(t)
+
+		_, err = service.AddTask(5, userID, models.PlanTaskInput{Title: strPtr("大重量卧推")})
+		assert.EqualError(t, err, "invalid task: 大重量训练升高血压。")
+
+		mockPlanRepo.On("GetTaskByID", uint(52)).Return(&models.PlanTask{ID: 52, PlanID: 5, Title: "快走", Order: 2}, nil).Once()
+		_, err = service.UpdateTask(52, userID, models.PlanTaskInput{Title: strPtr("大重量深蹲")})
+		assert.EqualError(t, err, "invalid task: 大重量训练升高血压。")
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlanTask", mock.Anything)
+	})
+
+	t.Run("Reorder tasks", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil).Once()
+		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 51 && task.Order == 2 })).Return(nil).Once()
+		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 52 && task.Order == 1 })).Return(nil).Once()
+
+		plan, err := service.ReorderTasks(5, userID, []uint{52, 51})
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint(52), plan.Tasks[0].ID)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("Reorder must list every task once", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(newPlan(), nil).Twice()
+
+		_, err := service.ReorderTasks(5, userID, []uint{51, 51})
+		assert.EqualError(t, err, "invalid task order: task 51 is listed twice")
+		_, err = service.ReorderTasks(5, userID, []uint{51, 53})
+		assert.EqualError(t, err, "invalid task order: task 52 is missing")
+	})
+
+	t.Run("Cancelled plans can no longer be edited", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		cancelled := newPlan()
+		cancelled.Status = models.PlanStatusCancelled
+		mockPlanRepo.On("GetTaskByID", uint(51)).Return(&cancelled.Tasks[0], nil).Once()
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(cancelled, nil).Once()
+
+		err := service.DeleteTask(51, userID)
+
+		assert.EqualError(t, err, "plan 5 is cancelled and can no longer be edited")
+		mockPlanRepo.AssertNotCalled(t, "DeletePlanTask", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPlanTasksDone(t *testing.T) {
+	loc := time.UTC
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 20, 0, 0, 0, loc) }
+	plan := &models.Plan{ID: 1, StartDate: "2024-03-11", EndDate: "2024-03-13", Tasks: []models.PlanTask{
+		{ID: 1, Frequency: "每日", Recurrence: &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 1}},
+		{ID: 2, Frequency: "每周2次", Recurrence: &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 2}},
+		{ID: 3, Frequency: "本周一次", IsCompleted: true},
+	}}
+	checkIns := []models.TaskCheckIn{
+		{TaskID: 1, DueDate: "2024-03-11", Status: models.TaskStatusCompleted},
+		{TaskID: 1, DueDate: "2024-03-12", Status: models.TaskStatusCompleted},
+		{TaskID: 2, DueDate: "2024-03-11", Status: models.TaskStatusCompleted},
+		{TaskID: 2, DueDate: "2024-03-13", Status: models.TaskStatusCompleted},
+	}
+
+	assert.False(t, planTasksDone(plan, checkIns, day(13)), "the daily task is still due on the 13th")
+
+	checkIns = append(checkIns, models.TaskCheckIn{TaskID: 1, DueDate: "2024-03-13", Status: models.TaskStatusCompleted})
+	assert.True(t, planTasksDone(plan, checkIns, day(13)))
+	assert.False(t, planTasksDone(plan, checkIns, day(12)), "not before the end date")
+
+	partial := append([]models.TaskCheckIn{}, checkIns[:3]...)
+	partial = append(partial, models.TaskCheckIn{TaskID: 2, DueDate: "2024-03-13", Status: models.TaskStatusPartial}, checkIns[4])
+	assert.False(t, planTasksDone(plan, partial, day(13)), "partial check-ins do not count as done")
+
+	openEnded := &models.Plan{ID: 2, Tasks: []models.PlanTask{{ID: 1, Frequency: "本周一次", IsCompleted: true}, {ID: 2, Frequency: "阅读一章"}}}
+	assert.False(t, planTasksDone(openEnded, nil, day(13)))
+	openEnded.Tasks[1].IsCompleted = true
+	assert.True(t, planTasksDone(openEnded, nil, day(13)))
+	openEnded.Tasks = append(openEnded.Tasks, models.PlanTask{ID: 3, Frequency: "每日"})
+	assert.False(t, planTasksDone(openEnded, nil, day(13)), "recurring tasks never finish without an end date")
+}
+
+func TestPlanService_AutoCompletesPlan(t *testing.T) {
+	mockPlanRepo := new(MockPlanRepository)
+	mockCheckInRepo := new(MockCheckInRepository)
+	service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+	now := time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC)
+	plan := &models.Plan{ID: 8, UserID: "finisher", Status: models.PlanStatusActive, Tasks: []models.PlanTask{
+		{ID: 81, PlanID: 8, Frequency: "本周一次", IsCompleted: true, Status: models.TaskStatusCompleted},
+		{ID: 82, PlanID: 8, Frequency: "本周一次", Status: models.TaskStatusPending},
+	}}
+	lastTask := plan.Tasks[1]
+
+	mockPlanRepo.On("GetTaskByID", uint(82)).Return(&lastTask, nil).Once()
+	mockPlanRepo.On("GetPlanByID", uint(8)).Return(plan, nil).Once()
+	mockCheckInRepo.On("GetCheckInsByTaskID", uint(82)).Return([]models.TaskCheckIn{}, nil).Once()
+	mockCheckInRepo.On("CreateCheckIn", mock.AnythingOfType("*models.TaskCheckIn")).Return(nil).Once()
+	mockPlanRepo.On("UpdatePlanTask", mock.AnythingOfType("*models.PlanTask")).Return(nil).Once()
+	mockCheckInRepo.On("GetCheckInsByPlanID", uint(8)).Return([]models.TaskCheckIn{{TaskID: 82, DueDate: "2024-03-13", Status: models.TaskStatusCompleted}}, nil).Once()
+	mockPlanRepo.On("UpdatePlan", mock.MatchedBy(func(p *models.Plan) bool { return p.Status == models.PlanStatusCompleted })).Return(nil).Once()
+
+	_, err := service.MarkTaskCompleted(82, "finisher", models.CheckInInput{Timezone: "UTC"}, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PlanStatusCompleted, plan.Status)
+	mockPlanRepo.AssertExpectations(t)
+	mockCheckInRepo.AssertExpectations(t)
+}
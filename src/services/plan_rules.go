@@ -0,0 +1,97 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"strings"
+)
+
+// applyPlanRules evaluates the configured plan rules against an assessment's answers.
+// It returns the plan tasks (tasks of fired rules first, then the base templates, each template at most once)
+// and the IDs of the rules that fired. A nil assessment yields only the base templates.
+func applyPlanRules(rules config.PlanRulesConfig, questions []models.AssessmentQuestion, assessment *models.UserAssessment) ([]models.PlanTask, []string) {
+	templatesByID := make(map[string]config.PlanTaskTemplate, len(rules.Templates))
+	for _, tpl := range rules.Templates {
+		templatesByID[tpl.ID] = tpl
+	}
+	questionsByID := make(map[string]*models.AssessmentQuestion, len(questions))
+	for i := range questions {
+		questionsByID[questions[i].ID] = &questions[i]
+	}
+
+	var templateIDs []string
+	var fired []string
+	if assessment != nil {
+		answers := answersByQuestion(assessment)
+		for _, rule := range rules.Rules {
+			raw, answered := answers[rule.QuestionID]
+			if !answered {
+				continue
+			}
+			if planRuleMatches(rule, resolveAnswerOptions(questionsByID[rule.QuestionID], raw)) {
+				fired = append(fired, rule.ID)
+				templateIDs = append(templateIDs, rule.Templates...)
+			}
+		}
+	}
+	templateIDs = append(templateIDs, rules.BaseTemplates...)
+
+	tasks := []models.PlanTask{}
+	added := make(map[string]bool, len(templateIDs))
+	for _, id := range templateIDs {
+		tpl, ok := templatesByID[id]
+		if !ok || added[id] {
+			continue
+		}
+		added[id] = true
+		tasks = append(tasks, models.PlanTask{
+			Type:        planTaskType(tpl.Type),
+			Title:       tpl.Title,
+			Description: tpl.Description,
+			Frequency:   tpl.Frequency,
+			Recurrence:  ParseFrequency(tpl.Frequency),
+			Duration:    tpl.Duration,
+			Status:      models.TaskStatusPending,
+			Order:       len(tasks) + 1,
+			Progression: tpl.Progression,
+			Level:       startLevel(tpl.Progression),
+		})
+	}
+	return tasks, fired
+}
+
+// startLevel is the level a new task following progression starts at: 1, or 0 if it does not progress.
+func startLevel(progression string) int {
+	if progression == "" {
+		return 0
+	}
+	return 1
+}
+
+// planRuleMatches reports whether any of the resolved answers satisfies the rule.
+func planRuleMatches(rule config.PlanRule, answers []string) bool {
+	for _, answer := range answers {
+		lower := strings.ToLower(answer)
+		for _, expected := range rule.Answers {
+			if strings.ToLower(expected) == lower {
+				return true
+			}
+		}
+		for _, keyword := range rule.Keywords {
+			if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// planTaskType maps a template type to a TaskType, falling back to generic for unknown values.
+func planTaskType(t string) models.TaskType {
+	switch models.TaskType(t) {
+	case models.TaskTypeExercise, models.TaskTypeHabit, models.TaskTypeKnowledge:
+		return models.TaskType(t)
+	default:
+		return models.TaskTypeGeneric
+	}
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// PlanSafetyService is the health and safety gate new plans pass before they become active.
+type PlanSafetyService interface {
+	// ReviewPlan checks a saved, pending plan against the user's risk flags (and optionally an LLM review),
+	// then activates it, adjusts its tasks or rejects it. The plan and its tasks are updated in place and persisted.
+	ReviewPlan(plan *models.Plan) (*models.PlanReview, error)
+	// CheckTasks applies the safety rules to tasks that enter a plan after its review, such as the tasks a
+	// rollback restores. It returns the tasks the review would keep, adjusted and renumbered, and the
+	// adjustments, which also name the tasks the rules remove or reject. Nothing is persisted.
+	CheckTasks(userID string, tasks []models.PlanTask) ([]models.PlanTask, []models.PlanAdjustment, error)
+	GetReviews(planID uint) ([]models.PlanReview, error)
+}
+
+type planSafetyService struct {
+	planRepo       repository.PlanRepository
+	reviewRepo     repository.PlanReviewRepository
+	profileService AssessmentProfileService // Optional; without it only rules that need no risk flag apply
+	llm            LLMClient                // Optional; required for the LLM review
+}
+
+// NewPlanSafetyService creates a new instance of PlanSafetyService.
+func NewPlanSafetyService(planRepo repository.PlanRepository, reviewRepo repository.PlanReviewRepository, profileService AssessmentProfileService, llm LLMClient) PlanSafetyService {
+	return &planSafetyService{
+		planRepo:       planRepo,
+		reviewRepo:     reviewRepo,
+		profileService: profileService,
+		llm:            llm,
+	}
+}
+
+// planSafetyResult is the outcome of the rule checks on a plan's tasks.
+type planSafetyResult struct {
+	tasks         []models.PlanTask // Remaining tasks after adjustments and removals, renumbered
+	removed       []models.PlanTask
+	adjustments   []models.PlanAdjustment
+	rejected      bool
+	rejectReasons []string
+}
+
+func (s *planSafetyService) ReviewPlan(plan *models.Plan) (*models.PlanReview, error) {
+	if plan == nil || plan.ID == 0 {
+		return nil, errors.New("plan must be saved before review")
+	}
+	if s.planRepo == nil {
+		return nil, errors.New("planrepo not initialized")
+	}
+	cfg := config.AppConfig.PlanSafety
+	log.Printf("INFO: [PlanSafetyService] Reviewing plan ID %d for userID %s.", plan.ID, plan.UserID)
+
+	var profile *models.AssessmentProfile
+	if s.profileService != nil {
+		var err error
+		profile, err = s.profileService.GetLatestProfile(plan.UserID)
+		if err != nil {
+			errMsg := fmt.Sprintf("failed to load assessment profile for plan ID %d", plan.ID)
+			log.Printf("ERROR: [PlanSafetyService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+	}
+	var riskFlags []string
+	if profile != nil {
+		riskFlags = profile.RiskFlags
+	}
+
+	result := checkPlanSafety(cfg.Rules, riskFlags, plan.Tasks)
+	review := &models.PlanReview{
+		PlanID:      plan.ID,
+		UserID:      plan.UserID,
+		RiskFlags:   riskFlags,
+		Adjustments: result.adjustments,
+	}
+	if !result.rejected && len(result.tasks) == 0 {
+		result.rejected = true
+		result.rejectReasons = append(result.rejectReasons, "计划中的所有任务都因安全原因被移除。")
+	}
+
+	if !result.rejected && cfg.LLMReview.Enabled && s.llm != nil {
+		reviewed := *plan
+		reviewed.Tasks = result.tasks
+		approved, reason, err := s.llmReview(cfg.LLMReview, &reviewed, profile)
+		if err != nil {
+			// The rule checks have passed; an unavailable LLM reviewer does not block the plan.
+			log.Printf("WARN: [PlanSafetyService] LLM review of plan ID %d failed, using the rule checks only: %v", plan.ID, err)
+		} else {
+			review.LLMReviewed = true
+			review.LLMReason = reason
+			if !approved {
+				result.rejected = true
+				result.rejectReasons = append(result.rejectReasons, reason)
+			}
+		}
+	}
+
+	switch {
+	case result.rejected:
+		review.Outcome = models.PlanReviewRejected
+		review.Explanation = "计划未通过安全审核：" + strings.Join(result.rejectReasons, " ")
+	case len(result.adjustments) > 0:
+		review.Outcome = models.PlanReviewAdjusted
+		var reasons []string
+		for _, adj := range result.adjustments {
+			if !contains(reasons, adj.Reason) {
+				reasons = append(reasons, adj.Reason)
+			}
+		}
+		review.Explanation = "为保证安全，计划已自动调整：" + strings.Join(reasons, " ")
+	default:
+		review.Outcome = models.PlanReviewApproved
+	}
+
+	if err := s.applyReview(plan, review, result); err != nil {
+		return nil, err
+	}
+	if s.reviewRepo != nil {
+		if err := s.reviewRepo.CreateReview(review); err != nil {
+			// The plan already carries the outcome and explanation; a missing log entry must not undo it.
+			log.Printf("ERROR: [PlanSafetyService] Failed to record review of plan ID %d: %v", plan.ID, err)
+		}
+	}
+	log.Printf("INFO: [PlanSafetyService] Plan ID %d review outcome: %s (%d adjustments).", plan.ID, review.Outcome, len(review.Adjustments))
+	return review, nil
+}
+
+func (s *planSafetyService) CheckTasks(userID string, tasks []models.PlanTask) ([]models.PlanTask, []models.PlanAdjustment, error) {
+	var riskFlags []string
+	if s.profileService != nil {
+		profile, err := s.profileService.GetLatestProfile(userID)
+		if err != nil {
+			errMsg := fmt.Sprintf("failed to load assessment profile for userID %s", userID)
+			log.Printf("ERROR: [PlanSafetyService] %s: %v", errMsg, err)
+			return nil, nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		if profile != nil {
+			riskFlags = profile.RiskFlags
+		}
+	}
+	result := checkPlanSafety(config.AppConfig.PlanSafety.Rules, riskFlags, tasks)
+	return result.tasks, result.adjustments, nil
+}
+
+// applyReview persists the outcome: a rejected plan keeps its tasks for reference, otherwise adjusted tasks
+// are updated, removed tasks deleted and the plan activated.
+func (s *planSafetyService) applyReview(plan *models.Plan, review *models.PlanReview, result planSafetyResult) error {
+	tasksBefore := plan.Tasks
+	if review.Outcome == models.PlanReviewRejected {
+		plan.Status = models.PlanStatusRejected
+	} else {
+		original := make(map[uint]models.PlanTask, len(plan.Tasks))
+		for _, task := range plan.Tasks {
+			original[task.ID] = task
+		}
+		for _, task := range result.removed {
+			if err := s.planRepo.DeletePlanTask(task.ID, false); err != nil {
+				errMsg := fmt.Sprintf("failed to remove unsafe task ID %d from plan ID %d", task.ID, plan.ID)
+				log.Printf("ERROR: [PlanSafetyService] %s: %v", errMsg, err)
+				return fmt.Errorf("%s: %w", errMsg, err)
+			}
+		}
+		for i := range result.tasks {
+			if result.tasks[i] == original[result.tasks[i].ID] {
+				continue
+			}
+			if err := s.planRepo.UpdatePlanTask(&result.tasks[i]); err != nil {
+				errMsg := fmt.Sprintf("failed to update adjusted task ID %d of plan ID %d", result.tasks[i].ID, plan.ID)
+				log.Printf("ERROR: [PlanSafetyService] %s: %v", errMsg, err)
+				return fmt.Errorf("%s: %w", errMsg, err)
+			}
+		}
+		plan.Tasks = result.tasks
+		plan.Status = models.PlanStatusActive
+	}
+	plan.ReviewNotes = review.Explanation
+	if err := s.planRepo.UpdatePlan(plan); err != nil {
+		plan.Status, plan.ReviewNotes, plan.Tasks = models.PlanStatusPending, "", tasksBefore
+		errMsg := fmt.Sprintf("failed to store review outcome of plan ID %d", plan.ID)
+		log.Printf("ERROR: [PlanSafetyService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return nil
+}
+
+// llmReview asks the safety agent whether the plan is safe for the user.
+func (s *planSafetyService) llmReview(cfg config.PlanLLMReviewConfig, plan *models.Plan, profile *models.AssessmentProfile) (bool, string, error) {
+	model := agentModel(cfg.Model, cfg.AgentID)
+	if model == "" {
+		return false, "", fmt.Errorf("no model configured for safety agent '%s'", cfg.AgentID)
+	}
+	var sb strings.Builder
+	if profile != nil {
+		sb.WriteString(describeProfile(profile))
+	} else {
+		sb.WriteString("[用户健康画像]\n- 暂无评估数据\n")
+	}
+	sb.WriteString(fmt.Sprintf("[待审核计划]《%s》\n", plan.Title))
+	for _, task := range plan.Tasks {
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s（%s，%s）：%s\n", task.Order, task.Type, task.Title, task.Frequency, task.Duration, task.Description))
+	}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: cfg.SystemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: sb.String()},
+	}
+
+	raw, err := s.llm.Complete(model, messages, true)
+	if err != nil {
+		return false, "", err
+	}
+	var verdict struct {
+		Approved *bool  `json:"approved"`
+		Reason   string `json:"reason"`
+	}
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start < 0 || end < start {
+		return false, "", errors.New("no JSON object in safety review output")
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &verdict); err != nil {
+		return false, "", fmt.Errorf("invalid safety review JSON: %w", err)
+	}
+	if verdict.Approved == nil {
+		return false, "", errors.New("safety review output has no approved field")
+	}
+	return *verdict.Approved, strings.TrimSpace(verdict.Reason), nil
+}
+
+func (s *planSafetyService) GetReviews(planID uint) ([]models.PlanReview, error) {
+	if s.reviewRepo == nil {
+		return nil, errors.New("planreviewrepo not initialized")
+	}
+	return s.reviewRepo.GetReviewsByPlanID(planID)
+}
+
+// checkPlanSafety applies the safety rules that concern the user's risk flags to each task, in rule order.
+// An adjusted task is checked against the remaining rules in its adjusted form.
+func checkPlanSafety(rules []config.PlanSafetyRule, riskFlags []string, tasks []models.PlanTask) planSafetyResult {
+	var result planSafetyResult
+	for _, task := range tasks {
+		removed := false
+		for _, rule := range rules {
+			if !safetyRuleApplies(rule, riskFlags) || !safetyRuleMatchesTask(rule, task) {
+				continue
+			}
+			adjustment := models.PlanAdjustment{RuleID: rule.ID, TaskTitle: task.Title, Action: rule.Action, Reason: rule.Reason}
+			switch rule.Action {
+			case "adjust":
+				task = adjustTask(task, rule.Replacement)
+				adjustment.AdjustedTitle = task.Title
+			case "remove":
+				removed = true
+			case "reject":
+				result.rejected = true
+				result.rejectReasons = append(result.rejectReasons, rule.Reason)
+			default:
+				log.Printf("WARN: [PlanSafetyService] Safety rule '%s' has unknown action '%s', ignoring it.", rule.ID, rule.Action)
+				continue
+			}
+			result.adjustments = append(result.adjustments, adjustment)
+			if removed {
+				break
+			}
+		}
+		if removed {
+			result.removed = append(result.removed, task)
+			continue
+		}
+		task.Order = len(result.tasks) + 1
+		result.tasks = append(result.tasks, task)
+	}
+	return result
+}
+
+// safetyRuleApplies reports whether the user has one of the rule's risk flags. A rule without risk flags applies to everyone.
+func safetyRuleApplies(rule config.PlanSafetyRule, riskFlags []string) bool {
+	if len(rule.RiskFlags) == 0 {
+		return true
+	}
+	for _, flag := range rule.RiskFlags {
+		if contains(riskFlags, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// safetyRuleMatchesTask reports whether a task falls under a rule. A rule with neither keywords nor types matches nothing.
+func safetyRuleMatchesTask(rule config.PlanSafetyRule, task models.PlanTask) bool {
+	if len(rule.TaskTypes) > 0 && !contains(rule.TaskTypes, string(task.Type)) {
+		return false
+	}
+	if len(rule.TaskKeywords) == 0 {
+		return len(rule.TaskTypes) > 0
+	}
+	text := strings.ToLower(task.Title + " " + task.Description)
+	for _, keyword := range rule.TaskKeywords {
+		if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// adjustTask replaces the task's content with the non-empty fields of the replacement template.
+func adjustTask(task models.PlanTask, replacement config.PlanTaskTemplate) models.PlanTask {
+	if replacement.Type != "" {
+		task.Type = planTaskType(replacement.Type)
+	}
+	if replacement.Title != "" {
+		task.Title = replacement.Title
+	}
+	if replacement.Description != "" {
+		task.Description = replacement.Description
+	}
+	if replacement.Frequency != "" {
+		task.Frequency = replacement.Frequency
+		task.Recurrence = ParseFrequency(replacement.Frequency)
+	}
+	if replacement.Duration != "" {
+		task.Duration = replacement.Duration
+	}
+	return task
+}
+
+// reviewNewPlan runs the safety review on a freshly created plan and records its outcome as a plan version.
+// Without a reviewer, or if the review fails, the plan stays pending rather than becoming active unchecked;
+// PendingPlanReviewJob retries the review later. An activated plan may pause the user's older active plans.
+func reviewNewPlan(safety PlanSafetyService, planRepo repository.PlanRepository, versionRepo repository.PlanVersionRepository, plan *models.Plan) {
+	if safety == nil {
+		log.Printf("WARN: [PlanSafetyService] No safety review configured; plan ID %d stays pending.", plan.ID)
+		return
+	}
+	review, err := safety.ReviewPlan(plan)
+	if err != nil {
+		log.Printf("ERROR: [PlanSafetyService] Safety review of plan ID %d failed; it stays pending: %v", plan.ID, err)
+		return
+	}
+	reason := review.Explanation
+	if reason == "" {
+		reason = "计划已通过安全审核。"
+	}
+	recordPlanVersion(versionRepo, plan, models.PlanVersionAuthorSafetyReview, "", reason)
+	if plan.Status == models.PlanStatusActive {
+		enforceActivePlanLimit(planRepo, versionRepo, plan)
+	}
+}
+
+// pendingReviewGrace keeps PendingPlanReviewJob away from plans whose first review may still be running.
+const pendingReviewGrace = 5 * time.Minute
+
+// PendingPlanReviewJob returns the background job that retries the safety review of plans left pending because
+// their review failed, registered with the JobRunner on the plan_safety.retry_cron schedule.
+func PendingPlanReviewJob(safety PlanSafetyService, planRepo repository.PlanRepository, versionRepo repository.PlanVersionRepository) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		plans, err := planRepo.GetPlansByStatus(models.PlanStatusPending)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		reviewed, pending := 0, 0
+		for _, plan := range plans {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if now.Sub(plan.UpdatedAt) < pendingReviewGrace {
+				continue
+			}
+			reviewNewPlan(safety, planRepo, versionRepo, plan)
+			if plan.Status == models.PlanStatusPending {
+				pending++
+				continue
+			}
+			reviewed++
+		}
+		out.Printf("%d pending plans reviewed, %d still pending.", reviewed, pending)
+		return nil
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPlanSafetyService is a mock type for the PlanSafetyService interface
+type MockPlanSafetyService struct {
+	mock.Mock
+}
+
+func (m *MockPlanSafetyService) ReviewPlan(plan *models.Plan) (*models.PlanReview, error) {
+	args := m.Called(plan)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PlanReview), args.Error(1)
+}
+
+func (m *MockPlanSafetyService) CheckTasks(userID string, tasks []models.PlanTask) ([]models.PlanTask, []models.PlanAdjustment, error) {
+	args := m.Called(userID, tasks)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	adjustments, _ := args.Get(1).([]models.PlanAdjustment)
+	return args.Get(0).([]models.PlanTask), adjustments, args.Error(2)
+}
+
+func (m *MockPlanSafetyService) GetReviews(planID uint) ([]models.PlanReview, error) {
+	args := m.Called(planID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.PlanReview), args.Error(1)
+}
+
+// MockPlanReviewRepository is a mock type for the PlanReviewRepository interface
+type MockPlanReviewRepository struct {
+	mock.Mock
+}
+
+func (m *MockPlanReviewRepository) CreateReview(review *models.PlanReview) error {
+	args := m.Called(review)
+	return args.Error(0)
+}
+
+func (m *MockPlanReviewRepository) GetReviewsByPlanID(planID uint) ([]models.PlanReview, error) {
+	args := m.Called(planID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.PlanReview), args.Error(1)
+}
+
+// testSafetyRules mirrors the relevant part of config.yaml.
+func testSafetyRules() []config.PlanSafetyRule {
+	return []config.PlanSafetyRule{
+		{ID: "hypertension_high_intensity_cardio", RiskFlags: []string{"hypertension"}, TaskTypes: []string{"exercise"},
+			TaskKeywords: []string{"高强度", "HIIT"}, Action: "adjust", Reason: "高血压不宜高强度有氧。",
+			Replacement: config.PlanTaskTemplate{Title: "中低强度有氧运动", Description: "快走为主"}},
+		{ID: "hypertension_heavy_lifting", RiskFlags: []string{"hypertension"}, TaskKeywords: []string{"大重量"}, Action: "remove", Reason: "大重量训练升高血压。"},
+		{ID: "nitrate_pde5_inhibitor", RiskFlags: []string{"nitrate_medication"}, TaskKeywords: []string{"西地那非"}, Action: "reject", Reason: "硝酸酯类药物禁止合用。"},
+	}
+}
+
+func testSafetyPlan() *models.Plan {
+	return &models.Plan{ID: 5, UserID: "safetyUser", Title: "体能计划", Status: models.PlanStatusPending, Tasks: []models.PlanTask{
+		{ID: 51, PlanID: 5, Type: models.TaskTypeExercise, Title: "HIIT 间歇训练", Frequency: "每周3次", Order: 1},
+		{ID: 52, PlanID: 5, Type: models.TaskTypeExercise, Title: "大重量深蹲", Frequency: "每周2次", Order: 2},
+		{ID: 53, PlanID: 5, Type: models.TaskTypeHabit, Title: "健康饮水", Frequency: "每日", Order: 3},
+	}}
+}
+
+func TestCheckPlanSafety(t *testing.T) {
+	t.Run("Hypertension adjusts high-intensity cardio and removes heavy lifting", func(t *testing.T) {
+		result := checkPlanSafety(testSafetyRules(), []string{"hypertension"}, testSafetyPlan().Tasks)
+
+		assert.False(t, result.rejected)
+		assert.Len(t, result.tasks, 2)
+		assert.Equal(t, "中低强度有氧运动", result.tasks[0].Title)
+		assert.Equal(t, "每周3次", result.tasks[0].Frequency) // Empty replacement fields keep the original
+		assert.Equal(t, "健康饮水", result.tasks[1].Title)
+		assert.Equal(t, 2, result.tasks[1].Order)
+		assert.Len(t, result.removed, 1)
+		assert.Equal(t, uint(52), result.removed[0].ID)
+		assert.Len(t, result.adjustments, 2)
+		assert.Equal(t, "HIIT 间歇训练", result.adjustments[0].TaskTitle)
+		assert.Equal(t, "中低强度有氧运动", result.adjustments[0].AdjustedTitle)
+	})
+
+	t.Run("Rules without a matching risk flag do not apply", func(t *testing.T) {
+		result := checkPlanSafety(testSafetyRules(), nil, testSafetyPlan().Tasks)
+
+		assert.Len(t, result.tasks, 3)
+		assert.Empty(t, result.adjustments)
+	})
+
+	t.Run("Reject rules reject the plan", func(t *testing.T) {
+		tasks := []models.PlanTask{{ID: 1, Type: models.TaskTypeGeneric, Title: "按需服用西地那非"}}
+
+		result := checkPlanSafety(testSafetyRules(), []string{"nitrate_medication"}, tasks)
+
+		assert.True(t, result.rejected)
+		assert.Equal(t, []string{"硝酸酯类药物禁止合用。"}, result.rejectReasons)
+	})
+}
+
+func TestPlanSafetyService_ReviewPlan(t *testing.T) {
+	config.AppConfig.PlanSafety = config.PlanSafetyConfig{Rules: testSafetyRules()}
+
+	t.Run("Adjusted plan is activated and the review logged", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockReviewRepo := new(MockPlanReviewRepository)
+		mockProfileRepo := new(MockAssessmentProfileRepository)
+		service := NewPlanSafetyService(mockPlanRepo, mockReviewRepo, NewAssessmentProfileService(mockProfileRepo, nil), nil)
+		plan := testSafetyPlan()
+
+		mockProfileRepo.On("GetLatestProfileByUserID", "safetyUser").Return(&models.AssessmentProfile{RiskFlags: []string{"hypertension"}}, nil).Once()
+		mockPlanRepo.On("DeletePlanTask", uint(52), false).Return(nil).Once()
+		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 51 && task.Title == "中低强度有氧运动" })).Return(nil).Once()
+		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 53 && task.Order == 2 })).Return(nil).Once()
+		mockPlanRepo.On("UpdatePlan", mock.MatchedBy(func(p *models.Plan) bool {
+			return p.Status == models.PlanStatusActive && len(p.Tasks) == 2 && p.ReviewNotes != ""
+		})).Return(nil).Once()
+		mockReviewRepo.On("CreateReview", mock.MatchedBy(func(r *models.PlanReview) bool {
+			return r.PlanID == 5 && r.Outcome == models.PlanReviewAdjusted && len(r.Adjustments) == 2
+		})).Return(nil).Once()
+
+		review, err := service.ReviewPlan(plan)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PlanReviewAdjusted, review.Outcome)
+		assert.Contains(t, review.Explanation, "高血压不宜高强度有氧。")
+		assert.Equal(t, models.PlanStatusActive, plan.Status)
+		mockPlanRepo.AssertExpectations(t)
+		mockReviewRepo.AssertExpectations(t)
+	})
+
+	t.Run("Plan without findings is approved unchanged", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockReviewRepo := new(MockPlanReviewRepository)
+		mockProfileRepo := new(MockAssessmentProfileRepository)
+		service := NewPlanSafetyService(mockPlanRepo, mockReviewRepo, NewAssessmentProfileService(mockProfileRepo, nil), nil)
+		plan := testSafetyPlan()
+
+		mockProfileRepo.On("GetLatestProfileByUserID", "safetyUser").Return(nil, nil).Once()
+		mockPlanRepo.On("UpdatePlan", mock.MatchedBy(func(p *models.Plan) bool { return p.Status == models.PlanStatusActive })).Return(nil).Once()
+		mockReviewRepo.On("CreateReview", mock.AnythingOfType("*models.PlanReview")).Return(nil).Once()
+
+		review, err := service.ReviewPlan(plan)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PlanReviewApproved, review.Outcome)
+		assert.Empty(t, plan.ReviewNotes)
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlanTask", mock.Anything)
+	})
+
+	t.Run("LLM review can reject the plan", func(t *testing.T) {
+		config.AppConfig.PlanSafety.LLMReview = config.PlanLLMReviewConfig{Enabled: true, Model: "safety-model", SystemPrompt: "审核计划"}
+		defer func() { config.AppConfig.PlanSafety.LLMReview = config.PlanLLMReviewConfig{} }()
+		mockPlanRepo := new(MockPlanRepository)
+		mockReviewRepo := new(MockPlanReviewRepository)
+		mockLLM := new(MockLLMClient)
+		service := NewPlanSafetyService(mockPlanRepo, mockReviewRepo, nil, mockLLM)
+		plan := testSafetyPlan()
+
+		mockLLM.On("Complete", "safety-model", mock.Anything, true).Return(`{"approved": false, "reason": "训练量对新手过大"}`, nil).Once()
+		mockPlanRepo.On("UpdatePlan", mock.MatchedBy(func(p *models.Plan) bool { return p.Status == models.PlanStatusRejected })).Return(nil).Once()
+		mockReviewRepo.On("CreateReview", mock.MatchedBy(func(r *models.PlanReview) bool { return r.LLMReviewed && r.Outcome == models.PlanReviewRejected })).Return(nil).Once()
+
+		review, err := service.ReviewPlan(plan)
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PlanReviewRejected, review.Outcome)
+		assert.Equal(t, "计划未通过安全审核：训练量对新手过大", plan.ReviewNotes)
+		assert.Len(t, plan.Tasks, 3) // Rejected plans keep their tasks for reference
+		mockLLM.AssertExpectations(t)
+		mockReviewRepo.AssertExpectations(t)
+	})
+
+	t.Run("Failure to store the outcome leaves the plan pending", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanSafetyService(mockPlanRepo, nil, nil, nil)
+		plan := testSafetyPlan()
+
+		mockPlanRepo.On("UpdatePlan", mock.AnythingOfType("*models.Plan")).Return(errors.New("DB error")).Once()
+
+		review, err := service.ReviewPlan(plan)
+
+		assert.Error(t, err)
+		assert.Nil(t, review)
+		assert.Equal(t, models.PlanStatusPending, plan.Status)
+	})
+}
+
+func TestPendingPlanReviewJob(t *testing.T) {
+	original := config.AppConfig.PlanLifecycle
+	config.AppConfig.PlanLifecycle = config.PlanLifecycleConfig{}
+	defer func() { config.AppConfig.PlanLifecycle = original }()
+
+	stale := time.Now().Add(-time.Hour)
+	reviewable := &models.Plan{ID: 1, UserID: "safetyUser", Status: models.PlanStatusPending, UpdatedAt: stale}
+	failing := &models.Plan{ID: 2, UserID: "safetyUser", Status: models.PlanStatusPending, UpdatedAt: stale}
+	fresh := &models.Plan{ID: 3, UserID: "safetyUser", Status: models.PlanStatusPending, UpdatedAt: time.Now()} // First review may still run
+	mockPlanRepo := new(MockPlanRepository)
+	mockSafety := new(MockPlanSafetyService)
+	mockPlanRepo.On("GetPlansByStatus", models.PlanStatusPending).Return([]*models.Plan{reviewable, failing, fresh}, nil).Once()
+	mockSafety.On("ReviewPlan", reviewable).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.Plan).Status = models.PlanStatusActive
+	}).Return(&models.PlanReview{PlanID: 1, Outcome: models.PlanReviewApproved}, nil).Once()
+	mockSafety.On("ReviewPlan", failing).Return(nil, errors.New("profile store unavailable")).Once()
+
+	out := &JobLog{job: "pending_plan_reviews"}
+	err := PendingPlanReviewJob(mockSafety, mockPlanRepo, nil)(context.Background(), out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.PlanStatusActive, reviewable.Status)
+	assert.Equal(t, models.PlanStatusPending, failing.Status)
+	assert.Contains(t, out.String(), "1 pending plans reviewed, 1 still pending.")
+	mockSafety.AssertNotCalled(t, "ReviewPlan", fresh)
+	mockSafety.AssertExpectations(t)
+	mockPlanRepo.AssertExpectations(t)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"project/config"
 	"project/models"
 	"project/repository"
 	"time"
@@ -14,24 +15,52 @@ type PlanService interface {
 	GeneratePlan(userID string) (*models.Plan, error)
 	GetPlanDetails(planID uint) (*models.Plan, error)
 	GetActivePlanForUser(userID string) (*models.Plan, error) // Returns the first active plan found
-	MarkTaskCompleted(taskID uint, userID string) (*models.PlanTask, error) // Added userID for authorization
-	MarkTaskSkipped(taskID uint, userID string) (*models.PlanTask, error)   // Added userID for authorization
+	MarkTaskCompleted(taskID uint, userID string, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error) // Checks in today's occurrence; userID for authorization
+	MarkTaskSkipped(taskID uint, userID string, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error)   // Checks in today's occurrence; userID for authorization
+	MarkTaskPartial(taskID uint, userID string, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error)   // Checks in today's occurrence; userID for authorization
+	GetDueTasks(planID uint, timezone string, now time.Time) ([]models.DueTask, error) // Tasks due on now's date in the user's timezone
+	GetCheckIns(planID uint) ([]models.TaskCheckIn, error)                              // Check-in history of a plan
+	GetPlanProgress(planID uint) (*models.PlanProgress, error)                          // Check-in report of a plan
+
+	// Lifecycle management; userID must own the plan
+	UpdatePlan(planID uint, userID string, input models.PlanUpdateInput) (*models.Plan, error)
+	AddTask(planID uint, userID string, input models.PlanTaskInput) (*models.PlanTask, error)
+	UpdateTask(taskID uint, userID string, input models.PlanTaskInput) (*models.PlanTask, error)
+	ReorderTasks(planID uint, userID string, taskIDs []uint) (*models.Plan, error)
+	DeleteTask(taskID uint, userID string) error
+	TransitionPlan(planID uint, userID string, to models.PlanStatus) (*models.Plan, error) // Pause, resume, cancel or complete
+	ArchivePlan(planID uint, userID string) error
+
+	// Version history; every change above records a new version
+	GetPlanVersions(planID uint) ([]models.PlanVersion, error)
+	DiffPlanVersions(planID uint, from, to int) (*models.PlanVersionDiff, error)
+	RollbackPlan(planID uint, userID string, version int, reason string) (*models.Plan, error) // userID must own the plan
 }
 
 type planService struct {
 	planRepo       repository.PlanRepository
-	assessmentRepo repository.AssessmentRepository // To fetch assessment results
+	assessmentRepo repository.AssessmentRepository  // To fetch assessment results
+	safetyService  PlanSafetyService                // Reviews new plans before they become active
+	checkInRepo    repository.CheckInRepository     // Per-occurrence check-ins of plan tasks
+	versionRepo    repository.PlanVersionRepository // Snapshots of every plan change; optional
+	exerciseRepo   repository.ExerciseRepository    // Exercises that exercise tasks follow; optional
 }
 
 // NewPlanService creates a new instance of PlanService.
-func NewPlanService(planRepo repository.PlanRepository, assessmentRepo repository.AssessmentRepository) PlanService {
+func NewPlanService(planRepo repository.PlanRepository, assessmentRepo repository.AssessmentRepository, safetyService PlanSafetyService, checkInRepo repository.CheckInRepository, versionRepo repository.PlanVersionRepository, exerciseRepo repository.ExerciseRepository) PlanService {
 	return &planService{
 		planRepo:       planRepo,
 		assessmentRepo: assessmentRepo,
+		safetyService:  safetyService,
+		checkInRepo:    checkInRepo,
+		versionRepo:    versionRepo,
+		exerciseRepo:   exerciseRepo,
 	}
 }
 
-// GeneratePlan creates a new plan for a user based on simplified logic or assessment data.
+// GeneratePlan creates a new plan for a user from their latest completed assessment.
+// Tasks come from the declarative rules in config.AppConfig.PlanRules; users without a
+// completed assessment receive only the base templates. The plan is saved as pending and then passes the safety review.
 func (s *planService) GeneratePlan(userID string) (*models.Plan, error) {
 	if userID == "" {
 		log.Printf("WARN: [PlanService] GeneratePlan called with empty userID.")
@@ -39,62 +68,49 @@ func (s *planService) GeneratePlan(userID string) (*models.Plan, error) {
 	}
 	log.Printf("INFO: [PlanService] Attempting to generate plan for userID: %s", userID)
 
-	// Placeholder: Fetch user's completed assessment.
-	// For now, we'll simulate this. Assume assessmentRepo has a method like:
-	// assessment, err := s.assessmentRepo.GetLatestCompletedAssessment(userID)
-	// if err != nil { log.Printf("WARN: [PlanService] Error fetching assessment for plan generation for userID %s: %v", userID, err) }
-	// if assessment == nil { log.Printf("INFO: [PlanService] No completed assessment found for userID %s for plan generation.", userID) }
-
-	// Simplified plan generation logic:
-	planTitle := "我的健康提升计划"
-	planDescription := "一个旨在改善整体健康的初步计划。"
-	tasks := []models.PlanTask{}
-
-	// Example rule: If assessment indicates interest in "凯格尔运动" (Kegel exercises)
-	// This is a placeholder. Real logic would parse `assessment.Results` or `assessment.Goals`.
-	// For now, let's add a default set of tasks.
-	tasks = append(tasks, models.PlanTask{
-		Type:        models.TaskTypeExercise,
-		Title:       "凯格尔运动",
-		Description: "每日进行凯格尔运动，强化盆底肌。",
-		Frequency:   "每日2次",
-		Duration:    "每次5分钟",
-		Status:      models.TaskStatusPending,
-		Order:       1,
-	})
-	tasks = append(tasks, models.PlanTask{
-		Type:        models.TaskTypeHabit,
-		Title:       "健康饮水",
-		Description: "确保每日饮用足够的水（约8杯）。",
-		Frequency:   "每日",
-		Duration:    "全天",
-		Status:      models.TaskStatusPending,
-		Order:       2,
-	})
-	tasks = append(tasks, models.PlanTask{
-		Type:        models.TaskTypeKnowledge,
-		Title:       "了解健康饮食",
-		Description: "阅读一篇关于均衡饮食的文章。",
-		Frequency:   "本周一次",
-		Duration:    "约15分钟阅读",
-		Status:      models.TaskStatusPending,
-		Order:       3,
-	})
+	assessment, err := s.assessmentRepo.GetUserAssessmentByUserID(userID, models.AssessmentStatusCompleted)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch completed assessment for userID %s", userID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if assessment == nil {
+		log.Printf("INFO: [PlanService] No completed assessment found for userID %s, using base plan templates only.", userID)
+	}
+
+	rules := config.AppConfig.PlanRules
+	tasks, firedRules := applyPlanRules(rules, getDefaultAssessmentQuestions(), assessment)
+	if len(tasks) == 0 {
+		log.Printf("ERROR: [PlanService] No plan tasks produced for userID %s; check plan_rules templates in config.", userID)
+		return nil, errors.New("no plan task templates configured")
+	}
+	log.Printf("INFO: [PlanService] Plan rules fired for userID %s: %v", userID, firedRules)
+
+	planTitle := rules.PlanTitle
+	if planTitle == "" {
+		planTitle = "我的健康提升计划"
+	}
 
 	newPlan := &models.Plan{
 		UserID:      userID,
 		Title:       planTitle,
-		Description: planDescription,
-		Status:      models.PlanStatusActive, // Start as active
-		Tasks:       tasks,                  // GORM will create these associated tasks
+		Description: rules.PlanDescription,
+		Status:      models.PlanStatusPending, // Activated by the safety review
+		FiredRules:  firedRules,
+		Tasks:       tasks, // GORM will create these associated tasks
+	}
+	if assessment != nil {
+		newPlan.SourceAssessmentID = &assessment.ID
 	}
 
-	err := s.planRepo.CreatePlan(newPlan)
+	err = s.planRepo.CreatePlan(newPlan)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to create plan for userID %s", userID)
 		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
 		return nil, fmt.Errorf("%s: %w", errMsg, err)
 	}
+	recordPlanVersion(s.versionRepo, newPlan, models.PlanVersionAuthorSystem, "", "根据评估结果生成计划")
+	reviewNewPlan(s.safetyService, s.planRepo, s.versionRepo, newPlan)
 
 	log.Printf("INFO: [PlanService] Successfully generated plan ID %d for userID %s with %d tasks.", newPlan.ID, newPlan.UserID, len(newPlan.Tasks))
 	return newPlan, nil
@@ -137,108 +153,244 @@ func (s *planService) GetActivePlanForUser(userID string) (*models.Plan, error)
 	return nil, nil // No active plan found is not an error in itself
 }
 
-// MarkTaskCompleted marks a task as completed.
-func (s *planService) MarkTaskCompleted(taskID uint, userID string) (*models.PlanTask, error) {
+// MarkTaskCompleted checks in today's next open occurrence of a task as completed.
+// Tasks that occur only once are marked completed as a whole as well.
+func (s *planService) MarkTaskCompleted(taskID uint, userID string, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error) {
 	log.Printf("INFO: [PlanService] UserID '%s' attempting to mark taskID %d as completed.", userID, taskID)
-	task, err := s.planRepo.GetTaskByID(taskID)
+	return s.checkInTask(taskID, userID, models.TaskStatusCompleted, input, now)
+}
+
+// MarkTaskSkipped checks in today's next occurrence of a task that has no check-in yet as skipped.
+func (s *planService) MarkTaskSkipped(taskID uint, userID string, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error) {
+	log.Printf("INFO: [PlanService] UserID '%s' attempting to mark taskID %d as skipped.", userID, taskID)
+	return s.checkInTask(taskID, userID, models.TaskStatusSkipped, input, now)
+}
+
+// MarkTaskPartial checks in today's next open occurrence of a task as partially completed.
+// input.CompletedAmount is required and, if input.TargetAmount is given, must be below it.
+func (s *planService) MarkTaskPartial(taskID uint, userID string, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error) {
+	log.Printf("INFO: [PlanService] UserID '%s' attempting to mark taskID %d as partially completed.", userID, taskID)
+	return s.checkInTask(taskID, userID, models.TaskStatusPartial, input, now)
+}
+
+// checkInTask records a completed, partial or skipped check-in for the occurrence of a task due today in the user's timezone.
+func (s *planService) checkInTask(taskID uint, userID string, status models.TaskStatus, input models.CheckInInput, now time.Time) (*models.TaskCheckIn, error) {
+	if err := validateCheckIn(status, input); err != nil {
+		log.Printf("WARN: [PlanService] Rejected %s check-in of taskID %d by userID '%s': %v", status, taskID, userID, err)
+		return nil, err
+	}
+	task, plan, err := s.getOwnedTask(taskID, userID)
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to fetch task ID %d for completion", taskID)
-		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
-		return nil, fmt.Errorf("%s: %w", errMsg, err)
+		return nil, err
 	}
-	if task == nil {
-		log.Printf("WARN: [PlanService] Task with ID %d not found for completion attempt by userID '%s'.", taskID, userID)
-		return nil, fmt.Errorf("task with ID %d not found", taskID)
+	if plan.Status != models.PlanStatusActive {
+		log.Printf("WARN: [PlanService] UserID '%s' attempted to check in taskID %d of plan ID %d, which is %s.", userID, taskID, plan.ID, plan.Status)
+		return nil, fmt.Errorf("plan %d is not active", plan.ID)
 	}
 
-	plan, err := s.planRepo.GetPlanByID(task.PlanID)
+	checkIns, err := s.checkInRepo.GetCheckInsByTaskID(taskID)
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to fetch plan ID %d for task ID %d (userID '%s')", task.PlanID, taskID, userID)
+		errMsg := fmt.Sprintf("failed to fetch check-ins of task ID %d", taskID)
 		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
 		return nil, fmt.Errorf("%s: %w", errMsg, err)
 	}
-	if plan == nil { // Should not happen if task exists and has valid PlanID, but good check
-		log.Printf("ERROR: [PlanService] Plan not found for task ID %d (PlanID: %d), userID '%s'. Data integrity issue?", taskID, task.PlanID, userID)
-		return nil, fmt.Errorf("plan associated with task %d not found", taskID)
-	}
 
-	if plan.UserID != userID {
-		log.Printf("WARN: [PlanService] Unauthorized attempt by userID '%s' to complete taskID %d (belongs to userID '%s').", userID, taskID, plan.UserID)
-		return nil, fmt.Errorf("unauthorized to modify task %d", taskID) // Specific error for authorization
+	today := now.In(LoadUserLocation(input.Timezone))
+	date := today.Format("2006-01-02")
+	rec := taskRecurrence(task)
+	occurrences := taskOccurrences(task, rec, checkIns, today)
+	if len(occurrences) == 0 {
+		log.Printf("WARN: [PlanService] TaskID %d is not due on %s; check-in by userID '%s' refused.", taskID, date, userID)
+		return nil, fmt.Errorf("task %d is not due on %s", taskID, date)
 	}
 
-	if task.Status == models.TaskStatusCompleted {
-		log.Printf("INFO: [PlanService] TaskID %d is already completed. No action taken for userID '%s'.", taskID, userID)
-		return task, nil
+	occurrence := nextOccurrence(occurrences, status)
+	if occurrence == nil {
+		for i := len(occurrences) - 1; i >= 0; i-- {
+			if occurrences[i].Status == status {
+				log.Printf("INFO: [PlanService] Today's occurrences of taskID %d are already %s. No action taken for userID '%s'.", taskID, status, userID)
+				return occurrences[i].CheckIn, nil
+			}
+		}
+		log.Printf("WARN: [PlanService] UserID '%s' attempted to mark already completed taskID %d on %s as %s.", userID, taskID, date, status)
+		if status == models.TaskStatusSkipped {
+			return nil, errors.New("cannot skip an already completed task")
+		}
+		return nil, errors.New("cannot record partial progress on an already completed task")
 	}
 
-	task.Status = models.TaskStatusCompleted
-	task.IsCompleted = true
-	now := time.Now()
-	task.CompletedAt.Time = now
-	task.CompletedAt.Valid = true
-
-
-	err = s.planRepo.UpdatePlanTask(task)
+	checkIn := occurrence.CheckIn
+	if checkIn == nil {
+		checkIn = &models.TaskCheckIn{TaskID: task.ID, PlanID: plan.ID, UserID: userID, DueDate: date, Slot: occurrence.Slot}
+	}
+	checkIn.Status = status
+	checkIn.CheckedAt = now
+	if input.Note != "" {
+		checkIn.Note = input.Note
+	}
+	if input.CompletedAmount > 0 {
+		checkIn.CompletedAmount = input.CompletedAmount
+	}
+	if input.TargetAmount > 0 {
+		checkIn.TargetAmount = input.TargetAmount
+	}
+	if input.Feedback != nil {
+		checkIn.Feedback = input.Feedback
+	}
+	if checkIn.ID == 0 {
+		err = s.checkInRepo.CreateCheckIn(checkIn)
+	} else {
+		err = s.checkInRepo.UpdateCheckIn(checkIn)
+	}
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to update task ID %d to completed for userID '%s'", taskID, userID)
+		errMsg := fmt.Sprintf("failed to record %s check-in of task ID %d for userID '%s'", status, taskID, userID)
 		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
 		return nil, fmt.Errorf("%s: %w", errMsg, err)
 	}
 
-	log.Printf("INFO: [PlanService] TaskID %d marked as completed for userID '%s'.", taskID, userID)
-	return task, nil
+	if status == models.TaskStatusCompleted && rec.Kind == models.RecurrenceOnce {
+		task.Status = models.TaskStatusCompleted
+		task.IsCompleted = true
+		task.CompletedAt.Time = now
+		task.CompletedAt.Valid = true
+		if err := s.planRepo.UpdatePlanTask(task); err != nil {
+			errMsg := fmt.Sprintf("failed to update task ID %d to completed for userID '%s'", taskID, userID)
+			log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+	}
+	if status == models.TaskStatusCompleted {
+		s.completePlanIfDone(plan, task, today)
+	}
+
+	log.Printf("INFO: [PlanService] TaskID %d occurrence %d on %s marked as %s for userID '%s'.", taskID, checkIn.Slot, date, status, userID)
+	return checkIn, nil
 }
 
-// MarkTaskSkipped marks a task as skipped.
-func (s *planService) MarkTaskSkipped(taskID uint, userID string) (*models.PlanTask, error) {
-	log.Printf("INFO: [PlanService] UserID '%s' attempting to mark taskID %d as skipped.", userID, taskID)
+// getOwnedTask fetches a task and its plan, making sure the plan belongs to userID.
+func (s *planService) getOwnedTask(taskID uint, userID string) (*models.PlanTask, *models.Plan, error) {
 	task, err := s.planRepo.GetTaskByID(taskID)
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to fetch task ID %d for skipping", taskID)
+		errMsg := fmt.Sprintf("failed to fetch task ID %d", taskID)
 		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
-		return nil, fmt.Errorf("%s: %w", errMsg, err)
+		return nil, nil, fmt.Errorf("%s: %w", errMsg, err)
 	}
 	if task == nil {
-		log.Printf("WARN: [PlanService] Task with ID %d not found for skip attempt by userID '%s'.", taskID, userID)
-		return nil, fmt.Errorf("task with ID %d not found", taskID)
+		log.Printf("WARN: [PlanService] Task with ID %d not found (userID '%s').", taskID, userID)
+		return nil, nil, fmt.Errorf("task with ID %d not found", taskID)
 	}
 
 	plan, err := s.planRepo.GetPlanByID(task.PlanID)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to fetch plan ID %d for task ID %d (userID '%s')", task.PlanID, taskID, userID)
 		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
-		return nil, fmt.Errorf("%s: %w", errMsg, err)
+		return nil, nil, fmt.Errorf("%s: %w", errMsg, err)
 	}
-	if plan == nil {
+	if plan == nil { // Should not happen if task exists and has valid PlanID, but good check
 		log.Printf("ERROR: [PlanService] Plan not found for task ID %d (PlanID: %d), userID '%s'. Data integrity issue?", taskID, task.PlanID, userID)
-		return nil, fmt.Errorf("plan associated with task %d not found", taskID)
+		return nil, nil, fmt.Errorf("plan associated with task %d not found", taskID)
 	}
 
 	if plan.UserID != userID {
-		log.Printf("WARN: [PlanService] Unauthorized attempt by userID '%s' to skip taskID %d (belongs to userID '%s').", userID, taskID, plan.UserID)
-		return nil, fmt.Errorf("unauthorized to modify task %d", taskID)
+		log.Printf("WARN: [PlanService] Unauthorized attempt by userID '%s' to modify taskID %d (belongs to userID '%s').", userID, taskID, plan.UserID)
+		return nil, nil, fmt.Errorf("unauthorized to modify task %d", taskID) // Specific error for authorization
 	}
+	return task, plan, nil
+}
 
-	if task.Status == models.TaskStatusSkipped {
-		log.Printf("INFO: [PlanService] TaskID %d is already skipped. No action taken for userID '%s'.", taskID, userID)
-		return task, nil
+// GetCheckIns returns the check-in history of all tasks of a plan.
+func (s *planService) GetCheckIns(planID uint) ([]models.TaskCheckIn, error) {
+	checkIns, err := s.checkInRepo.GetCheckInsByPlanID(planID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch check-ins for plan ID %d", planID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
 	}
-	if task.Status == models.TaskStatusCompleted {
-		log.Printf("WARN: [PlanService] UserID '%s' attempted to skip already completed taskID %d.", userID, taskID)
-		return nil, errors.New("cannot skip an already completed task")
+	return checkIns, nil
+}
+
+// GetPlanProgress summarizes the check-ins of a plan: counts by status, completion rate and feedback averages,
+// overall and per task.
+func (s *planService) GetPlanProgress(planID uint) (*models.PlanProgress, error) {
+	plan, err := s.GetPlanDetails(planID)
+	if err != nil {
+		return nil, err
+	}
+	checkIns, err := s.GetCheckIns(planID)
+	if err != nil {
+		return nil, err
+	}
+	return buildPlanProgress(plan, checkIns), nil
+}
+
+// GetDueTasks returns the tasks of an active plan that are due on the calendar day of now in the user's timezone,
+// with the check-in state of each of today's occurrences.
+func (s *planService) GetDueTasks(planID uint, timezone string, now time.Time) ([]models.DueTask, error) {
+	plan, err := s.GetPlanDetails(planID)
+	if err != nil {
+		return nil, err
+	}
+	due := []models.DueTask{}
+	if plan.Status != models.PlanStatusActive {
+		log.Printf("INFO: [PlanService] Plan ID %d is %s; no tasks are due.", planID, plan.Status)
+		return due, nil
+	}
+	checkIns, err := s.GetCheckIns(planID)
+	if err != nil {
+		return nil, err
+	}
+	checkInsByTask := make(map[uint][]models.TaskCheckIn)
+	for _, checkIn := range checkIns {
+		checkInsByTask[checkIn.TaskID] = append(checkInsByTask[checkIn.TaskID], checkIn)
 	}
 
-	task.Status = models.TaskStatusSkipped
-	task.IsCompleted = false 
-	task.CompletedAt.Valid = false 
+	loc := LoadUserLocation(timezone)
+	today := now.In(loc)
+	for i := range plan.Tasks {
+		task := &plan.Tasks[i]
+		if task.Status == models.TaskStatusSkipped { // Skipped as a whole before check-ins existed
+			continue
+		}
+		rec := taskRecurrence(task)
+		occurrences := taskOccurrences(task, rec, checkInsByTask[task.ID], today)
+		if len(occurrences) == 0 {
+			continue
+		}
+		due = append(due, models.DueTask{
+			TaskID:      task.ID,
+			PlanID:      plan.ID,
+			Title:       task.Title,
+			Type:        task.Type,
+			Date:        today.Format("2006-01-02"),
+			Times:       len(occurrences),
+			Windows:     rec.Windows,
+			Occurrences: occurrences,
+		})
+	}
+	log.Printf("INFO: [PlanService] %d tasks of plan ID %d are due on %s (%s).", len(due), planID, today.Format("2006-01-02"), loc)
+	return due, nil
+}
 
-	err = s.planRepo.UpdatePlanTask(task)
+// BackfillTaskRecurrences parses the Frequency text of tasks created before structured recurrences existed.
+// Tasks whose text is not understood keep a nil Recurrence and are retried on the next run.
+func BackfillTaskRecurrences(planRepo repository.PlanRepository) (int, error) {
+	tasks, err := planRepo.GetTasksWithoutRecurrence()
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to update task ID %d to skipped for userID '%s'", taskID, userID)
-		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
-		return nil, fmt.Errorf("%s: %w", errMsg, err)
+		return 0, err
+	}
+	migrated := 0
+	for _, task := range tasks {
+		rec := ParseFrequency(task.Frequency)
+		if rec == nil {
+			log.Printf("WARN: [PlanService] Could not parse frequency '%s' of task ID %d; leaving it unstructured.", task.Frequency, task.ID)
+			continue
+		}
+		task.Recurrence = rec
+		if err := planRepo.UpdatePlanTask(task); err != nil {
+			return migrated, err
+		}
+		migrated++
 	}
-	log.Printf("INFO: [PlanService] TaskID %d marked as skipped for userID '%s'.", taskID, userID)
-	return task, nil
+	log.Printf("INFO: [PlanService] Backfilled recurrence for %d of %d tasks.", migrated, len(tasks))
+	return migrated, nil
 }
@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"fmt"
+	"project/config"
 	"project/models"
 	"project/repository"
 	"testing"
@@ -38,6 +39,14 @@ func (m *MockPlanRepository) GetPlansByUserID(userID string) ([]*models.Plan, er
 	return args.Get(0).([]*models.Plan), args.Error(1)
 }
 
+func (m *MockPlanRepository) GetPlansByStatus(status models.PlanStatus) ([]*models.Plan, error) {
+	args := m.Called(status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Plan), args.Error(1)
+}
+
 func (m *MockPlanRepository) UpdatePlan(plan *models.Plan) error {
 	args := m.Called(plan)
 	return args.Error(0)
@@ -74,11 +83,24 @@ func (m *MockPlanRepository) UpdatePlanTask(task *models.PlanTask) error {
 	return args.Error(0)
 }
 
+func (m *MockPlanRepository) GetTasksWithoutRecurrence() ([]*models.PlanTask, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PlanTask), args.Error(1)
+}
+
 func (m *MockPlanRepository) DeletePlanTask(taskID uint, hardDelete bool) error {
 	args := m.Called(taskID, hardDelete)
 	return args.Error(0)
 }
 
+func (m *MockPlanRepository) RestorePlanTask(taskID uint) error {
+	args := m.Called(taskID)
+	return args.Error(0)
+}
+
 // MockAssessmentRepository (defined in assessment_service_test.go, re-declared here for plan service tests if needed,
 // or ideally placed in a shared test mocks package)
 // For this exercise, to avoid inter-file generation dependency, we can redefine a minimal version or use the one from assessment_service_test.go
@@ -101,20 +123,98 @@ func (m *MinimalMockAssessmentRepository) GetUserAssessmentByUserID(userID strin
 func (m *MinimalMockAssessmentRepository) CreateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {panic("implement me")}
 func (m *MinimalMockAssessmentRepository) GetUserAssessmentByID(id uint) (*models.UserAssessment, error) {panic("implement me")}
 func (m *MinimalMockAssessmentRepository) UpdateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {panic("implement me")}
+func (m *MinimalMockAssessmentRepository) ListUserAssessments(userID string, statusFilter ...models.UserAssessmentStatus) ([]*models.UserAssessment, error) {panic("implement me")}
+
+
+// testPlanRulesConfig mirrors the relevant part of config.yaml.
+func testPlanRulesConfig() config.PlanRulesConfig {
+	return config.PlanRulesConfig{
+		PlanTitle: "我的健康提升计划",
+		Templates: []config.PlanTaskTemplate{
+			{ID: "kegel_basic", Type: "exercise", Title: "凯格尔运动", Frequency: "每日2次"},
+			{ID: "hydration", Type: "habit", Title: "健康饮水"},
+			{ID: "healthy_diet_reading", Type: "knowledge", Title: "了解健康饮食"},
+			{ID: "beginner_walking", Type: "exercise", Title: "入门快走计划"},
+			{ID: "sleep_hygiene", Type: "habit", Title: "睡眠卫生习惯"},
+			{ID: "pelvic_floor_staged", Type: "exercise", Title: "分阶段盆底肌训练"},
+		},
+		Rules: []config.PlanRule{
+			{ID: "sedentary_beginner_walking", QuestionID: "q_exercise_habits", Answers: []string{"Almost never"}, Templates: []string{"beginner_walking"}},
+			{ID: "poor_sleep_hygiene", QuestionID: "q_sleep_quality", Answers: []string{"Poor, often suffer from insomnia or poor sleep quality"}, Templates: []string{"sleep_hygiene"}},
+			{ID: "premature_ejaculation_pelvic_floor", QuestionID: "q_sex_ability_concerns", Keywords: []string{"Premature ejaculation"}, Templates: []string{"pelvic_floor_staged", "kegel_basic"}},
+		},
+		BaseTemplates: []string{"kegel_basic", "hydration", "healthy_diet_reading"},
+	}
+}
+
+func TestApplyPlanRules(t *testing.T) {
+	rules := testPlanRulesConfig()
+	questions := getDefaultAssessmentQuestions()
+
+	t.Run("Matching answers fire rules and add their templates first", func(t *testing.T) {
+		assessment := &models.UserAssessment{ID: 3, UserID: "rulesUser", Status: models.AssessmentStatusCompleted, Answers: []models.UserAnswer{
+			{QuestionID: "q_exercise_habits", Answer: []string{"a"}}, // Resolved to "Almost never"
+			{QuestionID: "q_sleep_quality", Answer: []string{"Poor, often suffer from insomnia or poor sleep quality"}},
+			{QuestionID: "q_sex_ability_concerns", Answer: []string{"B, C"}},
+		}}
+
+		tasks, fired := applyPlanRules(rules, questions, assessment)
+
+		assert.Equal(t, []string{"sedentary_beginner_walking", "poor_sleep_hygiene", "premature_ejaculation_pelvic_floor"}, fired)
+		titles := make([]string, len(tasks))
+		for i, task := range tasks {
+			titles[i] = task.Title
+			assert.Equal(t, i+1, task.Order)
+			assert.Equal(t, models.TaskStatusPending, task.Status)
+		}
+		// kegel_basic is contributed by a rule and the base templates but appears once
+		assert.Equal(t, []string{"入门快走计划", "睡眠卫生习惯", "分阶段盆底肌训练", "凯格尔运动", "健康饮水", "了解健康饮食"}, titles)
+		assert.Equal(t, models.TaskTypeHabit, tasks[1].Type)
+	})
+
+	t.Run("Non-matching answers only yield base templates", func(t *testing.T) {
+		assessment := &models.UserAssessment{ID: 4, Status: models.AssessmentStatusCompleted, Answers: []models.UserAnswer{
+			{QuestionID: "q_exercise_habits", Answer: []string{"5 times a week or more"}},
+			{QuestionID: "q_sleep_quality", Answer: []string{"Average, occasionally insufficient sleep or poor quality"}},
+			{QuestionID: "q_sex_ability_concerns", Answer: []string{"No significant concerns"}},
+		}}
+
+		tasks, fired := applyPlanRules(rules, questions, assessment)
+
+		assert.Empty(t, fired)
+		assert.Len(t, tasks, 3)
+	})
+
+	t.Run("Unknown template types fall back to generic", func(t *testing.T) {
+		custom := config.PlanRulesConfig{
+			Templates:     []config.PlanTaskTemplate{{ID: "x", Type: "meditation", Title: "X"}},
+			BaseTemplates: []string{"x", "missing"},
+		}
+
+		tasks, _ := applyPlanRules(custom, questions, nil)
 
+		assert.Len(t, tasks, 1)
+		assert.Equal(t, models.TaskTypeGeneric, tasks[0].Type)
+	})
+}
 
 func TestPlanService_GeneratePlan(t *testing.T) {
+	config.AppConfig.PlanRules = testPlanRulesConfig()
 	mockPlanRepo := new(MockPlanRepository)
 	mockAssessmentRepo := new(MinimalMockAssessmentRepository) // Use the minimal mock
-	service := NewPlanService(mockPlanRepo, mockAssessmentRepo)
+	mockSafety := new(MockPlanSafetyService)
+	service := NewPlanService(mockPlanRepo, mockAssessmentRepo, mockSafety, nil, nil, nil)
 	userID := "userForPlan1"
+	completedFilter := []models.UserAssessmentStatus{models.AssessmentStatusCompleted}
 
 	t.Run("Successfully generate a default plan", func(t *testing.T) {
+		// No completed assessment: only the base templates are used
+		mockAssessmentRepo.On("GetUserAssessmentByUserID", userID, completedFilter).Return(nil, nil).Once()
 		// Mock CreatePlan
 		mockPlanRepo.On("CreatePlan", mock.MatchedBy(func(p *models.Plan) bool {
 			return p.UserID == userID &&
 				len(p.Tasks) == 3 && // Expecting 3 default tasks
-				p.Status == models.PlanStatusActive
+				p.Status == models.PlanStatusPending // Activated by the safety review
 		})).Run(func(args mock.Arguments) {
 			// Simulate GORM populating ID and Task IDs
 			planArg := args.Get(0).(*models.Plan)
@@ -124,9 +224,9 @@ func TestPlanService_GeneratePlan(t *testing.T) {
 				planArg.Tasks[i].PlanID = planArg.ID
 			}
 		}).Return(nil).Once()
-		
-		// (Optional) Mock Assessment Repo if GeneratePlan uses it, for now it's simplified
-		// mockAssessmentRepo.On("GetLatestCompletedAssessment", userID).Return(nil, nil).Once() // Example if used
+		mockSafety.On("ReviewPlan", mock.MatchedBy(func(p *models.Plan) bool { return p.ID == 1 })).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Plan).Status = models.PlanStatusActive
+		}).Return(&models.PlanReview{PlanID: 1, Outcome: models.PlanReviewApproved}, nil).Once()
 
 		plan, err := service.GeneratePlan(userID)
 
@@ -138,11 +238,46 @@ func TestPlanService_GeneratePlan(t *testing.T) {
 		assert.Equal(t, "凯格尔运动", plan.Tasks[0].Title) // Check one of the default tasks
 		assert.NotZero(t, plan.ID)
 		assert.NotZero(t, plan.Tasks[0].ID)
+		assert.Nil(t, plan.SourceAssessmentID)
+		assert.Empty(t, plan.FiredRules)
+		mockPlanRepo.AssertExpectations(t)
+		mockAssessmentRepo.AssertExpectations(t)
+		mockSafety.AssertExpectations(t)
+	})
+
+	t.Run("Plan records the assessment and the rules that fired", func(t *testing.T) {
+		assessment := &models.UserAssessment{ID: 12, UserID: userID, Status: models.AssessmentStatusCompleted, Answers: []models.UserAnswer{
+			{QuestionID: "q_exercise_habits", Answer: []string{"Almost never"}},
+			{QuestionID: "q_sleep_quality", Answer: []string{"C"}},
+		}}
+		mockAssessmentRepo.On("GetUserAssessmentByUserID", userID, completedFilter).Return(assessment, nil).Once()
+		mockPlanRepo.On("CreatePlan", mock.MatchedBy(func(p *models.Plan) bool {
+			return len(p.Tasks) == 5 && p.Tasks[0].Title == "入门快走计划"
+		})).Return(nil).Once()
+		mockSafety.On("ReviewPlan", mock.AnythingOfType("*models.Plan")).Return(&models.PlanReview{Outcome: models.PlanReviewApproved}, nil).Once()
+
+		plan, err := service.GeneratePlan(userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sedentary_beginner_walking", "poor_sleep_hygiene"}, plan.FiredRules)
+		if assert.NotNil(t, plan.SourceAssessmentID) {
+			assert.Equal(t, uint(12), *plan.SourceAssessmentID)
+		}
 		mockPlanRepo.AssertExpectations(t)
-		// mockAssessmentRepo.AssertExpectations(t)
+	})
+
+	t.Run("Fail to generate plan if assessment lookup fails", func(t *testing.T) {
+		mockAssessmentRepo.On("GetUserAssessmentByUserID", "lookupFails", completedFilter).Return(nil, errors.New("store error")).Once()
+
+		plan, err := service.GeneratePlan("lookupFails")
+
+		assert.Error(t, err)
+		assert.Nil(t, plan)
+		assert.Contains(t, err.Error(), "failed to fetch completed assessment")
 	})
 
 	t.Run("Fail to generate plan if repository create fails", func(t *testing.T) {
+		mockAssessmentRepo.On("GetUserAssessmentByUserID", userID, completedFilter).Return(nil, nil).Once()
 		mockPlanRepo.On("CreatePlan", mock.AnythingOfType("*models.Plan")).Return(errors.New("DB error")).Once()
 
 		plan, err := service.GeneratePlan(userID)
@@ -153,6 +288,17 @@ func TestPlanService_GeneratePlan(t *testing.T) {
 		mockPlanRepo.AssertExpectations(t)
 	})
 
+	t.Run("Plan stays pending if the safety review fails", func(t *testing.T) {
+		mockAssessmentRepo.On("GetUserAssessmentByUserID", "reviewFails", completedFilter).Return(nil, nil).Once()
+		mockPlanRepo.On("CreatePlan", mock.MatchedBy(func(p *models.Plan) bool { return p.UserID == "reviewFails" })).Return(nil).Once()
+		mockSafety.On("ReviewPlan", mock.MatchedBy(func(p *models.Plan) bool { return p.UserID == "reviewFails" })).Return(nil, errors.New("profile store down")).Once()
+
+		plan, err := service.GeneratePlan("reviewFails")
+
+		assert.NoError(t, err)
+		assert.Equal(t, models.PlanStatusPending, plan.Status)
+	})
+
 	t.Run("Generate plan with empty userID", func(t *testing.T) {
 		plan, err := service.GeneratePlan("")
 		assert.Error(t, err)
@@ -164,117 +310,261 @@ func TestPlanService_GeneratePlan(t *testing.T) {
 }
 
 func TestPlanService_MarkTaskCompleted(t *testing.T) {
-	mockPlanRepo := new(MockPlanRepository)
-	// Assessment repo not directly used by MarkTaskCompleted
-	service := NewPlanService(mockPlanRepo, nil) 
-	
 	userID := "userTaskOwner"
 	taskID := uint(1)
 	planID := uint(10)
+	now := time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC) // Wednesday
+	input := models.CheckInInput{Timezone: "UTC", Note: "感觉不错"}
+	activePlan := &models.Plan{ID: planID, UserID: userID, Status: models.PlanStatusActive}
+	dailyTwice := func() *models.PlanTask {
+		return &models.PlanTask{ID: taskID, PlanID: planID, Status: models.TaskStatusPending, Frequency: "每日2次",
+			Recurrence: &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2}}
+	}
+
+	t.Run("Completes today's first occurrence without completing the task", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(dailyTwice(), nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(activePlan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{
+			{ID: 7, TaskID: taskID, DueDate: "2024-03-12", Slot: 1, Status: models.TaskStatusCompleted}, // Yesterday
+		}, nil).Once()
+		mockCheckInRepo.On("CreateCheckIn", mock.MatchedBy(func(ci *models.TaskCheckIn) bool {
+			return ci.TaskID == taskID && ci.PlanID == planID && ci.UserID == userID && ci.DueDate == "2024-03-13" && ci.Slot == 1 &&
+				ci.Status == models.TaskStatusCompleted && ci.Note == "感觉不错" && ci.CheckedAt.Equal(now)
+		})).Return(nil).Once()
+
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, input, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, checkIn.Slot)
+		mockPlanRepo.AssertExpectations(t)
+		mockCheckInRepo.AssertExpectations(t)
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlanTask", mock.Anything) // A daily task is never completed as a whole
+	})
+
+	t.Run("Second completion checks in the next slot", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(dailyTwice(), nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(activePlan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{
+			{ID: 8, TaskID: taskID, DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusCompleted},
+		}, nil).Once()
+		mockCheckInRepo.On("CreateCheckIn", mock.MatchedBy(func(ci *models.TaskCheckIn) bool { return ci.Slot == 2 })).Return(nil).Once()
+
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, input, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, checkIn.Slot)
+		mockCheckInRepo.AssertExpectations(t)
+	})
 
-	t.Run("Successfully mark task as completed", func(t *testing.T) {
-		task := &models.PlanTask{ID: taskID, PlanID: planID, Status: models.TaskStatusPending, UserID: userID /* conceptual, real check is via plan */}
-		plan := &models.Plan{ID: planID, UserID: userID}
+	t.Run("Completing a skipped occurrence updates its check-in", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(dailyTwice(), nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(activePlan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{
+			{ID: 9, TaskID: taskID, DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusSkipped, Note: "太忙"},
+		}, nil).Once()
+		mockCheckInRepo.On("UpdateCheckIn", mock.MatchedBy(func(ci *models.TaskCheckIn) bool {
+			return ci.ID == 9 && ci.Status == models.TaskStatusCompleted && ci.Note == "太忙"
+		})).Return(nil).Once()
+
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, models.CheckInInput{Timezone: "UTC"}, now)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(9), checkIn.ID)
+		mockCheckInRepo.AssertExpectations(t)
+	})
+
+	t.Run("Completing a one-off task completes the task", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+		task := &models.PlanTask{ID: taskID, PlanID: planID, Status: models.TaskStatusPending, Frequency: "本周一次"}
 
 		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
-		mockPlanRepo.On("GetPlanByID", planID).Return(plan, nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(activePlan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{}, nil).Once()
+		mockCheckInRepo.On("CreateCheckIn", mock.AnythingOfType("*models.TaskCheckIn")).Return(nil).Once()
 		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(ut *models.PlanTask) bool {
 			return ut.ID == taskID && ut.Status == models.TaskStatusCompleted && ut.IsCompleted == true && ut.CompletedAt.Valid == true
 		})).Return(nil).Once()
 
-		updatedTask, err := service.MarkTaskCompleted(taskID, userID)
+		_, err := service.MarkTaskCompleted(taskID, userID, input, now)
 		assert.NoError(t, err)
-		assert.NotNil(t, updatedTask)
-		assert.Equal(t, models.TaskStatusCompleted, updatedTask.Status)
-		assert.True(t, updatedTask.IsCompleted)
-		assert.True(t, updatedTask.CompletedAt.Valid)
+		assert.True(t, task.IsCompleted)
 		mockPlanRepo.AssertExpectations(t)
 	})
 
+	t.Run("All of today's occurrences already completed", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(dailyTwice(), nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(activePlan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{
+			{ID: 10, DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusCompleted},
+			{ID: 11, DueDate: "2024-03-13", Slot: 2, Status: models.TaskStatusCompleted},
+		}, nil).Once()
+
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, input, now)
+		assert.NoError(t, err) // Service returns the existing check-in as is
+		assert.Equal(t, uint(11), checkIn.ID)
+		mockCheckInRepo.AssertNotCalled(t, "CreateCheckIn", mock.Anything)
+		mockCheckInRepo.AssertNotCalled(t, "UpdateCheckIn", mock.Anything)
+	})
+
+	t.Run("Task not due today", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+		task := &models.PlanTask{ID: taskID, PlanID: planID, Frequency: "每周二、四"}
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(activePlan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{}, nil).Once()
+
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, input, now)
+		assert.Error(t, err)
+		assert.Nil(t, checkIn)
+		assert.EqualError(t, err, "task 1 is not due on 2024-03-13")
+	})
+
 	t.Run("Task not found", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
 		mockPlanRepo.On("GetTaskByID", taskID).Return(nil, nil).Once() // Task not found
 
-		updatedTask, err := service.MarkTaskCompleted(taskID, userID)
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, input, now)
 		assert.Error(t, err)
-		assert.Nil(t, updatedTask)
+		assert.Nil(t, checkIn)
 		assert.Contains(t, err.Error(), fmt.Sprintf("task with ID %d not found", taskID))
 		mockPlanRepo.AssertExpectations(t)
 	})
 
 	t.Run("Unauthorized user", func(t *testing.T) {
-		task := &models.PlanTask{ID: taskID, PlanID: planID, UserID: "anotherUser"}
-		plan := &models.Plan{ID: planID, UserID: "anotherUser"} // Belongs to another user
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		plan := &models.Plan{ID: planID, UserID: "anotherUser", Status: models.PlanStatusActive} // Belongs to another user
 
-		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
+		mockPlanRepo.On("GetTaskByID", taskID).Return(dailyTwice(), nil).Once()
 		mockPlanRepo.On("GetPlanByID", planID).Return(plan, nil).Once()
 
-		updatedTask, err := service.MarkTaskCompleted(taskID, userID) // userID is "userTaskOwner"
+		checkIn, err := service.MarkTaskCompleted(taskID, userID, input, now) // userID is "userTaskOwner"
 		assert.Error(t, err)
-		assert.Nil(t, updatedTask)
+		assert.Nil(t, checkIn)
 		assert.Contains(t, err.Error(), "unauthorized to modify task")
 		mockPlanRepo.AssertExpectations(t)
 	})
 
-	t.Run("Task already completed", func(t *testing.T) {
-		now := time.Now()
-		task := &models.PlanTask{
-			ID: taskID, PlanID: planID, Status: models.TaskStatusCompleted, IsCompleted: true, CompletedAt: models.NullTime{Time: now, Valid: true},
-		}
-		plan := &models.Plan{ID: planID, UserID: userID}
-		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
+	t.Run("Plan not active", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+		plan := &models.Plan{ID: planID, UserID: userID, Status: models.PlanStatusPending}
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(dailyTwice(), nil).Once()
 		mockPlanRepo.On("GetPlanByID", planID).Return(plan, nil).Once()
-		// No UpdatePlanTask should be called
 
-		updatedTask, err := service.MarkTaskCompleted(taskID, userID)
-		assert.NoError(t, err) // Service returns the task as is
-		assert.NotNil(t, updatedTask)
-		assert.Equal(t, models.TaskStatusCompleted, updatedTask.Status)
-		mockPlanRepo.AssertExpectations(t)
+		_, err := service.MarkTaskCompleted(taskID, userID, input, now)
+		assert.EqualError(t, err, "plan 10 is not active")
 	})
 }
 
+func TestPlanService_MarkTaskPartial(t *testing.T) {
+	userID := "userTaskOwnerPartial"
+	taskID := uint(3)
+	planID := uint(30)
+	now := time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC)
+	plan := &models.Plan{ID: planID, UserID: userID, Status: models.PlanStatusActive}
+	fatigue := 4
+
+	t.Run("Records the amount and feedback without completing the occurrence", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+		task := &models.PlanTask{ID: taskID, PlanID: planID, Frequency: "本周一次", Duration: "3组"}
+		input := models.CheckInInput{Timezone: "UTC", CompletedAmount: 2, TargetAmount: 3, Feedback: &models.CheckInFeedback{Fatigue: &fatigue}}
+
+		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
+		mockPlanRepo.On("GetPlanByID", planID).Return(plan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{}, nil).Once()
+		mockCheckInRepo.On("CreateCheckIn", mock.MatchedBy(func(ci *models.TaskCheckIn) bool {
+			return ci.Status == models.TaskStatusPartial && ci.CompletedAmount == 2 && ci.TargetAmount == 3 && *ci.Feedback.Fatigue == 4
+		})).Return(nil).Once()
+
+		checkIn, err := service.MarkTaskPartial(taskID, userID, input, now)
+		assert.NoError(t, err)
+		assert.Equal(t, models.TaskStatusPartial, checkIn.Status)
+		assert.False(t, task.IsCompleted) // A one-off task is only done once fully completed
+		mockCheckInRepo.AssertExpectations(t)
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlanTask", mock.Anything)
+	})
+
+	t.Run("Invalid details are rejected before any lookup", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
+
+		checkIn, err := service.MarkTaskPartial(taskID, userID, models.CheckInInput{CompletedAmount: 3, TargetAmount: 3}, now)
+		assert.Error(t, err)
+		assert.Nil(t, checkIn)
+		assert.Contains(t, err.Error(), "invalid check-in")
+		mockPlanRepo.AssertNotCalled(t, "GetTaskByID", mock.Anything)
+	})
+}
 
 func TestPlanService_MarkTaskSkipped(t *testing.T) {
-	mockPlanRepo := new(MockPlanRepository)
-	service := NewPlanService(mockPlanRepo, nil) 
-	
 	userID := "userTaskOwnerSkip"
 	taskID := uint(2)
 	planID := uint(20)
+	// 23:30 on Wednesday in Shanghai
+	now := time.Date(2024, 3, 13, 15, 30, 0, 0, time.UTC)
+	input := models.CheckInInput{Timezone: "Asia/Shanghai"}
+	plan := &models.Plan{ID: planID, UserID: userID, Status: models.PlanStatusActive}
+	task := &models.PlanTask{ID: taskID, PlanID: planID, Status: models.TaskStatusPending, Frequency: "每日"}
 
-	t.Run("Successfully mark task as skipped", func(t *testing.T) {
-		task := &models.PlanTask{ID: taskID, PlanID: planID, Status: models.TaskStatusPending}
-		plan := &models.Plan{ID: planID, UserID: userID}
+	t.Run("Successfully skip today's occurrence", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
 
 		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
 		mockPlanRepo.On("GetPlanByID", planID).Return(plan, nil).Once()
-		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(ut *models.PlanTask) bool {
-			return ut.ID == taskID && ut.Status == models.TaskStatusSkipped && ut.IsCompleted == false && ut.CompletedAt.Valid == false
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{}, nil).Once()
+		mockCheckInRepo.On("CreateCheckIn", mock.MatchedBy(func(ci *models.TaskCheckIn) bool {
+			return ci.DueDate == "2024-03-13" && ci.Slot == 1 && ci.Status == models.TaskStatusSkipped
 		})).Return(nil).Once()
 
-		updatedTask, err := service.MarkTaskSkipped(taskID, userID)
+		checkIn, err := service.MarkTaskSkipped(taskID, userID, input, now)
 		assert.NoError(t, err)
-		assert.NotNil(t, updatedTask)
-		assert.Equal(t, models.TaskStatusSkipped, updatedTask.Status)
-		assert.False(t, updatedTask.IsCompleted)
-		assert.False(t, updatedTask.CompletedAt.Valid)
-		mockPlanRepo.AssertExpectations(t)
+		assert.Equal(t, models.TaskStatusSkipped, checkIn.Status)
+		assert.Equal(t, models.TaskStatusPending, task.Status) // Tomorrow's occurrence is still due
+		mockCheckInRepo.AssertExpectations(t)
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlanTask", mock.Anything)
 	})
 
-	t.Run("Cannot skip already completed task", func(t *testing.T) {
-		now := time.Now()
-		task := &models.PlanTask{
-			ID: taskID, PlanID: planID, Status: models.TaskStatusCompleted, IsCompleted: true, CompletedAt: models.NullTime{Time: now, Valid: true},
-		}
-		plan := &models.Plan{ID: planID, UserID: userID}
+	t.Run("Cannot skip already completed occurrence", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+
 		mockPlanRepo.On("GetTaskByID", taskID).Return(task, nil).Once()
 		mockPlanRepo.On("GetPlanByID", planID).Return(plan, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByTaskID", taskID).Return([]models.TaskCheckIn{
+			{ID: 12, DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusCompleted},
+		}, nil).Once()
 
-		updatedTask, err := service.MarkTaskSkipped(taskID, userID)
+		checkIn, err := service.MarkTaskSkipped(taskID, userID, input, now)
 		assert.Error(t, err)
-		assert.Nil(t, updatedTask)
+		assert.Nil(t, checkIn)
 		assert.EqualError(t, err, "cannot skip an already completed task")
-		mockPlanRepo.AssertExpectations(t)
+		mockCheckInRepo.AssertNotCalled(t, "CreateCheckIn", mock.Anything)
 	})
 }
 
@@ -290,7 +580,7 @@ func TestPlanService_MarkTaskSkipped(t *testing.T) {
 // Tests for GetPlanDetails and GetActivePlanForUser
 func TestPlanService_GetPlan(t *testing.T) {
 	mockPlanRepo := new(MockPlanRepository)
-	service := NewPlanService(mockPlanRepo, nil)
+	service := NewPlanService(mockPlanRepo, nil, nil, nil, nil, nil)
 	userID := "userGetPlan"
 	planID := uint(1)
 
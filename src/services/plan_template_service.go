@@ -0,0 +1,354 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlanTemplateService manages the plan template library and creates personal plans from templates.
+type PlanTemplateService interface {
+	CreateTemplate(input models.PlanTemplateInput) (*models.PlanTemplate, error)
+	UpdateTemplate(templateID uint, input models.PlanTemplateInput) (*models.PlanTemplate, error) // Replaces the template's content
+	DeleteTemplate(templateID uint) error
+	GetTemplate(templateID uint, publishedOnly bool) (*models.PlanTemplate, error)
+	ListTemplates(publishedOnly bool) ([]*models.PlanTemplate, error)
+	// MatchTemplates ranks the published templates by the goals they share with the user's latest assessment.
+	MatchTemplates(userID string) ([]models.PlanTemplateMatch, error)
+	// InstantiateTemplate creates a pending plan for userID from a published template and passes it through the
+	// safety review, like a generated plan. author records who asked for it in the plan's version history.
+	InstantiateTemplate(templateID uint, userID string, author models.PlanVersionAuthor, input models.PlanTemplateInstantiateInput, now time.Time) (*models.Plan, error)
+}
+
+type planTemplateService struct {
+	templateRepo   repository.PlanTemplateRepository
+	planRepo       repository.PlanRepository
+	profileService AssessmentProfileService         // Optional; supplies the user's goals for matching
+	safetyService  PlanSafetyService                // Reviews instantiated plans before they become active
+	versionRepo    repository.PlanVersionRepository // Optional
+	exerciseRepo   repository.ExerciseRepository    // Exercises that template tasks may follow
+}
+
+// NewPlanTemplateService creates a new instance of PlanTemplateService.
+func NewPlanTemplateService(templateRepo repository.PlanTemplateRepository, planRepo repository.PlanRepository, profileService AssessmentProfileService, safetyService PlanSafetyService, versionRepo repository.PlanVersionRepository, exerciseRepo repository.ExerciseRepository) PlanTemplateService {
+	return &planTemplateService{
+		templateRepo:   templateRepo,
+		planRepo:       planRepo,
+		profileService: profileService,
+		safetyService:  safetyService,
+		versionRepo:    versionRepo,
+		exerciseRepo:   exerciseRepo,
+	}
+}
+
+func (s *planTemplateService) CreateTemplate(input models.PlanTemplateInput) (*models.PlanTemplate, error) {
+	template := &models.PlanTemplate{Published: true}
+	if err := applyTemplateInput(template, input); err != nil {
+		return nil, err
+	}
+	if err := s.checkTemplateExercises(template); err != nil {
+		return nil, err
+	}
+	if err := s.templateRepo.CreateTemplate(template); err != nil {
+		errMsg := fmt.Sprintf("failed to create plan template '%s'", template.Title)
+		log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return template, nil
+}
+
+func (s *planTemplateService) UpdateTemplate(templateID uint, input models.PlanTemplateInput) (*models.PlanTemplate, error) {
+	template, err := s.GetTemplate(templateID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyTemplateInput(template, input); err != nil {
+		return nil, err
+	}
+	if err := s.checkTemplateExercises(template); err != nil {
+		return nil, err
+	}
+	if err := s.templateRepo.UpdateTemplate(template); err != nil {
+		errMsg := fmt.Sprintf("failed to update plan template ID %d", templateID)
+		log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return template, nil
+}
+
+func (s *planTemplateService) DeleteTemplate(templateID uint) error {
+	if _, err := s.GetTemplate(templateID, false); err != nil {
+		return err
+	}
+	if err := s.templateRepo.DeleteTemplate(templateID); err != nil {
+		errMsg := fmt.Sprintf("failed to delete plan template ID %d", templateID)
+		log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return nil
+}
+
+func (s *planTemplateService) GetTemplate(templateID uint, publishedOnly bool) (*models.PlanTemplate, error) {
+	template, err := s.templateRepo.GetTemplateByID(templateID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch plan template ID %d", templateID)
+		log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if template == nil || (publishedOnly && !template.Published) {
+		return nil, fmt.Errorf("plan template %d not found", templateID)
+	}
+	return template, nil
+}
+
+func (s *planTemplateService) ListTemplates(publishedOnly bool) ([]*models.PlanTemplate, error) {
+	templates, err := s.templateRepo.ListTemplates(publishedOnly)
+	if err != nil {
+		errMsg := "failed to list plan templates"
+		log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return templates, nil
+}
+
+func (s *planTemplateService) MatchTemplates(userID string) ([]models.PlanTemplateMatch, error) {
+	templates, err := s.ListTemplates(true)
+	if err != nil {
+		return nil, err
+	}
+	var goals []string
+	if s.profileService != nil {
+		profile, err := s.profileService.GetLatestProfile(userID)
+		if err != nil {
+			log.Printf("WARN: [PlanTemplateService] Failed to load assessment profile for userID %s; templates are not ranked: %v", userID, err)
+		} else if profile != nil {
+			goals = profile.Goals
+		}
+	}
+	return matchPlanTemplates(templates, goals), nil
+}
+
+func (s *planTemplateService) InstantiateTemplate(templateID uint, userID string, author models.PlanVersionAuthor, input models.PlanTemplateInstantiateInput, now time.Time) (*models.Plan, error) {
+	if userID == "" {
+		return nil, errors.New("invalid request: userID cannot be empty")
+	}
+	template, err := s.GetTemplate(templateID, true)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planFromTemplate(template, input, now)
+	if err != nil {
+		return nil, err
+	}
+	plan.UserID = userID
+
+	if err := s.planRepo.CreatePlan(plan); err != nil {
+		errMsg := fmt.Sprintf("failed to create plan from template ID %d for userID %s", templateID, userID)
+		log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	authorID := ""
+	if author == models.PlanVersionAuthorUser {
+		authorID = userID
+	}
+	recordPlanVersion(s.versionRepo, plan, author, authorID, fmt.Sprintf("从模板「%s」创建计划", template.Title))
+	reviewNewPlan(s.safetyService, s.planRepo, s.versionRepo, plan)
+	log.Printf("INFO: [PlanTemplateService] Created plan ID %d for userID %s from template ID %d.", plan.ID, userID, templateID)
+	return plan, nil
+}
+
+// planFromTemplate builds an unsaved, pending plan from a template. The plan runs from the start date for the
+// template's duration; without a duration it is open-ended and only gets a start date if one is given.
+func planFromTemplate(template *models.PlanTemplate, input models.PlanTemplateInstantiateInput, now time.Time) (*models.Plan, error) {
+	templateID := template.ID
+	plan := &models.Plan{
+		Title:       template.Title,
+		Description: template.Description,
+		Status:      models.PlanStatusPending, // Activated by the safety review
+		TemplateID:  &templateID,
+		StartDate:   input.StartDate,
+		Tasks:       make([]models.PlanTask, 0, len(template.Tasks)),
+	}
+	if plan.StartDate == "" && template.DurationDays > 0 {
+		plan.StartDate = now.In(LoadUserLocation(input.Timezone)).Format("2006-01-02")
+	}
+	if plan.StartDate != "" {
+		start, err := time.Parse("2006-01-02", plan.StartDate)
+		if err != nil {
+			return nil, errors.New("invalid plan: start_date must be YYYY-MM-DD")
+		}
+		if template.DurationDays > 0 {
+			plan.EndDate = start.AddDate(0, 0, template.DurationDays-1).Format("2006-01-02")
+		}
+	}
+	for i, task := range template.Tasks {
+		level := task.Level
+		if task.Progression != "" && level < 1 {
+			level = startLevel(task.Progression)
+		}
+		var exerciseID *uint
+		if task.ExerciseID != 0 {
+			id := task.ExerciseID
+			exerciseID = &id
+		}
+		plan.Tasks = append(plan.Tasks, models.PlanTask{
+			Type:        task.Type,
+			Title:       task.Title,
+			Description: task.Description,
+			Frequency:   task.Frequency,
+			Recurrence:  ParseFrequency(task.Frequency),
+			Duration:    task.Duration,
+			Status:      models.TaskStatusPending,
+			Order:       i + 1,
+			Progression: task.Progression,
+			Level:       level,
+			ExerciseID:  exerciseID,
+		})
+	}
+	return plan, nil
+}
+
+// matchPlanTemplates pairs each template with the user's goals it serves, templates sharing the most goals first.
+// Templates keep their order among equals.
+func matchPlanTemplates(templates []*models.PlanTemplate, goals []string) []models.PlanTemplateMatch {
+	matches := make([]models.PlanTemplateMatch, 0, len(templates))
+	for _, template := range templates {
+		match := models.PlanTemplateMatch{Template: template, MatchedGoals: []string{}}
+		for _, goal := range template.Goals {
+			if contains(goals, goal) {
+				match.MatchedGoals = append(match.MatchedGoals, goal)
+			}
+		}
+		matches = append(matches, match)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return len(matches[i].MatchedGoals) > len(matches[j].MatchedGoals) })
+	return matches
+}
+
+// applyTemplateInput validates input and copies it onto template.
+func applyTemplateInput(template *models.PlanTemplate, input models.PlanTemplateInput) error {
+	title := strings.TrimSpace(input.Title)
+	if title == "" {
+		return errors.New("invalid template: title is required")
+	}
+	if input.DurationDays < 0 {
+		return errors.New("invalid template: duration_days cannot be negative")
+	}
+	if len(input.Tasks) == 0 {
+		return errors.New("invalid template: at least one task is required")
+	}
+	if options := goalOptions(); len(options) > 0 {
+		for _, goal := range input.Goals {
+			if !contains(options, goal) {
+				return fmt.Errorf("invalid template: '%s' is not an option of the goal question", goal)
+			}
+		}
+	}
+	tasks := make([]models.PlanTemplateTask, len(input.Tasks))
+	for i, task := range input.Tasks {
+		if err := validateTemplateTask(&task); err != nil {
+			return fmt.Errorf("invalid template: task %d: %w", i+1, err)
+		}
+		tasks[i] = task
+	}
+
+	template.Title = title
+	template.Description = input.Description
+	template.Goals = input.Goals
+	template.DurationDays = input.DurationDays
+	template.Tasks = tasks
+	if input.Published != nil {
+		template.Published = *input.Published
+	}
+	return nil
+}
+
+// checkTemplateExercises checks that the tasks of a template only follow approved exercises of the library. As for
+// the planner agent, once the library has approved exercises every exercise task must follow one of them.
+func (s *planTemplateService) checkTemplateExercises(template *models.PlanTemplate) error {
+	var ids []uint
+	unlinked := 0 // Number of the first exercise task without an exercise
+	for i, task := range template.Tasks {
+		if task.ExerciseID != 0 {
+			ids = append(ids, task.ExerciseID)
+		} else if task.Type == models.TaskTypeExercise && unlinked == 0 {
+			unlinked = i + 1
+		}
+	}
+	if unlinked != 0 && s.exerciseRepo != nil {
+		library, err := s.exerciseRepo.ListExercises(models.ExerciseQuery{Status: models.ReviewStatusApproved, ReviewedAfter: approvalCutoff(time.Now())})
+		if err != nil {
+			errMsg := "failed to load the exercise library for the template"
+			log.Printf("ERROR: [PlanTemplateService] %s: %v", errMsg, err)
+			return fmt.Errorf("%s: %w", errMsg, err)
+		}
+		if len(library) > 0 {
+			return fmt.Errorf("invalid template: task %d: exercise tasks must follow an exercise of the library (exercise_id)", unlinked)
+		}
+	}
+	missing, err := missingExercise(s.exerciseRepo, ids)
+	if err != nil || missing == 0 {
+		return err
+	}
+	for i, task := range template.Tasks {
+		if task.ExerciseID == missing {
+			return fmt.Errorf("invalid template: task %d: exercise %d is not in the exercise library", i+1, missing)
+		}
+	}
+	return nil
+}
+
+// validateTemplateTask checks a template task, normalizing its type and starting level.
+func validateTemplateTask(task *models.PlanTemplateTask) error {
+	task.Title = strings.TrimSpace(task.Title)
+	if task.Title == "" {
+		return errors.New("title is required")
+	}
+	if task.Type == "" {
+		task.Type = models.TaskTypeGeneric
+	}
+	if planTaskType(string(task.Type)) != task.Type {
+		return fmt.Errorf("unknown type '%s'", task.Type)
+	}
+	if ParseFrequency(task.Frequency) == nil {
+		return fmt.Errorf("frequency '%s' is not understood", task.Frequency)
+	}
+	if task.ExerciseID != 0 && task.Type != models.TaskTypeExercise {
+		return errors.New("only exercise tasks can follow an exercise")
+	}
+	if task.Progression == "" {
+		task.Level = 0
+		return nil
+	}
+	progression := taskProgression(&models.PlanTask{Progression: task.Progression})
+	if progression == nil {
+		return fmt.Errorf("unknown progression '%s'", task.Progression)
+	}
+	if task.Level < 1 {
+		task.Level = 1
+	}
+	if task.Level > len(progression.Levels) {
+		return fmt.Errorf("progression '%s' has only %d levels", task.Progression, len(progression.Levels))
+	}
+	return nil
+}
+
+// goalOptions returns the options of the assessment's goal question, or nil if it is not configured.
+func goalOptions() []string {
+	questionID := config.AppConfig.AssessmentScoring.GoalQuestionID
+	if questionID == "" {
+		return nil
+	}
+	for _, question := range getDefaultAssessmentQuestions() {
+		if question.ID == questionID {
+			return question.Options
+		}
+	}
+	return nil
+}
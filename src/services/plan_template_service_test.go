@@ -0,0 +1,215 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPlanTemplateRepository is a mock type for the PlanTemplateRepository type
+type MockPlanTemplateRepository struct {
+	mock.Mock
+}
+
+func (m *MockPlanTemplateRepository) CreateTemplate(template *models.PlanTemplate) error {
+	args := m.Called(template)
+	return args.Error(0)
+}
+
+func (m *MockPlanTemplateRepository) GetTemplateByID(templateID uint) (*models.PlanTemplate, error) {
+	args := m.Called(templateID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PlanTemplate), args.Error(1)
+}
+
+func (m *MockPlanTemplateRepository) ListTemplates(publishedOnly bool) ([]*models.PlanTemplate, error) {
+	args := m.Called(publishedOnly)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.PlanTemplate), args.Error(1)
+}
+
+func (m *MockPlanTemplateRepository) UpdateTemplate(template *models.PlanTemplate) error {
+	args := m.Called(template)
+	return args.Error(0)
+}
+
+func (m *MockPlanTemplateRepository) DeleteTemplate(templateID uint) error {
+	args := m.Called(templateID)
+	return args.Error(0)
+}
+
+func TestApplyTemplateInput(t *testing.T) {
+	originalScoring, originalProgression := config.AppConfig.AssessmentScoring, config.AppConfig.Progression
+	config.AppConfig.AssessmentScoring = testScoringConfig()
+	config.AppConfig.Progression = config.ProgressionConfig{Exercises: []config.ExerciseProgression{testKegelProgression}}
+	defer func() {
+		config.AppConfig.AssessmentScoring, config.AppConfig.Progression = originalScoring, originalProgression
+	}()
+
+	validInput := func() models.PlanTemplateInput {
+		return models.PlanTemplateInput{
+			Title: "盆底肌四周计划",
+			Goals: []string{"Develop a personalized exercise plan"},
+			Tasks: []models.PlanTemplateTask{
+				{Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日2次", Progression: "kegel"},
+				{Title: "阅读一篇健康科普", Frequency: "每周1次"},
+			},
+		}
+	}
+
+	t.Run("Valid input", func(t *testing.T) {
+		template := &models.PlanTemplate{Published: true}
+		err := applyTemplateInput(template, validInput())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "盆底肌四周计划", template.Title)
+		assert.True(t, template.Published, "published is unchanged when omitted")
+		assert.Equal(t, 1, template.Tasks[0].Level, "progressive tasks start at level 1")
+		assert.Equal(t, models.TaskTypeGeneric, template.Tasks[1].Type)
+	})
+
+	tests := []struct {
+		name    string
+		modify  func(input *models.PlanTemplateInput)
+		wantErr string
+	}{
+		{"Missing title", func(input *models.PlanTemplateInput) { input.Title = " " }, "invalid template: title is required"},
+		{"No tasks", func(input *models.PlanTemplateInput) { input.Tasks = nil }, "invalid template: at least one task is required"},
+		{"Unknown goal", func(input *models.PlanTemplateInput) { input.Goals = []string{"变得更帅"} }, "invalid template: '变得更帅' is not an option of the goal question"},
+		{"Unknown task type", func(input *models.PlanTemplateInput) { input.Tasks[1].Type = "diet" }, "invalid template: task 2: unknown type 'diet'"},
+		{"Unparsable frequency", func(input *models.PlanTemplateInput) { input.Tasks[1].Frequency = "看心情" }, "invalid template: task 2: frequency '看心情' is not understood"},
+		{"Unknown progression", func(input *models.PlanTemplateInput) { input.Tasks[0].Progression = "yoga" }, "invalid template: task 1: unknown progression 'yoga'"},
+		{"Level beyond the progression", func(input *models.PlanTemplateInput) { input.Tasks[0].Level = 4 }, "invalid template: task 1: progression 'kegel' has only 3 levels"},
+		{"Exercise of a task that is no exercise", func(input *models.PlanTemplateInput) { input.Tasks[1].ExerciseID = 3 }, "invalid template: task 2: only exercise tasks can follow an exercise"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := validInput()
+			tt.modify(&input)
+			template := &models.PlanTemplate{Title: "原标题"}
+
+			err := applyTemplateInput(template, input)
+
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, "原标题", template.Title, "template is unchanged on error")
+		})
+	}
+}
+
+func TestMatchPlanTemplates(t *testing.T) {
+	knowledge := &models.PlanTemplate{ID: 1, Goals: []string{"Gain scientific sexual health knowledge"}}
+	exercise := &models.PlanTemplate{ID: 2, Goals: []string{"Improve sexual ability (e.g., stamina, hardness)", "Develop a personalized exercise plan"}}
+	habits := &models.PlanTemplate{ID: 3, Goals: []string{"Adjust unhealthy sexual habits", "Develop a personalized exercise plan"}}
+
+	matches := matchPlanTemplates([]*models.PlanTemplate{knowledge, exercise, habits}, []string{"Develop a personalized exercise plan", "Improve sexual ability (e.g., stamina, hardness)"})
+
+	assert.Equal(t, []uint{2, 3, 1}, []uint{matches[0].Template.ID, matches[1].Template.ID, matches[2].Template.ID})
+	assert.Len(t, matches[0].MatchedGoals, 2)
+	assert.Equal(t, []string{"Develop a personalized exercise plan"}, matches[1].MatchedGoals)
+	assert.Empty(t, matches[2].MatchedGoals)
+}
+
+func TestPlanTemplateService_CreateTemplate(t *testing.T) {
+	input := models.PlanTemplateInput{Title: "盆底肌四周计划", Tasks: []models.PlanTemplateTask{
+		{Type: models.TaskTypeHabit, Title: "早睡", Frequency: "每日"},
+		{Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日2次"},
+	}}
+	approved := models.ExerciseQuery{Status: models.ReviewStatusApproved}
+
+	t.Run("Exercise tasks must follow an approved exercise once the library has some", func(t *testing.T) {
+		mockTemplateRepo := new(MockPlanTemplateRepository)
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewPlanTemplateService(mockTemplateRepo, nil, nil, nil, nil, mockExerciseRepo)
+		mockExerciseRepo.On("ListExercises", approved).Return([]*models.Exercise{{ID: 3, Name: "凯格尔运动"}}, nil).Once()
+
+		template, err := service.CreateTemplate(input)
+
+		assert.Nil(t, template)
+		assert.EqualError(t, err, "invalid template: task 2: exercise tasks must follow an exercise of the library (exercise_id)")
+		mockTemplateRepo.AssertNotCalled(t, "CreateTemplate", mock.Anything)
+		mockExerciseRepo.AssertExpectations(t)
+	})
+
+	t.Run("Without approved exercises, exercise tasks need no exercise", func(t *testing.T) {
+		mockTemplateRepo := new(MockPlanTemplateRepository)
+		mockExerciseRepo := new(MockExerciseRepository)
+		service := NewPlanTemplateService(mockTemplateRepo, nil, nil, nil, nil, mockExerciseRepo)
+		mockExerciseRepo.On("ListExercises", approved).Return([]*models.Exercise{}, nil).Once()
+		mockTemplateRepo.On("CreateTemplate", mock.AnythingOfType("*models.PlanTemplate")).Return(nil).Once()
+
+		template, err := service.CreateTemplate(input)
+
+		assert.NoError(t, err)
+		assert.Len(t, template.Tasks, 2)
+		mockTemplateRepo.AssertExpectations(t)
+		mockExerciseRepo.AssertExpectations(t)
+	})
+}
+
+func TestPlanTemplateService_InstantiateTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 15, 3, 0, 0, 0, time.UTC)
+	template := &models.PlanTemplate{ID: 5, Title: "盆底肌四周计划", DurationDays: 28, Published: true, Tasks: []models.PlanTemplateTask{
+		{Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日2次", Progression: "kegel", Level: 1},
+		{Type: models.TaskTypeHabit, Title: "早睡", Frequency: "每日"},
+	}}
+
+	t.Run("Creates a reviewed plan", func(t *testing.T) {
+		mockTemplateRepo := new(MockPlanTemplateRepository)
+		mockPlanRepo := new(MockPlanRepository)
+		mockSafety := new(MockPlanSafetyService)
+		service := NewPlanTemplateService(mockTemplateRepo, mockPlanRepo, nil, mockSafety, nil, nil)
+		mockTemplateRepo.On("GetTemplateByID", uint(5)).Return(template, nil).Once()
+		mockPlanRepo.On("CreatePlan", mock.MatchedBy(func(p *models.Plan) bool {
+			return p.UserID == "templateUser" && p.Status == models.PlanStatusPending && *p.TemplateID == 5 && len(p.Tasks) == 2
+		})).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Plan).ID = 50
+		}).Return(nil).Once()
+		mockSafety.On("ReviewPlan", mock.MatchedBy(func(p *models.Plan) bool { return p.ID == 50 })).Return(&models.PlanReview{PlanID: 50, Outcome: models.PlanReviewApproved}, nil).Once()
+
+		plan, err := service.InstantiateTemplate(5, "templateUser", models.PlanVersionAuthorUser, models.PlanTemplateInstantiateInput{Timezone: "Asia/Shanghai"}, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "2024-03-15", plan.StartDate)
+		assert.Equal(t, "2024-04-11", plan.EndDate)
+		assert.Equal(t, "kegel", plan.Tasks[0].Progression)
+		assert.Equal(t, 1, plan.Tasks[0].Level)
+		assert.NotNil(t, plan.Tasks[0].Recurrence)
+		assert.Equal(t, 2, plan.Tasks[1].Order)
+		mockPlanRepo.AssertExpectations(t)
+		mockSafety.AssertExpectations(t)
+	})
+
+	t.Run("Unpublished templates are not found", func(t *testing.T) {
+		mockTemplateRepo := new(MockPlanTemplateRepository)
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanTemplateService(mockTemplateRepo, mockPlanRepo, nil, nil, nil, nil)
+		draft := *template
+		draft.Published = false
+		mockTemplateRepo.On("GetTemplateByID", uint(5)).Return(&draft, nil).Once()
+
+		_, err := service.InstantiateTemplate(5, "templateUser", models.PlanVersionAuthorUser, models.PlanTemplateInstantiateInput{}, now)
+
+		assert.EqualError(t, err, "plan template 5 not found")
+		mockPlanRepo.AssertNotCalled(t, "CreatePlan", mock.Anything)
+	})
+
+	t.Run("Invalid start date", func(t *testing.T) {
+		mockTemplateRepo := new(MockPlanTemplateRepository)
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlanTemplateService(mockTemplateRepo, mockPlanRepo, nil, nil, nil, nil)
+		mockTemplateRepo.On("GetTemplateByID", uint(5)).Return(template, nil).Once()
+
+		_, err := service.InstantiateTemplate(5, "templateUser", models.PlanVersionAuthorUser, models.PlanTemplateInstantiateInput{StartDate: "15/03/2024"}, now)
+
+		assert.EqualError(t, err, "invalid plan: start_date must be YYYY-MM-DD")
+		mockPlanRepo.AssertNotCalled(t, "CreatePlan", mock.Anything)
+	})
+}
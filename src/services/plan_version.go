@@ -0,0 +1,343 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project/models"
+	"project/repository"
+	"sort"
+	"strconv"
+)
+
+// snapshotPlan captures the content of a plan and its tasks, tasks in plan order.
+func snapshotPlan(plan *models.Plan) models.PlanSnapshot {
+	snapshot := models.PlanSnapshot{
+		Title:       plan.Title,
+		Description: plan.Description,
+		Status:      plan.Status,
+		StartDate:   plan.StartDate,
+		EndDate:     plan.EndDate,
+		Tasks:       make([]models.PlanTaskSnapshot, 0, len(plan.Tasks)),
+	}
+	for _, task := range plan.Tasks {
+		snapshot.Tasks = append(snapshot.Tasks, models.PlanTaskSnapshot{
+			TaskID:      task.ID,
+			Type:        task.Type,
+			Title:       task.Title,
+			Description: task.Description,
+			Frequency:   task.Frequency,
+			Recurrence:  task.Recurrence,
+			Duration:    task.Duration,
+			Order:       task.Order,
+			Level:       task.Level,
+		})
+		if task.ExerciseID != nil {
+			snapshot.Tasks[len(snapshot.Tasks)-1].ExerciseID = *task.ExerciseID
+		}
+	}
+	sort.SliceStable(snapshot.Tasks, func(i, j int) bool { return snapshot.Tasks[i].Order < snapshot.Tasks[j].Order })
+	return snapshot
+}
+
+// recordPlanVersion stores the current state of plan as its next version. Versioning never blocks a change
+// that is already saved, so failures are logged only; without a version repository nothing is recorded.
+func recordPlanVersion(versionRepo repository.PlanVersionRepository, plan *models.Plan, author models.PlanVersionAuthor, authorID, reason string) {
+	if versionRepo == nil || plan == nil || plan.ID == 0 {
+		return
+	}
+	number := 1
+	latest, err := versionRepo.GetLatestVersion(plan.ID)
+	if err != nil {
+		log.Printf("ERROR: [PlanService] Could not record a version of plan ID %d: %v", plan.ID, err)
+		return
+	}
+	if latest != nil {
+		number = latest.Version + 1
+	}
+	version := &models.PlanVersion{
+		PlanID:   plan.ID,
+		Version:  number,
+		Author:   author,
+		AuthorID: authorID,
+		Reason:   reason,
+		Snapshot: snapshotPlan(plan),
+	}
+	if err := versionRepo.CreateVersion(version); err != nil {
+		log.Printf("ERROR: [PlanService] Failed to record version %d of plan ID %d: %v", number, plan.ID, err)
+	}
+}
+
+// diffPlanSnapshots compares two plan snapshots. Tasks are matched by TaskID.
+func diffPlanSnapshots(from, to models.PlanSnapshot) *models.PlanVersionDiff {
+	diff := &models.PlanVersionDiff{
+		Changes:      diffFields(planSnapshotFields(from), planSnapshotFields(to)),
+		AddedTasks:   []models.PlanTaskSnapshot{},
+		RemovedTasks: []models.PlanTaskSnapshot{},
+		ChangedTasks: []models.PlanTaskChange{},
+	}
+	before := make(map[uint]models.PlanTaskSnapshot, len(from.Tasks))
+	for _, task := range from.Tasks {
+		before[task.TaskID] = task
+	}
+	after := make(map[uint]bool, len(to.Tasks))
+	for _, task := range to.Tasks {
+		after[task.TaskID] = true
+		old, ok := before[task.TaskID]
+		if !ok {
+			diff.AddedTasks = append(diff.AddedTasks, task)
+			continue
+		}
+		if changes := diffFields(taskSnapshotFields(old), taskSnapshotFields(task)); len(changes) > 0 {
+			diff.ChangedTasks = append(diff.ChangedTasks, models.PlanTaskChange{TaskID: task.TaskID, Title: task.Title, Changes: changes})
+		}
+	}
+	for _, task := range from.Tasks {
+		if !after[task.TaskID] {
+			diff.RemovedTasks = append(diff.RemovedTasks, task)
+		}
+	}
+	return diff
+}
+
+// snapshotField is a named field value compared by diffFields.
+type snapshotField struct {
+	name, value string
+}
+
+func planSnapshotFields(s models.PlanSnapshot) []snapshotField {
+	return []snapshotField{
+		{"title", s.Title}, {"description", s.Description}, {"status", string(s.Status)},
+		{"start_date", s.StartDate}, {"end_date", s.EndDate},
+	}
+}
+
+func taskSnapshotFields(t models.PlanTaskSnapshot) []snapshotField {
+	return []snapshotField{
+		{"type", string(t.Type)}, {"title", t.Title}, {"description", t.Description},
+		{"frequency", t.Frequency}, {"duration", t.Duration}, {"order", strconv.Itoa(t.Order)}, {"level", strconv.Itoa(t.Level)},
+		{"exercise_id", strconv.FormatUint(uint64(t.ExerciseID), 10)},
+	}
+}
+
+// diffFields lists the fields whose values differ; both slices hold the same fields in the same order.
+func diffFields(from, to []snapshotField) []models.PlanFieldChange {
+	var changes []models.PlanFieldChange
+	for i := range from {
+		if from[i].value != to[i].value {
+			changes = append(changes, models.PlanFieldChange{Field: from[i].name, From: from[i].value, To: to[i].value})
+		}
+	}
+	return changes
+}
+
+// GetPlanVersions lists the versions of a plan, oldest first.
+func (s *planService) GetPlanVersions(planID uint) ([]models.PlanVersion, error) {
+	if s.versionRepo == nil {
+		return nil, errors.New("planversionrepo not initialized")
+	}
+	if _, err := s.GetPlanDetails(planID); err != nil {
+		return nil, err
+	}
+	versions, err := s.versionRepo.GetVersionsByPlanID(planID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch versions of plan ID %d", planID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	return versions, nil
+}
+
+// DiffPlanVersions describes the changes from version from to version to of a plan.
+func (s *planService) DiffPlanVersions(planID uint, from, to int) (*models.PlanVersionDiff, error) {
+	if s.versionRepo == nil {
+		return nil, errors.New("planversionrepo not initialized")
+	}
+	fromVersion, err := s.getPlanVersion(planID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.getPlanVersion(planID, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffPlanSnapshots(fromVersion.Snapshot, toVersion.Snapshot)
+	diff.PlanID, diff.FromVersion, diff.ToVersion = planID, from, to
+	return diff, nil
+}
+
+// RollbackPlan restores the content of a plan owned by userID to an earlier version: its title, description,
+// dates and tasks. Tasks keep their IDs, so tasks removed since that version are restored together with their
+// check-ins, and tasks added since are removed. The plan's status is not rolled back. The restored tasks pass the
+// safety rules again, since the version may predate the plan's safety review. The rollback is recorded as a new
+// version.
+func (s *planService) RollbackPlan(planID uint, userID string, version int, reason string) (*models.Plan, error) {
+	if s.versionRepo == nil {
+		return nil, errors.New("planversionrepo not initialized")
+	}
+	plan, err := s.getEditablePlan(planID, userID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getPlanVersion(planID, version)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := target.Snapshot
+	snapshot.Status = plan.Status
+	if snapshot.Tasks, err = s.screenRestoredTasks(plan, version, snapshot.Tasks); err != nil {
+		return nil, err
+	}
+	if diffPlanSnapshots(snapshotPlan(plan), snapshot).Empty() {
+		log.Printf("INFO: [PlanService] Plan ID %d already matches version %d. No action taken for userID '%s'.", planID, version, userID)
+		return plan, nil
+	}
+
+	current := make(map[uint]*models.PlanTask, len(plan.Tasks))
+	for i := range plan.Tasks {
+		current[plan.Tasks[i].ID] = &plan.Tasks[i]
+	}
+	tasks := make([]models.PlanTask, 0, len(snapshot.Tasks))
+	for _, taskSnapshot := range snapshot.Tasks {
+		task, ok := current[taskSnapshot.TaskID]
+		if !ok {
+			if task, err = s.restoreTask(planID, taskSnapshot.TaskID); err != nil {
+				return nil, err
+			}
+		}
+		delete(current, taskSnapshot.TaskID)
+		task.Type, task.Title, task.Description = taskSnapshot.Type, taskSnapshot.Title, taskSnapshot.Description
+		task.Frequency, task.Recurrence, task.Duration, task.Order = taskSnapshot.Frequency, taskSnapshot.Recurrence, taskSnapshot.Duration, taskSnapshot.Order
+		task.Level = taskSnapshot.Level
+		task.ExerciseID, task.Exercise = nil, nil
+		if taskSnapshot.ExerciseID != 0 {
+			exerciseID := taskSnapshot.ExerciseID
+			task.ExerciseID = &exerciseID
+		}
+		if err := s.planRepo.UpdatePlanTask(task); err != nil {
+			errMsg := fmt.Sprintf("failed to roll back task ID %d of plan ID %d", task.ID, planID)
+			log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		tasks = append(tasks, *task)
+	}
+	for taskID := range current { // Added after the target version
+		if err := s.planRepo.DeletePlanTask(taskID, false); err != nil {
+			errMsg := fmt.Sprintf("failed to remove task ID %d of plan ID %d during rollback", taskID, planID)
+			log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+	}
+
+	plan.Title, plan.Description = snapshot.Title, snapshot.Description
+	plan.StartDate, plan.EndDate = snapshot.StartDate, snapshot.EndDate
+	plan.Tasks = tasks
+	if err := s.planRepo.UpdatePlan(plan); err != nil {
+		errMsg := fmt.Sprintf("failed to roll back plan ID %d to version %d", planID, version)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("回滚到版本 %d", version)
+	}
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorUser, userID, reason)
+	log.Printf("INFO: [PlanService] Plan ID %d rolled back to version %d by userID '%s'.", planID, version, userID)
+	return plan, nil
+}
+
+// screenRestoredTasks applies the safety rules to the tasks of the version a plan rolls back to, as the plan's
+// safety review did: tasks it adjusted are adjusted again and tasks it removed stay removed. A version the rules
+// reject cannot be restored. Without a safety service the tasks are restored as they were.
+func (s *planService) screenRestoredTasks(plan *models.Plan, version int, snapshots []models.PlanTaskSnapshot) ([]models.PlanTaskSnapshot, error) {
+	if s.safetyService == nil {
+		return snapshots, nil
+	}
+	tasks := make([]models.PlanTask, 0, len(snapshots))
+	byTaskID := make(map[uint]models.PlanTaskSnapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		byTaskID[snapshot.TaskID] = snapshot
+		tasks = append(tasks, models.PlanTask{
+			ID: snapshot.TaskID, PlanID: plan.ID, Type: snapshot.Type, Title: snapshot.Title, Description: snapshot.Description,
+			Frequency: snapshot.Frequency, Recurrence: snapshot.Recurrence, Duration: snapshot.Duration, Order: snapshot.Order,
+		})
+	}
+	checked, adjustments, err := s.safetyService.CheckTasks(plan.UserID, tasks)
+	if err != nil {
+		return nil, err
+	}
+	for _, adjustment := range adjustments {
+		if adjustment.Action == "reject" {
+			return nil, fmt.Errorf("cannot roll back plan %d to version %d: %s", plan.ID, version, adjustment.Reason)
+		}
+	}
+	if len(checked) == 0 {
+		return nil, fmt.Errorf("cannot roll back plan %d to version %d: none of its tasks pass the safety rules", plan.ID, version)
+	}
+	screened := make([]models.PlanTaskSnapshot, 0, len(checked))
+	for _, task := range checked {
+		snapshot := byTaskID[task.ID]
+		snapshot.Type, snapshot.Title, snapshot.Description = task.Type, task.Title, task.Description
+		snapshot.Frequency, snapshot.Recurrence, snapshot.Duration, snapshot.Order = task.Frequency, task.Recurrence, task.Duration, task.Order
+		screened = append(screened, snapshot)
+	}
+	if len(adjustments) > 0 {
+		log.Printf("INFO: [PlanService] Safety rules changed %d tasks of version %d restored to plan ID %d.", len(adjustments), version, plan.ID)
+	}
+	return screened, nil
+}
+
+// getPlanVersion fetches one version of a plan.
+func (s *planService) getPlanVersion(planID uint, version int) (*models.PlanVersion, error) {
+	planVersion, err := s.versionRepo.GetVersion(planID, version)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch version %d of plan ID %d", version, planID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if planVersion == nil {
+		return nil, fmt.Errorf("version %d of plan %d not found", version, planID)
+	}
+	return planVersion, nil
+}
+
+// restoreTask undoes the deletion of a task of the plan for a rollback.
+func (s *planService) restoreTask(planID, taskID uint) (*models.PlanTask, error) {
+	if err := s.planRepo.RestorePlanTask(taskID); err != nil {
+		errMsg := fmt.Sprintf("failed to restore task ID %d of plan ID %d", taskID, planID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	task, err := s.planRepo.GetTaskByID(taskID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to fetch restored task ID %d", taskID)
+		log.Printf("ERROR: [PlanService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if task == nil || task.PlanID != planID { // Hard-deleted since
+		return nil, fmt.Errorf("cannot roll back: task %d of plan %d no longer exists", taskID, planID)
+	}
+	return task, nil
+}
+
+// BackfillPlanVersions records a first version for plans created before versioning, so that their current
+// content can be rolled back to. It returns the number of plans versioned.
+func BackfillPlanVersions(planRepo repository.PlanRepository, versionRepo repository.PlanVersionRepository) (int, error) {
+	planIDs, err := versionRepo.GetUnversionedPlanIDs()
+	if err != nil {
+		return 0, err
+	}
+	versioned := 0
+	for _, planID := range planIDs {
+		plan, err := planRepo.GetPlanByID(planID)
+		if err != nil {
+			return versioned, err
+		}
+		if plan == nil {
+			continue
+		}
+		recordPlanVersion(versionRepo, plan, models.PlanVersionAuthorSystem, "", "版本记录启用前的计划内容")
+		versioned++
+	}
+	log.Printf("INFO: [PlanService] Backfilled a first version for %d plans.", versioned)
+	return versioned, nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPlanVersionRepository is a mock type for the PlanVersionRepository type
+type MockPlanVersionRepository struct {
+	mock.Mock
+}
+
+func (m *MockPlanVersionRepository) CreateVersion(version *models.PlanVersion) error {
+	args := m.Called(version)
+	return args.Error(0)
+}
+
+func (m *MockPlanVersionRepository) GetVersionsByPlanID(planID uint) ([]models.PlanVersion, error) {
+	args := m.Called(planID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.PlanVersion), args.Error(1)
+}
+
+func (m *MockPlanVersionRepository) GetVersion(planID uint, version int) (*models.PlanVersion, error) {
+	args := m.Called(planID, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PlanVersion), args.Error(1)
+}
+
+func (m *MockPlanVersionRepository) GetLatestVersion(planID uint) (*models.PlanVersion, error) {
+	args := m.Called(planID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PlanVersion), args.Error(1)
+}
+
+func (m *MockPlanVersionRepository) GetUnversionedPlanIDs() ([]uint, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
+func TestDiffPlanSnapshots(t *testing.T) {
+	from := models.PlanSnapshot{Title: "计划", Status: models.PlanStatusActive, Tasks: []models.PlanTaskSnapshot{
+		{TaskID: 1, Title: "凯格尔运动", Frequency: "每日", Order: 1},
+		{TaskID: 2, Title: "深蹲", Frequency: "每周3次", Order: 2},
+	}}
+	to := models.PlanSnapshot{Title: "计划", Status: models.PlanStatusActive, EndDate: "2024-04-01", Tasks: []models.PlanTaskSnapshot{
+		{TaskID: 1, Title: "凯格尔运动", Frequency: "每日2次", Order: 1},
+		{TaskID: 3, Title: "快走", Frequency: "每日", Order: 2},
+	}}
+
+	diff := diffPlanSnapshots(from, to)
+
+	assert.Equal(t, []models.PlanFieldChange{{Field: "end_date", From: "", To: "2024-04-01"}}, diff.Changes)
+	assert.Equal(t, []models.PlanTaskChange{{TaskID: 1, Title: "凯格尔运动", Changes: []models.PlanFieldChange{{Field: "frequency", From: "每日", To: "每日2次"}}}}, diff.ChangedTasks)
+	assert.Len(t, diff.AddedTasks, 1)
+	assert.Equal(t, uint(3), diff.AddedTasks[0].TaskID)
+	assert.Len(t, diff.RemovedTasks, 1)
+	assert.Equal(t, uint(2), diff.RemovedTasks[0].TaskID)
+	assert.False(t, diff.Empty())
+	assert.True(t, diffPlanSnapshots(from, from).Empty())
+}
+
+func TestRecordPlanVersion(t *testing.T) {
+	mockVersionRepo := new(MockPlanVersionRepository)
+	plan := &models.Plan{ID: 4, Title: "计划", Tasks: []models.PlanTask{{ID: 42, Title: "后", Order: 2}, {ID: 41, Title: "前", Order: 1}}}
+	mockVersionRepo.On("GetLatestVersion", uint(4)).Return(&models.PlanVersion{PlanID: 4, Version: 2}, nil).Once()
+	mockVersionRepo.On("CreateVersion", mock.MatchedBy(func(v *models.PlanVersion) bool {
+		return v.PlanID == 4 && v.Version == 3 && v.Author == models.PlanVersionAuthorUser && v.AuthorID == "u1" &&
+			len(v.Snapshot.Tasks) == 2 && v.Snapshot.Tasks[0].TaskID == 41
+	})).Return(nil).Once()
+
+	recordPlanVersion(mockVersionRepo, plan, models.PlanVersionAuthorUser, "u1", "修改计划信息")
+
+	mockVersionRepo.AssertExpectations(t)
+	assert.NotPanics(t, func() { recordPlanVersion(nil, plan, models.PlanVersionAuthorUser, "u1", "") })
+}
+
+func TestPlanService_RollbackPlan(t *testing.T) {
+	userID := "rollbackUser"
+	version1 := &models.PlanVersion{PlanID: 6, Version: 1, Snapshot: models.PlanSnapshot{
+		Title: "原计划", Status: models.PlanStatusPending, Tasks: []models.PlanTaskSnapshot{
+			{TaskID: 61, Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日", Order: 1},
+			{TaskID: 62, Type: models.TaskTypeHabit, Title: "早睡", Frequency: "每日", Order: 2},
+		},
+	}}
+	newPlan := func() *models.Plan {
+		return &models.Plan{ID: 6, UserID: userID, Title: "新计划", Status: models.PlanStatusActive, Tasks: []models.PlanTask{
+			{ID: 61, PlanID: 6, Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日2次", Order: 1},
+			{ID: 63, PlanID: 6, Type: models.TaskTypeHabit, Title: "冥想", Frequency: "每日", Order: 2},
+		}}
+	}
+
+	t.Run("Restores deleted tasks and removes added ones", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockVersionRepo := new(MockPlanVersionRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, mockVersionRepo, nil)
+		plan := newPlan()
+		mockPlanRepo.On("GetPlanByID", uint(6)).Return(plan, nil).Once()
+		mockVersionRepo.On("GetVersion", uint(6), 1).Return(version1, nil).Once()
+		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 61 && task.Frequency == "每日" })).Return(nil).Once()
+		mockPlanRepo.On("RestorePlanTask", uint(62)).Return(nil).Once()
+		mockPlanRepo.On("GetTaskByID", uint(62)).Return(&models.PlanTask{ID: 62, PlanID: 6, Title: "早睡（旧）", IsCompleted: true}, nil).Once()
+		mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 62 && task.Title == "早睡" && task.IsCompleted })).Return(nil).Once()
+		mockPlanRepo.On("DeletePlanTask", uint(63), false).Return(nil).Once()
+		mockPlanRepo.On("UpdatePlan", plan).Return(nil).Once()
+		mockVersionRepo.On("GetLatestVersion", uint(6)).Return(&models.PlanVersion{PlanID: 6, Version: 3}, nil).Once()
+		mockVersionRepo.On("CreateVersion", mock.MatchedBy(func(v *models.PlanVersion) bool {
+			return v.Version == 4 && v.Author == models.PlanVersionAuthorUser && v.Reason == "回滚到版本 1"
+		})).Return(nil).Once()
+
+		result, err := service.RollbackPlan(6, userID, 1, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "原计划", result.Title)
+		assert.Equal(t, models.PlanStatusActive, result.Status, "status is not rolled back")
+		assert.Equal(t, []uint{61, 62}, []uint{result.Tasks[0].ID, result.Tasks[1].ID})
+		mockPlanRepo.AssertExpectations(t)
+		mockVersionRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown version", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockVersionRepo := new(MockPlanVersionRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, mockVersionRepo, nil)
+		mockPlanRepo.On("GetPlanByID", uint(6)).Return(newPlan(), nil).Once()
+		mockVersionRepo.On("GetVersion", uint(6), 9).Return(nil, nil).Once()
+
+		_, err := service.RollbackPlan(6, userID, 9, "")
+
+		assert.EqualError(t, err, "version 9 of plan 6 not found")
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlan", mock.Anything)
+	})
+
+	t.Run("Rolling back past the safety review keeps its changes", func(t *testing.T) {
+		config.AppConfig.PlanSafety = config.PlanSafetyConfig{Rules: testSafetyRules()}
+		defer func() { config.AppConfig.PlanSafety = config.PlanSafetyConfig{} }()
+		mockPlanRepo := new(MockPlanRepository)
+		mockVersionRepo := new(MockPlanVersionRepository)
+		mockProfileRepo := new(MockAssessmentProfileRepository)
+		safety := NewPlanSafetyService(mockPlanRepo, nil, NewAssessmentProfileService(mockProfileRepo, nil), nil)
+		service := NewPlanService(mockPlanRepo, nil, safety, nil, mockVersionRepo, nil)
+		// Version 1 is the plan as generated; the review then adjusted task 51 and removed task 52
+		generated := testSafetyPlan()
+		reviewed := &models.Plan{ID: 5, UserID: "safetyUser", Title: generated.Title, Status: models.PlanStatusActive, Tasks: []models.PlanTask{
+			{ID: 51, PlanID: 5, Type: models.TaskTypeExercise, Title: "中低强度有氧运动", Description: "快走为主", Frequency: "每周3次", Order: 1},
+			{ID: 53, PlanID: 5, Type: models.TaskTypeHabit, Title: "健康饮水", Frequency: "每日", Order: 2},
+		}}
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(reviewed, nil).Once()
+		mockVersionRepo.On("GetVersion", uint(5), 1).Return(&models.PlanVersion{PlanID: 5, Version: 1, Snapshot: snapshotPlan(generated)}, nil).Once()
+		mockProfileRepo.On("GetLatestProfileByUserID", "safetyUser").Return(&models.AssessmentProfile{RiskFlags: []string{"hypertension"}}, nil)
+
+		result, err := service.RollbackPlan(5, "safetyUser", 1, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"中低强度有氧运动", "健康饮水"}, []string{result.Tasks[0].Title, result.Tasks[1].Title})
+		mockPlanRepo.AssertNotCalled(t, "RestorePlanTask", mock.Anything)
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlan", mock.Anything)
+
+		// A version the rules reject cannot be restored
+		mockProfileRepo.ExpectedCalls = nil
+		mockProfileRepo.On("GetLatestProfileByUserID", "safetyUser").Return(&models.AssessmentProfile{RiskFlags: []string{"nitrate_medication"}}, nil)
+		generated.Tasks[2].Title = "按需服用西地那非"
+		mockPlanRepo.On("GetPlanByID", uint(5)).Return(reviewed, nil).Once()
+		mockVersionRepo.On("GetVersion", uint(5), 1).Return(&models.PlanVersion{PlanID: 5, Version: 1, Snapshot: snapshotPlan(generated)}, nil).Once()
+
+		_, err = service.RollbackPlan(5, "safetyUser", 1, "")
+
+		assert.EqualError(t, err, "cannot roll back plan 5 to version 1: 硝酸酯类药物禁止合用。")
+	})
+
+	t.Run("Only the owner can roll back", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockVersionRepo := new(MockPlanVersionRepository)
+		service := NewPlanService(mockPlanRepo, nil, nil, nil, mockVersionRepo, nil)
+		mockPlanRepo.On("GetPlanByID", uint(6)).Return(newPlan(), nil).Once()
+
+		_, err := service.RollbackPlan(6, "intruder", 1, "")
+
+		assert.EqualError(t, err, "unauthorized to modify plan 6")
+		mockVersionRepo.AssertNotCalled(t, "GetVersion", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"sort"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// plannerSchemaPrompt describes the JSON the planner agent must return. It mirrors models.Plan/models.PlanTask.
+const plannerSchemaPrompt = `输出格式（JSON）：
+{
+  "title": "计划标题",
+  "description": "计划简介",
+  "tasks": [
+    {
+      "type": "exercise | habit | knowledge | generic",
+      "title": "任务标题",
+      "description": "具体做法与注意事项",
+      "frequency": "频率，如：每日2次、每周3次",
+      "duration": "时长或数量，如：每次10分钟、3组每组10次",
+      "order": 1
+    }
+  ]
+}`
+
+// PlannerService asks the planner agent for a structured plan and persists it as a models.Plan.
+type PlannerService interface {
+	// GeneratePlan creates a plan for the user from the agent's JSON output. userRequest is the user's own wording
+	// of what the plan should address and may be empty.
+	GeneratePlan(userID, userRequest string) (*models.Plan, error)
+}
+
+type plannerService struct {
+	planRepo       repository.PlanRepository
+	profileService AssessmentProfileService // Optional; supplies the user's health profile as context
+	safetyService  PlanSafetyService
+	versionRepo    repository.PlanVersionRepository // Optional; records the created plan's versions
+	exerciseRepo   repository.ExerciseRepository    // Optional; exercise tasks must then follow approved exercises
+	llm            LLMClient
+}
+
+// NewPlannerService creates a new instance of PlannerService.
+func NewPlannerService(planRepo repository.PlanRepository, profileService AssessmentProfileService, safetyService PlanSafetyService, versionRepo repository.PlanVersionRepository, exerciseRepo repository.ExerciseRepository, llm LLMClient) PlannerService {
+	return &plannerService{
+		planRepo:       planRepo,
+		profileService: profileService,
+		safetyService:  safetyService,
+		versionRepo:    versionRepo,
+		exerciseRepo:   exerciseRepo,
+		llm:            llm,
+	}
+}
+
+// plannerPlanOutput is the JSON shape requested from the planner agent.
+type plannerPlanOutput struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Tasks       []plannerTaskOutput `json:"tasks"`
+}
+
+type plannerTaskOutput struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Frequency   string `json:"frequency"`
+	Duration    string `json:"duration"`
+	Order       int    `json:"order"`
+	ExerciseID  uint   `json:"exercise_id"`
+}
+
+// GeneratePlan asks the planner agent for a plan in JSON mode. Malformed output is repaired where possible,
+// otherwise the agent is told what was wrong and asked again, up to PlannerConfig.MaxAttempts times.
+// With an exercise library, exercise tasks must follow its approved exercises.
+// The plan is saved as pending and then passes the safety review.
+func (s *plannerService) GeneratePlan(userID, userRequest string) (*models.Plan, error) {
+	if userID == "" {
+		log.Printf("WARN: [PlannerService] GeneratePlan called with empty userID.")
+		return nil, errors.New("userID cannot be empty")
+	}
+	if s.llm == nil || s.planRepo == nil {
+		return nil, errors.New("planner service not initialized")
+	}
+	cfg := config.AppConfig.Planner
+	model := agentModel(cfg.Model, cfg.AgentID)
+	if model == "" {
+		log.Printf("ERROR: [PlannerService] No model configured for planner agent '%s'.", cfg.AgentID)
+		return nil, fmt.Errorf("no model configured for planner agent '%s'", cfg.AgentID)
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	log.Printf("INFO: [PlannerService] Generating plan for userID %s with model %s.", userID, model)
+
+	library, err := s.exerciseLibrary()
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt := strings.TrimSpace(cfg.SystemPrompt) + "\n\n" + plannerSchemaPrompt
+	if len(library) > 0 {
+		systemPrompt += "\n\n" + describeExerciseLibrary(library)
+	}
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: s.buildPlannerRequest(userID, userRequest)},
+	}
+
+	var plan *models.Plan
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		raw, err := s.llm.Complete(model, messages, true)
+		if err != nil {
+			errMsg := fmt.Sprintf("planner agent call failed for userID %s", userID)
+			log.Printf("ERROR: [PlannerService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		plan, lastErr = parsePlannerOutput(raw, cfg.MaxTasks, library)
+		if lastErr == nil {
+			break
+		}
+		log.Printf("WARN: [PlannerService] Attempt %d/%d for userID %s returned an invalid plan: %v", attempt, maxAttempts, userID, lastErr)
+		messages = append(messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: raw},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf("上面的输出无法使用：%v。请修正后只输出一个符合上述格式的 JSON 对象。", lastErr)},
+		)
+	}
+	if lastErr != nil {
+		errMsg := fmt.Sprintf("planner agent did not return a valid plan for userID %s after %d attempts", userID, maxAttempts)
+		log.Printf("ERROR: [PlannerService] %s: %v", errMsg, lastErr)
+		return nil, fmt.Errorf("%s: %w", errMsg, lastErr)
+	}
+
+	plan.UserID = userID
+	plan.Status = models.PlanStatusPending // Activated by the safety review
+	if err := s.planRepo.CreatePlan(plan); err != nil {
+		errMsg := fmt.Sprintf("failed to create planner plan for userID %s", userID)
+		log.Printf("ERROR: [PlannerService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	reason := "规划师智能体生成计划"
+	if request := strings.TrimSpace(userRequest); request != "" {
+		reason += "：" + request
+	}
+	recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorPlannerAgent, "", reason)
+	reviewNewPlan(s.safetyService, s.planRepo, s.versionRepo, plan)
+	log.Printf("INFO: [PlannerService] Successfully generated plan ID %d for userID %s with %d tasks.", plan.ID, userID, len(plan.Tasks))
+	return plan, nil
+}
+
+// exerciseLibrary returns the approved exercises the planner may choose from, by ID; none without an exercise
+// repository.
+func (s *plannerService) exerciseLibrary() (map[uint]*models.Exercise, error) {
+	library := make(map[uint]*models.Exercise)
+	if s.exerciseRepo == nil {
+		return library, nil
+	}
+	exercises, err := s.exerciseRepo.ListExercises(models.ExerciseQuery{Status: models.ReviewStatusApproved, ReviewedAfter: approvalCutoff(time.Now())})
+	if err != nil {
+		errMsg := "failed to load the exercise library for the planner"
+		log.Printf("ERROR: [PlannerService] %s: %v", errMsg, err)
+		return nil, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	for _, exercise := range exercises {
+		library[exercise.ID] = exercise
+	}
+	return library, nil
+}
+
+// buildPlannerRequest describes the user to the planner: their latest health profile and their own request.
+func (s *plannerService) buildPlannerRequest(userID, userRequest string) string {
+	var sb strings.Builder
+	sb.WriteString("请为以下用户制定个性化计划。\n")
+	if s.profileService != nil {
+		profile, err := s.profileService.GetLatestProfile(userID)
+		if err != nil {
+			log.Printf("WARN: [PlannerService] Failed to load assessment profile for userID %s: %v", userID, err)
+		}
+		if profile != nil {
+			sb.WriteString(describeProfile(profile))
+		}
+	}
+	if strings.TrimSpace(userRequest) != "" {
+		sb.WriteString(fmt.Sprintf("用户的需求：%s\n", strings.TrimSpace(userRequest)))
+	}
+	return sb.String()
+}
+
+// describeProfile renders an assessment profile as plain text for LLM context.
+func describeProfile(profile *models.AssessmentProfile) string {
+	var sb strings.Builder
+	sb.WriteString("[用户健康画像]\n")
+	if len(profile.Goals) > 0 {
+		sb.WriteString(fmt.Sprintf("- 目标：%s\n", strings.Join(profile.Goals, "；")))
+	}
+	if len(profile.Concerns) > 0 {
+		sb.WriteString(fmt.Sprintf("- 关注的问题：%s\n", strings.Join(profile.Concerns, "；")))
+	}
+	if len(profile.RiskFlags) > 0 {
+		sb.WriteString(fmt.Sprintf("- 风险标记：%s\n", strings.Join(profile.RiskFlags, ", ")))
+	}
+	dimensionIDs := make([]string, 0, len(profile.Dimensions))
+	for id := range profile.Dimensions {
+		dimensionIDs = append(dimensionIDs, id)
+	}
+	sort.Strings(dimensionIDs)
+	for _, id := range dimensionIDs {
+		dim := profile.Dimensions[id]
+		sb.WriteString(fmt.Sprintf("- 指标 %s：%s（%s）\n", id, dim.Label, dim.Answer))
+	}
+	return sb.String()
+}
+
+// parsePlannerOutput turns the planner agent's reply into an unsaved plan. It repairs what it safely can
+// (code fences or text around the JSON object, unknown task types, missing or duplicate order, untitled tasks,
+// too many tasks, exercise IDs on other tasks) and returns an error describing anything it cannot. If library is
+// not empty, every exercise task must reference one of its exercises, whose name titles an untitled task;
+// otherwise exercise IDs are dropped.
+func parsePlannerOutput(raw string, maxTasks int, library map[uint]*models.Exercise) (*models.Plan, error) {
+	text := strings.TrimSpace(raw)
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object found in output")
+	}
+	var output plannerPlanOutput
+	if err := json.Unmarshal([]byte(text[start:end+1]), &output); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var tasks []plannerTaskOutput
+	for _, task := range output.Tasks {
+		taskType := planTaskType(strings.ToLower(strings.TrimSpace(task.Type)))
+		if len(library) == 0 || taskType != models.TaskTypeExercise {
+			task.ExerciseID = 0
+		} else if exercise := library[task.ExerciseID]; exercise == nil {
+			if task.ExerciseID == 0 {
+				return nil, fmt.Errorf("exercise task %q has no exercise_id", task.Title)
+			}
+			return nil, fmt.Errorf("task %q references exercise %d, which is not in the exercise library", task.Title, task.ExerciseID)
+		} else if strings.TrimSpace(task.Title) == "" {
+			task.Title = exercise.Name
+		}
+		if strings.TrimSpace(task.Title) == "" {
+			continue
+		}
+		if strings.TrimSpace(task.Frequency) == "" {
+			return nil, fmt.Errorf("task %q has no frequency", task.Title)
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return nil, errors.New("plan has no tasks")
+	}
+	// Tasks without an order keep their position after the ordered ones.
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Order > 0 && (tasks[j].Order <= 0 || tasks[i].Order < tasks[j].Order)
+	})
+	if maxTasks > 0 && len(tasks) > maxTasks {
+		log.Printf("WARN: [PlannerService] Planner returned %d tasks, keeping the first %d.", len(tasks), maxTasks)
+		tasks = tasks[:maxTasks]
+	}
+
+	title := strings.TrimSpace(output.Title)
+	if title == "" {
+		title = "我的个性化计划"
+	}
+	plan := &models.Plan{
+		Title:       title,
+		Description: strings.TrimSpace(output.Description),
+		Tasks:       make([]models.PlanTask, 0, len(tasks)),
+	}
+	for i, task := range tasks {
+		var exerciseID *uint
+		if task.ExerciseID != 0 {
+			id := task.ExerciseID
+			exerciseID = &id
+		}
+		plan.Tasks = append(plan.Tasks, models.PlanTask{
+			Type:        planTaskType(strings.ToLower(strings.TrimSpace(task.Type))),
+			Title:       strings.TrimSpace(task.Title),
+			Description: strings.TrimSpace(task.Description),
+			Frequency:   strings.TrimSpace(task.Frequency),
+			Recurrence:  ParseFrequency(task.Frequency),
+			Duration:    strings.TrimSpace(task.Duration),
+			Status:      models.TaskStatusPending,
+			Order:       i + 1,
+			ExerciseID:  exerciseID,
+		})
+	}
+	return plan, nil
+}
+
+// agentModel returns the configured model, falling back to the model of the given agent.
+func agentModel(model, agentID string) string {
+	if model != "" {
+		return model
+	}
+	for _, char := range config.AppConfig.LLMCharacters {
+		if char.ID == agentID {
+			return char.Model
+		}
+	}
+	return ""
+}
+
+// PlanLink returns the link to a plan as configured by PlannerConfig.PlanLinkFormat.
+func PlanLink(planID uint) string {
+	format := config.AppConfig.Planner.PlanLinkFormat
+	if format == "" {
+		format = "/plan/%d"
+	}
+	return fmt.Sprintf(format, planID)
+}
+
+// IsPlanRequest reports whether a chat message asks the planner agent to create a plan.
+func IsPlanRequest(message string) bool {
+	lower := strings.ToLower(message)
+	for _, keyword := range config.AppConfig.Planner.TriggerKeywords {
+		if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"project/config"
+	"project/models"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockLLMClient is a mock type for the LLMClient interface
+type MockLLMClient struct {
+	mock.Mock
+}
+
+func (m *MockLLMClient) Complete(model string, messages []openai.ChatCompletionMessage, jsonMode bool) (string, error) {
+	args := m.Called(model, messages, jsonMode)
+	return args.String(0), args.Error(1)
+}
+
+const validPlannerOutput = `{"title": "耐力提升计划", "description": "四周循序渐进", "tasks": [
+	{"type": "habit", "title": "规律作息", "frequency": "每日", "duration": "全天", "order": 2},
+	{"type": "exercise", "title": "盆底肌训练", "description": "收缩保持5秒", "frequency": "每日2次", "duration": "每次10分钟", "order": 1}
+]}`
+
+func TestParsePlannerOutput(t *testing.T) {
+	t.Run("Valid output is ordered by the order field", func(t *testing.T) {
+		plan, err := parsePlannerOutput(validPlannerOutput, 0, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "耐力提升计划", plan.Title)
+		assert.Len(t, plan.Tasks, 2)
+		assert.Equal(t, "盆底肌训练", plan.Tasks[0].Title)
+		assert.Equal(t, models.TaskTypeExercise, plan.Tasks[0].Type)
+		assert.Equal(t, 1, plan.Tasks[0].Order)
+		assert.Equal(t, 2, plan.Tasks[1].Order)
+		assert.Equal(t, models.TaskStatusPending, plan.Tasks[1].Status)
+	})
+
+	t.Run("Repairs fences, unknown types, missing order and extra tasks", func(t *testing.T) {
+		raw := "好的，计划如下：\n```json\n" + `{"tasks": [
+			{"type": "Meditation", "title": "冥想", "frequency": "每日"},
+			{"type": "EXERCISE", "title": "快走", "frequency": "每周3次", "order": 1},
+			{"title": "", "frequency": "每日"},
+			{"type": "knowledge", "title": "阅读", "frequency": "每周一次"}
+		]}` + "\n```"
+
+		plan, err := parsePlannerOutput(raw, 2, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "我的个性化计划", plan.Title)
+		assert.Len(t, plan.Tasks, 2)
+		assert.Equal(t, "快走", plan.Tasks[0].Title)
+		assert.Equal(t, models.TaskTypeExercise, plan.Tasks[0].Type)
+		assert.Equal(t, "冥想", plan.Tasks[1].Title)
+		assert.Equal(t, models.TaskTypeGeneric, plan.Tasks[1].Type)
+	})
+
+	t.Run("Exercise tasks follow the exercise library", func(t *testing.T) {
+		library := map[uint]*models.Exercise{3: {ID: 3, Name: "凯格尔基础收缩"}}
+
+		plan, err := parsePlannerOutput(`{"tasks": [
+			{"type": "exercise", "exercise_id": 3, "frequency": "每日2次"},
+			{"type": "habit", "title": "早睡", "exercise_id": 3, "frequency": "每日"}
+		]}`, 0, library)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "凯格尔基础收缩", plan.Tasks[0].Title, "untitled exercise tasks take the exercise's name")
+		if assert.NotNil(t, plan.Tasks[0].ExerciseID) {
+			assert.Equal(t, uint(3), *plan.Tasks[0].ExerciseID)
+		}
+		assert.Nil(t, plan.Tasks[1].ExerciseID, "only exercise tasks follow an exercise")
+
+		_, err = parsePlannerOutput(`{"tasks": [{"type": "exercise", "title": "深蹲", "exercise_id": 9, "frequency": "每日"}]}`, 0, library)
+		assert.EqualError(t, err, `task "深蹲" references exercise 9, which is not in the exercise library`)
+
+		_, err = parsePlannerOutput(`{"tasks": [{"type": "exercise", "title": "深蹲", "frequency": "每日"}]}`, 0, library)
+		assert.EqualError(t, err, `exercise task "深蹲" has no exercise_id`)
+
+		plan, err = parsePlannerOutput(`{"tasks": [{"type": "exercise", "title": "深蹲", "exercise_id": 9, "frequency": "每日"}]}`, 0, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, plan.Tasks[0].ExerciseID, "without a library exercise IDs are dropped")
+	})
+
+	t.Run("Unrepairable output is rejected", func(t *testing.T) {
+		_, err := parsePlannerOutput("我无法生成计划", 0, nil)
+		assert.EqualError(t, err, "no JSON object found in output")
+
+		_, err = parsePlannerOutput(`{"title": "x", "tasks": []}`, 0, nil)
+		assert.EqualError(t, err, "plan has no tasks")
+
+		_, err = parsePlannerOutput(`{"tasks": [{"title": "快走"}]}`, 0, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "has no frequency")
+
+		_, err = parsePlannerOutput(`{"tasks": [{"title": "快走", "order": "first"}]}`, 0, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid JSON")
+	})
+}
+
+func TestPlannerService_GeneratePlan(t *testing.T) {
+	config.AppConfig.Planner = config.PlannerConfig{AgentID: "hs_planner_agent", Model: "planner-model", SystemPrompt: "你是规划师。", MaxAttempts: 2}
+	userID := "plannerUser"
+
+	t.Run("Valid output is saved as a pending plan", func(t *testing.T) {
+		mockLLM := new(MockLLMClient)
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlannerService(mockPlanRepo, nil, nil, nil, nil, mockLLM)
+
+		mockLLM.On("Complete", "planner-model", mock.MatchedBy(func(msgs []openai.ChatCompletionMessage) bool {
+			return len(msgs) == 2 && msgs[1].Role == openai.ChatMessageRoleUser
+		}), true).Return(validPlannerOutput, nil).Once()
+		mockPlanRepo.On("CreatePlan", mock.MatchedBy(func(p *models.Plan) bool {
+			return p.UserID == userID && p.Status == models.PlanStatusPending && len(p.Tasks) == 2
+		})).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Plan).ID = 21
+		}).Return(nil).Once()
+
+		plan, err := service.GeneratePlan(userID, "帮我制定提升耐力的计划")
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint(21), plan.ID)
+		assert.Equal(t, models.PlanStatusPending, plan.Status) // No safety reviewer configured
+		mockLLM.AssertExpectations(t)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("Malformed output is retried with the validation error", func(t *testing.T) {
+		mockLLM := new(MockLLMClient)
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlannerService(mockPlanRepo, nil, nil, nil, nil, mockLLM)
+
+		mockLLM.On("Complete", "planner-model", mock.MatchedBy(func(msgs []openai.ChatCompletionMessage) bool { return len(msgs) == 2 }), true).
+			Return(`{"title": "计划", "tasks": []}`, nil).Once()
+		mockLLM.On("Complete", "planner-model", mock.MatchedBy(func(msgs []openai.ChatCompletionMessage) bool {
+			return len(msgs) == 4 && msgs[2].Role == openai.ChatMessageRoleAssistant
+		}), true).Return(validPlannerOutput, nil).Once()
+		mockPlanRepo.On("CreatePlan", mock.AnythingOfType("*models.Plan")).Return(nil).Once()
+
+		plan, err := service.GeneratePlan(userID, "")
+
+		assert.NoError(t, err)
+		assert.Len(t, plan.Tasks, 2)
+		mockLLM.AssertExpectations(t)
+		mockPlanRepo.AssertExpectations(t)
+	})
+
+	t.Run("Gives up after the configured attempts", func(t *testing.T) {
+		mockLLM := new(MockLLMClient)
+		mockPlanRepo := new(MockPlanRepository)
+		service := NewPlannerService(mockPlanRepo, nil, nil, nil, nil, mockLLM)
+
+		mockLLM.On("Complete", "planner-model", mock.Anything, true).Return("not json", nil).Twice()
+
+		plan, err := service.GeneratePlan(userID, "")
+
+		assert.Error(t, err)
+		assert.Nil(t, plan)
+		assert.Contains(t, err.Error(), "after 2 attempts")
+		mockLLM.AssertExpectations(t)
+		mockPlanRepo.AssertNotCalled(t, "CreatePlan", mock.Anything)
+	})
+
+	t.Run("LLM failure is returned", func(t *testing.T) {
+		mockLLM := new(MockLLMClient)
+		service := NewPlannerService(new(MockPlanRepository), nil, nil, nil, nil, mockLLM)
+		mockLLM.On("Complete", "planner-model", mock.Anything, true).Return("", errors.New("timeout")).Once()
+
+		plan, err := service.GeneratePlan(userID, "")
+
+		assert.Error(t, err)
+		assert.Nil(t, plan)
+		assert.Contains(t, err.Error(), "planner agent call failed")
+	})
+}
+
+func TestIsPlanRequest(t *testing.T) {
+	config.AppConfig.Planner.TriggerKeywords = []string{"制定计划", "make a plan"}
+
+	assert.True(t, IsPlanRequest("请帮我制定计划，想提升耐力"))
+	assert.True(t, IsPlanRequest("Can you MAKE A PLAN for me?"))
+	assert.False(t, IsPlanRequest("凯格尔运动怎么做？"))
+}
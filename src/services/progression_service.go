@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+	"time"
+)
+
+// ProgressionService moves exercise tasks through the levels of their progression based on check-in history.
+type ProgressionService interface {
+	// EvaluatePlan promotes or regresses the progressive tasks of a plan and tells the user about each change in chat.
+	// Days are counted in the user's timezone.
+	EvaluatePlan(plan *models.Plan, now time.Time) ([]models.LevelChange, error)
+	// EvaluateActivePlans evaluates every active plan and returns the number of level changes. It stops between plans
+	// once ctx is done, e.g. when the job's lease expires.
+	EvaluateActivePlans(ctx context.Context, now time.Time) (int, error)
+}
+
+type progressionService struct {
+	planRepo      repository.PlanRepository
+	checkInRepo   repository.CheckInRepository
+	versionRepo   repository.PlanVersionRepository // Optional; level changes are recorded as plan versions
+	notifications NotificationService              // Optional; level changes are announced to the user
+}
+
+// NewProgressionService creates a new instance of ProgressionService.
+func NewProgressionService(planRepo repository.PlanRepository, checkInRepo repository.CheckInRepository, versionRepo repository.PlanVersionRepository, notifications NotificationService) ProgressionService {
+	return &progressionService{
+		planRepo:      planRepo,
+		checkInRepo:   checkInRepo,
+		versionRepo:   versionRepo,
+		notifications: notifications,
+	}
+}
+
+func (s *progressionService) EvaluateActivePlans(ctx context.Context, now time.Time) (int, error) {
+	if s.planRepo == nil || s.checkInRepo == nil {
+		return 0, errors.New("planrepo or checkinrepo not initialized")
+	}
+	plans, err := s.planRepo.GetPlansByStatus(models.PlanStatusActive)
+	if err != nil {
+		errMsg := "failed to fetch active plans for progression"
+		log.Printf("ERROR: [ProgressionService] %s: %v", errMsg, err)
+		return 0, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	changed := 0
+	for _, plan := range plans {
+		if err := ctx.Err(); err != nil {
+			return changed, err
+		}
+		changes, err := s.EvaluatePlan(plan, now)
+		if err != nil {
+			// One plan failing must not hold back the others
+			log.Printf("ERROR: [ProgressionService] Evaluation of plan ID %d failed: %v", plan.ID, err)
+			continue
+		}
+		changed += len(changes)
+	}
+	log.Printf("INFO: [ProgressionService] Evaluated %d active plans, %d level changes.", len(plans), changed)
+	return changed, nil
+}
+
+func (s *progressionService) EvaluatePlan(plan *models.Plan, now time.Time) ([]models.LevelChange, error) {
+	if plan.Status != models.PlanStatusActive {
+		return nil, nil
+	}
+	timezone := ""
+	if s.notifications != nil {
+		prefs, err := s.notifications.GetPreferences(plan.UserID)
+		if err != nil {
+			errMsg := fmt.Sprintf("failed to fetch notification preferences of userID '%s'", plan.UserID)
+			log.Printf("ERROR: [ProgressionService] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		timezone = prefs.Timezone
+	}
+	today := now.In(LoadUserLocation(timezone))
+	var checkIns []models.TaskCheckIn
+	var changes []models.LevelChange
+	for i := range plan.Tasks {
+		task := &plan.Tasks[i]
+		progression := taskProgression(task)
+		if progression == nil {
+			continue
+		}
+		if task.Progression == "" || task.Level < 1 { // Matched by keyword, e.g. created by the planner agent
+			task.Progression, task.Level = progression.ID, 1
+			if err := s.planRepo.UpdatePlanTask(task); err != nil {
+				return changes, fmt.Errorf("failed to attach task ID %d to progression '%s': %w", task.ID, progression.ID, err)
+			}
+			log.Printf("INFO: [ProgressionService] Task ID %d ('%s') now follows progression '%s'.", task.ID, task.Title, progression.ID)
+		}
+		if checkIns == nil {
+			var err error
+			if checkIns, err = s.checkInRepo.GetCheckInsByPlanID(plan.ID); err != nil {
+				errMsg := fmt.Sprintf("failed to fetch check-ins of plan ID %d", plan.ID)
+				log.Printf("ERROR: [ProgressionService] %s: %v", errMsg, err)
+				return changes, fmt.Errorf("%s: %w", errMsg, err)
+			}
+		}
+
+		since := task.LevelSince
+		if since == "" {
+			since = plan.StartDate
+		}
+		if since == "" && !plan.CreatedAt.IsZero() {
+			since = plan.CreatedAt.In(today.Location()).Format("2006-01-02")
+		}
+		level, reason := evaluateProgression(progression, task, checkIns, since, today)
+		if level == task.Level {
+			continue
+		}
+		change := models.LevelChange{PlanID: plan.ID, TaskID: task.ID, Progression: progression.ID, FromLevel: task.Level, ToLevel: level, Reason: reason}
+		before := *task
+		applyExerciseLevel(task, progression.Levels[level-1])
+		task.Level, task.LevelSince = level, today.Format("2006-01-02")
+		if err := s.planRepo.UpdatePlanTask(task); err != nil {
+			*task = before
+			errMsg := fmt.Sprintf("failed to move task ID %d to level %d", task.ID, level)
+			log.Printf("ERROR: [ProgressionService] %s: %v", errMsg, err)
+			return changes, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		change.Title = task.Title
+		changes = append(changes, change)
+		log.Printf("INFO: [ProgressionService] Task ID %d of plan ID %d moved from level %d to %d: %s", task.ID, plan.ID, change.FromLevel, level, reason)
+	}
+	if len(changes) > 0 {
+		reasons := make([]string, 0, len(changes))
+		for _, change := range changes {
+			reasons = append(reasons, fmt.Sprintf("「%s」调整到第%d级", change.Title, change.ToLevel))
+		}
+		recordPlanVersion(s.versionRepo, plan, models.PlanVersionAuthorSystem, "", "运动进阶评估："+strings.Join(reasons, "；"))
+		s.notify(plan, changes, now)
+	}
+	return changes, nil
+}
+
+// ProgressionJob returns the background job that evaluates all active plans, registered with the JobRunner on the
+// progression.cron schedule.
+func ProgressionJob(service ProgressionService) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		changed, err := service.EvaluateActivePlans(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		out.Printf("%d level changes.", changed)
+		return nil
+	}
+}
+
+// notify tells the user about level changes through a notification from the progression agent.
+func (s *progressionService) notify(plan *models.Plan, changes []models.LevelChange, now time.Time) {
+	if s.notifications == nil {
+		return
+	}
+	var sb strings.Builder
+	for _, change := range changes {
+		if change.Promoted() {
+			sb.WriteString(fmt.Sprintf("恭喜！你的运动任务已升级到第%d级：「%s」。%s\n", change.ToLevel, change.Title, change.Reason))
+		} else {
+			sb.WriteString(fmt.Sprintf("为了循序渐进、保证安全，你的运动任务已调整回第%d级：「%s」。%s\n", change.ToLevel, change.Title, change.Reason))
+		}
+	}
+	notification := &models.Notification{
+		UserID:    plan.UserID,
+		Category:  models.NotificationCategoryLevelChange,
+		Topic:     models.NotificationTopicExercise,
+		Title:     "运动进阶调整",
+		Content:   strings.TrimSpace(sb.String()),
+		AgentID:   config.AppConfig.Progression.AgentID,
+		PlanID:    plan.ID,
+		DedupeKey: fmt.Sprintf("%s:%d:%d", models.NotificationCategoryLevelChange, plan.ID, now.Unix()),
+	}
+	if err := s.notifications.Send(notification, now); err != nil {
+		log.Printf("ERROR: [ProgressionService] Failed to notify userID '%s' of %d level changes: %v", plan.UserID, len(changes), err)
+	}
+}
+
+// taskProgression returns the exercise progression a task follows: the one it references, or for an exercise task
+// without one, the first whose keywords appear in its title. It returns nil if there is none or it has no levels.
+func taskProgression(task *models.PlanTask) *config.ExerciseProgression {
+	exercises := config.AppConfig.Progression.Exercises
+	if task.Progression == "" && task.Type != models.TaskTypeExercise {
+		return nil
+	}
+	title := strings.ToLower(task.Title)
+	for i := range exercises {
+		if len(exercises[i].Levels) == 0 {
+			continue
+		}
+		if task.Progression != "" {
+			if exercises[i].ID == task.Progression {
+				return &exercises[i]
+			}
+			continue
+		}
+		for _, keyword := range exercises[i].Keywords {
+			if keyword != "" && strings.Contains(title, strings.ToLower(keyword)) {
+				return &exercises[i]
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateProgression decides the level of a task from its check-ins (of any task; others are ignored) in the
+// criteria window ending yesterday. since is the date the current level started, today is in the user's timezone.
+// Discomfort can regress a task at any time; otherwise a level must be held for a full window before it changes.
+// It returns the task's current level and no reason if nothing changes.
+func evaluateProgression(progression *config.ExerciseProgression, task *models.PlanTask, checkIns []models.TaskCheckIn, since string, today time.Time) (int, string) {
+	criteria := progression.Criteria
+	level := task.Level
+	if level < 1 {
+		level = 1
+	}
+	if level > len(progression.Levels) {
+		level = len(progression.Levels)
+	}
+	windowDays := criteria.WindowDays
+	if windowDays <= 0 {
+		windowDays = 7
+	}
+	date := today.Format("2006-01-02")
+	windowStart := today.AddDate(0, 0, -windowDays).Format("2006-01-02")
+	held := since != "" && since <= windowStart
+
+	completedDays := make(map[string]bool)
+	discomfort := 0
+	var fatigue scoreMean
+	for i := range checkIns {
+		checkIn := &checkIns[i]
+		if checkIn.TaskID != task.ID || checkIn.DueDate < windowStart || checkIn.DueDate < since || checkIn.DueDate > date {
+			continue
+		}
+		if checkIn.Feedback != nil {
+			for _, tag := range checkIn.Feedback.Tags {
+				if contains(criteria.DiscomfortTags, tag) {
+					discomfort++
+					break
+				}
+			}
+			fatigue.add(checkIn.Feedback.Fatigue)
+		}
+		if checkIn.DueDate < date && checkIn.Status == models.TaskStatusCompleted {
+			completedDays[checkIn.DueDate] = true
+		}
+	}
+
+	if level > 1 && criteria.RegressOnTags > 0 && discomfort >= criteria.RegressOnTags {
+		return level - 1, fmt.Sprintf("最近有%d次打卡反馈身体不适，先降低强度。如不适持续，请及时就医。", discomfort)
+	}
+	if !held {
+		return level, ""
+	}
+	if level > 1 && criteria.RegressBelowDays > 0 && len(completedDays) < criteria.RegressBelowDays {
+		return level - 1, fmt.Sprintf("过去%d天只完成了%d天，先回到更容易坚持的强度。", windowDays, len(completedDays))
+	}
+	if level >= len(progression.Levels) || criteria.MinCompletedDays <= 0 || len(completedDays) < criteria.MinCompletedDays || discomfort > 0 {
+		return level, ""
+	}
+	if avg := fatigue.mean(); criteria.MaxAvgFatigue > 0 && avg != nil && *avg > criteria.MaxAvgFatigue {
+		return level, ""
+	}
+	return level + 1, fmt.Sprintf("过去%d天你完成了%d天，且没有不适反馈。", windowDays, len(completedDays))
+}
+
+// applyExerciseLevel replaces the task's content with the non-empty fields of the level.
+func applyExerciseLevel(task *models.PlanTask, level config.ExerciseLevel) {
+	if level.Title != "" {
+		task.Title = level.Title
+	}
+	if level.Description != "" {
+		task.Description = level.Description
+	}
+	if level.Frequency != "" {
+		task.Frequency = level.Frequency
+		task.Recurrence = ParseFrequency(level.Frequency)
+	}
+	if level.Duration != "" {
+		task.Duration = level.Duration
+	}
+}
+
+// agentName returns the display name of a chat agent, or its ID if it is not configured.
+func agentName(agentID string) string {
+	for _, char := range config.AppConfig.LLMCharacters {
+		if char.ID == agentID && char.Name != "" {
+			return char.Name
+		}
+	}
+	return agentID
+}
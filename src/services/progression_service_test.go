@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"project/config"
+	"project/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockChatRepository is a mock type for the ChatRepository type
+type MockChatRepository struct {
+	mock.Mock
+}
+
+func (m *MockChatRepository) SaveMessage(message models.ChatMessage) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMessagesByUserID(userID string) ([]models.ChatMessage, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ChatMessage), args.Error(1)
+}
+
+var testKegelProgression = config.ExerciseProgression{
+	ID:       "kegel",
+	Keywords: []string{"凯格尔"},
+	Levels: []config.ExerciseLevel{
+		{Title: "凯格尔运动", Frequency: "每日2次", Duration: "每次5分钟"},
+		{Title: "凯格尔运动（进阶）", Duration: "每组10次，共2组"},
+		{Title: "凯格尔运动（强化）", Frequency: "每日3次", Duration: "每组10次，共3组"},
+	},
+	Criteria: config.ProgressionCriteria{
+		WindowDays:       7,
+		MinCompletedDays: 5,
+		MaxAvgFatigue:    3.5,
+		DiscomfortTags:   []string{"身体不适"},
+		RegressOnTags:    2,
+		RegressBelowDays: 2,
+	},
+}
+
+// completedDays builds completed check-ins of task 1 on the given days of March 2024.
+func completedDays(days ...int) []models.TaskCheckIn {
+	var checkIns []models.TaskCheckIn
+	for _, d := range days {
+		checkIns = append(checkIns, models.TaskCheckIn{TaskID: 1, DueDate: time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC).Format("2006-01-02"), Status: models.TaskStatusCompleted})
+	}
+	return checkIns
+}
+
+func TestEvaluateProgression(t *testing.T) {
+	today := time.Date(2024, 3, 15, 3, 0, 0, 0, time.UTC) // Window: 8th to 14th
+	discomfort := func(day int) models.TaskCheckIn {
+		checkIn := completedDays(day)[0]
+		checkIn.Feedback = &models.CheckInFeedback{Tags: []string{"身体不适"}}
+		return checkIn
+	}
+	tired := func(checkIns []models.TaskCheckIn) []models.TaskCheckIn {
+		for i := range checkIns {
+			checkIns[i].Feedback = &models.CheckInFeedback{Fatigue: intPtr(5)}
+		}
+		return checkIns
+	}
+
+	tests := []struct {
+		name      string
+		level     int
+		since     string
+		checkIns  []models.TaskCheckIn
+		wantLevel int
+		wantInfo  string
+	}{
+		{"5 of 7 days promotes", 1, "2024-03-01", completedDays(8, 9, 10, 12, 14), 2, "完成了5天"},
+		{"4 of 7 days stays", 1, "2024-03-01", completedDays(8, 9, 10, 12), 1, ""},
+		{"Today's check-in does not count yet", 1, "2024-03-01", completedDays(9, 10, 12, 14, 15), 1, ""},
+		{"Level must be held for the whole window", 1, "2024-03-10", completedDays(10, 11, 12, 13, 14), 1, ""},
+		{"Discomfort blocks promotion", 1, "2024-03-01", append(completedDays(8, 9, 10, 12, 13), discomfort(14)), 1, ""},
+		{"High fatigue blocks promotion", 1, "2024-03-01", tired(completedDays(8, 9, 10, 12, 14)), 1, ""},
+		{"Top level stays", 3, "2024-03-01", completedDays(8, 9, 10, 11, 12, 13, 14), 3, ""},
+		{"Repeated discomfort regresses even right after a level change", 2, "2024-03-13", []models.TaskCheckIn{discomfort(13), discomfort(14)}, 1, "身体不适"},
+		{"Low adherence regresses", 2, "2024-03-01", completedDays(9), 1, "只完成了1天"},
+		{"Level 1 does not regress", 1, "2024-03-01", nil, 1, ""},
+		{"Check-ins before the level started are ignored", 2, "2024-03-12", []models.TaskCheckIn{discomfort(10), discomfort(11)}, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &models.PlanTask{ID: 1, Progression: "kegel", Level: tt.level}
+			level, reason := evaluateProgression(&testKegelProgression, task, tt.checkIns, tt.since, today)
+			assert.Equal(t, tt.wantLevel, level)
+			if tt.wantInfo == "" {
+				assert.Empty(t, reason)
+			} else {
+				assert.Contains(t, reason, tt.wantInfo)
+			}
+		})
+	}
+}
+
+func TestProgressionService_EvaluatePlan(t *testing.T) {
+	original := config.AppConfig.Progression
+	config.AppConfig.Progression = config.ProgressionConfig{AgentID: "hs_planner_agent", Exercises: []config.ExerciseProgression{testKegelProgression}}
+	defer func() { config.AppConfig.Progression = original }()
+
+	now := time.Date(2024, 3, 15, 3, 0, 0, 0, time.UTC)
+	plan := &models.Plan{ID: 9, UserID: "progressUser", Status: models.PlanStatusActive, StartDate: "2024-03-01", Tasks: []models.PlanTask{
+		{ID: 1, PlanID: 9, Type: models.TaskTypeExercise, Title: "凯格尔运动", Frequency: "每日2次"}, // Created by the planner, matched by keyword
+		{ID: 2, PlanID: 9, Type: models.TaskTypeHabit, Title: "早睡"},
+	}}
+	mockPlanRepo := new(MockPlanRepository)
+	mockCheckInRepo := new(MockCheckInRepository)
+	mockNotifications := new(MockNotificationService)
+	service := NewProgressionService(mockPlanRepo, mockCheckInRepo, nil, mockNotifications)
+
+	// Still the 14th for the user: the window is the 7th to the 13th
+	mockNotifications.On("GetPreferences", "progressUser").Return(&models.NotificationPreferences{UserID: "progressUser", Timezone: "America/Los_Angeles"}, nil).Once()
+	mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool { return task.ID == 1 && task.Level == 1 })).Return(nil).Once()
+	mockCheckInRepo.On("GetCheckInsByPlanID", uint(9)).Return(completedDays(8, 9, 10, 11, 12, 13), nil).Once()
+	mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool {
+		return task.ID == 1 && task.Level == 2 && task.LevelSince == "2024-03-14" && task.Title == "凯格尔运动（进阶）" &&
+			task.Frequency == "每日2次" && task.Duration == "每组10次，共2组"
+	})).Return(nil).Once()
+	mockNotifications.On("Send", mock.MatchedBy(func(n *models.Notification) bool {
+		return n.UserID == "progressUser" && n.Category == models.NotificationCategoryLevelChange &&
+			n.Topic == models.NotificationTopicExercise && strings.Contains(n.Content, "升级到第2级")
+	}), now).Return(nil).Once()
+
+	changes, err := service.EvaluatePlan(plan, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.LevelChange{{PlanID: 9, TaskID: 1, Progression: "kegel", FromLevel: 1, ToLevel: 2, Title: "凯格尔运动（进阶）", Reason: "过去7天你完成了6天，且没有不适反馈。"}}, changes)
+	assert.Equal(t, "kegel", plan.Tasks[0].Progression)
+	assert.Empty(t, plan.Tasks[1].Progression)
+	mockPlanRepo.AssertExpectations(t)
+	mockCheckInRepo.AssertExpectations(t)
+	mockNotifications.AssertExpectations(t)
+}
+
+func TestProgressionService_EvaluateActivePlans(t *testing.T) {
+	t.Run("Stops between plans once the context is done", func(t *testing.T) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		service := NewProgressionService(mockPlanRepo, mockCheckInRepo, nil, nil)
+		plans := []*models.Plan{{ID: 9, UserID: "progressUser", Status: models.PlanStatusActive, Tasks: []models.PlanTask{
+			{ID: 1, PlanID: 9, Type: models.TaskTypeExercise, Title: "凯格尔运动"},
+		}}}
+		mockPlanRepo.On("GetPlansByStatus", models.PlanStatusActive).Return(plans, nil).Once()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // The job's lease has expired
+
+		changed, err := service.EvaluateActivePlans(ctx, time.Now())
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, changed)
+		mockPlanRepo.AssertNotCalled(t, "UpdatePlanTask", mock.Anything)
+		mockCheckInRepo.AssertNotCalled(t, "GetCheckInsByPlanID", mock.Anything)
+		mockPlanRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"log"
+	"project/config"
+	"project/models"
+	"sync"
+	"time"
+)
+
+// pushSessionBuffer is how many messages a session may fall behind before it is dropped. The client then
+// reconnects and resumes from its last event ID.
+const pushSessionBuffer = 64
+
+// PushMessage is an event as sent on the push channel, numbered so that reconnecting sessions can resume.
+type PushMessage struct {
+	ID    uint64
+	Event models.Event
+}
+
+// PushSession is one open push connection of a user.
+type PushSession struct {
+	UserID string
+	// Messages carries the user's events. It is closed when the session falls too far behind.
+	Messages <-chan PushMessage
+	messages chan PushMessage
+}
+
+// PushHub pushes the events of each user, as published on the EventBus, to all of that user's open sessions. The
+// recent events of each user are kept for push.replay_minutes, so that a session that reconnects with the ID of
+// the last event it received gets the ones it missed.
+type PushHub interface {
+	// Connect opens a session for the user. If lastEventID is not 0, the events after it are returned to be sent
+	// first. If some of them are no longer available, e.g. after a restart, resync is not 0 and no events are
+	// returned: the client should reload its data, and use resync as its last event ID.
+	Connect(userID string, lastEventID uint64) (session *PushSession, backlog []PushMessage, resync uint64)
+	Disconnect(session *PushSession)
+	// HandleEvent pushes an event to its user's sessions. It is subscribed to the EventBus; events without a user
+	// are ignored.
+	HandleEvent(event models.Event)
+}
+
+type pushRecord struct {
+	message    PushMessage
+	receivedAt time.Time
+}
+
+type pushUser struct {
+	sessions map[*PushSession]struct{}
+	recent   []pushRecord
+	evicted  uint64 // ID of the newest event no longer kept
+}
+
+type pushHub struct {
+	mu         sync.Mutex
+	baseID     uint64 // IDs issued by this process are greater; lower ones are from before a restart
+	lastID     uint64
+	users      map[string]*pushUser
+	swept      uint64 // ID of the newest event evicted with a user that was removed
+	lastSweep  time.Time
+	now        func() time.Time
+	replaySize int
+	replayTime time.Duration
+}
+
+// NewPushHub creates a new instance of PushHub.
+func NewPushHub() PushHub {
+	cfg := config.AppConfig.Push
+	replaySize := cfg.ReplaySize
+	if replaySize <= 0 {
+		replaySize = 100
+	}
+	replayTime := time.Duration(cfg.ReplayMinutes) * time.Minute
+	if replayTime <= 0 {
+		replayTime = time.Hour
+	}
+	// Time based, so that IDs keep growing across restarts
+	base := uint64(time.Now().UnixMicro())
+	return &pushHub{
+		baseID:     base,
+		lastID:     base,
+		users:      make(map[string]*pushUser),
+		lastSweep:  time.Now(),
+		now:        time.Now,
+		replaySize: replaySize,
+		replayTime: replayTime,
+	}
+}
+
+func (h *pushHub) Connect(userID string, lastEventID uint64) (*PushSession, []PushMessage, uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	messages := make(chan PushMessage, pushSessionBuffer)
+	session := &PushSession{UserID: userID, Messages: messages, messages: messages}
+
+	user, known := h.users[userID]
+	if !known {
+		user = &pushUser{sessions: make(map[*PushSession]struct{})}
+		h.users[userID] = user
+	}
+	h.expire(user)
+	user.sessions[session] = struct{}{}
+
+	if lastEventID == 0 {
+		return session, nil, 0
+	}
+	evicted := user.evicted
+	if !known && h.swept > evicted {
+		evicted = h.swept
+	}
+	if lastEventID < h.baseID || lastEventID < evicted || lastEventID > h.lastID {
+		log.Printf("INFO: [PushHub] Session of userID '%s' cannot resume after event %d; asking it to resync.", userID, lastEventID)
+		return session, nil, h.lastID
+	}
+	var backlog []PushMessage
+	for _, record := range user.recent {
+		if record.message.ID > lastEventID {
+			backlog = append(backlog, record.message)
+		}
+	}
+	return session, backlog, 0
+}
+
+func (h *pushHub) Disconnect(session *PushSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	user := h.users[session.UserID]
+	if user == nil {
+		return
+	}
+	if _, open := user.sessions[session]; open {
+		delete(user.sessions, session)
+		close(session.messages)
+	}
+}
+
+func (h *pushHub) HandleEvent(event models.Event) {
+	if event.UserID == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	h.lastID++
+	message := PushMessage{ID: h.lastID, Event: event}
+
+	user := h.users[event.UserID]
+	if user == nil {
+		user = &pushUser{sessions: make(map[*PushSession]struct{})}
+		h.users[event.UserID] = user
+	}
+	user.recent = append(user.recent, pushRecord{message: message, receivedAt: now})
+	h.expire(user)
+	for session := range user.sessions {
+		select {
+		case session.messages <- message:
+		default:
+			// Falling behind; the client reconnects and resumes from the last event it received
+			log.Printf("WARN: [PushHub] Dropping a slow session of userID '%s'.", event.UserID)
+			delete(user.sessions, session)
+			close(session.messages)
+		}
+	}
+
+	if now.Sub(h.lastSweep) >= h.replayTime {
+		h.sweep()
+		h.lastSweep = now
+	}
+}
+
+// expire drops the events of a user beyond push.replay_size or older than push.replay_minutes.
+func (h *pushHub) expire(user *pushUser) {
+	cutoff := h.now().Add(-h.replayTime)
+	drop := 0
+	for drop < len(user.recent) && (len(user.recent)-drop > h.replaySize || user.recent[drop].receivedAt.Before(cutoff)) {
+		user.evicted = user.recent[drop].message.ID
+		drop++
+	}
+	if drop > 0 {
+		user.recent = append([]pushRecord(nil), user.recent[drop:]...)
+	}
+}
+
+// sweep removes the users without sessions whose events have all expired.
+func (h *pushHub) sweep() {
+	for userID, user := range h.users {
+		h.expire(user)
+		if len(user.sessions) == 0 && len(user.recent) == 0 {
+			if user.evicted > h.swept {
+				h.swept = user.evicted
+			}
+			delete(h.users, userID)
+		}
+	}
+}
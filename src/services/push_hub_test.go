@@ -0,0 +1,140 @@
+package services
+
+import (
+	"project/config"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPushHub creates a push hub keeping 3 events per user for 10 minutes, with a controllable clock.
+func newTestPushHub(t *testing.T) (*pushHub, *time.Time) {
+	original := config.AppConfig.Push
+	config.AppConfig.Push = config.PushConfig{ReplaySize: 3, ReplayMinutes: 10}
+	t.Cleanup(func() { config.AppConfig.Push = original })
+
+	hub := NewPushHub().(*pushHub)
+	now := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	hub.now = func() time.Time { return now }
+	hub.lastSweep = now
+	return hub, &now
+}
+
+// receivedTypes returns the event types of the messages waiting in a session.
+func receivedTypes(session *PushSession) []string {
+	var types []string
+	for {
+		select {
+		case message, open := <-session.Messages:
+			if !open {
+				return types
+			}
+			types = append(types, message.Event.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestPushHub_HandleEvent(t *testing.T) {
+	hub, _ := newTestPushHub(t)
+	phone, backlog, resync := hub.Connect("pushUser", 0)
+	assert.Empty(t, backlog)
+	assert.Zero(t, resync)
+	laptop, _, _ := hub.Connect("pushUser", 0)
+	other, _, _ := hub.Connect("otherUser", 0)
+
+	hub.HandleEvent(models.Event{Type: models.EventMessageCreated, UserID: "pushUser"})
+	hub.HandleEvent(models.Event{Type: models.EventPlanUpdated, UserID: "pushUser"})
+	hub.HandleEvent(models.Event{Type: "system.maintenance"}) // No user; not pushed
+
+	assert.Equal(t, []string{models.EventMessageCreated, models.EventPlanUpdated}, receivedTypes(phone))
+	assert.Equal(t, []string{models.EventMessageCreated, models.EventPlanUpdated}, receivedTypes(laptop), "every session of the user")
+	assert.Empty(t, receivedTypes(other), "only the user's own events")
+
+	hub.Disconnect(laptop)
+	_, open := <-laptop.Messages
+	assert.False(t, open, "closed on disconnect")
+	hub.HandleEvent(models.Event{Type: models.EventQuotaUpdated, UserID: "pushUser"})
+	assert.Equal(t, []string{models.EventQuotaUpdated}, receivedTypes(phone))
+}
+
+func TestPushHub_HandleEvent_SlowSession(t *testing.T) {
+	hub, _ := newTestPushHub(t)
+	slow, _, _ := hub.Connect("pushUser", 0)
+
+	for i := 0; i <= pushSessionBuffer; i++ {
+		hub.HandleEvent(models.Event{Type: models.EventNotificationDelivered, UserID: "pushUser"})
+	}
+
+	assert.Len(t, receivedTypes(slow), pushSessionBuffer, "closed after its buffer filled up")
+	_, open := <-slow.Messages
+	assert.False(t, open)
+	hub.Disconnect(slow) // Already dropped; must not close it again
+}
+
+func TestPushHub_Connect_Resume(t *testing.T) {
+	hub, now := newTestPushHub(t)
+	session, _, _ := hub.Connect("pushUser", 0)
+	hub.HandleEvent(models.Event{Type: models.EventMessageCreated, UserID: "pushUser"})
+	hub.HandleEvent(models.Event{Type: models.EventPlanUpdated, UserID: "pushUser"})
+	hub.HandleEvent(models.Event{Type: models.EventTaskCompleted, UserID: "otherUser"})
+	hub.HandleEvent(models.Event{Type: models.EventQuotaUpdated, UserID: "pushUser"})
+	first := <-session.Messages
+	hub.Disconnect(session)
+
+	t.Run("missed events", func(t *testing.T) {
+		resumed, backlog, resync := hub.Connect("pushUser", first.ID)
+		defer hub.Disconnect(resumed)
+		assert.Zero(t, resync)
+		if assert.Len(t, backlog, 2) {
+			assert.Equal(t, models.EventPlanUpdated, backlog[0].Event.Type)
+			assert.Equal(t, models.EventQuotaUpdated, backlog[1].Event.Type)
+			assert.Greater(t, backlog[1].ID, backlog[0].ID)
+		}
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		resumed, backlog, resync := hub.Connect("pushUser", hub.lastID)
+		defer hub.Disconnect(resumed)
+		assert.Zero(t, resync)
+		assert.Empty(t, backlog)
+	})
+
+	t.Run("from before a restart", func(t *testing.T) {
+		resumed, backlog, resync := hub.Connect("pushUser", hub.baseID-5)
+		defer hub.Disconnect(resumed)
+		assert.Equal(t, hub.lastID, resync)
+		assert.Empty(t, backlog)
+	})
+
+	t.Run("unknown ID", func(t *testing.T) {
+		resumed, backlog, resync := hub.Connect("pushUser", hub.lastID+1)
+		defer hub.Disconnect(resumed)
+		assert.Equal(t, hub.lastID, resync)
+		assert.Empty(t, backlog)
+	})
+
+	t.Run("beyond replay size", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			hub.HandleEvent(models.Event{Type: models.EventNotificationDelivered, UserID: "pushUser"})
+		}
+		resumed, backlog, resync := hub.Connect("pushUser", first.ID)
+		defer hub.Disconnect(resumed)
+		assert.Equal(t, hub.lastID, resync, "the events after it are no longer all kept")
+		assert.Empty(t, backlog)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		last := hub.lastID
+		*now = now.Add(11 * time.Minute)
+		hub.HandleEvent(models.Event{Type: models.EventTaskCompleted, UserID: "otherUser"}) // Sweeps users without sessions
+
+		resumed, backlog, resync := hub.Connect("pushUser", last-1)
+		defer hub.Disconnect(resumed)
+		assert.Equal(t, hub.lastID, resync, "the user's events were removed with the user")
+		assert.Empty(t, backlog)
+	})
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"log"
+	"project/config"
+	"project/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time-of-day windows recognised in frequency texts.
+var (
+	windowMorning   = models.TimeWindow{Label: "morning", Start: "06:00", End: "10:00"}
+	windowNoon      = models.TimeWindow{Label: "noon", Start: "11:00", End: "14:00"}
+	windowAfternoon = models.TimeWindow{Label: "afternoon", Start: "14:00", End: "18:00"}
+	windowEvening   = models.TimeWindow{Label: "evening", Start: "18:00", End: "22:00"}
+	windowBedtime   = models.TimeWindow{Label: "bedtime", Start: "21:00", End: "23:59"}
+)
+
+const countPattern = `(\d+|[一二两三四五六七八九十]+|once|twice|one|two|three|four|five|six|seven)`
+
+var (
+	reDate              = regexp.MustCompile(`(\d{4}-\d{1,2}-\d{1,2})`)
+	reOnceThisWeek      = regexp.MustCompile(`(本周|这周|本星期)[^0-9一二两三四五六七八九十]*一次|once this week|one time this week`)
+	reOnceOnly          = regexp.MustCompile(`^(一次|单次|仅一次|once|one time)$`)
+	reTimesPerWeekZh    = regexp.MustCompile(`(每|一)(周|星期|礼拜)\s*` + countPattern + `\s*次`)
+	reTimesPerWeekEn    = regexp.MustCompile(countPattern + `\s*(times?)?\s*(a|per|each|every)\s*week`)
+	reWeekdaysZh        = regexp.MustCompile(`(周|星期|礼拜)([一二三四五六日天、,，和及与\s]+)`)
+	reWeekdayRangeZh    = regexp.MustCompile(`(周|星期|礼拜)([一二三四五六日天])\s*(至|到|-|~)\s*(周|星期|礼拜)?([一二三四五六日天])`)
+	reTimesPerDayZh     = regexp.MustCompile(`(每日|每天|天天|一天|一日)[^0-9一二两三四五六七八九十]*` + countPattern + `\s*次`)
+	reEachTimesZh       = regexp.MustCompile(`各\s*` + countPattern + `\s*次`)
+	reTimesPerDayEn     = regexp.MustCompile(countPattern + `\s*(times?)?\s*(a|per|each|every)\s*day`)
+	reEveryOtherWeek    = regexp.MustCompile(`隔周|每隔一(个)?(周|星期|礼拜)|every other week|biweekly|fortnight`)
+	reEveryWeeksZh      = regexp.MustCompile(`每隔?\s*` + countPattern + `\s*个?(周|星期|礼拜)`)
+	reWeeksOnceZh       = regexp.MustCompile(`(^|[^周期拜])` + countPattern + `\s*个?(周|星期|礼拜)\s*` + countPattern + `\s*次`)
+	reEveryWeeksEn      = regexp.MustCompile(`every\s+` + countPattern + `\s+weeks`)
+	reDailyMarker       = regexp.MustCompile(`每日|每天|天天|每晚|每早|daily|every day|each day|every night|every morning|nightly`)
+	englishWeekdayNames = []struct {
+		prefix  string
+		weekday time.Weekday
+	}{
+		{"monday", time.Monday}, {"tuesday", time.Tuesday}, {"wednesday", time.Wednesday}, {"thursday", time.Thursday},
+		{"friday", time.Friday}, {"saturday", time.Saturday}, {"sunday", time.Sunday},
+		{"mon", time.Monday}, {"tue", time.Tuesday}, {"wed", time.Wednesday}, {"thu", time.Thursday},
+		{"fri", time.Friday}, {"sat", time.Saturday}, {"sun", time.Sunday},
+	}
+	reEnglishWord = regexp.MustCompile(`[a-z]+`)
+)
+
+// ParseFrequency turns a free-text frequency such as "每日2次", "每周一、三、五", "3 times a week" or
+// "once on 2024-03-15" into a Recurrence. It returns nil if the text is not understood, or if it repeats less
+// often than weekly, such as "两周一次", which a Recurrence cannot express.
+func ParseFrequency(text string) *models.Recurrence {
+	s := strings.ToLower(strings.TrimSpace(text))
+	s = strings.NewReplacer("（", "(", "）", ")", "：", ":", "　", " ").Replace(s)
+	if s == "" {
+		return nil
+	}
+	windows := parseTimeWindows(s)
+
+	if m := reDate.FindStringSubmatch(s); m != nil {
+		if date, err := time.Parse("2006-1-2", m[1]); err == nil {
+			return &models.Recurrence{Kind: models.RecurrenceOnce, Date: date.Format("2006-01-02"), Windows: windows}
+		}
+	}
+	if reOnceThisWeek.MatchString(s) || reOnceOnly.MatchString(s) {
+		return &models.Recurrence{Kind: models.RecurrenceOnce, Windows: windows}
+	}
+	if spansSeveralWeeks(s) {
+		return nil // Not weekly: "两周一次" must not become every Monday
+	}
+	if m := reTimesPerWeekZh.FindStringSubmatch(s); m != nil {
+		if n := parseCount(m[3]); n > 0 {
+			return &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: n, Windows: windows}
+		}
+	}
+	if m := reTimesPerWeekEn.FindStringSubmatch(s); m != nil {
+		if n := parseCount(m[1]); n > 0 {
+			return &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: n, Windows: windows}
+		}
+	}
+	if s == "weekly" || s == "每周" || s == "每周一次" || s == "once a week" {
+		return &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 1, Windows: windows}
+	}
+
+	timesPerDay := parseTimesPerDay(s)
+	if m := reEachTimesZh.FindStringSubmatch(s); m != nil && len(windows) > 0 {
+		timesPerDay = maxInt(timesPerDay, parseCount(m[1])*len(windows)) // e.g. "早晚各一次"
+	}
+	if weekdays := parseWeekdays(s); len(weekdays) > 0 {
+		return &models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: weekdays, TimesPerDay: maxInt(timesPerDay, len(windows)), Windows: windows}
+	}
+	if timesPerDay > 0 || reDailyMarker.MatchString(s) || (len(windows) > 0 && strings.Contains(s, "每")) {
+		return &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: maxInt(timesPerDay, len(windows), 1), Windows: windows}
+	}
+	return nil
+}
+
+// spansSeveralWeeks reports whether the text repeats over a period of more than one week, e.g. "两周一次",
+// "每3周", "隔周" or "every two weeks".
+func spansSeveralWeeks(s string) bool {
+	if reEveryOtherWeek.MatchString(s) {
+		return true
+	}
+	if m := reEveryWeeksZh.FindStringSubmatch(s); m != nil && parseCount(m[1]) > 1 {
+		return true
+	}
+	if m := reWeeksOnceZh.FindStringSubmatch(s); m != nil && parseCount(m[2]) > 1 {
+		return true
+	}
+	if m := reEveryWeeksEn.FindStringSubmatch(s); m != nil && parseCount(m[1]) > 1 {
+		return true
+	}
+	return false
+}
+
+// parseTimesPerDay returns the explicit number of daily occurrences in the text, or 0 if none is given.
+func parseTimesPerDay(s string) int {
+	if m := reTimesPerDayZh.FindStringSubmatch(s); m != nil {
+		return parseCount(m[2])
+	}
+	if m := reTimesPerDayEn.FindStringSubmatch(s); m != nil {
+		return parseCount(m[1])
+	}
+	if strings.Contains(s, "twice") {
+		return 2
+	}
+	return 0
+}
+
+// parseWeekdays returns the sorted weekdays named in the text, e.g. "每周一、三、五", "工作日" or "Mon, Wed and Fri".
+func parseWeekdays(s string) []int {
+	days := make(map[time.Weekday]bool)
+	if strings.Contains(s, "工作日") || strings.Contains(s, "weekdays") {
+		for d := time.Monday; d <= time.Friday; d++ {
+			days[d] = true
+		}
+	}
+	if strings.Contains(s, "周末") || strings.Contains(s, "weekends") {
+		days[time.Saturday], days[time.Sunday] = true, true
+	}
+	if m := reWeekdayRangeZh.FindStringSubmatch(s); m != nil {
+		// "周一至周五": walk from the first to the last day, wrapping past Sunday
+		from, to := chineseWeekday([]rune(m[2])[0]), chineseWeekday([]rune(m[5])[0])
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	for _, m := range reWeekdaysZh.FindAllStringSubmatch(s, -1) {
+		for _, r := range m[2] {
+			if d := chineseWeekday(r); d >= 0 {
+				days[d] = true
+			}
+		}
+	}
+	for _, word := range reEnglishWord.FindAllString(s, -1) {
+		for _, name := range englishWeekdayNames {
+			if word == name.prefix || word == name.prefix+"s" {
+				days[name.weekday] = true
+				break
+			}
+		}
+	}
+
+	weekdays := make([]int, 0, len(days))
+	for d := range days {
+		weekdays = append(weekdays, int(d))
+	}
+	sort.Ints(weekdays)
+	return weekdays
+}
+
+// chineseWeekday maps the numeral in "周一" … "周日" to a weekday, or -1.
+func chineseWeekday(r rune) time.Weekday {
+	switch r {
+	case '一':
+		return time.Monday
+	case '二':
+		return time.Tuesday
+	case '三':
+		return time.Wednesday
+	case '四':
+		return time.Thursday
+	case '五':
+		return time.Friday
+	case '六':
+		return time.Saturday
+	case '日', '天':
+		return time.Sunday
+	}
+	return -1
+}
+
+// parseTimeWindows returns the time-of-day windows mentioned in the text, in chronological order.
+func parseTimeWindows(s string) []models.TimeWindow {
+	var windows []models.TimeWindow
+	if strings.Contains(s, "早晚") {
+		return []models.TimeWindow{windowMorning, windowEvening}
+	}
+	if strings.Contains(s, "早上") || strings.Contains(s, "早晨") || strings.Contains(s, "晨起") || strings.Contains(s, "上午") || strings.Contains(s, "每早") || strings.Contains(s, "morning") {
+		windows = append(windows, windowMorning)
+	}
+	if strings.Contains(s, "中午") || strings.Contains(s, "午饭") || strings.Contains(strings.ReplaceAll(s, "afternoon", ""), "noon") || strings.Contains(s, "lunch") {
+		windows = append(windows, windowNoon)
+	}
+	if strings.Contains(s, "下午") || strings.Contains(s, "afternoon") {
+		windows = append(windows, windowAfternoon)
+	}
+	if strings.Contains(s, "睡前") || strings.Contains(s, "before bed") || strings.Contains(s, "bedtime") {
+		windows = append(windows, windowBedtime)
+	} else if strings.Contains(s, "晚上") || strings.Contains(s, "晚间") || strings.Contains(s, "每晚") || strings.Contains(s, "evening") || strings.Contains(s, "night") {
+		windows = append(windows, windowEvening)
+	}
+	return windows
+}
+
+// parseCount parses a count written as digits, Chinese numerals up to 十 or an English word.
+func parseCount(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	switch s {
+	case "once", "one":
+		return 1
+	case "twice", "two":
+		return 2
+	case "three":
+		return 3
+	case "four":
+		return 4
+	case "five":
+		return 5
+	case "six":
+		return 6
+	case "seven":
+		return 7
+	}
+	digits := map[rune]int{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	runes := []rune(s)
+	switch {
+	case len(runes) == 1 && runes[0] == '十':
+		return 10
+	case len(runes) == 1:
+		return digits[runes[0]]
+	case len(runes) == 2 && runes[0] == '十':
+		return 10 + digits[runes[1]]
+	}
+	return 0
+}
+
+func maxInt(values ...int) int {
+	m := 0
+	for _, v := range values {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+// DueCount returns how many occurrences of a recurrence are due on the calendar day of day (already in the
+// user's timezone). completedInPeriod is the number of occurrences completed before that day in the current
+// period: the week (Monday to Sunday) for times_per_week, all time for an undated once.
+func DueCount(rec *models.Recurrence, day time.Time, completedInPeriod int) int {
+	if rec == nil {
+		return 0
+	}
+	timesPerDay := maxInt(rec.TimesPerDay, 1)
+	switch rec.Kind {
+	case models.RecurrenceDaily:
+		return timesPerDay
+	case models.RecurrenceWeekdays:
+		for _, d := range rec.Weekdays {
+			if time.Weekday(d) == day.Weekday() {
+				return timesPerDay
+			}
+		}
+	case models.RecurrenceTimesPerWeek:
+		if completedInPeriod < rec.TimesPerWeek {
+			return 1
+		}
+	case models.RecurrenceOnce:
+		if rec.Date != "" {
+			if rec.Date == day.Format("2006-01-02") {
+				return 1
+			}
+		} else if completedInPeriod == 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// WeekStart returns midnight of the Monday of t's week, in t's location.
+func WeekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7 // Days since Monday
+	y, m, d := t.Date()
+	return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+}
+
+// LoadUserLocation resolves an IANA timezone name, falling back to the configured default timezone and then UTC.
+func LoadUserLocation(timezone string) *time.Location {
+	for _, name := range []string{timezone, config.AppConfig.PlanSchedule.DefaultTimezone} {
+		if name == "" {
+			continue
+		}
+		loc, err := time.LoadLocation(name)
+		if err == nil {
+			return loc
+		}
+		log.Printf("WARN: [PlanService] Unknown timezone '%s': %v", name, err)
+	}
+	return time.UTC
+}
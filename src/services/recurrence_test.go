@@ -0,0 +1,131 @@
+package services
+
+import (
+	"database/sql"
+	"project/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseFrequency(t *testing.T) {
+	tests := []struct {
+		text string
+		want *models.Recurrence
+	}{
+		{"每日2次", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2}},
+		{"每日", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 1}},
+		{"每天两次", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2}},
+		{"早晚各一次", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2, Windows: []models.TimeWindow{windowMorning, windowEvening}}},
+		{"每晚睡前", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 1, Windows: []models.TimeWindow{windowBedtime}}},
+		{"每周3次", &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}},
+		{"每周三次", &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}},
+		{"每周一、三、五", &models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{1, 3, 5}}},
+		{"周一至周五 早上", &models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{1, 2, 3, 4, 5}, TimesPerDay: 1, Windows: []models.TimeWindow{windowMorning}}},
+		{"周末", &models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{0, 6}}},
+		{"本周一次", &models.Recurrence{Kind: models.RecurrenceOnce}},
+		{"daily", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 1}},
+		{"Twice a day", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2}},
+		{"3 times a week", &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}},
+		{"once a week", &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 1}},
+		{"every Monday and Thursday afternoon", &models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{1, 4}, TimesPerDay: 1, Windows: []models.TimeWindow{windowAfternoon}}},
+		{"once on 2024-03-15", &models.Recurrence{Kind: models.RecurrenceOnce, Date: "2024-03-15"}},
+		{"两周一次", nil}, // Not weekly on Monday
+		{"每两周", nil},
+		{"隔周周六", nil},
+		{"every 2 weeks", nil},
+		{"一周两次", &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 2}},
+		{"每周二、周四", &models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{2, 4}}},
+		{"每日一次，坚持两周", &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 1}},
+		{"全天", nil},
+		{"随意", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseFrequency(tt.text))
+		})
+	}
+}
+
+func TestDueCount(t *testing.T) {
+	wednesday := time.Date(2024, 3, 13, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 2, DueCount(&models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2}, wednesday, 0))
+	assert.Equal(t, 1, DueCount(&models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{1, 3, 5}}, wednesday, 0))
+	assert.Equal(t, 0, DueCount(&models.Recurrence{Kind: models.RecurrenceWeekdays, Weekdays: []int{2, 4}}, wednesday, 0))
+	assert.Equal(t, 1, DueCount(&models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}, wednesday, 2))
+	assert.Equal(t, 0, DueCount(&models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}, wednesday, 3))
+	assert.Equal(t, 1, DueCount(&models.Recurrence{Kind: models.RecurrenceOnce, Date: "2024-03-13"}, wednesday, 0))
+	assert.Equal(t, 0, DueCount(&models.Recurrence{Kind: models.RecurrenceOnce, Date: "2024-03-15"}, wednesday, 0))
+	assert.Equal(t, 0, DueCount(&models.Recurrence{Kind: models.RecurrenceOnce}, wednesday, 1))
+	assert.Equal(t, 0, DueCount(nil, wednesday, 0))
+
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), WeekStart(wednesday))
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), WeekStart(time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC)))
+}
+
+func TestPlanService_GetDueTasks(t *testing.T) {
+	mockPlanRepo := new(MockPlanRepository)
+	mockCheckInRepo := new(MockCheckInRepository)
+	service := NewPlanService(mockPlanRepo, nil, nil, mockCheckInRepo, nil, nil)
+	// 2024-03-12 (Tuesday) 20:00 UTC is already Wednesday 04:00 in Shanghai
+	now := time.Date(2024, 3, 12, 20, 0, 0, 0, time.UTC)
+	mondayInShanghai := time.Date(2024, 3, 11, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+
+	plan := &models.Plan{ID: 3, UserID: "dueUser", Status: models.PlanStatusActive, Tasks: []models.PlanTask{
+		{ID: 1, Title: "凯格尔运动", Frequency: "每日2次", Recurrence: &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 2}},
+		{ID: 2, Title: "力量训练", Frequency: "每周一、三、五"}, // Not migrated yet, parsed on the fly
+		{ID: 3, Title: "慢跑", Frequency: "每周二、四"},
+		{ID: 4, Title: "阅读", Frequency: "本周一次", IsCompleted: true, CompletedAt: sql.NullTime{Time: mondayInShanghai, Valid: true}},
+		{ID: 5, Title: "随缘", Frequency: "看心情"}, // Unparseable: due until done once
+		{ID: 6, Title: "旧任务", Frequency: "每日", Status: models.TaskStatusSkipped},
+	}}
+	mockPlanRepo.On("GetPlanByID", uint(3)).Return(plan, nil)
+	mockCheckInRepo.On("GetCheckInsByPlanID", uint(3)).Return([]models.TaskCheckIn{
+		{ID: 1, TaskID: 1, DueDate: "2024-03-13", Slot: 1, Status: models.TaskStatusCompleted},
+		{ID: 2, TaskID: 4, DueDate: "2024-03-11", Slot: 1, Status: models.TaskStatusCompleted},
+	}, nil)
+
+	t.Run("Uses the user's timezone to pick the day", func(t *testing.T) {
+		due, err := service.GetDueTasks(3, "Asia/Shanghai", now)
+
+		assert.NoError(t, err)
+		assert.Len(t, due, 3)
+		assert.Equal(t, uint(1), due[0].TaskID)
+		assert.Equal(t, 2, due[0].Times)
+		assert.Equal(t, "2024-03-13", due[0].Date)
+		assert.Equal(t, models.TaskStatusCompleted, due[0].Occurrences[0].Status)
+		assert.Equal(t, models.TaskStatusPending, due[0].Occurrences[1].Status)
+		assert.Equal(t, uint(2), due[1].TaskID)
+		assert.Equal(t, uint(5), due[2].TaskID)
+	})
+
+	t.Run("Falls back to UTC for unknown timezones", func(t *testing.T) {
+		due, err := service.GetDueTasks(3, "Mars/Olympus", now)
+
+		assert.NoError(t, err)
+		assert.Len(t, due, 3)
+		assert.Equal(t, "2024-03-12", due[0].Date)
+		assert.Equal(t, models.TaskStatusPending, due[0].Occurrences[0].Status) // Today's check-in belongs to the 13th
+		assert.Equal(t, uint(3), due[1].TaskID)                                 // Tuesday task
+	})
+}
+
+func TestBackfillTaskRecurrences(t *testing.T) {
+	mockPlanRepo := new(MockPlanRepository)
+	tasks := []*models.PlanTask{{ID: 1, Frequency: "每周3次"}, {ID: 2, Frequency: "看心情"}}
+	mockPlanRepo.On("GetTasksWithoutRecurrence").Return(tasks, nil).Once()
+	mockPlanRepo.On("UpdatePlanTask", mock.MatchedBy(func(task *models.PlanTask) bool {
+		return task.ID == 1 && task.Recurrence != nil && task.Recurrence.TimesPerWeek == 3
+	})).Return(nil).Once()
+
+	migrated, err := BackfillTaskRecurrences(mockPlanRepo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+	assert.Nil(t, tasks[1].Recurrence)
+	mockPlanRepo.AssertExpectations(t)
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// maxReminderRunes caps LLM-written reminders; longer output falls back to the template.
+const maxReminderRunes = 200
+
+// ReminderService sends proactive reminders about plan tasks from the reminder agent. Reminders go through the
+// NotificationService, which applies the user's preferences and ensures each is sent only once.
+type ReminderService interface {
+	// SendReminders reminds users of task occurrences starting within reminders.lead_minutes of now, and follows up
+	// on occurrences still without a check-in reminders.follow_up_minutes after they ended the same day. It returns
+	// the number sent.
+	SendReminders(ctx context.Context, now time.Time) (int, error)
+}
+
+type reminderService struct {
+	planRepo      repository.PlanRepository
+	checkInRepo   repository.CheckInRepository
+	notifications NotificationService
+	llm           LLMClient // Optional; reminders use the templates without it
+}
+
+// NewReminderService creates a new instance of ReminderService.
+func NewReminderService(planRepo repository.PlanRepository, checkInRepo repository.CheckInRepository, notifications NotificationService, llm LLMClient) ReminderService {
+	return &reminderService{
+		planRepo:      planRepo,
+		checkInRepo:   checkInRepo,
+		notifications: notifications,
+		llm:           llm,
+	}
+}
+
+// taskReminder is a reminder due about one occurrence of a task.
+type taskReminder struct {
+	category string
+	plan     *models.Plan
+	task     *models.PlanTask
+	date     string // YYYY-MM-DD in the user's timezone
+	slot     int
+	start    time.Time
+}
+
+// dedupeKey identifies the reminder across runs.
+func (r taskReminder) dedupeKey() string {
+	return fmt.Sprintf("%s:%d:%s:%d", r.category, r.task.ID, r.date, r.slot)
+}
+
+func (s *reminderService) SendReminders(ctx context.Context, now time.Time) (int, error) {
+	if s.planRepo == nil || s.checkInRepo == nil || s.notifications == nil {
+		return 0, errors.New("planrepo, checkinrepo or notificationservice not initialized")
+	}
+	cfg := config.AppConfig.Reminders
+	lead := time.Duration(cfg.LeadMinutes) * time.Minute
+	if lead <= 0 {
+		lead = 15 * time.Minute
+	}
+	followUp := time.Duration(cfg.FollowUpMinutes) * time.Minute
+
+	plans, err := s.planRepo.GetPlansByStatus(models.PlanStatusActive)
+	if err != nil {
+		errMsg := "failed to fetch active plans for reminders"
+		log.Printf("ERROR: [ReminderService] %s: %v", errMsg, err)
+		return 0, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	sent := 0
+	for _, plan := range plans {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		checkIns, err := s.checkInRepo.GetCheckInsByPlanID(plan.ID)
+		if err != nil {
+			// One plan failing must not hold back the others
+			log.Printf("ERROR: [ReminderService] Failed to fetch check-ins of plan ID %d: %v", plan.ID, err)
+			continue
+		}
+		prefs, err := s.notifications.GetPreferences(plan.UserID)
+		if err != nil {
+			log.Printf("ERROR: [ReminderService] Failed to fetch notification preferences of userID '%s': %v", plan.UserID, err)
+			continue
+		}
+		for _, reminder := range dueReminders(plan, checkIns, LoadUserLocation(prefs.Timezone), now, lead, followUp) {
+			ok, err := s.send(reminder, now)
+			if err != nil {
+				log.Printf("ERROR: [ReminderService] Failed to send %s for task ID %d: %v", reminder.category, reminder.task.ID, err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+	}
+	log.Printf("INFO: [ReminderService] Checked %d active plans, %d reminders sent.", len(plans), sent)
+	return sent, nil
+}
+
+// dueReminders lists the reminders due at now for today's occurrences (in loc) of a plan's tasks that have no
+// check-in: a reminder from lead before an occurrence starts until lead after, and a follow-up once followUp has
+// passed since it ended. Follow-ups are only sent the same day, while the occurrence can still be checked in.
+// Tasks on flexible schedules (times per week, undated once) are due every day until done, so they get reminders
+// but no follow-ups.
+func dueReminders(plan *models.Plan, checkIns []models.TaskCheckIn, loc *time.Location, now time.Time, lead, followUp time.Duration) []taskReminder {
+	checkInsByTask := make(map[uint][]models.TaskCheckIn)
+	for _, checkIn := range checkIns {
+		checkInsByTask[checkIn.TaskID] = append(checkInsByTask[checkIn.TaskID], checkIn)
+	}
+	today := now.In(loc)
+	date := today.Format("2006-01-02")
+	if (plan.StartDate != "" && date < plan.StartDate) || (plan.EndDate != "" && date > plan.EndDate) {
+		return nil
+	}
+	var reminders []taskReminder
+	for i := range plan.Tasks {
+		task := &plan.Tasks[i]
+		if task.Status == models.TaskStatusSkipped {
+			continue
+		}
+		rec := taskRecurrence(task)
+		flexible := rec.Kind == models.RecurrenceTimesPerWeek || (rec.Kind == models.RecurrenceOnce && rec.Date == "")
+		for _, occurrence := range taskOccurrences(task, rec, checkInsByTask[task.ID], today) {
+			if occurrence.CheckIn != nil {
+				continue
+			}
+			reminder := taskReminder{plan: plan, task: task, date: date, slot: occurrence.Slot, start: slotStart(today, rec, occurrence.Slot)}
+			switch {
+			case followUp > 0 && !flexible && !today.Before(occurrenceEnd(today, rec, occurrence.Slot).Add(followUp)):
+				reminder.category = models.NotificationCategoryMissedCheckIn
+			case !today.Before(reminder.start.Add(-lead)) && today.Before(reminder.start.Add(lead)):
+				reminder.category = models.NotificationCategoryTaskReminder
+			default:
+				continue
+			}
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders
+}
+
+// occurrenceEnd returns the end of an occurrence's time window, or its start if it has none.
+func occurrenceEnd(day time.Time, rec *models.Recurrence, slot int) time.Time {
+	if slot <= len(rec.Windows) && rec.Windows[slot-1].End != "" {
+		var hour, minute int
+		if _, err := fmt.Sscanf(rec.Windows[slot-1].End, "%d:%d", &hour, &minute); err == nil {
+			y, m, d := day.Date()
+			return time.Date(y, m, d, hour, minute, 0, 0, day.Location())
+		}
+	}
+	return slotStart(day, rec, slot)
+}
+
+// send passes the reminder to the NotificationService, unless it was sent before. Reminders that cannot be
+// delivered the same day, e.g. because of quiet hours, are dropped.
+func (s *reminderService) send(reminder taskReminder, now time.Time) (bool, error) {
+	key := reminder.dedupeKey()
+	exists, err := s.notifications.Sent(key)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	agentID := config.AppConfig.Reminders.AgentID
+	content := s.compose(reminder)
+	title := "任务提醒：" + reminder.task.Title
+	if reminder.category == models.NotificationCategoryMissedCheckIn {
+		title = "打卡提醒：" + reminder.task.Title
+	}
+	y, m, d := reminder.start.Date()
+	endOfDay := time.Date(y, m, d+1, 0, 0, 0, 0, reminder.start.Location())
+	notification := &models.Notification{
+		UserID:    reminder.plan.UserID,
+		Category:  reminder.category,
+		Topic:     notificationTopic(reminder.task.Type),
+		Title:     title,
+		Content:   content,
+		AgentID:   agentID,
+		PlanID:    reminder.plan.ID,
+		TaskID:    reminder.task.ID,
+		DueDate:   reminder.date,
+		Slot:      reminder.slot,
+		DedupeKey: key,
+		ExpiresAt: &endOfDay,
+	}
+	if err := s.notifications.Send(notification, now); err != nil {
+		return false, err
+	}
+	log.Printf("INFO: [ReminderService] %s for task ID %d (%s slot %d) to userID '%s': %s.", reminder.category, reminder.task.ID, reminder.date, reminder.slot, reminder.plan.UserID, notification.Status)
+	return notification.Status != models.NotificationStatusDropped, nil
+}
+
+// compose writes the reminder text: by the reminder agent if reminders.use_llm is set, otherwise, or if the agent
+// fails, from the configured template.
+func (s *reminderService) compose(reminder taskReminder) string {
+	cfg := config.AppConfig.Reminders
+	if cfg.UseLLM && s.llm != nil {
+		if model := agentModel(cfg.Model, cfg.AgentID); model != "" {
+			messages := []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: strings.TrimSpace(cfg.SystemPrompt)},
+				{Role: openai.ChatMessageRoleUser, Content: describeReminder(reminder)},
+			}
+			text, err := s.llm.Complete(model, messages, false)
+			text = strings.TrimSpace(text)
+			if err == nil && text != "" && utf8.RuneCountInString(text) <= maxReminderRunes {
+				return text
+			}
+			log.Printf("WARN: [ReminderService] Reminder agent output unusable for task ID %d, using the template: %v", reminder.task.ID, err)
+		}
+	}
+	template := cfg.ReminderTemplate
+	if reminder.category == models.NotificationCategoryMissedCheckIn {
+		template = cfg.FollowUpTemplate
+	}
+	if template == "" {
+		template = "{time}要做「{task}」啦，加油！"
+	}
+	description := strings.TrimSpace(reminder.task.Description)
+	if description != "" && !strings.HasSuffix(description, "。") {
+		description += "。"
+	}
+	return strings.NewReplacer(
+		"{task}", reminder.task.Title,
+		"{time}", reminder.start.Format("15:04"),
+		"{description}", description,
+	).Replace(template)
+}
+
+// describeReminder tells the reminder agent what to write about.
+func describeReminder(reminder taskReminder) string {
+	var sb strings.Builder
+	if reminder.category == models.NotificationCategoryMissedCheckIn {
+		sb.WriteString("提醒类型：用户错过了下面的任务且没有打卡，请温和地跟进，提醒完成后打卡或标记跳过。\n")
+	} else {
+		sb.WriteString("提醒类型：下面的任务即将开始，请提醒用户按时完成。\n")
+	}
+	sb.WriteString(fmt.Sprintf("任务：%s\n", reminder.task.Title))
+	if reminder.task.Description != "" {
+		sb.WriteString(fmt.Sprintf("说明：%s\n", reminder.task.Description))
+	}
+	if reminder.task.Duration != "" {
+		sb.WriteString(fmt.Sprintf("时长：%s\n", reminder.task.Duration))
+	}
+	sb.WriteString(fmt.Sprintf("时间：%s %s\n", reminder.date, reminder.start.Format("15:04")))
+	return sb.String()
+}
+
+// ReminderJob returns the background job that sends due reminders, registered with the JobRunner on the
+// reminders.cron schedule.
+func ReminderJob(service ReminderService) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		sent, err := service.SendReminders(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		out.Printf("%d reminders sent.", sent)
+		return nil
+	}
+}
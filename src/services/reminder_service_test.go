@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/config"
+	"project/models"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testReminderConfig() config.ReminderConfig {
+	return config.ReminderConfig{
+		AgentID:          "hs_reminder_agent",
+		Model:            "reminder-model",
+		LeadMinutes:      15,
+		FollowUpMinutes:  120,
+		ReminderTemplate: "{time}要做「{task}」啦。{description}",
+		FollowUpTemplate: "今天{time}的「{task}」还没有打卡哦。",
+	}
+}
+
+func reminderTestPlan() *models.Plan {
+	daily := func(start, end string) *models.Recurrence {
+		return &models.Recurrence{Kind: models.RecurrenceDaily, TimesPerDay: 1, Windows: []models.TimeWindow{{Start: start, End: end}}}
+	}
+	return &models.Plan{ID: 5, UserID: "remindUser", Status: models.PlanStatusActive, StartDate: "2024-03-01", Tasks: []models.PlanTask{
+		{ID: 51, Title: "凯格尔运动", Description: "收缩盆底肌", Recurrence: daily("07:30", "10:00")},
+		{ID: 52, Title: "拉伸", Recurrence: daily("20:00", "22:00")},
+		{ID: 53, Title: "快走", Recurrence: &models.Recurrence{Kind: models.RecurrenceTimesPerWeek, TimesPerWeek: 3}},
+		{ID: 54, Title: "冥想", Recurrence: daily("22:00", "23:00")},
+	}}
+}
+
+func TestDueReminders(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	plan := reminderTestPlan()
+	checkIns := []models.TaskCheckIn{{TaskID: 52, DueDate: "2024-03-15", Slot: 1, Status: models.TaskStatusCompleted}}
+	lead, followUp := 15*time.Minute, 2*time.Hour
+
+	type due struct {
+		taskID   uint
+		date     string
+		category string
+	}
+	collect := func(reminders []taskReminder) []due {
+		var result []due
+		for _, r := range reminders {
+			result = append(result, due{r.task.ID, r.date, r.category})
+		}
+		return result
+	}
+
+	t.Run("Before the morning task", func(t *testing.T) {
+		now := time.Date(2024, 3, 15, 7, 20, 0, 0, loc)
+		assert.Equal(t, []due{{51, "2024-03-15", models.NotificationCategoryTaskReminder}}, collect(dueReminders(plan, checkIns, loc, now, lead, followUp)),
+			"yesterday's meditation can no longer be checked in and gets no follow-up")
+	})
+
+	t.Run("After the morning window", func(t *testing.T) {
+		now := time.Date(2024, 3, 15, 12, 5, 0, 0, loc)
+		assert.Equal(t, []due{{51, "2024-03-15", models.NotificationCategoryMissedCheckIn}}, collect(dueReminders(plan, checkIns, loc, now, lead, followUp)))
+
+		done := append(checkIns, models.TaskCheckIn{TaskID: 51, DueDate: "2024-03-15", Slot: 1, Status: models.TaskStatusSkipped})
+		assert.Empty(t, dueReminders(plan, done, loc, now, lead, followUp), "a skipped occurrence has a check-in")
+	})
+
+	t.Run("Flexible tasks get reminders only", func(t *testing.T) {
+		now := time.Date(2024, 3, 15, 19, 50, 0, 0, loc)
+		assert.Equal(t, []due{
+			{51, "2024-03-15", models.NotificationCategoryMissedCheckIn},
+			{53, "2024-03-15", models.NotificationCategoryTaskReminder}, // No window: the 20:00 default
+		}, collect(dueReminders(plan, checkIns, loc, now, lead, followUp)), "the stretching was done early")
+
+		late := time.Date(2024, 3, 15, 23, 50, 0, 0, loc)
+		assert.Equal(t, []due{{51, "2024-03-15", models.NotificationCategoryMissedCheckIn}}, collect(dueReminders(plan, checkIns, loc, late, lead, followUp)),
+			"the walk is not followed up and the meditation not before 01:00, which is tomorrow")
+	})
+
+	t.Run("No follow-ups when disabled", func(t *testing.T) {
+		now := time.Date(2024, 3, 15, 12, 5, 0, 0, loc)
+		assert.Empty(t, dueReminders(plan, checkIns, loc, now, lead, 0))
+	})
+
+	t.Run("Not before the plan starts", func(t *testing.T) {
+		future := reminderTestPlan()
+		future.StartDate = "2024-03-16"
+		assert.Empty(t, dueReminders(future, nil, loc, time.Date(2024, 3, 15, 7, 20, 0, 0, loc), lead, followUp))
+	})
+}
+
+func TestReminderService_SendReminders(t *testing.T) {
+	originalReminders, originalSchedule := config.AppConfig.Reminders, config.AppConfig.PlanSchedule
+	config.AppConfig.Reminders = testReminderConfig()
+	config.AppConfig.PlanSchedule.DefaultTimezone = "Asia/Shanghai"
+	defer func() {
+		config.AppConfig.Reminders, config.AppConfig.PlanSchedule = originalReminders, originalSchedule
+	}()
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Date(2024, 3, 15, 19, 50, 0, 0, loc)
+
+	setup := func() (*MockPlanRepository, *MockCheckInRepository, *MockNotificationService) {
+		mockPlanRepo := new(MockPlanRepository)
+		mockCheckInRepo := new(MockCheckInRepository)
+		mockNotifications := new(MockNotificationService)
+		mockPlanRepo.On("GetPlansByStatus", models.PlanStatusActive).Return([]*models.Plan{reminderTestPlan()}, nil).Once()
+		mockCheckInRepo.On("GetCheckInsByPlanID", uint(5)).Return([]models.TaskCheckIn{{TaskID: 52, DueDate: "2024-03-15", Slot: 1, Status: models.TaskStatusCompleted}}, nil).Once()
+		mockNotifications.On("GetPreferences", "remindUser").Return(&models.NotificationPreferences{UserID: "remindUser", Timezone: "Asia/Shanghai"}, nil).Once()
+		return mockPlanRepo, mockCheckInRepo, mockNotifications
+	}
+	delivered := func(args mock.Arguments) {
+		args.Get(0).(*models.Notification).Status = models.NotificationStatusDelivered
+	}
+
+	t.Run("Templates and dedupe", func(t *testing.T) {
+		mockPlanRepo, mockCheckInRepo, mockNotifications := setup()
+		service := NewReminderService(mockPlanRepo, mockCheckInRepo, mockNotifications, nil)
+		mockNotifications.On("Sent", "missed_check_in:51:2024-03-15:1").Return(true, nil).Once()
+		mockNotifications.On("Sent", "task_reminder:53:2024-03-15:1").Return(false, nil).Once()
+		mockNotifications.On("Send", mock.MatchedBy(func(n *models.Notification) bool {
+			return n.UserID == "remindUser" && n.Category == models.NotificationCategoryTaskReminder && n.TaskID == 53 &&
+				n.Topic == models.NotificationTopicHabit && n.Title == "任务提醒：快走" && n.Content == "20:00要做「快走」啦。" &&
+				n.AgentID == "hs_reminder_agent" && n.ExpiresAt != nil && n.ExpiresAt.Equal(time.Date(2024, 3, 16, 0, 0, 0, 0, loc))
+		}), now).Run(delivered).Return(nil).Once()
+
+		sent, err := service.SendReminders(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent, "the follow-up was already sent")
+		mockNotifications.AssertExpectations(t)
+	})
+
+	t.Run("Written by the agent", func(t *testing.T) {
+		config.AppConfig.Reminders.UseLLM = true
+		defer func() { config.AppConfig.Reminders.UseLLM = false }()
+		mockPlanRepo, mockCheckInRepo, mockNotifications := setup()
+		mockLLM := new(MockLLMClient)
+		service := NewReminderService(mockPlanRepo, mockCheckInRepo, mockNotifications, mockLLM)
+		mockNotifications.On("Sent", mock.Anything).Return(false, nil)
+		mockLLM.On("Complete", "reminder-model", mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+			return len(messages) == 2 && strings.Contains(messages[1].Content, "任务：凯格尔运动") && strings.Contains(messages[1].Content, "错过")
+		}), false).Return("", errors.New("timeout")).Once()
+		mockLLM.On("Complete", "reminder-model", mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+			return strings.Contains(messages[1].Content, "任务：快走") && strings.Contains(messages[1].Content, "即将开始")
+		}), false).Return(" 晚上好！快走时间快到啦，动起来吧～ ", nil).Once()
+		var contents []string
+		mockNotifications.On("Send", mock.AnythingOfType("*models.Notification"), now).Run(func(args mock.Arguments) {
+			contents = append(contents, args.Get(0).(*models.Notification).Content)
+			delivered(args)
+		}).Return(nil).Twice()
+
+		sent, err := service.SendReminders(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Equal(t, []string{"今天07:30的「凯格尔运动」还没有打卡哦。", "晚上好！快走时间快到啦，动起来吧～"}, contents, "the template is used if the agent fails")
+		mockLLM.AssertExpectations(t)
+	})
+
+	t.Run("Dropped by the preferences", func(t *testing.T) {
+		mockPlanRepo, mockCheckInRepo, mockNotifications := setup()
+		service := NewReminderService(mockPlanRepo, mockCheckInRepo, mockNotifications, nil)
+		mockNotifications.On("Sent", mock.Anything).Return(false, nil)
+		mockNotifications.On("Send", mock.AnythingOfType("*models.Notification"), now).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Notification).Status = models.NotificationStatusDropped
+		}).Return(nil).Twice()
+
+		sent, err := service.SendReminders(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent)
+	})
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"project/utils"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// BM25 parameters: term frequency saturation and passage length normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Passage is part of a knowledge base article, retrieved to ground the answer of a knowledge agent.
+type Passage struct {
+	ArticleID uint
+	Title     string
+	Text      string
+	Score     float64
+}
+
+// Retriever finds the knowledge base passages most relevant to a question, best first. The BM25 retriever works
+// offline; a retriever using embeddings can implement the same interface.
+type Retriever interface {
+	Retrieve(query string, limit int) ([]Passage, error)
+}
+
+type bm25Passage struct {
+	passage Passage
+	tf      map[string]int
+	length  int
+}
+
+// bm25Index ranks passages by Okapi BM25 over their search tokens.
+type bm25Index struct {
+	passages  []bm25Passage
+	df        map[string]int // Number of passages containing each token
+	avgLength float64
+}
+
+type bm25Retriever struct {
+	articleRepo repository.ArticleRepository
+	mu          sync.Mutex
+	index       *bm25Index
+	builtAt     time.Time
+	now         func() time.Time
+}
+
+// NewBM25Retriever creates a Retriever ranking the passages of the approved articles by BM25. The passages are
+// indexed in memory and reloaded every retrieval.refresh_minutes, so edits to articles show with a delay; an
+// article that is no longer approved, or whose approval has expired, is never returned.
+func NewBM25Retriever(articleRepo repository.ArticleRepository) Retriever {
+	return &bm25Retriever{articleRepo: articleRepo, now: time.Now}
+}
+
+func (r *bm25Retriever) Retrieve(query string, limit int) ([]Passage, error) {
+	index, err := r.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	// The index may predate a review decision, so the review of each candidate article is checked again.
+	candidates := index.search(utils.SearchTokens(query), len(index.passages), config.AppConfig.Retrieval.MinScore)
+	citable := make(map[uint]bool)
+	var passages []Passage
+	for _, passage := range candidates {
+		ok, checked := citable[passage.ArticleID]
+		if !checked {
+			if ok, err = r.stillApproved(passage.ArticleID); err != nil {
+				return nil, err
+			}
+			citable[passage.ArticleID] = ok
+		}
+		if !ok {
+			continue
+		}
+		passages = append(passages, passage)
+		if len(passages) == limit {
+			break
+		}
+	}
+	return passages, nil
+}
+
+// stillApproved reports whether an indexed article is still approved and its approval has not expired.
+func (r *bm25Retriever) stillApproved(articleID uint) (bool, error) {
+	article, err := r.articleRepo.GetArticleByID(articleID)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to check the review of article ID %d", articleID)
+		log.Printf("ERROR: [Retriever] %s: %v", errMsg, err)
+		return false, fmt.Errorf("%s: %w", errMsg, err)
+	}
+	if article == nil || article.ReviewStatus != models.ReviewStatusApproved || approvalExpired(&article.ContentReview, r.now()) {
+		log.Printf("INFO: [Retriever] Article ID %d is no longer approved; its passages are not cited.", articleID)
+		return false, nil
+	}
+	return true, nil
+}
+
+// currentIndex returns the passage index, rebuilding it if it has expired.
+func (r *bm25Retriever) currentIndex() (*bm25Index, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refresh := time.Duration(config.AppConfig.Retrieval.RefreshMinutes) * time.Minute
+	if refresh <= 0 {
+		refresh = 10 * time.Minute
+	}
+	now := r.now()
+	if r.index != nil && now.Sub(r.builtAt) < refresh {
+		return r.index, nil
+	}
+
+	var articles []*models.Article
+	for offset := 0; ; offset += maxArticlePage {
+		page, err := r.articleRepo.ListArticles(models.ArticleQuery{Status: models.ReviewStatusApproved, ReviewedAfter: approvalCutoff(now), Offset: offset, Limit: maxArticlePage})
+		if err != nil {
+			errMsg := "failed to load articles for retrieval"
+			log.Printf("ERROR: [Retriever] %s: %v", errMsg, err)
+			return nil, fmt.Errorf("%s: %w", errMsg, err)
+		}
+		articles = append(articles, page.Articles...)
+		if len(page.Articles) < maxArticlePage {
+			break
+		}
+	}
+	passageChars := config.AppConfig.Retrieval.PassageChars
+	if passageChars <= 0 {
+		passageChars = 600
+	}
+	var passages []Passage
+	for _, article := range articles {
+		passages = append(passages, splitPassages(article, passageChars)...)
+	}
+	r.index = newBM25Index(passages)
+	r.builtAt = now
+	log.Printf("INFO: [Retriever] Indexed %d passages of %d approved articles.", len(passages), len(articles))
+	return r.index, nil
+}
+
+// splitPassages splits the body of an article into passages of at most maxChars characters, merging short
+// paragraphs and cutting long ones.
+func splitPassages(article *models.Article, maxChars int) []Passage {
+	var passages []Passage
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			passages = append(passages, Passage{ArticleID: article.ID, Title: article.Title, Text: current.String()})
+			current.Reset()
+		}
+	}
+	body := strings.ReplaceAll(article.Body, "\r\n", "\n")
+	for _, paragraph := range strings.Split(body, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		for utf8.RuneCountInString(paragraph) > maxChars {
+			flush()
+			runes := []rune(paragraph)
+			passages = append(passages, Passage{ArticleID: article.ID, Title: article.Title, Text: string(runes[:maxChars])})
+			paragraph = string(runes[maxChars:])
+		}
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(paragraph) > maxChars {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(paragraph)
+	}
+	flush()
+	return passages
+}
+
+// newBM25Index indexes passages by their text and the title of their article.
+func newBM25Index(passages []Passage) *bm25Index {
+	index := &bm25Index{df: make(map[string]int)}
+	total := 0
+	for _, passage := range passages {
+		tokens := utils.SearchTokens(passage.Title + "\n" + passage.Text)
+		indexed := bm25Passage{passage: passage, tf: make(map[string]int), length: len(tokens)}
+		for _, token := range tokens {
+			if indexed.tf[token] == 0 {
+				index.df[token]++
+			}
+			indexed.tf[token]++
+		}
+		total += len(tokens)
+		index.passages = append(index.passages, indexed)
+	}
+	if len(passages) > 0 {
+		index.avgLength = float64(total) / float64(len(passages))
+	}
+	return index
+}
+
+// search returns up to limit passages matching the query tokens, best first, leaving out those scoring less than
+// minScore.
+func (index *bm25Index) search(queryTokens []string, limit int, minScore float64) []Passage {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range queryTokens {
+		if !seen[token] && index.df[token] > 0 {
+			seen[token] = true
+			terms = append(terms, token)
+		}
+	}
+	if len(terms) == 0 || limit <= 0 {
+		return nil
+	}
+	n := float64(len(index.passages))
+	var results []Passage
+	for _, indexed := range index.passages {
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(indexed.tf[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(index.df[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(indexed.length)/index.avgLength))
+		}
+		if score > 0 && score >= minScore {
+			passage := indexed.passage
+			passage.Score = score
+			results = append(results, passage)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// retrievalContext instructs a knowledge agent to answer from the retrieved passages and cite their article IDs.
+func retrievalContext(passages []Passage) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n[系统指令：知识库参考资料]\n")
+	if len(passages) == 0 {
+		sb.WriteString("知识库中没有检索到与用户问题相关的文章。请只给出一般性的科普建议，并说明知识库中暂无相关资料；不要编造具体的医学数据或文献，必要时建议用户咨询医生。\n")
+		return sb.String()
+	}
+	sb.WriteString("以下是从知识库检索到的文章段落，方括号中为文章ID：\n")
+	for _, passage := range passages {
+		sb.WriteString(fmt.Sprintf("\n[%d] 《%s》\n%s\n", passage.ArticleID, passage.Title, passage.Text))
+	}
+	sb.WriteString(fmt.Sprintf("\n回答要求：涉及医学和健康事实时只依据以上资料，并在引用资料的句子后用方括号标注文章ID，例如 [%d]。", passages[0].ArticleID))
+	sb.WriteString("资料没有涵盖的内容请说明知识库中暂无相关资料，不要编造，必要时建议用户咨询医生。\n")
+	return sb.String()
+}
+
+// citationPattern matches citations such as [12], 【12】 and [12, 15].
+var citationPattern = regexp.MustCompile(`[\[【]\s*(\d+(?:\s*[,，、]\s*\d+)*)\s*[\]】]`)
+
+// citedArticles returns the articles of the passages that reply cites, in the order they are first cited.
+// Bracketed numbers that are not IDs of the passages are ignored.
+func citedArticles(reply string, passages []Passage) []models.ArticleCitation {
+	titles := make(map[uint]string)
+	for _, passage := range passages {
+		titles[passage.ArticleID] = passage.Title
+	}
+	citations := []models.ArticleCitation{}
+	cited := make(map[uint]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(reply, -1) {
+		for _, field := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == '，' || r == '、' || r == ' ' }) {
+			id, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				continue
+			}
+			articleID := uint(id)
+			if title, ok := titles[articleID]; ok && !cited[articleID] {
+				cited[articleID] = true
+				citations = append(citations, models.ArticleCitation{ID: articleID, Title: title, Link: ArticleLink(articleID)})
+			}
+		}
+	}
+	return citations
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"project/config"
+	"project/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func retrievalTestArticles() []*models.Article {
+	approved := models.ContentReview{ReviewStatus: models.ReviewStatusApproved}
+	return []*models.Article{
+		{ID: 11, Title: "盆底肌训练入门", Body: "凯格尔运动通过收缩盆底肌来增强控制力。\n\n每天练习三组，每组收缩十次，每次保持三秒。", ContentReview: approved},
+		{ID: 12, Title: "饮食与男性健康", Body: "均衡饮食有助于维持体重和血管健康。\n\n多吃蔬菜水果，少吃高脂食物。", ContentReview: approved},
+		{ID: 13, Title: "缓解表现焦虑", Body: "焦虑会影响表现。正念呼吸练习可以帮助放松。", ContentReview: approved},
+	}
+}
+
+func TestSplitPassages(t *testing.T) {
+	article := &models.Article{ID: 5, Title: "标题", Body: "第一段。\r\n\r\n第二段。\n\n\n\n第三段比较长一些。\n\n" + strings.Repeat("长", 25)}
+
+	passages := splitPassages(article, 10)
+
+	var texts []string
+	for _, passage := range passages {
+		assert.Equal(t, uint(5), passage.ArticleID)
+		assert.Equal(t, "标题", passage.Title)
+		texts = append(texts, passage.Text)
+	}
+	assert.Equal(t, []string{"第一段。\n第二段。", "第三段比较长一些。", strings.Repeat("长", 10), strings.Repeat("长", 10), strings.Repeat("长", 5)}, texts,
+		"short paragraphs are merged, long ones cut")
+}
+
+func TestBM25Index_Search(t *testing.T) {
+	var passages []Passage
+	for _, article := range retrievalTestArticles() {
+		passages = append(passages, splitPassages(article, 600)...)
+	}
+	index := newBM25Index(passages)
+
+	results := index.search([]string{"盆底", "底肌", "训练"}, 3, 0)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, uint(11), results[0].ArticleID)
+		for i := 1; i < len(results); i++ {
+			assert.GreaterOrEqual(t, results[i-1].Score, results[i].Score, "best first")
+		}
+	}
+
+	results = index.search([]string{"焦虑", "放松", "焦虑"}, 1, 0)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, uint(13), results[0].ArticleID)
+	}
+
+	assert.Empty(t, index.search([]string{"量子"}, 3, 0), "no passage has the term")
+	assert.Empty(t, index.search([]string{"焦虑"}, 3, 100), "below the minimum score")
+	assert.Empty(t, newBM25Index(nil).search([]string{"焦虑"}, 3, 0))
+}
+
+func TestBM25Retriever_Retrieve(t *testing.T) {
+	original := config.AppConfig.Retrieval
+	config.AppConfig.Retrieval = config.RetrievalConfig{RefreshMinutes: 10}
+	defer func() { config.AppConfig.Retrieval = original }()
+	mockArticleRepo := new(MockArticleRepository)
+	retriever := NewBM25Retriever(mockArticleRepo).(*bm25Retriever)
+	now := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	retriever.now = func() time.Time { return now }
+	approved := models.ArticleQuery{Status: models.ReviewStatusApproved, Limit: maxArticlePage}
+	mockArticleRepo.On("ListArticles", approved).Return(&models.ArticlePage{Articles: retrievalTestArticles(), Total: 3}, nil).Twice()
+	for _, article := range retrievalTestArticles() {
+		mockArticleRepo.On("GetArticleByID", article.ID).Return(article, nil)
+	}
+
+	passages, err := retriever.Retrieve("凯格尔运动怎么练？", 2)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, passages) {
+		assert.Equal(t, uint(11), passages[0].ArticleID)
+	}
+	_, err = retriever.Retrieve("蔬菜", 2)
+	assert.NoError(t, err)
+	mockArticleRepo.AssertNumberOfCalls(t, "ListArticles", 1) // The index is reused until it expires
+
+	now = now.Add(11 * time.Minute)
+	_, err = retriever.Retrieve("蔬菜", 2)
+	assert.NoError(t, err)
+	mockArticleRepo.AssertNumberOfCalls(t, "ListArticles", 2)
+
+	now = now.Add(11 * time.Minute)
+	mockArticleRepo.On("ListArticles", approved).Return(nil, errors.New("db down")).Once()
+	_, err = retriever.Retrieve("蔬菜", 2)
+	assert.Error(t, err)
+}
+
+func TestBM25Retriever_RetrieveOnlyCitesApprovedArticles(t *testing.T) {
+	original, originalReview := config.AppConfig.Retrieval, config.AppConfig.ContentReview
+	config.AppConfig.Retrieval = config.RetrievalConfig{RefreshMinutes: 10}
+	config.AppConfig.ContentReview = config.ContentReviewConfig{MaxAgeDays: 365}
+	defer func() { config.AppConfig.Retrieval, config.AppConfig.ContentReview = original, originalReview }()
+	mockArticleRepo := new(MockArticleRepository)
+	retriever := NewBM25Retriever(mockArticleRepo).(*bm25Retriever)
+	now := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	retriever.now = func() time.Time { return now }
+	articles := retrievalTestArticles()
+	cutoff := now.AddDate(0, 0, -365)
+	mockArticleRepo.On("ListArticles", models.ArticleQuery{Status: models.ReviewStatusApproved, ReviewedAfter: &cutoff, Limit: maxArticlePage}).
+		Return(&models.ArticlePage{Articles: articles, Total: 3}, nil).Once()
+
+	// After indexing, article 11 is retired and the approval of article 12 expires
+	retired := *articles[0]
+	retired.ReviewStatus = models.ReviewStatusRetired
+	expired := *articles[1]
+	approvedAt := now.AddDate(-2, 0, 0)
+	expired.ReviewedAt = &approvedAt
+	mockArticleRepo.On("GetArticleByID", uint(11)).Return(&retired, nil).Once()
+	mockArticleRepo.On("GetArticleByID", uint(12)).Return(&expired, nil).Once()
+	mockArticleRepo.On("GetArticleByID", uint(13)).Return(articles[2], nil).Once()
+
+	passages, err := retriever.Retrieve("凯格尔运动 蔬菜 焦虑", 3)
+
+	assert.NoError(t, err)
+	if assert.Len(t, passages, 1) {
+		assert.Equal(t, uint(13), passages[0].ArticleID)
+	}
+	mockArticleRepo.AssertExpectations(t)
+
+	mockArticleRepo.On("GetArticleByID", uint(13)).Return(nil, errors.New("db down")).Once()
+	_, err = retriever.Retrieve("焦虑", 3)
+	assert.Error(t, err)
+}
+
+func TestRetrievalContext(t *testing.T) {
+	context := retrievalContext([]Passage{{ArticleID: 11, Title: "盆底肌训练入门", Text: "每天练习三组。"}})
+	assert.Contains(t, context, "[11] 《盆底肌训练入门》\n每天练习三组。")
+	assert.Contains(t, context, "例如 [11]")
+
+	assert.Contains(t, retrievalContext(nil), "暂无相关资料")
+}
+
+func TestCitedArticles(t *testing.T) {
+	passages := []Passage{
+		{ArticleID: 11, Title: "盆底肌训练入门"},
+		{ArticleID: 11, Title: "盆底肌训练入门"},
+		{ArticleID: 13, Title: "缓解表现焦虑"},
+		{ArticleID: 12, Title: "饮食与男性健康"},
+	}
+	reply := "放松有帮助【13】。每天练习三组 [11]，坚持 [2] 周 [11，13]。"
+
+	citations := citedArticles(reply, passages)
+
+	assert.Equal(t, []models.ArticleCitation{
+		{ID: 13, Title: "缓解表现焦虑", Link: "/articles/13"},
+		{ID: 11, Title: "盆底肌训练入门", Link: "/articles/11"},
+	}, citations, "in citation order, once each, without numbers that are not retrieved articles")
+	assert.Empty(t, citedArticles("没有引用。", passages))
+}
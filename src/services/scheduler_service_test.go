@@ -37,6 +37,7 @@ func (m *MockAssessmentRepository) GetUserAssessmentByUserID(userID string, stat
 func (m *MockAssessmentRepository) CreateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {panic("not needed for these scheduler tests")}
 func (m *MockAssessmentRepository) GetUserAssessmentByID(id uint) (*models.UserAssessment, error) {panic("not needed for these scheduler tests")}
 func (m *MockAssessmentRepository) UpdateUserAssessment(assessment *models.UserAssessment) (*models.UserAssessment, error) {panic("not needed for these scheduler tests")}
+func (m *MockAssessmentRepository) ListUserAssessments(userID string, statusFilter ...models.UserAssessmentStatus) ([]*models.UserAssessment, error) {panic("not needed for these scheduler tests")}
 
 
 // analyzeMessageWithAI is an unexported method and makes a real API call.
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"project/config"
+	"project/models"
+	"project/repository"
+	"strconv"
+	"time"
+)
+
+// Headers of webhook requests.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-ID" // The event ID, the same on every attempt
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookBatchSize caps the deliveries one DeliverDue call attempts.
+const webhookBatchSize = 100
+
+// WebhookService delivers the events published on the EventBus to the configured webhook endpoints. Each event is
+// queued for every endpoint subscribed to its type and sent right away; failed attempts are retried with
+// exponential backoff by the retry job, and deliveries that fail webhooks.max_attempts times become dead letters.
+type WebhookService interface {
+	// HandleEvent queues an event for the endpoints that receive it. It is subscribed to the EventBus and returns
+	// right away; the event is queued in the background.
+	HandleEvent(event models.Event)
+	// DeliverDue attempts the queued deliveries whose retry is due and returns the number delivered.
+	DeliverDue(ctx context.Context, now time.Time) (int, error)
+	ListDeadLetters(limit int) ([]models.WebhookDeadLetter, error)
+	// ReplayDeadLetter queues a dead letter again, with a fresh set of attempts.
+	ReplayDeadLetter(id uint, now time.Time) (*models.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	client      *http.Client
+	dispatch    func(func()) // Runs queuing and the first attempt of a delivery in the background
+}
+
+// NewWebhookService creates a new instance of WebhookService.
+func NewWebhookService(webhookRepo repository.WebhookRepository) WebhookService {
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		client:      &http.Client{},
+		dispatch:    func(f func()) { go f() },
+	}
+}
+
+// SignWebhook returns the signature of a webhook request: the hex HMAC-SHA256 of the timestamp, a dot and the body.
+// Receivers recompute it with the shared secret to verify the request.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookEndpoint returns the configured endpoint with the name, or nil.
+func webhookEndpoint(name string) *config.WebhookEndpoint {
+	for i, endpoint := range config.AppConfig.Webhooks.Endpoints {
+		if endpoint.Name == name {
+			return &config.AppConfig.Webhooks.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// receivesEvent reports whether an endpoint is subscribed to an event type.
+func receivesEvent(endpoint config.WebhookEndpoint, eventType string) bool {
+	if len(endpoint.Events) == 0 {
+		return true
+	}
+	return contains(endpoint.Events, eventType)
+}
+
+// webhookLease is how long a delivery being attempted right away is hidden from the retry job.
+func webhookLease() time.Duration {
+	return 2*webhookTimeout() + 30*time.Second
+}
+
+func webhookTimeout() time.Duration {
+	if seconds := config.AppConfig.Webhooks.TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// webhookBackoff returns how long to wait before the next attempt after the given number of failed attempts.
+func webhookBackoff(attempts int) time.Duration {
+	cfg := config.AppConfig.Webhooks
+	backoff := time.Duration(cfg.BackoffSeconds) * time.Second
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+	maxBackoff := time.Duration(cfg.MaxBackoffSeconds) * time.Second
+	if maxBackoff <= 0 {
+		maxBackoff = time.Hour
+	}
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func (s *webhookService) HandleEvent(event models.Event) {
+	if !config.AppConfig.Webhooks.Enabled || s.webhookRepo == nil {
+		return
+	}
+	s.dispatch(func() { s.enqueue(event) })
+}
+
+// enqueue queues an event for every endpoint that receives it and attempts each delivery right away.
+func (s *webhookService) enqueue(event models.Event) {
+	var payload []byte
+	for _, endpoint := range config.AppConfig.Webhooks.Endpoints {
+		if !receivesEvent(endpoint, event.Type) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				log.Printf("ERROR: [WebhookService] Failed to encode %s event %s: %v", event.Type, event.ID, err)
+				return
+			}
+		}
+		now := time.Now()
+		delivery := &models.WebhookDelivery{
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Endpoint:      endpoint.Name,
+			Payload:       string(payload),
+			NextAttemptAt: now.Add(webhookLease()), // The retry job takes over if the process stops before the attempt
+		}
+		if err := s.webhookRepo.CreateDelivery(delivery); err != nil {
+			continue // Logged by the repository
+		}
+		s.dispatch(func() { s.attempt(context.Background(), delivery) })
+	}
+}
+
+func (s *webhookService) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	if s.webhookRepo == nil {
+		return 0, errors.New("webhookrepo not initialized")
+	}
+	deliveries, err := s.webhookRepo.GetDueDeliveries(now, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for i := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		if s.attempt(ctx, &deliveries[i]) {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attempt sends a delivery to its endpoint once and records the outcome: a delivered event is removed from the
+// queue, a failed one is scheduled for a retry or becomes a dead letter. It reports whether the endpoint accepted it.
+func (s *webhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) bool {
+	err := s.send(ctx, delivery)
+	now := time.Now()
+	if err == nil {
+		if err := s.webhookRepo.DeleteDelivery(delivery.ID); err != nil {
+			log.Printf("ERROR: [WebhookService] Delivered %s event %s to '%s' but failed to dequeue it; it will be sent again.", delivery.EventType, delivery.EventID, delivery.Endpoint)
+		}
+		log.Printf("INFO: [WebhookService] Delivered %s event %s to '%s' (attempt %d).", delivery.EventType, delivery.EventID, delivery.Endpoint, delivery.Attempts+1)
+		return true
+	}
+
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	maxAttempts := config.AppConfig.Webhooks.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	if delivery.Attempts >= maxAttempts || errors.Is(err, errWebhookEndpointRemoved) {
+		deadLetter := &models.WebhookDeadLetter{
+			EventID:   delivery.EventID,
+			EventType: delivery.EventType,
+			Endpoint:  delivery.Endpoint,
+			Payload:   delivery.Payload,
+			Attempts:  delivery.Attempts,
+			LastError: delivery.LastError,
+			QueuedAt:  delivery.CreatedAt,
+		}
+		if err := s.webhookRepo.MoveToDeadLetter(delivery, deadLetter); err == nil {
+			log.Printf("WARN: [WebhookService] Gave up on %s event %s for '%s' after %d attempts: %s", delivery.EventType, delivery.EventID, delivery.Endpoint, delivery.Attempts, delivery.LastError)
+		}
+		return false
+	}
+	delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+	if err := s.webhookRepo.UpdateDelivery(delivery); err == nil {
+		log.Printf("WARN: [WebhookService] Attempt %d of %s event %s for '%s' failed, retrying at %s: %s", delivery.Attempts, delivery.EventType, delivery.EventID, delivery.Endpoint, delivery.NextAttemptAt.Format(time.RFC3339), delivery.LastError)
+	}
+	return false
+}
+
+// errWebhookEndpointRemoved fails deliveries to endpoints no longer in the configuration without retrying them.
+var errWebhookEndpointRemoved = errors.New("endpoint is no longer configured")
+
+// send posts the signed payload of a delivery to its endpoint. Any 2xx response counts as accepted.
+func (s *webhookService) send(ctx context.Context, delivery *models.WebhookDelivery) error {
+	endpoint := webhookEndpoint(delivery.Endpoint)
+	if endpoint == nil {
+		return errWebhookEndpointRemoved
+	}
+	secret := os.Getenv(endpoint.SecretEnv)
+	if secret == "" {
+		return fmt.Errorf("signing secret not set: environment variable '%s' is empty", endpoint.SecretEnv)
+	}
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookIDHeader, delivery.EventID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *webhookService) ListDeadLetters(limit int) ([]models.WebhookDeadLetter, error) {
+	if s.webhookRepo == nil {
+		return nil, errors.New("webhookrepo not initialized")
+	}
+	return s.webhookRepo.GetDeadLetters(limit)
+}
+
+func (s *webhookService) ReplayDeadLetter(id uint, now time.Time) (*models.WebhookDelivery, error) {
+	if s.webhookRepo == nil {
+		return nil, errors.New("webhookrepo not initialized")
+	}
+	deadLetter, err := s.webhookRepo.GetDeadLetterByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetter == nil {
+		return nil, fmt.Errorf("dead letter %d not found", id)
+	}
+	if webhookEndpoint(deadLetter.Endpoint) == nil {
+		return nil, fmt.Errorf("cannot replay dead letter %d: endpoint '%s' is no longer configured", id, deadLetter.Endpoint)
+	}
+	// Retried by the next run of the retry job
+	delivery := &models.WebhookDelivery{
+		EventID:       deadLetter.EventID,
+		EventType:     deadLetter.EventType,
+		Endpoint:      deadLetter.Endpoint,
+		Payload:       deadLetter.Payload,
+		NextAttemptAt: now,
+	}
+	if err := s.webhookRepo.ReplayDeadLetter(deadLetter, delivery); err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: [WebhookService] Replaying dead letter ID %d (%s event %s for '%s') as delivery ID %d.", id, deadLetter.EventType, deadLetter.EventID, deadLetter.Endpoint, delivery.ID)
+	return delivery, nil
+}
+
+// WebhookRetryJob returns the background job that retries failed webhook deliveries once their backoff has passed.
+func WebhookRetryJob(service WebhookService) JobFunc {
+	return func(ctx context.Context, out *JobLog) error {
+		delivered, err := service.DeliverDue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		out.Printf("%d webhook deliveries retried successfully.", delivered)
+		return nil
+	}
+}
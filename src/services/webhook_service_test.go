@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"project/config"
+	"project/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockWebhookRepository is a mock type for the WebhookRepository type
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) DeleteDelivery(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	args := m.Called(now, limit)
+	var deliveries []models.WebhookDelivery
+	if args.Get(0) != nil {
+		deliveries = args.Get(0).([]models.WebhookDelivery)
+	}
+	return deliveries, args.Error(1)
+}
+
+func (m *MockWebhookRepository) MoveToDeadLetter(delivery *models.WebhookDelivery, deadLetter *models.WebhookDeadLetter) error {
+	args := m.Called(delivery, deadLetter)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetDeadLetters(limit int) ([]models.WebhookDeadLetter, error) {
+	args := m.Called(limit)
+	var deadLetters []models.WebhookDeadLetter
+	if args.Get(0) != nil {
+		deadLetters = args.Get(0).([]models.WebhookDeadLetter)
+	}
+	return deadLetters, args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetDeadLetterByID(id uint) (*models.WebhookDeadLetter, error) {
+	args := m.Called(id)
+	var deadLetter *models.WebhookDeadLetter
+	if args.Get(0) != nil {
+		deadLetter = args.Get(0).(*models.WebhookDeadLetter)
+	}
+	return deadLetter, args.Error(1)
+}
+
+func (m *MockWebhookRepository) ReplayDeadLetter(deadLetter *models.WebhookDeadLetter, delivery *models.WebhookDelivery) error {
+	args := m.Called(deadLetter, delivery)
+	return args.Error(0)
+}
+
+// webhookReceiver is a local endpoint that records the requests it receives and answers with a status per path.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   map[string]int
+}
+
+func newWebhookReceiver(t *testing.T, status map[string]int) (*webhookReceiver, *httptest.Server) {
+	receiver := &webhookReceiver{status: status}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.mu.Unlock()
+		w.WriteHeader(receiver.status[r.URL.Path])
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+// useWebhookConfig configures the endpoints for a test, with the secret in TEST_WEBHOOK_SECRET.
+func useWebhookConfig(t *testing.T, endpoints ...config.WebhookEndpoint) {
+	original := config.AppConfig.Webhooks
+	config.AppConfig.Webhooks = config.WebhookConfig{Enabled: true, Endpoints: endpoints, MaxAttempts: 3, BackoffSeconds: 30, MaxBackoffSeconds: 300}
+	t.Cleanup(func() { config.AppConfig.Webhooks = original })
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"id":"e1"}`)
+	signature := SignWebhook("s3cret", "1710475200", body)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+	assert.Equal(t, signature, SignWebhook("s3cret", "1710475200", body))
+	assert.NotEqual(t, signature, SignWebhook("other", "1710475200", body), "depends on the secret")
+	assert.NotEqual(t, signature, SignWebhook("s3cret", "1710475201", body), "depends on the timestamp")
+	assert.NotEqual(t, signature, SignWebhook("s3cret", "1710475200", []byte(`{"id":"e2"}`)), "depends on the body")
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	useWebhookConfig(t)
+
+	assert.Equal(t, 30*time.Second, webhookBackoff(1))
+	assert.Equal(t, 60*time.Second, webhookBackoff(2))
+	assert.Equal(t, 240*time.Second, webhookBackoff(4))
+	assert.Equal(t, 300*time.Second, webhookBackoff(5), "capped")
+	assert.Equal(t, 300*time.Second, webhookBackoff(60))
+}
+
+func TestWebhookService_HandleEvent(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, map[string]int{"/gateway": http.StatusNoContent, "/bot": http.StatusBadGateway})
+	useWebhookConfig(t,
+		config.WebhookEndpoint{Name: "gateway", URL: server.URL + "/gateway", SecretEnv: "TEST_WEBHOOK_SECRET", Events: []string{models.EventReminderDue}},
+		config.WebhookEndpoint{Name: "bot", URL: server.URL + "/bot", SecretEnv: "TEST_WEBHOOK_SECRET"},
+		config.WebhookEndpoint{Name: "crm", URL: server.URL + "/crm", SecretEnv: "TEST_WEBHOOK_SECRET", Events: []string{models.EventPlanCreated}},
+	)
+	mockWebhookRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockWebhookRepo).(*webhookService)
+	service.dispatch = func(f func()) { f() }
+	events := NewEventBus()
+	events.Subscribe(service.HandleEvent)
+
+	var queued []string
+	mockWebhookRepo.On("CreateDelivery", mock.AnythingOfType("*models.WebhookDelivery")).Run(func(args mock.Arguments) {
+		delivery := args.Get(0).(*models.WebhookDelivery)
+		delivery.ID = uint(len(queued) + 1)
+		queued = append(queued, delivery.Endpoint)
+		assert.True(t, delivery.NextAttemptAt.After(time.Now()), "hidden from the retry job while attempted")
+	}).Return(nil).Twice()
+	mockWebhookRepo.On("DeleteDelivery", uint(1)).Return(nil).Once()
+	mockWebhookRepo.On("UpdateDelivery", mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+		return d.ID == 2 && d.Attempts == 1 && d.LastError == "endpoint responded with status 502" &&
+			d.NextAttemptAt.After(time.Now().Add(25*time.Second))
+	})).Return(nil).Once()
+
+	before := time.Now()
+	events.Publish(models.Event{Type: models.EventReminderDue, UserID: "hookUser", Data: map[string]interface{}{"task_id": 53}})
+
+	assert.Equal(t, []string{"gateway", "bot"}, queued, "the crm only receives new plans")
+	mockWebhookRepo.AssertExpectations(t)
+	if assert.Len(t, receiver.requests, 2) {
+		req, body := receiver.requests[0], receiver.bodies[0]
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, models.EventReminderDue, req.Header.Get(WebhookEventHeader))
+		assert.Equal(t, SignWebhook("s3cret", req.Header.Get(WebhookTimestampHeader), body), req.Header.Get(WebhookSignatureHeader))
+
+		var event models.Event
+		assert.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, req.Header.Get(WebhookIDHeader), event.ID)
+		assert.NotEmpty(t, event.ID)
+		assert.Equal(t, "hookUser", event.UserID)
+		assert.False(t, event.OccurredAt.Before(before.Truncate(time.Second)))
+		assert.Equal(t, map[string]interface{}{"task_id": float64(53)}, event.Data)
+		assert.Equal(t, body, receiver.bodies[1], "every endpoint gets the same payload")
+	}
+}
+
+func TestWebhookService_HandleEventQueuesInBackground(t *testing.T) {
+	_, server := newWebhookReceiver(t, map[string]int{"/gateway": http.StatusNoContent})
+	useWebhookConfig(t, config.WebhookEndpoint{Name: "gateway", URL: server.URL + "/gateway", SecretEnv: "TEST_WEBHOOK_SECRET"})
+	mockWebhookRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockWebhookRepo).(*webhookService)
+	var background []func()
+	service.dispatch = func(f func()) { background = append(background, f) }
+	mockWebhookRepo.On("CreateDelivery", mock.AnythingOfType("*models.WebhookDelivery")).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.WebhookDelivery).ID = 1
+	}).Return(nil).Once()
+	mockWebhookRepo.On("DeleteDelivery", uint(1)).Return(nil).Once()
+
+	service.HandleEvent(models.Event{ID: "e1", Type: models.EventPlanCreated, UserID: "hookUser"})
+
+	mockWebhookRepo.AssertNotCalled(t, "CreateDelivery", mock.Anything) // Nothing is stored in the publisher's goroutine
+	for len(background) > 0 {
+		f := background[0]
+		background = background[1:]
+		f()
+	}
+	mockWebhookRepo.AssertExpectations(t)
+}
+
+func TestWebhookService_DeliverDue(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, map[string]int{"/ok": http.StatusOK, "/down": http.StatusServiceUnavailable})
+	useWebhookConfig(t,
+		config.WebhookEndpoint{Name: "ok", URL: server.URL + "/ok", SecretEnv: "TEST_WEBHOOK_SECRET"},
+		config.WebhookEndpoint{Name: "down", URL: server.URL + "/down", SecretEnv: "TEST_WEBHOOK_SECRET"},
+		config.WebhookEndpoint{Name: "unsigned", URL: server.URL + "/ok", SecretEnv: "TEST_WEBHOOK_MISSING_SECRET"},
+	)
+	now := time.Now()
+	queuedAt := now.Add(-time.Hour)
+	mockWebhookRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockWebhookRepo)
+
+	mockWebhookRepo.On("GetDueDeliveries", now, webhookBatchSize).Return([]models.WebhookDelivery{
+		{ID: 1, EventID: "e1", EventType: models.EventPlanCreated, Endpoint: "ok", Payload: `{"id":"e1"}`, Attempts: 2},
+		{ID: 2, EventID: "e2", EventType: models.EventPlanCreated, Endpoint: "down", Payload: `{"id":"e2"}`, Attempts: 1},
+		{ID: 3, EventID: "e3", EventType: models.EventPlanCreated, Endpoint: "down", Payload: `{"id":"e3"}`, Attempts: 2, CreatedAt: queuedAt},
+		{ID: 4, EventID: "e4", EventType: models.EventPlanCreated, Endpoint: "removed", Payload: `{"id":"e4"}`},
+		{ID: 5, EventID: "e5", EventType: models.EventPlanCreated, Endpoint: "unsigned", Payload: `{"id":"e5"}`},
+	}, nil).Once()
+	mockWebhookRepo.On("DeleteDelivery", uint(1)).Return(nil).Once()
+	mockWebhookRepo.On("UpdateDelivery", mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+		return d.ID == 2 && d.Attempts == 2 && d.NextAttemptAt.After(now.Add(55*time.Second)) // Second failure: 60s
+	})).Return(nil).Once()
+	mockWebhookRepo.On("MoveToDeadLetter", mock.MatchedBy(func(d *models.WebhookDelivery) bool { return d.ID == 3 }), mock.MatchedBy(func(dl *models.WebhookDeadLetter) bool {
+		return dl.EventID == "e3" && dl.Endpoint == "down" && dl.Attempts == 3 && dl.Payload == `{"id":"e3"}` &&
+			dl.LastError == "endpoint responded with status 503" && dl.QueuedAt.Equal(queuedAt)
+	})).Return(nil).Once()
+	mockWebhookRepo.On("MoveToDeadLetter", mock.MatchedBy(func(d *models.WebhookDelivery) bool { return d.ID == 4 }), mock.MatchedBy(func(dl *models.WebhookDeadLetter) bool {
+		return dl.Attempts == 1 && dl.LastError == "endpoint is no longer configured"
+	})).Return(nil).Once()
+	mockWebhookRepo.On("UpdateDelivery", mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+		return d.ID == 5 && d.Attempts == 1 && d.LastError == "signing secret not set: environment variable 'TEST_WEBHOOK_MISSING_SECRET' is empty"
+	})).Return(nil).Once()
+
+	delivered, err := service.DeliverDue(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Len(t, receiver.requests, 3, "nothing is sent to removed or unsigned endpoints")
+	mockWebhookRepo.AssertExpectations(t)
+}
+
+func TestWebhookService_ReplayDeadLetter(t *testing.T) {
+	useWebhookConfig(t, config.WebhookEndpoint{Name: "down", URL: "http://127.0.0.1:1/down", SecretEnv: "TEST_WEBHOOK_SECRET"})
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	mockWebhookRepo := new(MockWebhookRepository)
+	service := NewWebhookService(mockWebhookRepo)
+
+	deadLetter := &models.WebhookDeadLetter{ID: 7, EventID: "e3", EventType: models.EventTaskCompleted, Endpoint: "down", Payload: `{"id":"e3"}`, Attempts: 3, LastError: "timeout"}
+	mockWebhookRepo.On("GetDeadLetterByID", uint(7)).Return(deadLetter, nil).Once()
+	mockWebhookRepo.On("ReplayDeadLetter", deadLetter, mock.MatchedBy(func(d *models.WebhookDelivery) bool {
+		return d.EventID == "e3" && d.Endpoint == "down" && d.Payload == `{"id":"e3"}` && d.Attempts == 0 && d.NextAttemptAt.Equal(now)
+	})).Return(nil).Once()
+	delivery, err := service.ReplayDeadLetter(7, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "e3", delivery.EventID)
+
+	mockWebhookRepo.On("GetDeadLetterByID", uint(8)).Return(nil, nil).Once()
+	_, err = service.ReplayDeadLetter(8, now)
+	assert.EqualError(t, err, "dead letter 8 not found")
+
+	mockWebhookRepo.On("GetDeadLetterByID", uint(9)).Return(&models.WebhookDeadLetter{ID: 9, Endpoint: "removed"}, nil).Once()
+	_, err = service.ReplayDeadLetter(9, now)
+	assert.EqualError(t, err, "cannot replay dead letter 9: endpoint 'removed' is no longer configured")
+	mockWebhookRepo.AssertExpectations(t)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchTerms splits text into search terms: lowercase words of letters and digits, and runs of CJK characters,
+// which are not separated by spaces. Punctuation and spaces separate terms.
+func SearchTerms(text string) []string {
+	var terms []string
+	var current []rune
+	currentCJK := false
+	flush := func() {
+		if len(current) > 0 {
+			terms = append(terms, string(current))
+			current = current[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case IsCJK(r):
+			if !currentCJK {
+				flush()
+			}
+			currentCJK = true
+			current = append(current, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if currentCJK {
+				flush()
+			}
+			currentCJK = false
+			current = append(current, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// IsCJK reports whether r is a Chinese, Japanese or Korean character.
+func IsCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Bigrams returns the overlapping pairs of characters of a CJK term, e.g. 盆底肌 gives 盆底 and 底肌, which stand in
+// for the words a CJK tokenizer would find. Other terms, and single characters, are returned as they are.
+func Bigrams(term string) []string {
+	runes := []rune(term)
+	if len(runes) < 2 || !IsCJK(runes[0]) {
+		return []string{term}
+	}
+	bigrams := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		bigrams = append(bigrams, string(runes[i:i+2]))
+	}
+	return bigrams
+}
+
+// SearchTokens tokenizes text for indexing: words as they are, and for CJK terms their bigrams followed by the
+// last character, so that every character starts a token and a single-character search can match by prefix.
+func SearchTokens(text string) []string {
+	var tokens []string
+	for _, term := range SearchTerms(text) {
+		tokens = append(tokens, Bigrams(term)...)
+		if runes := []rune(term); len(runes) > 1 && IsCJK(runes[0]) {
+			tokens = append(tokens, string(runes[len(runes)-1]))
+		}
+	}
+	return tokens
+}
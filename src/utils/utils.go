@@ -60,9 +60,10 @@ func FormatTime(t time.Time) string {
 	return t.Format("2006-01-02 15:04:05")
 }
 
-// ParseCronExpression 解析Cron表达式
+// ParseCronExpression 校验Cron表达式是否合法，解析规则见 ParseCron
 func ParseCronExpression(expr string) (bool, error) {
-	// 这里可以添加Cron表达式解析逻辑
-	// 简单实现，实际应使用cron库
+	if _, err := ParseCron(expr); err != nil {
+		return false, err
+	}
 	return true, nil
 }